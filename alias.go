@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	aliasBashFile       = "aliases.sh"
+	aliasFishFile       = "aliases.fish"
+	aliasPowerShellFile = "aliases.ps1"
+)
+
+// aliasNamePattern 别名只允许字母、数字、下划线和连字符，且不能以数字或连字符开头
+var aliasNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// ValidateCommandAlias 校验命令别名的格式。别名是否被其他命令占用在保存指令的写事务中检查，
+// 避免并发保存时写入重复的别名
+func ValidateCommandAlias(cmd *Command) error {
+	if cmd.Alias != "" && !aliasNamePattern.MatchString(cmd.Alias) {
+		return NewValidationError("alias", "alias.invalid", cmd.Alias)
+	}
+	return nil
+}
+
+// GenerateShellAliases 根据所有设置了别名的命令重新生成bash/zsh、fish和PowerShell别名文件
+func GenerateShellAliases(ctx context.Context) error {
+	commands, err := GetAliasCommandsSQLite(ctx)
+	if err != nil {
+		return err
+	}
+	commands = withoutLeakedSecrets(commands)
+	dir, err := quickcmdConfigDir()
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		aliasBashFile:       renderBashAliases(commands),
+		aliasFishFile:       renderFishAliases(commands),
+		aliasPowerShellFile: renderPowerShellAliases(commands),
+	}
+	for name, content := range files {
+		if err = writeFileAtomic(filepath.Join(dir, name), []byte(content)); err != nil {
+			return fmt.Errorf("写入别名文件%s失败: %w", name, err)
+		}
+	}
+	slog.Info("已生成命令别名", "count", len(commands), "dir", dir)
+	return nil
+}
+
+// withoutLeakedSecrets 别名文件是明文，内容中有疑似密钥的指令不写入；
+// 引用了密钥库的指令需要解锁后才能展开，写成别名也无法使用，同样跳过
+func withoutLeakedSecrets(commands []*Command) []*Command {
+	result := commands[:0:0]
+	for _, cmd := range commands {
+		if refs := ParseSecretRefs(cmd.Content); len(refs) > 0 {
+			slog.Warn("指令引用了密钥库中的密钥，不生成别名", "name", cmd.Name, "secrets", refs)
+			continue
+		}
+		if findings := ScanSecrets(cmd.Content); len(findings) > 0 {
+			slog.Warn("指令包含疑似密钥，不生成别名", "name", cmd.Name, "finding", findings[0].Description, "findingLine", findings[0].Line, "findingColumn", findings[0].Column)
+			continue
+		}
+		result = append(result, cmd)
+	}
+	return result
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免shell读取到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeAliasHeader 写入别名文件开头的说明，使用当前语言
+func writeAliasHeader(b *strings.Builder, profile, line string) {
+	fmt.Fprintf(b, "# %s\n", localize("export.generated"))
+	fmt.Fprintf(b, "# %s\n\n", localize("export.install_hint", profile, line))
+}
+
+// renderBashAliases 生成bash/zsh别名文件，带模板变量的命令生成为函数，变量按出现顺序对应位置参数
+func renderBashAliases(commands []*Command) string {
+	var b strings.Builder
+	writeAliasHeader(&b, "~/.bashrc / ~/.zshrc", "source ~/.config/quickcmd/aliases.sh")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "# %s\n", commentLine(cmd.Name))
+		vars := ParseTemplateVars(cmd.Content)
+		if len(vars) == 0 {
+			fmt.Fprintf(&b, "alias %s=%s\n\n", cmd.Alias, posixSingleQuote(cmd.Content))
+			continue
+		}
+		body := substituteTemplateVars(cmd.Content, vars, '\\', func(i int, _ string, quote byte) string {
+			return posixArgRef(fmt.Sprintf("${%d}", i), quote)
+		})
+		fmt.Fprintf(&b, "%s() {\n%s\n}\n\n", cmd.Alias, body)
+	}
+	return b.String()
+}
+
+// renderFishAliases 生成fish函数文件，模板变量对应 $argv[n]
+func renderFishAliases(commands []*Command) string {
+	var b strings.Builder
+	writeAliasHeader(&b, "~/.config/fish/config.fish", "source ~/.config/quickcmd/aliases.fish")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "# %s\n", commentLine(cmd.Name))
+		vars := ParseTemplateVars(cmd.Content)
+		body := cmd.Content + " $argv"
+		if len(vars) > 0 {
+			body = substituteTemplateVars(cmd.Content, vars, '\\', func(i int, _ string, quote byte) string {
+				return posixArgRef(fmt.Sprintf("$argv[%d]", i), quote)
+			})
+		}
+		fmt.Fprintf(&b, "function %s\n%s\nend\n\n", cmd.Alias, body)
+	}
+	return b.String()
+}
+
+// renderPowerShellAliases 生成PowerShell配置片段，模板变量对应 $args[n-1]。
+// 参数先按所在的引号上下文转义再拼入指令，Invoke-Expression不会把参数当作代码执行
+func renderPowerShellAliases(commands []*Command) string {
+	var b strings.Builder
+	writeAliasHeader(&b, "$PROFILE", `. "$HOME\.config\quickcmd\aliases.ps1"`)
+	b.WriteString(powerShellQuoteFunc)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "# %s\n", commentLine(cmd.Name))
+		vars := ParseTemplateVars(cmd.Content)
+		fmt.Fprintf(&b, "function %s {\n", cmd.Alias)
+		if len(vars) == 0 {
+			fmt.Fprintf(&b, "    $quickcmd = %s\n", powerShellSingleQuote(cmd.Content))
+			b.WriteString("    if ($args.Count -gt 0) { $quickcmd += ' ' + (($args | ForEach-Object { __quickcmd_quote $_ 'bare' }) -join ' ') }\n")
+		} else {
+			// 模板变量替换为 {{序号:引号上下文}}，执行时一次性替换，参数中的内容不会被再次替换
+			content := substituteTemplateVars(cmd.Content, vars, '`', func(i int, _ string, quote byte) string {
+				return fmt.Sprintf("{{%d:%s}}", i, quoteContextName(quote))
+			})
+			b.WriteString("    $quickcmdArgs = $args\n")
+			fmt.Fprintf(&b, "    $quickcmd = [regex]::Replace(%s, '\\{\\{(\\d+):(\\w+)\\}\\}', { param($m) __quickcmd_quote $quickcmdArgs[[int]$m.Groups[1].Value - 1] $m.Groups[2].Value })\n", powerShellSingleQuote(content))
+		}
+		b.WriteString("    Invoke-Expression $quickcmd\n}\n\n")
+	}
+	return b.String()
+}
+
+// powerShellQuoteFunc 别名文件中转义参数的函数：单引号内把单引号写两次，双引号内用反引号转义反引号、$和双引号，
+// 不在引号内时包裹为单引号字符串。PowerShell把弯引号也当作引号，一并转义
+const powerShellQuoteFunc = `function __quickcmd_quote([string]$value, [string]$quote) {
+    switch ($quote) {
+        'single' { return $value -replace "['\u2018-\u201B]", '$0$0' }
+        'double' { return $value -replace '[` + "`" + `$"\u201C-\u201E]', '` + "`" + `$0' }
+        default { return "'" + ($value -replace "['\u2018-\u201B]", '$0$0') + "'" }
+    }
+}
+
+`
+
+// substituteTemplateVars 将模板变量替换为ref返回的参数引用，ref的参数为变量的序号（从1开始）、
+// 变量名和变量所在的引号（不在引号内时为0）。escape为shell的转义字符，单引号内不处理转义
+func substituteTemplateVars(content string, vars []string, escape byte, ref func(index int, name string, quote byte) string) string {
+	positions := make(map[string]int, len(vars))
+	for i, v := range vars {
+		positions[v] = i + 1
+	}
+	return substituteQuoted(content, templateVarPattern, escape, func(m []string, quote byte) string {
+		return ref(positions[m[1]], m[1], quote)
+	})
+}
+
+// posixArgRef 返回POSIX shell中引用参数ref（如 ${1}）的写法，保证参数值不会被再次分词或展开
+func posixArgRef(ref string, quote byte) string {
+	switch quote {
+	case '\'':
+		return `'"` + ref + `"'`
+	case '"':
+		return ref
+	default:
+		return `"` + ref + `"`
+	}
+}
+
+// quoteContextName 引号上下文的名称，对应 __quickcmd_quote 的第二个参数
+func quoteContextName(quote byte) string {
+	switch quote {
+	case '\'':
+		return "single"
+	case '"':
+		return "double"
+	default:
+		return "bare"
+	}
+}
+
+// substituteQuoted 替换content中匹配pattern的占位符，ref根据子匹配和占位符所在的引号返回替换后的文本。
+// escape为shell的转义字符，被转义的引号不会开始或结束引号上下文
+func substituteQuoted(content string, pattern *regexp.Regexp, escape byte, ref func(m []string, quote byte) string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(content); {
+		if strings.HasPrefix(content[i:], "{{") {
+			if loc := pattern.FindStringSubmatchIndex(content[i:]); loc != nil && loc[0] == 0 {
+				m := make([]string, len(loc)/2)
+				for j := range m {
+					if loc[2*j] >= 0 {
+						m[j] = content[i+loc[2*j] : i+loc[2*j+1]]
+					}
+				}
+				b.WriteString(ref(m, quote))
+				i += loc[1]
+				continue
+			}
+		}
+		c := content[i]
+		switch {
+		case c == escape && quote != '\'' && i+1 < len(content):
+			b.WriteByte(c)
+			b.WriteByte(content[i+1])
+			i += 2
+			continue
+		case (c == '\'' || c == '"') && quote == 0:
+			quote = c
+		case c == quote:
+			quote = 0
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// posixSingleQuote 用单引号包裹字符串，并转义内部的单引号
+func posixSingleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// powerShellSingleQuote 用单引号包裹字符串，内部的单引号写两次进行转义
+func powerShellSingleQuote(s string) string {
+	return "'" + powerShellSingleQuoteEscaper.Replace(s) + "'"
+}
+
+// powerShellSingleQuoteEscaper 转义单引号字符串中的单引号，PowerShell把弯单引号也当作单引号
+var powerShellSingleQuoteEscaper = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201A", "\u201A\u201A", "\u201B", "\u201B\u201B")
+
+// powerShellDoubleQuoteEscaper 转义双引号字符串中会被展开或结束字符串的字符
+var powerShellDoubleQuoteEscaper = strings.NewReplacer("`", "``", "$", "`$", `"`, "`\"", "\u201C", "`\u201C", "\u201D", "`\u201D", "\u201E", "`\u201E")
+
+// powerShellQuote 按值所在的引号上下文转义，规则与别名文件中的 __quickcmd_quote 相同
+func powerShellQuote(value string, quote byte) string {
+	switch quote {
+	case '\'':
+		return powerShellSingleQuoteEscaper.Replace(value)
+	case '"':
+		return powerShellDoubleQuoteEscaper.Replace(value)
+	default:
+		return powerShellSingleQuote(value)
+	}
+}
+
+// commentLine 将命令名称压缩为单行，避免换行破坏注释
+func commentLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 )
 
+// OS的规范写法，写入数据库前都会转换为这些值，AllOs表示适用于所有OS
 const (
 	Windows string = "windows"
 	Mac     string = "mac"
@@ -15,164 +19,274 @@ const (
 
 // App struct
 type App struct {
-	ctx         context.Context
-	commands    []*Command    // 模拟指令数据库
-	tags        []*Tag        // 模拟标签数据库
-	collections []*Collection // 模拟集合数据库
+	ctx        context.Context
+	hookServer *HookServer // 接收shell钩子上报的命令
+
+	mu            sync.RWMutex
+	opCtx         context.Context                 // 后端操作使用的context，应用关闭时取消
+	cancelOps     context.CancelFunc              // 取消所有进行中的操作
+	cancelSearch  map[string]context.CancelFunc   // 按GetOptions的查询类型取消上一次还没有完成的查询
+	osFilter      []string                        // 前端当前的OS筛选条件，用于选择指令变体
+	searchHits    map[uint64]bool                 // 当前搜索结果中的指令，用户复制或执行其中一条时记为一次搜索命中
+	confirmations map[string]*pendingConfirmation // 高风险指令已签发的确认令牌
+	vault         *secretVault                    // 解锁后的密钥库
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{
-		commands:    make([]*Command, 0, 64),
-		tags:        make([]*Tag, 0, 16),
-		collections: make([]*Collection, 0, 16),
-	}
+	a := &App{}
+	a.opCtx, a.cancelOps = context.WithCancel(withAuditSource(context.Background(), AuditSourceGUI))
+	a.vault = a.newSecretVault()
+	return a
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.mu.Lock()
+	a.cancelOps()
+	a.opCtx, a.cancelOps = context.WithCancel(withAuditSource(ctx, AuditSourceGUI))
+	opCtx := a.opCtx
+	a.mu.Unlock()
+
+	// 规则可能在上次运行后被修改过，按当前规则重新计算风险等级
+	refreshCommandRisk(opCtx)
+	hookServer, err := StartHookServer(opCtx, a.containsSecret)
+	if err != nil {
+		slog.Error("启动shell钩子监听失败", "err", err)
+		return
+	}
+	a.hookServer = hookServer
+}
+
+// shutdown is called when the app is about to quit
+func (a *App) shutdown(ctx context.Context) {
+	// 先取消进行中的查询和写入，进行中的事务会回滚
+	a.mu.Lock()
+	a.cancelOps()
+	a.mu.Unlock()
+
+	a.vault.lock()
+	if a.hookServer != nil {
+		if err := a.hookServer.Close(); err != nil {
+			slog.Warn("关闭shell钩子监听失败", "err", err)
+		}
+	}
+
+	// 等待已经开始的写事务结束后再关闭数据库
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	if DB != nil {
+		if err := DB.Close(); err != nil {
+			slog.Warn("关闭数据库失败", "err", err)
+		}
+		DB = nil
+	}
+}
+
+// opContext 返回后端操作使用的context，应用关闭时会被取消
+func (a *App) opContext() context.Context {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.opCtx
+}
+
+// startSearch 取消同一类型上一次还没有完成的列表查询，返回本次查询使用的context，查询结束后需要调用返回的cancel。
+// 前端会同时加载指令、标签和集合列表，不同类型的查询互不影响
+func (a *App) startSearch(kind string) (context.Context, context.CancelFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if cancel := a.cancelSearch[kind]; cancel != nil {
+		cancel()
+	}
+	if a.cancelSearch == nil {
+		a.cancelSearch = make(map[string]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(a.opCtx)
+	a.cancelSearch[kind] = cancel
+	return ctx, cancel
+}
+
+// setOsFilter 记录前端当前的OS筛选条件
+func (a *App) setOsFilter(osList []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.osFilter = append([]string(nil), osList...)
+}
+
+// activeOsFilter 返回前端当前的OS筛选条件，为空表示按当前系统选择变体
+func (a *App) activeOsFilter() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.osFilter
 }
 
 // GetMenuItems returns the menu items for the application
-func (a *App) GetMenuItems() map[string]interface{} {
+func (a *App) GetMenuItems() Response {
 
-	return nil
+	return dataResponse(nil)
 }
 
 type Option struct {
-	Name string     `json:"name"`
-	Os   []string   `json:"os"`
-	Type string     `json:"type"` //command,tag,collection
-	ID   uint64     `json:"id"`
-	Sort SortOption `json:"sort"`
+	Name   string     `json:"name"`
+	Os     []string   `json:"os"`
+	Type   string     `json:"type"` //command,tag,collection
+	ID     uint64     `json:"id"`
+	Sort   SortOption `json:"sort"`
+	Limit  int        `json:"limit"`  // 每页条数，0表示不分页
+	Cursor string     `json:"cursor"` // 上一页返回的nextCursor，为空时从第一页开始
 }
 
 type SortOption struct {
 	Name       *string `json:"name"`
 	CreateTime *string `json:"create_time"`
 	CopyCounts *string `json:"copy_counts"`
-	// SortValue  *string `json:"sort_value"`
+	SortValue  *string `json:"sort_value"` // 手动排序值
+	Frecency   *string `json:"frecency"`   // 使用频率和时效评分，只对指令有效
+
+	relevance string // 搜索时按frecency和全文相关度综合排序，值为FTS的MATCH表达式
 }
 
+// isEmpty 是否没有指定任何排序条件
+func (s SortOption) isEmpty() bool {
+	return s.Name == nil && s.CreateTime == nil && s.CopyCounts == nil && s.SortValue == nil && s.Frecency == nil
+}
+
+// GetOptions 按类型查询指令、标签或集合列表，搜索语法错误时Data中返回出错位置
 func (a *App) GetOptions(option Option) (response Response) {
-	log.Printf("GetOptions: %+v\n", option)
+	slog.Debug("GetOptions", "type", option.Type, "query", option.Name, "os", option.Os, "cursor", option.Cursor)
+	// 输入搜索词时前端会连续调用，新的查询开始后同一类型旧的查询结果已经没有用处
+	ctx, cancel := a.startSearch(option.Type)
+	defer cancel()
+	var data AllCommands
+	var err error
 	switch option.Type {
 	case "commands", "all":
-		log.Printf("GetCommandsOptions")
-		response.Data = getCommandsOptions(option)
+		option.Os = canonicalOSFilter(option.Os)
+		a.setOsFilter(option.Os)
+		if data, err = getCommandsOptions(ctx, option); err != nil {
+			return errorResponse(err)
+		}
+		for _, cmd := range data.Commands {
+			applyCommandVariant(cmd, option.Os, currentShell())
+		}
+		a.setSearchResults(option, data.Commands)
 	case "tags":
-		log.Printf("GetTagsOptions")
-		response.Data = getTagsOptions(option)
+		data, err = getTagsOptions(ctx, option)
 	case "collections":
-		log.Printf("GetCollectionsOptions")
-		response.Data = getCollectionsOptions(option)
+		data, err = getCollectionsOptions(ctx, option)
+	default:
+		err = NewValidationError("type", "option.invalid_type", option.Type)
+	}
+	if err != nil {
+		return errorResponse(err)
 	}
-	log.Printf("response: %+v\n", response)
-	// fmt.Printf("response.data.tags: %+v\n", response.Data.(AllCommands).Tags)
-	// fmt.Printf("response.data.collections: %+v\n", response.Data.(AllCommands).Collections)
-	// fmt.Printf("response.data.commands: %+v\n", response.Data.(AllCommands).Commands)
+	response.Data = data
 	return response
 }
 
-func getTagsOptions(option Option) AllCommands {
-	// 这里应该根据option参数查询数据库获取标签列表
-	// 目前返回空列表，后续需要实现具体逻辑
+// setSearchResults 记录当前搜索词对应的结果，翻页时追加；没有搜索词时清空。
+// 只记录不写库，用户真正选用其中的指令时才算搜索命中
+func (a *App) setSearchResults(option Option, commands []*Command) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if option.Name == "" {
+		a.searchHits = nil
+		return
+	}
+	if option.Cursor == "" || a.searchHits == nil {
+		a.searchHits = make(map[uint64]bool, len(commands))
+	}
+	for _, cmd := range commands {
+		a.searchHits[cmd.ID] = true
+	}
+}
 
-	tags, err := GetTagsSQLite(option)
+// recordSearchHit 用户复制或执行了当前搜索结果中的指令时记录一次搜索命中，同一次搜索只记录一次
+func (a *App) recordSearchHit(ctx context.Context, id uint64) {
+	a.mu.Lock()
+	hit := a.searchHits[id]
+	if hit {
+		a.searchHits = nil
+	}
+	a.mu.Unlock()
+	if !hit {
+		return
+	}
+	if err := RecordCommandUsageSQLite(ctx, id, usageSearch, time.Now()); err != nil {
+		slog.Warn("记录搜索命中失败", "id", id, "err", err)
+	}
+}
+
+func getTagsOptions(ctx context.Context, option Option) (AllCommands, error) {
+	tags, page, err := GetTagsSQLite(ctx, option)
 	if err != nil {
-		log.Printf("GetTagsSQLite failed: %v", err)
-		return AllCommands{
-			Tags:        []*Tag{},
-			Collections: []*Collection{},
-			Commands:    []*Command{},
-		}
+		return AllCommands{}, fmt.Errorf("获取标签列表失败: %w", err)
 	}
-	// commands := make([]*Command, 0, 64)
 	tagIDs := make([]uint64, 0, len(tags))
 	for _, tag := range tags {
 		tagIDs = append(tagIDs, tag.ID)
 	}
-	commands, err := GetCommandsByTagIDs(tagIDs)
+	commands, err := GetCommandsByTagIDs(ctx, tagIDs, option.Sort)
 	if err != nil {
-		log.Printf("GetCommandsByTagIDs failed: %v", err)
-		return AllCommands{
-			Tags:        tags,
-			Collections: []*Collection{},
-			Commands:    commands,
-		}
+		return AllCommands{}, fmt.Errorf("获取标签下的指令失败: %w", err)
 	}
 	return AllCommands{
 		Tags:        tags,
 		Collections: []*Collection{},
 		Commands:    commands,
-	}
+		Page:        page,
+	}, nil
 }
 
-func getCollectionsOptions(option Option) AllCommands {
-	// 这里应该根据option参数查询数据库获取集合列表
-	// 目前返回空列表，后续需要实现具体逻辑
-	collections, err := GetCollectionsSQLite(option)
+func getCollectionsOptions(ctx context.Context, option Option) (AllCommands, error) {
+	collections, page, err := GetCollectionsSQLite(ctx, option)
 	if err != nil {
-		log.Printf("GetCollectionsSQLite failed: %v", err)
-		return AllCommands{
-			Tags:        []*Tag{},
-			Collections: []*Collection{},
-			Commands:    []*Command{},
-		}
+		return AllCommands{}, fmt.Errorf("获取集合列表失败: %w", err)
 	}
 	collectionIDs := make([]uint64, 0, len(collections))
 	for _, collection := range collections {
 		collectionIDs = append(collectionIDs, collection.ID)
 	}
-	commands, err := GetCommandByCollectionIds(collectionIDs)
+	commands, err := GetCommandByCollectionIds(ctx, collectionIDs, option.Sort)
 	if err != nil {
-		log.Printf("GetCommandByCollectionIds failed: %v", err)
-		return AllCommands{
-			Tags:        []*Tag{},
-			Collections: collections,
-			Commands:    []*Command{},
-		}
+		return AllCommands{}, fmt.Errorf("获取集合中的指令失败: %w", err)
 	}
 	return AllCommands{
 		Tags:        []*Tag{},
 		Collections: collections,
 		Commands:    commands,
-	}
+		Page:        page,
+	}, nil
 }
 
-func getCommandsOptions(option Option) AllCommands {
-	// 这里应该根据option参数查询数据库获取命令列表
-	// 目前返回空列表，后续需要实现具体逻辑
-	commands, err := GetCommandsSQLite(option)
+func getCommandsOptions(ctx context.Context, option Option) (AllCommands, error) {
+	commands, page, err := GetCommandsSQLite(ctx, option)
 	if err != nil {
-		log.Printf("GetCommandsSQLite failed: %v", err)
-		return AllCommands{
-			Tags:        []*Tag{},
-			Collections: []*Collection{},
-			Commands:    []*Command{},
-		}
+		return AllCommands{}, fmt.Errorf("获取指令列表失败: %w", err)
 	}
-	log.Printf("GetCommandsSQLite success: %v", commands)
 	return AllCommands{
 		Tags:        []*Tag{},
 		Collections: []*Collection{},
 		Commands:    commands,
-	}
+		Page:        page,
+	}, nil
 }
 
 type AllCommands struct {
 	Tags        []*Tag        `json:"tags"`
 	Collections []*Collection `json:"collections"`
 	Commands    []*Command    `json:"options"`
+	Page                      // 主列表（指令、标签或集合）的分页信息
 }
 
-func (a *App) GetAllTagsIDAndName() ([]Tag, error) {
-	return GetTagIDAndNameSQLite()
+func (a *App) GetAllTagsIDAndName() Response {
+	ctx := a.opContext()
+	return newResponse(GetTagIDAndNameSQLite(ctx))
 }
 
-func (a *App) GetAllCollectionsIDAndName() ([]Collection, error) {
-	return GetCollectionIDAndNameSQLite()
+func (a *App) GetAllCollectionsIDAndName() Response {
+	ctx := a.opContext()
+	return newResponse(GetCollectionIDAndNameSQLite(ctx))
 }
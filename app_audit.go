@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 审计日志导出格式
+const (
+	auditExportJSONL = "jsonl"
+	auditExportCSV   = "csv"
+)
+
+// AuditExport 导出的审计日志文件，由前端保存
+type AuditExport struct {
+	FileName string `json:"fileName"`
+	Content  string `json:"content"`
+}
+
+// GetAuditLog 按条件分页查询审计日志
+func (a *App) GetAuditLog(query AuditQuery) Response {
+	ctx := a.opContext()
+	entries, page, err := GetAuditLogSQLite(ctx, query)
+	if err != nil {
+		return errorResponse(fmt.Errorf("查询审计日志失败: %w", err))
+	}
+	return dataResponse(&AuditLog{Entries: entries, Page: page})
+}
+
+// ExportAuditLog 导出符合条件的全部审计日志，format为jsonl（默认，每行一条记录）或csv
+func (a *App) ExportAuditLog(query AuditQuery, format string) Response {
+	ctx := a.opContext()
+	if format == "" {
+		format = auditExportJSONL
+	}
+	if format != auditExportJSONL && format != auditExportCSV {
+		return errorResponse(NewValidationError("format", "audit.invalid_format", format))
+	}
+	query.Limit, query.Cursor = 0, ""
+	entries, _, err := GetAuditLogSQLite(ctx, query)
+	if err != nil {
+		return errorResponse(fmt.Errorf("导出审计日志失败: %w", err))
+	}
+	// 导出文件会离开应用，差异中的疑似密钥在导出前替换掉
+	for _, e := range entries {
+		if e.Diff, err = redactAuditDiff(e.Diff); err != nil {
+			return errorResponse(fmt.Errorf("导出审计日志失败: %w", err))
+		}
+	}
+	content, err := encodeAuditLog(entries, format)
+	if err != nil {
+		return errorResponse(fmt.Errorf("导出审计日志失败: %w", err))
+	}
+	return dataResponse(&AuditExport{
+		FileName: fmt.Sprintf("quickcmd-audit-%s.%s", time.Now().Format("20060102-150405"), format),
+		Content:  content,
+	})
+}
+
+// encodeAuditLog 将审计日志编码为导出格式
+func encodeAuditLog(entries []*AuditEntry, format string) (string, error) {
+	var buf bytes.Buffer
+	if format == auditExportJSONL {
+		enc := json.NewEncoder(&buf)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return "", fmt.Errorf("编码审计日志失败: %w", err)
+			}
+		}
+		return buf.String(), nil
+	}
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"id", "createdAt", "entityType", "entityId", "action", "source", "diff"}); err != nil {
+		return "", fmt.Errorf("编码审计日志失败: %w", err)
+	}
+	for _, e := range entries {
+		record := []string{
+			strconv.FormatUint(e.ID, 10), e.CreatedAt, e.EntityType, strconv.FormatUint(e.EntityID, 10), e.Action, e.Source, string(e.Diff),
+		}
+		if err := w.Write(record); err != nil {
+			return "", fmt.Errorf("编码审计日志失败: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("编码审计日志失败: %w", err)
+	}
+	return buf.String(), nil
+}
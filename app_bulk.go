@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// BulkCommandRequest 对多条指令执行的批量操作
+type BulkCommandRequest struct {
+	// Action 操作类型：add_tags、remove_tags、add_collections、remove_collections、set_os、delete、restore
+	Action        string   `json:"action"`
+	CommandIDs    []uint64 `json:"commandIds"`
+	TagIDs        []uint64 `json:"tagIds,omitempty"`        // add_tags、remove_tags使用
+	CollectionIDs []uint64 `json:"collectionIds,omitempty"` // add_collections、remove_collections使用
+	Os            []string `json:"os,omitempty"`            // set_os使用，为空表示清除OS
+}
+
+// BulkItemResult 批量操作中单条指令的结果
+type BulkItemResult struct {
+	CommandID uint64 `json:"commandId"`
+	OK        bool   `json:"ok"`
+	ErrorKey  string `json:"errorKey,omitempty"` // 跳过原因在消息目录中的键
+	Error     string `json:"error,omitempty"`    // 按当前语言显示的跳过原因
+
+	reason *AppError
+}
+
+// BulkResult 批量操作的结果
+type BulkResult struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Items     []BulkItemResult `json:"items"`
+
+	aliasChanged bool // 删除或恢复了带别名的指令，需要重新生成别名文件
+}
+
+// BulkUpdateCommands 在一个事务中对多条指令执行同一种操作，返回每条指令的结果
+func (a *App) BulkUpdateCommands(req *BulkCommandRequest) Response {
+	if req == nil {
+		return errorResponse(NewValidationError("", "bulk.request_required"))
+	}
+	ctx := a.opContext()
+	slog.Debug("BulkUpdateCommands", "action", req.Action, "ids", req.CommandIDs)
+	result, err := BulkUpdateCommandsSQLite(ctx, req)
+	if err != nil {
+		return errorResponse(fmt.Errorf("批量操作失败: %w", err))
+	}
+	if result.aliasChanged {
+		a.regenerateShellAliases()
+	}
+	for i := range result.Items {
+		if reason := result.Items[i].reason; reason != nil {
+			result.Items[i].Error = reason.Error()
+		}
+	}
+	return dataResponse(result)
+}
+
+// BulkAddTags 为多条指令添加标签
+func (a *App) BulkAddTags(commandIDs []uint64, tagIDs []uint64) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkAddTags, CommandIDs: commandIDs, TagIDs: tagIDs})
+}
+
+// BulkRemoveTags 从多条指令移除标签
+func (a *App) BulkRemoveTags(commandIDs []uint64, tagIDs []uint64) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkRemoveTags, CommandIDs: commandIDs, TagIDs: tagIDs})
+}
+
+// BulkAddCollections 将多条指令加入集合，依次排在集合末尾
+func (a *App) BulkAddCollections(commandIDs []uint64, collectionIDs []uint64) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkAddCollections, CommandIDs: commandIDs, CollectionIDs: collectionIDs})
+}
+
+// BulkRemoveCollections 将多条指令移出集合
+func (a *App) BulkRemoveCollections(commandIDs []uint64, collectionIDs []uint64) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkRemoveCollections, CommandIDs: commandIDs, CollectionIDs: collectionIDs})
+}
+
+// BulkSetOs 将多条指令的OS设置为os
+func (a *App) BulkSetOs(commandIDs []uint64, os []string) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkSetOs, CommandIDs: commandIDs, Os: os})
+}
+
+// BulkDeleteCommands 删除多条指令（软删除）
+func (a *App) BulkDeleteCommands(commandIDs []uint64) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkDelete, CommandIDs: commandIDs})
+}
+
+// BulkRestoreCommands 恢复多条已删除的指令
+func (a *App) BulkRestoreCommands(commandIDs []uint64) Response {
+	return a.BulkUpdateCommands(&BulkCommandRequest{Action: bulkRestore, CommandIDs: commandIDs})
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -12,49 +12,75 @@ type Collection struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description,omitempty"`
 	SearchCount int      `json:"searchCount,omitempty"`
+	Pinned      bool     `json:"pinned,omitempty"`
+	SortValue   int      `json:"sortValue"`
 	Os          []string `json:"os,omitempty"`
 	CommandIDs  []uint64 `json:"commandIds,omitempty"`
-	CreatedAt   string   `json:"createdAt,omitempty"`
-	UpdatedAt   string   `json:"updatedAt,omitempty"`
-	DeletedAt   string   `json:"deletedAt,omitempty"`
+	// Steps 按顺序排列的步骤，只在获取单个集合时填充
+	Steps     []*CollectionStep `json:"steps,omitempty"`
+	CreatedAt string            `json:"createdAt,omitempty"`
+	UpdatedAt string            `json:"updatedAt,omitempty"`
+	DeletedAt string            `json:"deletedAt,omitempty"`
 }
 
 // CreateCollection 创建集合
-func (a *App) CreateCollection(col *Collection) error {
-	log.Printf("CreateCollection: %+v\n", col)
+func (a *App) CreateCollection(col *Collection) Response {
+	ctx := a.opContext()
+	slog.Debug("CreateCollection", "name", col.Name)
 	// 简单的ID生成
 	col.ID = uint64(time.Now().UnixNano())
-	err := CreateCollectionSQLite(col)
+	err := CreateCollectionSQLite(ctx, col)
 	if err != nil {
-		log.Printf("创建集合失败: %v", err)
-		return fmt.Errorf("创建集合失败: %v", err)
+		return errorResponse(fmt.Errorf("创建集合失败: %w", err))
 	}
-	log.Printf("创建集合成功: %+v", col)
-	return nil
+	slog.Info("创建集合成功", "id", col.ID, "name", col.Name)
+	return Response{}
 }
 
-// GetCollection 获取单个集合
-func (a *App) GetCollection(id uint64) (*Collection, error) {
-
-	return nil, nil
+// GetCollection 获取单个集合及其步骤
+func (a *App) GetCollection(id uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("GetCollection", "id", id)
+	col, err := GetCollectionSQLite(ctx, id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取集合失败: %w", err))
+	}
+	if col.Steps, err = GetCollectionStepsSQLite(ctx, id); err != nil {
+		return errorResponse(fmt.Errorf("获取集合失败: %w", err))
+	}
+	return dataResponse(col)
 }
 
-// GetCollections 获取所有集合
-func (a *App) GetCommandsByCollectionID(option Option) []*Command {
-	fmt.Printf("GetCommandsByCollectionID: %v\n", option)
-	return a.commands
+// GetCommandsByCollectionID 获取option.ID集合中的指令
+func (a *App) GetCommandsByCollectionID(option Option) Response {
+	ctx := a.opContext()
+	slog.Debug("GetCommandsByCollectionID", "id", option.ID)
+	commands, err := GetCommandByCollectionIds(ctx, []uint64{option.ID}, option.Sort)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取集合中的指令失败: %w", err))
+	}
+	return dataResponse(commands)
 }
 
 // UpdateCollection 更新集合
-func (a *App) UpdateCollection(col *Collection) error {
-	// 检查集合是否存在
-
-	return nil
+func (a *App) UpdateCollection(col *Collection) Response {
+	ctx := a.opContext()
+	slog.Debug("UpdateCollection", "id", col.ID, "name", col.Name)
+	if col.Name == "" {
+		return errorResponse(NewValidationError("name", "collection.name_required"))
+	}
+	if err := UpdateCollectionSQLite(ctx, col); err != nil {
+		return errorResponse(fmt.Errorf("更新集合失败: %w", err))
+	}
+	return Response{}
 }
 
 // DeleteCollection 删除集合
-func (a *App) DeleteCollection(id uint64) error {
-	// 检查集合是否存在
-
-	return nil
+func (a *App) DeleteCollection(id uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("DeleteCollection", "id", id)
+	if err := DeleteCollectionSQLite(ctx, id); err != nil {
+		return errorResponse(fmt.Errorf("删除集合失败: %w", err))
+	}
+	return Response{}
 }
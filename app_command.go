@@ -2,60 +2,252 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 )
 
 // Command 指令结构体
 type Command struct {
-	ID            uint64   `json:"id"`
-	Name          string   `json:"name"`
-	Content       string   `json:"content,omitempty"`
-	Description   string   `json:"description,omitempty"`
-	CopyCounts    int      `json:"copyCount,omitempty"`
-	SearchCount   int      `json:"searchCount,omitempty"`
-	Os            []string `json:"os,omitempty"`
-	TagIDs        []uint64 `json:"tagIDs,omitempty"`        // 标签ID列表
-	CollectionIDs []uint64 `json:"collectionIDs,omitempty"` // 集合ID列表
-	CreatedAt     string   `json:"createdAt,omitempty"`
-	UpdatedAt     string   `json:"updatedAt,omitempty"`
-	DeletedAt     string   `json:"deletedAt,omitempty"`
-}
-
-// CreateCommand 创建指令
-func (a *App) CreateCommand(cmd *Command) error {
+	ID          uint64  `json:"id"`
+	Name        string  `json:"name"`
+	Content     string  `json:"content,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Alias       string  `json:"alias,omitempty"` // shell别名，非空时生成到别名文件中
+	CopyCounts  int     `json:"copyCount,omitempty"`
+	RunCount    int     `json:"runCount,omitempty"`
+	Frecency    float64 `json:"frecency,omitempty"`   // 按使用频率和时间衰减计算的当前评分
+	LastUsedAt  string  `json:"lastUsedAt,omitempty"` // 最近一次复制、执行或被搜索命中的时间
+	SearchCount int     `json:"searchCount,omitempty"`
+	Pinned      bool    `json:"pinned,omitempty"` // 置顶的指令在列表中总是排在最前
+	Risk        string  `json:"risk,omitempty"`   // 风险等级：none、low、medium、high、critical
+	// SkipSyntaxCheck 内容不是shell命令（SQL、配置片段等），保存时不做shell语法检查；更新时为nil表示保持原值
+	SkipSyntaxCheck *bool    `json:"skipSyntaxCheck,omitempty"`
+	SortValue       int      `json:"sortValue"` // 手动排序值，越小越靠前
+	Os              []string `json:"os,omitempty"`
+	TagIDs          []uint64 `json:"tagIDs,omitempty"`        // 标签ID列表
+	CollectionIDs   []uint64 `json:"collectionIDs,omitempty"` // 集合ID列表
+	// Variants 各OS和shell下的专用内容，更新时为nil表示保持已保存的变体不变
+	Variants []*CommandVariant `json:"variants,omitempty"`
+	// Variant 读取时按当前OS筛选条件选中的变体，Content已替换为该变体的内容；为空表示使用默认内容
+	Variant *CommandVariant `json:"variant,omitempty"`
+	// RiskFindings 读取单个指令时给出命中的危险规则及说明
+	RiskFindings []*RiskFinding `json:"riskFindings,omitempty"`
+	CreatedAt    string         `json:"createdAt,omitempty"`
+	UpdatedAt    string         `json:"updatedAt,omitempty"`
+	DeletedAt    string         `json:"deletedAt,omitempty"`
+}
+
+// LogValue 将指令记录为一组字段，内容和变体内容使用content键，非debug级别时由redactLogAttr隐藏
+func (cmd Command) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Uint64("id", cmd.ID),
+		slog.String("name", cmd.Name),
+		slog.String("content", cmd.Content),
+		slog.Any("os", cmd.Os),
+	}
+	if cmd.Alias != "" {
+		attrs = append(attrs, slog.String("alias", cmd.Alias))
+	}
+	for i, v := range cmd.Variants {
+		attrs = append(attrs, slog.Group(fmt.Sprintf("variant%d", i), "os", v.Os, "shell", v.Shell, "content", v.Content))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// skipSyntaxCheck 是否跳过shell语法检查，没有设置时检查
+func (cmd *Command) skipSyntaxCheck() bool {
+	return cmd.SkipSyntaxCheck != nil && *cmd.SkipSyntaxCheck
+}
+
+// CommandSaveResult 保存指令的结果，Issues为内容的语法检查结果，Secrets为内容中的疑似密钥（只提示，不阻止保存）
+type CommandSaveResult struct {
+	Command *Command         `json:"command,omitempty"`
+	Issues  []*SyntaxIssue   `json:"issues"`
+	Secrets []*SecretFinding `json:"secrets"`
+}
+
+// CreateCommand 创建指令。内容存在语法错误时不保存，Data中返回出错的行列；只有警告时照常保存并一并返回
+func (a *App) CreateCommand(cmd *Command) (response Response) {
+	issues, err := a.createCommand(cmd)
+	return commandSaveResponse(cmd, issues, err)
+}
+
+// createCommand 校验并保存新指令，返回语法检查发现的问题
+func (a *App) createCommand(cmd *Command) ([]*SyntaxIssue, error) {
+	ctx := a.opContext()
 	// 简单的ID生成（实际应用中应该使用更可靠的ID生成方式）
-	log.Printf("创建指令请求: %v\n", cmd)
-	err := CreateCommandSQLite(cmd)
+	slog.Debug("CreateCommand", "name", cmd.Name, "content", cmd.Content)
+	if err := ValidateCommandAlias(cmd); err != nil {
+		return nil, fmt.Errorf("创建指令失败: %w", err)
+	}
+	if err := ValidateCommandOs(cmd); err != nil {
+		return nil, fmt.Errorf("创建指令失败: %w", err)
+	}
+	if err := ValidateCommandVariants(cmd.Variants); err != nil {
+		return nil, fmt.Errorf("创建指令失败: %w", err)
+	}
+	issues := CheckCommandSyntax(cmd)
+	if hasSyntaxError(issues) {
+		return issues, fmt.Errorf("创建指令失败: %w", &CommandSyntaxError{Issues: issues})
+	}
+	mergeVariantOs(cmd)
+	cmd.Risk = currentRiskAnalyzer().AnalyzeCommand(cmd).Level
+	err := CreateCommandSQLite(ctx, cmd)
+	if err != nil {
+		return issues, fmt.Errorf("创建指令失败: %w", err)
+	}
+	if cmd.Alias != "" {
+		a.regenerateShellAliases()
+	}
+	return issues, nil
+}
+
+// commandSaveResponse 将保存指令的结果转换为Response
+func commandSaveResponse(cmd *Command, issues []*SyntaxIssue, err error) (response Response) {
+	if issues == nil {
+		issues = []*SyntaxIssue{}
+	}
+	secrets := ScanCommandSecrets(cmd)
+	if secrets == nil {
+		secrets = []*SecretFinding{}
+	}
+	result := &CommandSaveResult{Issues: issues, Secrets: secrets}
+	if err != nil {
+		response = errorResponse(err)
+	} else {
+		result.Command = cmd
+	}
+	response.Data = result
+	return response
+}
+
+// GetCommand 获取单个指令，内容按当前OS筛选条件选择对应的变体
+func (a *App) GetCommand(id uint64) Response {
+	slog.Debug("GetCommand", "id", id)
+	cmd, err := a.resolveCommand(id)
 	if err != nil {
-		return fmt.Errorf("创建指令失败: %v", err)
+		return errorResponse(fmt.Errorf("获取指令失败: %w", err))
 	}
-	return nil
+	return dataResponse(cmd)
 }
 
-// GetCommand 获取单个指令
-func (a *App) GetCommand(id uint64) (*Command, error) {
-	log.Printf("GetCommand: %d\n", id)
-	cmd, err := GetCommandSQLite(id)
+// resolveCommand 读取指令及其关联数据，并按当前OS筛选条件和shell选择变体
+func (a *App) resolveCommand(id uint64) (*Command, error) {
+	ctx := a.opContext()
+	cmd, err := GetCommandSQLite(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("获取指令失败: %v", err)
+		return nil, err
 	}
+	commands := []*Command{cmd}
+	if err = FillCommandRelations(ctx, commands); err != nil {
+		return nil, err
+	}
+	applyCommandVariant(cmd, a.activeOsFilter(), currentShell())
+	cmd.RiskFindings = currentRiskAnalyzer().Analyze(cmd.Content).Findings
 	return cmd, nil
 }
 
-// UpdateCommand 更新指令
-func (a *App) UpdateCommand(cmd *Command) error {
-	// 检查指令是否存在
+// UpdateCommand 更新指令，语法检查的处理与CreateCommand相同
+func (a *App) UpdateCommand(cmd *Command) (response Response) {
+	issues, err := a.updateCommand(cmd)
+	return commandSaveResponse(cmd, issues, err)
+}
 
-	return nil
+// updateCommand 校验并保存修改后的指令，返回语法检查发现的问题
+func (a *App) updateCommand(cmd *Command) ([]*SyntaxIssue, error) {
+	ctx := a.opContext()
+	slog.Debug("UpdateCommand", "id", cmd.ID, "name", cmd.Name, "content", cmd.Content)
+	// 检查指令是否存在
+	old, err := GetCommandSQLite(ctx, cmd.ID)
+	if err != nil {
+		return nil, fmt.Errorf("更新指令失败: %w", err)
+	}
+	if err = ValidateCommandAlias(cmd); err != nil {
+		return nil, fmt.Errorf("更新指令失败: %w", err)
+	}
+	if cmd.SkipSyntaxCheck == nil {
+		cmd.SkipSyntaxCheck = old.SkipSyntaxCheck
+	}
+	if cmd.Variants == nil {
+		// 前端编辑指令时不提交变体，语法检查、风险分析和变体编辑都基于已保存的变体
+		if cmd.Variants, err = GetCommandVariantsSQLite(ctx, cmd.ID); err != nil {
+			return nil, fmt.Errorf("更新指令失败: %w", err)
+		}
+	}
+	if cmd.Variant != nil {
+		// 编辑的是选中变体的内容，写回该变体，默认内容保持不变
+		keepVariantContent(cmd, old.Content)
+	}
+	if err = ValidateCommandOs(cmd); err != nil {
+		return nil, fmt.Errorf("更新指令失败: %w", err)
+	}
+	if err = ValidateCommandVariants(cmd.Variants); err != nil {
+		return nil, fmt.Errorf("更新指令失败: %w", err)
+	}
+	issues := CheckCommandSyntax(cmd)
+	if hasSyntaxError(issues) {
+		return issues, fmt.Errorf("更新指令失败: %w", &CommandSyntaxError{Issues: issues})
+	}
+	mergeVariantOs(cmd)
+	cmd.Risk = currentRiskAnalyzer().AnalyzeCommand(cmd).Level
+	if err = UpdateCommandSQLite(ctx, cmd); err != nil {
+		return issues, fmt.Errorf("更新指令失败: %w", err)
+	}
+	if old.Alias != "" || cmd.Alias != "" {
+		a.regenerateShellAliases()
+	}
+	return issues, nil
 }
 
 // DeleteCommand 删除指令
-func (a *App) DeleteCommand(id uint64) error {
+func (a *App) DeleteCommand(id uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("DeleteCommand", "id", id)
 	// 检查指令是否存在
+	old, err := GetCommandSQLite(ctx, id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("删除指令失败: %w", err))
+	}
+	if err = DeleteCommandSQLite(ctx, id); err != nil {
+		return errorResponse(fmt.Errorf("删除指令失败: %w", err))
+	}
+	if old.Alias != "" {
+		a.regenerateShellAliases()
+	}
+	return Response{}
+}
 
-	return nil
+// keepVariantContent 将cmd.Content写回cmd.Variant对应的变体，并把Content恢复为默认内容
+func keepVariantContent(cmd *Command, baseContent string) {
+	selected := cmd.Variant
+	cmd.Variant = nil
+	for _, v := range cmd.Variants {
+		if strings.EqualFold(v.Os, selected.Os) && strings.EqualFold(v.Shell, selected.Shell) {
+			v.Content = cmd.Content
+			cmd.Content = baseContent
+			return
+		}
+	}
+	cmd.Variants = append(cmd.Variants, &CommandVariant{Os: selected.Os, Shell: selected.Shell, Content: cmd.Content})
+	cmd.Content = baseContent
+}
+
+// GenerateShellAliases 手动重新生成shell别名文件
+func (a *App) GenerateShellAliases() Response {
+	if err := GenerateShellAliases(a.opContext()); err != nil {
+		return errorResponse(fmt.Errorf("生成别名文件失败: %w", err))
+	}
+	return Response{}
+}
+
+// regenerateShellAliases 在别名相关的指令变更后重新生成别名文件，失败不影响指令本身的保存
+func (a *App) regenerateShellAliases() {
+	if err := GenerateShellAliases(a.opContext()); err != nil {
+		slog.Warn("生成别名文件失败", "err", err)
+	}
 }
 
-func (a *App) GetAllCommandsIDAndName() ([]*Command, error) {
-	return GetAllCommandsIDAndNameSQLite()
+func (a *App) GetAllCommandsIDAndName() Response {
+	ctx := a.opContext()
+	return newResponse(GetAllCommandsIDAndNameSQLite(ctx))
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// commandRunTimeout 执行指令的最长时间
+	commandRunTimeout = 5 * time.Minute
+	// cmdUnsafeChars cmd会在解析前展开变量，引号内也无法完全转义，值中包含这些字符时拒绝执行
+	cmdUnsafeChars = "\"%!^&|<>()\r\n"
+)
+
+// RunResult 执行指令的结果
+type RunResult struct {
+	Content    string `json:"content"` // 实际执行的内容（已选择变体并替换模板变量）
+	Shell      string `json:"shell"`
+	Output     string `json:"output"` // 标准输出和标准错误
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// CopyCommand 按当前OS筛选条件选择变体、替换模板变量后复制到剪贴板，返回复制的内容。
+// 高风险指令需要先调用ConfirmDangerousCommand获取确认令牌
+func (a *App) CopyCommand(id uint64, values map[string]string, confirmToken string) Response {
+	ctx := a.opContext()
+	slog.Debug("CopyCommand", "id", id)
+	cmd, err := a.resolveCommand(id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("复制指令失败: %w", err))
+	}
+	rendered, err := renderCommandContent(cmd.Content, values, a.lookupSecrets)
+	if err != nil {
+		return errorResponse(fmt.Errorf("复制指令失败: %w", err))
+	}
+	if err = a.checkRiskConfirmation(id, rendered.Redacted, confirmToken); err != nil {
+		return errorResponse(err)
+	}
+	if err = runtime.ClipboardSetText(a.ctx, rendered.Content); err != nil {
+		return errorResponse(fmt.Errorf("写入剪贴板失败: %w", err))
+	}
+	if err = RecordCommandUsageSQLite(ctx, id, usageCopy, time.Now()); err != nil {
+		slog.Warn("记录指令复制失败", "id", id, "err", err)
+	}
+	a.recordSearchHit(ctx, id)
+	return dataResponse(rendered.Redacted)
+}
+
+// RunCommand 按当前OS筛选条件选择变体、替换模板变量后用对应的shell执行，
+// 变体指定了shell时使用该shell，否则使用当前用户的shell。模板变量和密钥的值只作为数据传给shell，不会被当作代码执行。
+// 高风险指令需要先调用ConfirmDangerousCommand获取确认令牌
+func (a *App) RunCommand(id uint64, values map[string]string, confirmToken string) Response {
+	ctx := a.opContext()
+	slog.Debug("RunCommand", "id", id)
+	cmd, err := a.resolveCommand(id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("执行指令失败: %w", err))
+	}
+	rendered, err := renderCommandContent(cmd.Content, values, a.lookupSecrets)
+	if err != nil {
+		return errorResponse(fmt.Errorf("执行指令失败: %w", err))
+	}
+	if err = a.checkRiskConfirmation(id, rendered.Redacted, confirmToken); err != nil {
+		return errorResponse(err)
+	}
+
+	shell := currentShell()
+	if cmd.Variant != nil && cmd.Variant.Shell != "" {
+		shell = cmd.Variant.Shell
+	}
+	script, args, err := rendered.shellScript(shell)
+	if err != nil {
+		return errorResponse(fmt.Errorf("执行指令失败: %w", err))
+	}
+	result, err := runShellCommand(a.runContext(), shell, script, args)
+	if err != nil {
+		return errorResponse(fmt.Errorf("执行指令失败: %v", rendered.Mask(err.Error())))
+	}
+	// 返回给前端的内容和输出中不能出现密钥明文
+	result.Content = rendered.Redacted
+	result.Output = rendered.Mask(result.Output)
+	if err = RecordCommandUsageSQLite(ctx, id, usageRun, time.Now()); err != nil {
+		slog.Warn("记录指令执行失败", "id", id, "err", err)
+	}
+	a.recordSearchHit(ctx, id)
+	return dataResponse(result)
+}
+
+// runContext 返回执行指令使用的上下文，应用退出时随之取消
+func (a *App) runContext() context.Context {
+	if a.ctx != nil {
+		return a.ctx
+	}
+	return context.Background()
+}
+
+// shellScript 返回用shell执行时的脚本和位置参数。模板变量和密钥的值不直接拼进脚本：
+// sh、bash、zsh和fish中替换为位置参数的引用，值通过参数传入；PowerShell中按所在的引号上下文转义；
+// cmd无法可靠地转义，值中包含特殊字符时返回校验错误
+func (r *renderedCommand) shellScript(shell string) (string, []string, error) {
+	switch shell {
+	case "powershell", "pwsh":
+		script := substituteQuoted(r.source, templateRefPattern, '`', func(m []string, quote byte) string {
+			_, value := r.refValue(m)
+			return powerShellQuote(value, quote)
+		})
+		return script, nil, nil
+	case "cmd":
+		for _, m := range templateRefPattern.FindAllStringSubmatch(r.source, -1) {
+			if name, value := r.refValue(m); strings.ContainsAny(value, cmdUnsafeChars) {
+				return "", nil, NewValidationError("values", "template.unsafe_value", name)
+			}
+		}
+		return r.Content, nil, nil
+	default:
+		var args []string
+		positions := make(map[string]int)
+		script := substituteQuoted(r.source, templateRefPattern, '\\', func(m []string, quote byte) string {
+			name, value := r.refValue(m)
+			if _, ok := positions[name]; !ok {
+				args = append(args, value)
+				positions[name] = len(args)
+			}
+			ref := fmt.Sprintf("${%d}", positions[name])
+			if shell == "fish" {
+				ref = fmt.Sprintf("$argv[%d]", positions[name])
+			}
+			return posixArgRef(ref, quote)
+		})
+		return script, args, nil
+	}
+}
+
+// runShellCommand 执行script并收集输出，args作为位置参数传入，非零退出码不视为错误
+func runShellCommand(ctx context.Context, shell, script string, args []string) (*RunResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandRunTimeout)
+	defer cancel()
+
+	start := time.Now()
+	output, err := shellCommand(ctx, shell, script, args...).CombinedOutput()
+	result := &RunResult{
+		Content:    script,
+		Shell:      shell,
+		Output:     string(output),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
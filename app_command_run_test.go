@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellScript(t *testing.T) {
+	const secret = `p@ss $HOME; echo pwned`
+	lookup := func(names []string) (map[string]string, error) {
+		return map[string]string{"token": secret}, nil
+	}
+	content := `echo {{msg}} '{{msg}}' "{{msg}}" {{secret:token}}`
+	r, err := renderCommandContent(content, map[string]string{"msg": hostileArg}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 复制时仍然直接替换
+	if want := "echo " + hostileArg + " '" + hostileArg + `' "` + hostileArg + `" ` + secret; r.Content != want {
+		t.Errorf("Content = %q, want %q", r.Content, want)
+	}
+
+	psBare := `'x; echo pwned $(echo pwned) "''` + "`echo pwned`'"
+	psDouble := "\"x; echo pwned `$(echo pwned) `\"'``echo pwned``\""
+	tests := []struct {
+		shell  string
+		script string
+		args   []string
+	}{
+		{"sh", `echo "${1}" ''"${1}"'' "${1}" "${2}"`, []string{hostileArg, secret}},
+		{"bash", `echo "${1}" ''"${1}"'' "${1}" "${2}"`, []string{hostileArg, secret}},
+		{"fish", `echo "$argv[1]" ''"$argv[1]"'' "$argv[1]" "$argv[2]"`, []string{hostileArg, secret}},
+		{"pwsh", "echo " + psBare + " " + psBare + " " + psDouble + " 'p@ss $HOME; echo pwned'", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			script, args, err := r.shellScript(tt.shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if script != tt.script || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("shellScript(%s) = %q, %q, want %q, %q", tt.shell, script, args, tt.script, tt.args)
+			}
+			if _, err := exec.LookPath(tt.shell); err != nil {
+				return
+			}
+			result, err := runShellCommand(context.Background(), tt.shell, script, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join([]string{hostileArg, hostileArg, hostileArg, secret}, " ")
+			if got := strings.TrimSpace(result.Output); got != want || result.ExitCode != 0 {
+				t.Errorf("%s output = %q (exit %d), want %q", tt.shell, got, result.ExitCode, want)
+			}
+		})
+	}
+}
+
+func TestShellScriptCmd(t *testing.T) {
+	lookup := func(names []string) (map[string]string, error) {
+		return map[string]string{"pass": "a&b"}, nil
+	}
+	tests := []struct {
+		name    string
+		content string
+		values  map[string]string
+		want    string
+		code    ErrorCode
+	}{
+		{"plain", "ping {{host}}", map[string]string{"host": "db1"}, "ping db1", CodeOK},
+		{"command separator", "ping {{host}}", map[string]string{"host": "db1 & del /q *"}, "", CodeValidation},
+		{"variable expansion", `echo "{{msg}}"`, map[string]string{"msg": "%PATH%"}, "", CodeValidation},
+		{"secret", "net use {{secret:pass}}", nil, "", CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := renderCommandContent(tt.content, tt.values, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			script, args, err := r.shellScript("cmd")
+			if errorCode(err) != tt.code {
+				t.Fatalf("shellScript(cmd) error = %v, want code %d", err, tt.code)
+			}
+			if script != tt.want || args != nil {
+				t.Errorf("shellScript(cmd) = %q, %q, want %q", script, args, tt.want)
+			}
+		})
+	}
+}
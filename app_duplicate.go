@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// FindDuplicateCommands 查找重复和近似重复的指令，threshold为0时使用默认相似度阈值
+func (a *App) FindDuplicateCommands(threshold float64) Response {
+	ctx := a.opContext()
+	if threshold <= 0 {
+		threshold = defaultDuplicateThreshold
+	}
+	if threshold > 1 {
+		return errorResponse(NewValidationError("threshold", "duplicate.invalid_threshold"))
+	}
+	groups, err := FindDuplicateCommandsSQLite(ctx, threshold)
+	if err != nil {
+		return errorResponse(fmt.Errorf("查找重复指令失败: %w", err))
+	}
+	return dataResponse(groups)
+}
+
+// MergeCommands 将otherIDs指令合并到survivorID，被合并的指令会被软删除
+func (a *App) MergeCommands(survivorID uint64, otherIDs []uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("MergeCommands", "survivorId", survivorID, "otherIds", otherIDs)
+	if err := MergeCommandsSQLite(ctx, survivorID, otherIDs); err != nil {
+		return errorResponse(fmt.Errorf("合并指令失败: %w", err))
+	}
+	// 合并进来的变体可能带来新的风险
+	refreshCommandRisk(ctx, survivorID)
+	// 别名可能转移到了保留的指令上
+	a.regenerateShellAliases()
+	return Response{}
+}
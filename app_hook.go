@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// TypedCommandSuggestion 根据shell钩子统计出的高频命令，建议保存为指令
+type TypedCommandSuggestion struct {
+	Line    string `json:"line"`
+	Count   int    `json:"count"`
+	LastDay string `json:"lastDay"`
+	Message string `json:"message"`
+}
+
+// GetTypedCommandSuggestions 获取统计窗口内反复输入、但还没有保存为指令的命令
+func (a *App) GetTypedCommandSuggestions() (response Response) {
+	ctx := a.opContext()
+	windowDays := currentSettings().TypedCommandWindowDays
+	since := time.Now().AddDate(0, 0, -windowDays)
+	suggestions, err := GetTypedCommandSuggestionsSQLite(ctx, since, typedCommandMinCount)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取命令建议失败: %w", err))
+	}
+	for _, s := range suggestions {
+		s.Message = localize("suggestion.typed_command", windowDays, s.Count)
+	}
+	response.Data = suggestions
+	return response
+}
+
+// DismissTypedCommandSuggestion 忽略一条命令建议
+func (a *App) DismissTypedCommandSuggestion(line string) Response {
+	ctx := a.opContext()
+	if err := DismissTypedCommandSQLite(ctx, line); err != nil {
+		return errorResponse(fmt.Errorf("忽略命令建议失败: %w", err))
+	}
+	return Response{}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "log/slog"
+
+// GetLocale 获取当前语言、系统语言和可选的语言
+func (a *App) GetLocale() Response {
+	localeMu.RLock()
+	override := localeOverride
+	localeMu.RUnlock()
+	return dataResponse(&LocaleInfo{
+		Locale:    currentLocale(),
+		Override:  override,
+		System:    systemLocale(),
+		Available: availableLocales(),
+	})
+}
+
+// SetLocale 设置界面语言，为空表示跟随系统。之后生成的消息和导出的文件都使用该语言
+func (a *App) SetLocale(locale string) Response {
+	slog.Debug("SetLocale", "locale", locale)
+	settings := currentSettings()
+	settings.Locale = locale
+	if err := a.saveSettings(settings); err != nil {
+		return errorResponse(err)
+	}
+	return a.GetLocale()
+}
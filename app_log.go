@@ -0,0 +1,22 @@
+package main
+
+// RecentLogs 诊断页面显示的最近日志
+type RecentLogs struct {
+	Lines []string `json:"lines"` // 按时间顺序排列，最新的在最后
+	Level string   `json:"level"`
+	File  string   `json:"file,omitempty"` // 当前写入的日志文件，只输出到stderr时为空
+}
+
+// GetRecentLogs 获取最近的日志，limit不大于0时返回内存中保存的全部日志。
+// 日志按当前级别记录，非debug级别时指令内容已隐藏；debug级别记录的内容中的疑似密钥在返回前替换掉
+func (a *App) GetRecentLogs(limit int) Response {
+	lines := recentLogs.last(limit)
+	for i, line := range lines {
+		lines[i] = redactSecrets(line)
+	}
+	return dataResponse(&RecentLogs{
+		Lines: lines,
+		Level: currentSettings().Log.Level,
+		File:  logOutput.filePath(),
+	})
+}
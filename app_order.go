@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// PinCommand 置顶或取消置顶指令
+func (a *App) PinCommand(id uint64, pinned bool) Response {
+	return errorResponse(a.setPinned(commandListEntity, id, pinned))
+}
+
+// PinTag 置顶或取消置顶标签
+func (a *App) PinTag(id uint64, pinned bool) Response {
+	return errorResponse(a.setPinned(tagListEntity, id, pinned))
+}
+
+// PinCollection 置顶或取消置顶集合
+func (a *App) PinCollection(id uint64, pinned bool) Response {
+	return errorResponse(a.setPinned(collectionListEntity, id, pinned))
+}
+
+func (a *App) setPinned(e listEntity, id uint64, pinned bool) error {
+	ctx := a.opContext()
+	slog.Debug("SetPinned", "table", e.table, "id", id, "pinned", pinned)
+	if err := SetPinnedSQLite(ctx, e, id, pinned); err != nil {
+		return fmt.Errorf("置顶失败: %w", err)
+	}
+	return nil
+}
+
+// ReorderItems 按ids的顺序保存手动排序，itemType与Option.Type一致（commands、tags、collections）
+func (a *App) ReorderItems(itemType string, ids []uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("ReorderItems", "type", itemType, "ids", ids)
+	e, err := listEntityByType(itemType)
+	if err != nil {
+		return errorResponse(fmt.Errorf("调整顺序失败: %w", err))
+	}
+	if err = ReorderSQLite(ctx, e, ids); err != nil {
+		return errorResponse(fmt.Errorf("调整顺序失败: %w", err))
+	}
+	return Response{}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// riskConfirmTTL 确认令牌的有效期
+const riskConfirmTTL = 2 * time.Minute
+
+// RiskConfirmation 高风险指令的确认信息，复制或执行时带上Token
+type RiskConfirmation struct {
+	Token     string         `json:"token"`
+	Content   string         `json:"content"` // 将要复制或执行的内容
+	Level     string         `json:"level"`
+	Findings  []*RiskFinding `json:"findings"`
+	ExpiresAt string         `json:"expiresAt"`
+}
+
+// pendingConfirmation 已发放但尚未使用的确认令牌，只对签发时的指令和内容有效
+type pendingConfirmation struct {
+	commandID uint64
+	digest    string
+	expiresAt time.Time
+}
+
+// RiskConfirmationError 高风险指令未确认时返回的错误
+type RiskConfirmationError struct {
+	CommandID uint64
+	Report    *RiskReport
+}
+
+func (e *RiskConfirmationError) Error() string {
+	reasons := make([]string, 0, len(e.Report.Findings))
+	for _, f := range e.Report.Findings {
+		reasons = append(reasons, f.Explanation)
+	}
+	return localize("risk.confirm_required", e.CommandID, e.Report.Level, strings.Join(reasons, localize("list.separator")))
+}
+
+// AnalyzeCommandRisk 分析指令内容的风险，用于编辑时提示
+func (a *App) AnalyzeCommandRisk(content string) Response {
+	return dataResponse(currentRiskAnalyzer().Analyze(content))
+}
+
+// GetRiskRules 获取当前生效的危险指令规则，包括内置规则和配置文件中的自定义规则
+func (a *App) GetRiskRules() Response {
+	return dataResponse(currentRiskAnalyzer().Rules())
+}
+
+// ReloadRiskRules 重新读取风险规则配置文件并重新计算所有指令的风险等级，返回等级发生变化的指令数
+func (a *App) ReloadRiskRules() Response {
+	ctx := a.opContext()
+	analyzer, err := reloadRiskAnalyzer()
+	if err != nil {
+		return errorResponse(fmt.Errorf("加载风险规则失败: %w", err))
+	}
+	changed, err := RefreshCommandRisksSQLite(ctx, analyzer, nil)
+	if err != nil {
+		return errorResponse(fmt.Errorf("更新指令风险等级失败: %w", err))
+	}
+	return dataResponse(changed)
+}
+
+// ConfirmDangerousCommand 按当前OS和模板变量生成将要复制或执行的内容并分析风险，
+// 需要确认时返回一次性的确认令牌，在有效期内传给CopyCommand或RunCommand
+func (a *App) ConfirmDangerousCommand(id uint64, values map[string]string) Response {
+	cmd, err := a.resolveCommand(id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("确认指令失败: %w", err))
+	}
+	content, err := RenderTemplate(cmd.Content, values)
+	if err != nil {
+		return errorResponse(fmt.Errorf("确认指令失败: %w", err))
+	}
+	report := currentRiskAnalyzer().Analyze(content)
+	confirmation := &RiskConfirmation{Content: content, Level: report.Level, Findings: report.Findings}
+	if !riskNeedsConfirm(report.Level) {
+		return dataResponse(confirmation)
+	}
+
+	buf := make([]byte, 16)
+	if _, err = rand.Read(buf); err != nil {
+		return errorResponse(fmt.Errorf("生成确认令牌失败: %w", err))
+	}
+	expiresAt := time.Now().Add(riskConfirmTTL)
+	confirmation.Token = hex.EncodeToString(buf)
+	confirmation.ExpiresAt = expiresAt.Format("2006-01-02 15:04:05")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.confirmations == nil {
+		a.confirmations = make(map[string]*pendingConfirmation)
+	}
+	for token, p := range a.confirmations {
+		if time.Now().After(p.expiresAt) {
+			delete(a.confirmations, token)
+		}
+	}
+	a.confirmations[confirmation.Token] = &pendingConfirmation{commandID: id, digest: contentDigest(content), expiresAt: expiresAt}
+	slog.Info("高风险指令已签发确认令牌", "id", id, "risk", report.Level)
+	return dataResponse(confirmation)
+}
+
+// checkRiskConfirmation 高风险内容必须带上为同一指令和内容签发的有效令牌，令牌使用一次后失效
+func (a *App) checkRiskConfirmation(id uint64, content, token string) error {
+	report := currentRiskAnalyzer().Analyze(content)
+	if !riskNeedsConfirm(report.Level) {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.confirmations[token]
+	if token == "" || !ok {
+		return &RiskConfirmationError{CommandID: id, Report: report}
+	}
+	delete(a.confirmations, token)
+	if p.commandID != id || p.digest != contentDigest(content) || time.Now().After(p.expiresAt) {
+		return &RiskConfirmationError{CommandID: id, Report: report}
+	}
+	return nil
+}
+
+// refreshCommandRisk 按当前规则重新计算指令的风险等级，失败只记录日志
+func refreshCommandRisk(ctx context.Context, ids ...uint64) {
+	if _, err := RefreshCommandRisksSQLite(ctx, currentRiskAnalyzer(), ids); err != nil {
+		slog.Warn("更新指令风险等级失败", "err", err)
+	}
+}
+
+// contentDigest 计算内容摘要，确认令牌只对签发时的内容有效
+func contentDigest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
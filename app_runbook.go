@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// CollectionStep 集合中的一个步骤，即按顺序排列的一条指令
+type CollectionStep struct {
+	CollectionID uint64 `json:"collectionId"`
+	CommandID    uint64 `json:"commandId"`
+	Position     int    `json:"position"`           // 步骤顺序，从1开始
+	Name         string `json:"name,omitempty"`     // 指令名称，只读
+	Content      string `json:"content,omitempty"`  // 指令内容，只读
+	Note         string `json:"note,omitempty"`     // 步骤备注，例如执行前的检查项
+	Optional     bool   `json:"optional,omitempty"` // 可选步骤在运行时可以跳过
+}
+
+// RunbookRun 一次按集合步骤逐步执行的运行，可以中断后按名称继续
+type RunbookRun struct {
+	ID            uint64         `json:"id"`
+	CollectionID  uint64         `json:"collectionId"`
+	Name          string         `json:"name"`
+	Status        string         `json:"status"`        // running 或 completed
+	CurrentStep   int            `json:"currentStep"`   // 第一个未完成步骤的位置，0表示全部完成
+	TotalSteps    int            `json:"totalSteps"`    // 步骤总数
+	FinishedSteps int            `json:"finishedSteps"` // 已完成或已跳过的步骤数
+	Steps         []*RunbookStep `json:"steps,omitempty"`
+	StartedAt     string         `json:"startedAt"`
+	UpdatedAt     string         `json:"updatedAt"`
+	FinishedAt    string         `json:"finishedAt,omitempty"`
+}
+
+// RunbookStep 运行中的一个步骤，开始运行时从集合复制
+type RunbookStep struct {
+	Position    int    `json:"position"`
+	CommandID   uint64 `json:"commandId"`
+	Name        string `json:"name"`
+	Content     string `json:"content"`
+	Note        string `json:"note,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
+	Status      string `json:"status"` // pending、done 或 skipped
+	CompletedAt string `json:"completedAt,omitempty"`
+}
+
+// GetCollectionSteps 按顺序获取集合中的步骤
+func (a *App) GetCollectionSteps(collectionID uint64) Response {
+	ctx := a.opContext()
+	steps, err := GetCollectionStepsSQLite(ctx, collectionID)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取集合步骤失败: %w", err))
+	}
+	return dataResponse(steps)
+}
+
+// UpdateCollectionStep 更新步骤备注和是否可选
+func (a *App) UpdateCollectionStep(step *CollectionStep) Response {
+	ctx := a.opContext()
+	slog.Debug("UpdateCollectionStep", "collectionId", step.CollectionID, "commandId", step.CommandID)
+	if err := UpdateCollectionStepSQLite(ctx, step); err != nil {
+		return errorResponse(fmt.Errorf("更新集合步骤失败: %w", err))
+	}
+	return Response{}
+}
+
+// ReorderCollectionSteps 按给定的指令ID顺序重排集合的全部步骤
+func (a *App) ReorderCollectionSteps(collectionID uint64, commandIDs []uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("ReorderCollectionSteps", "collectionId", collectionID, "commandIds", commandIDs)
+	if err := ReorderCollectionStepsSQLite(ctx, collectionID, commandIDs); err != nil {
+		return errorResponse(fmt.Errorf("调整步骤顺序失败: %w", err))
+	}
+	return Response{}
+}
+
+// MoveCollectionStep 将一个步骤移动到指定位置（从1开始）
+func (a *App) MoveCollectionStep(collectionID uint64, commandID uint64, position int) Response {
+	ctx := a.opContext()
+	slog.Debug("MoveCollectionStep", "collectionId", collectionID, "commandId", commandID, "position", position)
+	if err := MoveCollectionStepSQLite(ctx, collectionID, commandID, position); err != nil {
+		return errorResponse(fmt.Errorf("调整步骤顺序失败: %w", err))
+	}
+	return Response{}
+}
+
+// StartRunbook 以集合当前的步骤开始一次新的运行
+func (a *App) StartRunbook(collectionID uint64, name string) Response {
+	ctx := a.opContext()
+	slog.Debug("StartRunbook", "collectionId", collectionID, "name", name)
+	run, err := StartRunbookRunSQLite(ctx, collectionID, name)
+	if err != nil {
+		return errorResponse(fmt.Errorf("开始运行失败: %w", err))
+	}
+	return dataResponse(run)
+}
+
+// ResumeRunbook 按名称找回之前中断的运行，从CurrentStep继续
+func (a *App) ResumeRunbook(collectionID uint64, name string) Response {
+	ctx := a.opContext()
+	slog.Debug("ResumeRunbook", "collectionId", collectionID, "name", name)
+	run, err := FindRunbookRunSQLite(ctx, collectionID, name)
+	if err != nil {
+		return errorResponse(fmt.Errorf("继续运行失败: %w", err))
+	}
+	return dataResponse(run)
+}
+
+// GetRunbookRun 获取运行及其步骤
+func (a *App) GetRunbookRun(runID uint64) Response {
+	ctx := a.opContext()
+	run, err := GetRunbookRunSQLite(ctx, runID)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取运行失败: %w", err))
+	}
+	return dataResponse(run)
+}
+
+// GetRunbookRuns 获取集合的所有运行及进度
+func (a *App) GetRunbookRuns(collectionID uint64) Response {
+	ctx := a.opContext()
+	runs, err := GetRunbookRunsSQLite(ctx, collectionID)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取运行列表失败: %w", err))
+	}
+	return dataResponse(runs)
+}
+
+// SetRunbookStepStatus 标记运行中某一步为done、skipped或改回pending，返回更新后的运行
+func (a *App) SetRunbookStepStatus(runID uint64, position int, status string) Response {
+	ctx := a.opContext()
+	slog.Debug("SetRunbookStepStatus", "runId", runID, "position", position, "status", status)
+	run, err := SetRunbookStepStatusSQLite(ctx, runID, position, status)
+	if err != nil {
+		return errorResponse(fmt.Errorf("更新步骤状态失败: %w", err))
+	}
+	return dataResponse(run)
+}
+
+// DeleteRunbookRun 删除运行记录
+func (a *App) DeleteRunbookRun(runID uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("DeleteRunbookRun", "runId", runID)
+	if err := DeleteRunbookRunSQLite(ctx, runID); err != nil {
+		return errorResponse(fmt.Errorf("删除运行失败: %w", err))
+	}
+	return Response{}
+}
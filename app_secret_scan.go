@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// ScanContentSecrets 检测内容中的疑似密钥，用于编辑时提示
+func (a *App) ScanContentSecrets(content string) Response {
+	findings := ScanSecrets(content)
+	if findings == nil {
+		findings = []*SecretFinding{}
+	}
+	return dataResponse(findings)
+}
+
+// RewriteSecrets 将内容中的疑似密钥改写为模板变量或密钥引用，返回改写后的内容。
+// 改写为密钥引用时原值存入密钥库，需要先解锁，且不会覆盖已有的同名密钥
+func (a *App) RewriteSecrets(content string, rewrites []*SecretRewrite) Response {
+	ctx := a.opContext()
+	rewritten, secrets, err := rewriteSecrets(content, rewrites)
+	if err != nil {
+		return errorResponse(fmt.Errorf("改写密钥失败: %w", err))
+	}
+	if len(secrets) == 0 {
+		return dataResponse(rewritten)
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// 所有密钥在一个事务中保存，任一失败时都不写入，避免内容没有改写而密钥库中已有部分密钥
+	err = a.vault.withKey(func(key []byte, cache map[string]string) error {
+		sealed := make(map[string]*sealedSecret, len(names))
+		for _, name := range names {
+			nonce, ciphertext, err := sealVaultValue(key, name, []byte(secrets[name]))
+			if err != nil {
+				return err
+			}
+			sealed[name] = &sealedSecret{nonce: nonce, ciphertext: ciphertext}
+		}
+		if err := CreateSecretsSQLite(ctx, sealed); err != nil {
+			return err
+		}
+		for _, name := range names {
+			cache[name] = secrets[name]
+		}
+		return nil
+	})
+	if err != nil {
+		return errorResponse(fmt.Errorf("改写密钥失败: %w", err))
+	}
+	slog.Info("已将密钥存入密钥库", "count", len(names), "names", names)
+	return dataResponse(rewritten)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// eventSettingsChanged 设置保存后发给前端的事件，参数为新的设置
+const eventSettingsChanged = "settings:changed"
+
+// GetSettings 获取当前设置
+func (a *App) GetSettings() Response {
+	s := currentSettings()
+	return dataResponse(&s)
+}
+
+// UpdateSettings 校验并保存整份设置，返回规范化后的设置。数据库配置在重启后生效
+func (a *App) UpdateSettings(settings Settings) Response {
+	slog.Debug("UpdateSettings", "settings", settings)
+	if err := a.saveSettings(settings); err != nil {
+		return errorResponse(fmt.Errorf("保存设置失败: %w", err))
+	}
+	return a.GetSettings()
+}
+
+// saveSettings 保存设置并应用到正在运行的应用，然后通知前端
+func (a *App) saveSettings(settings Settings) error {
+	ctx := a.opContext()
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	previous := currentSettings()
+	if reflect.DeepEqual(previous, settings) {
+		return nil
+	}
+
+	values, err := settings.encode()
+	if err != nil {
+		return err
+	}
+	if err = SaveSettingsSQLite(ctx, values); err != nil {
+		return err
+	}
+	if settings.DBProfile != previous.DBProfile {
+		if err = saveDBProfile(settings.DBProfile); err != nil {
+			return err
+		}
+	}
+	setCurrentSettings(settings)
+
+	// 别名文件的说明使用当前语言
+	if settings.Locale != previous.Locale {
+		a.regenerateShellAliases()
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventSettingsChanged, &settings)
+	}
+	return nil
+}
+
+// PurgeTrash 按回收站保留天数彻底删除过期的已删除数据，返回删除的行数。
+// 只在用户请求时清理，保留天数为0表示永久保留，不删除任何数据。被清理数据的审计日志仍然保留
+func (a *App) PurgeTrash() Response {
+	ctx := a.opContext()
+	days := currentSettings().TrashRetentionDays
+	if days == 0 {
+		return dataResponse(int64(0))
+	}
+	n, err := PurgeDeletedSQLite(ctx, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return errorResponse(fmt.Errorf("清理回收站失败: %w", err))
+	}
+	slog.Info("已清理过期的已删除数据", "count", n, "days", days)
+	return dataResponse(n)
+}
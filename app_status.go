@@ -15,17 +15,11 @@ type SortIndex struct {
 	CopyCountAsc bool `json:"copyCountAsc"`
 }
 
-func (a *App) GetStatus() *Status {
-	return &Status{
-		Os: []string{
-			Linux,
-		},
-		SortIndex: SortIndex{
-			CreatTimeAsc: true,
-			IDAsc:        true,
-			NameAsc:      true,
-			SortValueAsc: true,
-			CopyCountAsc: true,
-		},
-	}
+// GetStatus 按用户设置返回启动时的OS筛选条件和排序方式
+func (a *App) GetStatus() Response {
+	settings := currentSettings()
+	return dataResponse(&Status{
+		Os:        settings.Os,
+		SortIndex: settings.Sort,
+	})
 }
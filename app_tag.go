@@ -2,8 +2,7 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
 )
 
 type CommandIDName struct {
@@ -16,7 +15,12 @@ type Tag struct {
 	ID            uint64          `json:"id"`
 	Name          string          `json:"name"`
 	Description   string          `json:"description,omitempty"`
+	ParentID      uint64          `json:"parentId,omitempty"` // 父标签ID，0表示顶层标签
+	Path          string          `json:"path,omitempty"`     // 从顶层标签开始的完整路径，例如 cloud/aws/s3
+	Children      []*Tag          `json:"children,omitempty"`
 	SearchCount   int             `json:"searchCount,omitempty"`
+	Pinned        bool            `json:"pinned,omitempty"`
+	SortValue     int             `json:"sortValue"`
 	Os            []string        `json:"os,omitempty"`
 	CommandIDs    []uint64        `json:"commandIds,omitempty"`
 	ComandIdNames []CommandIDName `json:"commandIdNames,omitempty"`
@@ -26,57 +30,94 @@ type Tag struct {
 }
 
 // CreateTag 创建标签
-func (a *App) CreateTag(tag *Tag) error {
-	log.Printf("CreateTag: %+v\n", tag)
+func (a *App) CreateTag(tag *Tag) Response {
+	ctx := a.opContext()
+	slog.Debug("CreateTag", "name", tag.Name, "parentId", tag.ParentID)
 	// 输入验证
 	if tag.Name == "" {
-		return fmt.Errorf("标签名称不能为空")
+		return errorResponse(NewValidationError("name", "tag.name_required"))
 	}
-	err := CreateTagSQLite(tag)
+	err := CreateTagSQLite(ctx, tag)
 	if err != nil {
-		return fmt.Errorf("创建标签失败: %v", err)
+		return errorResponse(fmt.Errorf("创建标签失败: %w", err))
 	}
-	return nil
+	return Response{}
 }
 
 // GetTag 获取单个标签
-func (a *App) GetTag(id uint64) (*Tag, error) {
-	log.Printf("GetTag: %d\n", id)
-	tag, err := GetTagSQLite(id)
+func (a *App) GetTag(id uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("GetTag", "id", id)
+	tag, err := GetTagSQLite(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("获取标签失败: %v", err)
+		return errorResponse(fmt.Errorf("获取标签失败: %w", err))
 	}
-	return tag, nil
+	return dataResponse(tag)
 }
 
-func (a *App) GetCommandsByTagId(option Option) []*Command {
-	fmt.Printf("GetCommandsByTagId: %v\n", option)
-	return a.commands
+// GetCommandsByTagId 获取option.ID标签及其子标签下的指令
+func (a *App) GetCommandsByTagId(option Option) Response {
+	ctx := a.opContext()
+	slog.Debug("GetCommandsByTagId", "id", option.ID)
+	commands, err := GetCommandsByTagIDs(ctx, []uint64{option.ID}, option.Sort)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取标签下的指令失败: %w", err))
+	}
+	return dataResponse(commands)
 }
 
 // UpdateTag 更新标签
-func (a *App) UpdateTag(tag *Tag) error {
-	log.Printf("UpdateTag: %+v\n", tag)
-	// 检查标签是否存在
-	for _, t := range a.tags {
-		if t.ID == tag.ID && t.DeletedAt == "" {
-			t.Name = tag.Name
-			t.Description = tag.Description
-			t.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
-			return nil
-		}
+func (a *App) UpdateTag(tag *Tag) Response {
+	ctx := a.opContext()
+	slog.Debug("UpdateTag", "id", tag.ID, "name", tag.Name)
+	if tag.ID == 0 {
+		return errorResponse(NewValidationError("tagId", "tag.id_required"))
+	}
+	if tag.Name == "" {
+		return errorResponse(NewValidationError("name", "tag.name_required"))
+	}
+	if err := UpdateTagSQLite(ctx, tag); err != nil {
+		return errorResponse(fmt.Errorf("更新标签失败: %w", err))
+	}
+	return Response{}
+}
+
+// DeleteTag 删除标签，子标签会一并删除
+func (a *App) DeleteTag(id uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("DeleteTag", "id", id)
+	if err := DeleteTagSQLite(ctx, id); err != nil {
+		return errorResponse(fmt.Errorf("删除标签失败: %w", err))
 	}
-	// 更新时间
-	tag.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	return Response{}
+}
 
-	// 保存到模拟数据库
-	a.tags = append(a.tags, tag)
-	return nil
+// GetTagTree 获取树形结构的标签列表
+func (a *App) GetTagTree() Response {
+	ctx := a.opContext()
+	tags, err := GetTagTreeSQLite(ctx)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取标签树失败: %w", err))
+	}
+	return dataResponse(tags)
 }
 
-// DeleteTag 删除标签
-func (a *App) DeleteTag(id uint64) error {
-	// 检查标签是否存在
+// MoveTag 将标签移动到另一个父标签下，parentID为0表示移动到顶层
+func (a *App) MoveTag(id uint64, parentID uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("MoveTag", "id", id, "parentId", parentID)
+	if err := MoveTagSQLite(ctx, id, parentID); err != nil {
+		return errorResponse(fmt.Errorf("移动标签失败: %w", err))
+	}
+	return Response{}
+}
 
-	return nil
+// MergeTags 将源标签合并到目标标签
+func (a *App) MergeTags(sourceID uint64, targetID uint64) Response {
+	ctx := a.opContext()
+	slog.Debug("MergeTags", "sourceId", sourceID, "targetId", targetID)
+	if err := MergeTagsSQLite(ctx, sourceID, targetID); err != nil {
+		return errorResponse(fmt.Errorf("合并标签失败: %w", err))
+	}
+	return Response{}
 }
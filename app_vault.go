@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// eventVaultLocked 密钥库因空闲超时自动锁定时发给前端的事件
+const eventVaultLocked = "vault:locked"
+
+// VaultStatus 密钥库状态
+type VaultStatus struct {
+	Initialized     bool `json:"initialized"`
+	Unlocked        bool `json:"unlocked"`
+	AutoLockMinutes int  `json:"autoLockMinutes"` // 0表示不自动锁定
+	SecretCount     int  `json:"secretCount"`
+}
+
+// SecretInfo 密钥的名称和时间，任何接口都不返回密钥明文
+type SecretInfo struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// newSecretVault 创建锁定状态的密钥库，自动锁定时通知前端
+func (a *App) newSecretVault() *secretVault {
+	return &secretVault{onLock: func() {
+		slog.Info("密钥库空闲超时，已自动锁定")
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, eventVaultLocked)
+		}
+	}}
+}
+
+// InitVault 用主密码创建密钥库并解锁，主密码无法找回
+func (a *App) InitVault(passphrase string) Response {
+	ctx := a.opContext()
+	if len(passphrase) < minVaultPassphraseLen {
+		return errorResponse(NewValidationError("passphrase", "vault.passphrase_too_short", minVaultPassphraseLen))
+	}
+	rec := &vaultRecord{salt: make([]byte, vaultSaltLen), kdf: defaultVaultKDF, autoLockMinutes: defaultVaultAutoLockMin}
+	if _, err := rand.Read(rec.salt); err != nil {
+		return errorResponse(fmt.Errorf("初始化密钥库失败: %w", err))
+	}
+	key := deriveVaultKey(passphrase, rec.salt, rec.kdf)
+	var err error
+	rec.checkNonce, rec.checkValue, err = sealVaultValue(key, "", []byte(vaultCheckPlaintext))
+	if err != nil {
+		return errorResponse(fmt.Errorf("初始化密钥库失败: %w", err))
+	}
+	if err = CreateVaultSQLite(ctx, rec); err != nil {
+		return errorResponse(fmt.Errorf("初始化密钥库失败: %w", err))
+	}
+	a.vault.unlock(key, time.Duration(rec.autoLockMinutes)*time.Minute)
+	slog.Info("密钥库已初始化")
+	return Response{}
+}
+
+// UnlockVault 校验主密码并解锁密钥库，超过自动锁定时间没有使用会重新锁定
+func (a *App) UnlockVault(passphrase string) Response {
+	ctx := a.opContext()
+	rec, err := GetVaultSQLite(ctx)
+	if err != nil {
+		return errorResponse(fmt.Errorf("解锁密钥库失败: %w", err))
+	}
+	if rec == nil {
+		return errorResponse(NewNotFoundError("vault.not_initialized"))
+	}
+	key := deriveVaultKey(passphrase, rec.salt, rec.kdf)
+	check, err := openVaultValue(key, "", rec.checkNonce, rec.checkValue)
+	if err != nil || string(check) != vaultCheckPlaintext {
+		return errorResponse(NewValidationError("passphrase", "vault.wrong_passphrase"))
+	}
+	a.vault.unlock(key, time.Duration(rec.autoLockMinutes)*time.Minute)
+	slog.Info("密钥库已解锁")
+	return Response{}
+}
+
+// LockVault 立即锁定密钥库，清除内存中的主密钥
+func (a *App) LockVault() Response {
+	a.vault.lock()
+	slog.Info("密钥库已锁定")
+	return Response{}
+}
+
+// GetVaultStatus 获取密钥库状态
+func (a *App) GetVaultStatus() Response {
+	ctx := a.opContext()
+	rec, err := GetVaultSQLite(ctx)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取密钥库状态失败: %w", err))
+	}
+	status := &VaultStatus{Initialized: rec != nil, Unlocked: a.vault.unlocked()}
+	if rec == nil {
+		return dataResponse(status)
+	}
+	status.AutoLockMinutes = rec.autoLockMinutes
+	secrets, err := GetSecretsSQLite(ctx)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取密钥库状态失败: %w", err))
+	}
+	status.SecretCount = len(secrets)
+	return dataResponse(status)
+}
+
+// SetVaultAutoLock 设置空闲多少分钟后自动锁定，0表示不自动锁定
+func (a *App) SetVaultAutoLock(minutes int) Response {
+	ctx := a.opContext()
+	if minutes < 0 {
+		return errorResponse(NewValidationError("minutes", "vault.invalid_auto_lock"))
+	}
+	if err := SetVaultAutoLockSQLite(ctx, minutes); err != nil {
+		return errorResponse(fmt.Errorf("设置自动锁定时间失败: %w", err))
+	}
+	a.vault.setIdle(time.Duration(minutes) * time.Minute)
+	return Response{}
+}
+
+// GetSecrets 获取所有密钥的名称，锁定状态下也可以查看
+func (a *App) GetSecrets() Response {
+	ctx := a.opContext()
+	secrets, err := GetSecretsSQLite(ctx)
+	if err != nil {
+		return errorResponse(fmt.Errorf("获取密钥列表失败: %w", err))
+	}
+	return dataResponse(secrets)
+}
+
+// SetSecret 加密保存密钥，同名密钥已存在时覆盖，需要先解锁
+func (a *App) SetSecret(name, value string) Response {
+	slog.Debug("SetSecret", "name", name)
+	return errorResponse(a.saveSecret(name, value))
+}
+
+// saveSecret 校验并加密保存密钥
+func (a *App) saveSecret(name, value string) error {
+	ctx := a.opContext()
+	if err := ValidateSecretName(name); err != nil {
+		return fmt.Errorf("保存密钥失败: %w", err)
+	}
+	if value == "" {
+		return NewValidationError("value", "secret.value_required")
+	}
+	err := a.vault.withKey(func(key []byte, cache map[string]string) error {
+		nonce, ciphertext, err := sealVaultValue(key, name, []byte(value))
+		if err != nil {
+			return err
+		}
+		if err = SaveSecretSQLite(ctx, name, &sealedSecret{nonce: nonce, ciphertext: ciphertext}); err != nil {
+			return err
+		}
+		cache[name] = value
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("保存密钥失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteSecret 删除密钥，需要先解锁
+func (a *App) DeleteSecret(name string) Response {
+	ctx := a.opContext()
+	slog.Debug("DeleteSecret", "name", name)
+	err := a.vault.withKey(func(key []byte, cache map[string]string) error {
+		if err := DeleteSecretSQLite(ctx, name); err != nil {
+			return err
+		}
+		delete(cache, name)
+		return nil
+	})
+	if err != nil {
+		return errorResponse(fmt.Errorf("删除密钥失败: %w", err))
+	}
+	return Response{}
+}
+
+// lookupSecrets 解密names对应的密钥，密钥库锁定或有密钥不存在时返回错误
+func (a *App) lookupSecrets(names []string) (map[string]string, error) {
+	result := make(map[string]string, len(names))
+	err := a.vault.withKey(func(key []byte, cache map[string]string) error {
+		return decryptSecrets(a.opContext(), key, cache, names, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decryptSecrets 先从缓存取，其余的从数据库读取并解密；names为空时取全部
+func decryptSecrets(ctx context.Context, key []byte, cache map[string]string, names []string, result map[string]string) error {
+	var missing []string
+	for _, name := range names {
+		if v, ok := cache[name]; ok {
+			result[name] = v
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(names) > 0 && len(missing) == 0 {
+		return nil
+	}
+
+	sealed, err := GetSealedSecretsSQLite(ctx, missing)
+	if err != nil {
+		return err
+	}
+	var notFound []string
+	for _, name := range missing {
+		if sealed[name] == nil {
+			notFound = append(notFound, name)
+		}
+	}
+	if len(notFound) > 0 {
+		return NewNotFoundError("secret.not_found", strings.Join(notFound, ", "))
+	}
+	for name, s := range sealed {
+		if v, ok := cache[name]; ok {
+			result[name] = v
+			continue
+		}
+		plaintext, err := openVaultValue(key, name, s.nonce, s.ciphertext)
+		if err != nil {
+			return fmt.Errorf("解密密钥[%s]失败: %w", name, err)
+		}
+		cache[name] = string(plaintext)
+		result[name] = string(plaintext)
+	}
+	return nil
+}
+
+// containsSecret 判断text中是否包含密钥，用于避免把密钥写入输入历史。
+// 总是用ScanSecrets按模式和熵检查，密钥库已解锁时还会比对所有密钥明文
+func (a *App) containsSecret(text string) bool {
+	if len(ScanSecrets(text)) > 0 {
+		return true
+	}
+	if !a.vault.unlocked() {
+		return false
+	}
+	// 只是检查，不算作使用密钥库，不推迟自动锁定
+	all := make(map[string]string)
+	err := a.vault.inspect(func(key []byte, cache map[string]string) error {
+		return decryptSecrets(a.opContext(), key, cache, nil, all)
+	})
+	if err != nil {
+		slog.Warn("读取密钥失败", "err", err)
+		return false
+	}
+	for _, v := range all {
+		if len(v) >= minMaskedSecretLen && strings.Contains(text, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 审计日志记录的实体类型
+const (
+	AuditEntityCommand    = "command"
+	AuditEntityTag        = "tag"
+	AuditEntityCollection = "collection"
+	AuditEntityRunbookRun = "runbook_run"
+	AuditEntitySecret     = "secret"
+	AuditEntityVault      = "vault"
+	AuditEntitySettings   = "settings"
+)
+
+// 审计日志的操作类型
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionMerge   = "merge"
+)
+
+// 修改的来源
+const (
+	AuditSourceGUI    = "gui"
+	AuditSourceCLI    = "cli"
+	AuditSourceAPI    = "api"
+	AuditSourceImport = "import"
+)
+
+// auditSourceKey context中保存修改来源的键
+type auditSourceKey struct{}
+
+// withAuditSource 返回带有修改来源的context，写入审计日志时使用
+func withAuditSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
+}
+
+// auditSourceFrom 读取context中的修改来源，没有设置时视为图形界面
+func auditSourceFrom(ctx context.Context) string {
+	if source, ok := ctx.Value(auditSourceKey{}).(string); ok {
+		return source
+	}
+	return AuditSourceGUI
+}
+
+// AuditEntry 审计日志中的一条记录。清理回收站时不删除审计日志，被清理的实体的历史仍然保留
+type AuditEntry struct {
+	ID         uint64          `json:"id"`
+	EntityType string          `json:"entityType"`
+	EntityID   uint64          `json:"entityId"`
+	Action     string          `json:"action"`
+	Source     string          `json:"source"`
+	Diff       json.RawMessage `json:"diff"` // {"before": {...}, "after": {...}}，只包含发生变化的字段，疑似密钥已替换掉
+	CreatedAt  string          `json:"createdAt"`
+}
+
+// auditSnapshot 实体在修改前或修改后的状态，键为字段名
+type auditSnapshot map[string]interface{}
+
+// auditEntity 描述如何读取一种实体的快照。
+// row 查询实体本身，参数为ID，不存在时快照为nil；lists 查询关联数据，单列时为值的列表，多列时为对象的列表；
+// pairs 查询键值对，每一对作为快照的一个字段。
+// 使用计数、频率等统计字段以及updated_at不属于用户的修改，不放入快照
+type auditEntity struct {
+	row   string
+	lists map[string]string
+	pairs string
+}
+
+var auditEntities = map[string]auditEntity{
+	AuditEntityCommand: {
+		row: "SELECT name, content, description, alias, pinned, sort_value, skip_syntax_check, deleted_at FROM commands WHERE id = ?",
+		lists: map[string]string{
+			"os":            "SELECT os FROM command_os WHERE command_id = ? ORDER BY os",
+			"tagIds":        "SELECT tag_id FROM command_tags WHERE command_id = ? ORDER BY tag_id",
+			"collectionIds": "SELECT collection_id FROM command_collections WHERE command_id = ? ORDER BY collection_id",
+			"variants":      "SELECT os, shell, content FROM command_variants WHERE command_id = ? ORDER BY os, shell",
+		},
+	},
+	AuditEntityTag: {
+		row: "SELECT name, description, parent_id, pinned, sort_value, deleted_at FROM tags WHERE id = ?",
+		lists: map[string]string{
+			"os":         "SELECT os FROM tag_os WHERE tag_id = ? ORDER BY os",
+			"commandIds": "SELECT command_id FROM command_tags WHERE tag_id = ? ORDER BY command_id",
+		},
+	},
+	AuditEntityCollection: {
+		row: "SELECT name, description, pinned, sort_value, deleted_at FROM collections WHERE id = ?",
+		lists: map[string]string{
+			"os":    "SELECT os FROM collection_os WHERE collection_id = ? ORDER BY os",
+			"steps": "SELECT command_id, note, optional FROM command_collections WHERE collection_id = ? ORDER BY position, command_id",
+		},
+	},
+	AuditEntityRunbookRun: {
+		row: "SELECT collection_id, name, status, finished_at FROM runbook_runs WHERE id = ?",
+		lists: map[string]string{
+			"steps": "SELECT position, command_id, status FROM runbook_run_steps WHERE run_id = ? ORDER BY position",
+		},
+	},
+	// 只记录密钥的名称和修改时间，任何情况下都不记录密文
+	AuditEntitySecret: {
+		row: "SELECT name, updated_at FROM secrets WHERE id = ?",
+	},
+	AuditEntityVault: {
+		row: "SELECT auto_lock_minutes, kdf_time, kdf_memory, kdf_threads FROM vault WHERE id = ?",
+	},
+	// 设置只有一份，ID总是0
+	AuditEntitySettings: {
+		pairs: "SELECT key, value FROM settings WHERE ? = 0",
+	},
+}
+
+// sqlExecQueryer *sql.DB 和 *sql.Tx 共有的查询和执行方法
+type sqlExecQueryer interface {
+	sqlQueryer
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// auditBatch 一组实体修改前的快照，修改完成后与修改后的快照比较并写入审计日志
+type auditBatch struct {
+	entityType string
+	ids        []uint64
+	before     map[uint64]auditSnapshot
+}
+
+// snapshotAudit 在修改之前读取实体的快照，需要与修改在同一个事务中调用。
+// 使用计数、frecency评分、shell钩子上报的命令和风险等级由应用自动维护，不写入审计日志
+func snapshotAudit(ctx context.Context, q sqlQueryer, entityType string, ids ...uint64) (*auditBatch, error) {
+	b := &auditBatch{entityType: entityType, ids: ids, before: make(map[uint64]auditSnapshot, len(ids))}
+	for _, id := range ids {
+		snapshot, err := loadAuditSnapshot(ctx, q, entityType, id)
+		if err != nil {
+			return nil, err
+		}
+		b.before[id] = snapshot
+	}
+	return b, nil
+}
+
+// record 为批次中的所有实体写入审计日志
+func (b *auditBatch) record(ctx context.Context, q sqlExecQueryer, action string) error {
+	for _, id := range b.ids {
+		if err := b.recordOne(ctx, q, id, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordOne 为批次中的一个实体写入审计日志，用于同一批次中各实体操作不同的情况
+func (b *auditBatch) recordOne(ctx context.Context, q sqlExecQueryer, id uint64, action string) error {
+	return recordAudit(ctx, q, b.entityType, id, action, b.before[id])
+}
+
+// recordAudit 读取实体修改后的快照，与before比较后写入审计日志；没有任何变化时不记录。
+// before为nil表示新建的实体。差异中的疑似密钥在写入前替换掉，审计日志不会随回收站清理而删除
+func recordAudit(ctx context.Context, q sqlExecQueryer, entityType string, id uint64, action string, before auditSnapshot) error {
+	after, err := loadAuditSnapshot(ctx, q, entityType, id)
+	if err != nil {
+		return err
+	}
+	diff, changed := auditDiff(before, after)
+	if !changed {
+		return nil
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return fmt.Errorf("编码审计日志失败: %w", err)
+	}
+	if data, err = redactAuditDiff(data); err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx,
+		"INSERT INTO audit_log (entity_type, entity_id, action, source, diff, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		entityType, id, action, auditSourceFrom(ctx), string(data), time.Now().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	return nil
+}
+
+// loadAuditSnapshot 读取实体当前的快照，实体不存在时返回nil
+func loadAuditSnapshot(ctx context.Context, q sqlQueryer, entityType string, id uint64) (auditSnapshot, error) {
+	entity, ok := auditEntities[entityType]
+	if !ok {
+		return nil, fmt.Errorf("未知的审计实体类型: %s", entityType)
+	}
+	snapshot := auditSnapshot{}
+	if entity.row != "" {
+		rows, err := querySnapshotRows(ctx, q, entity.row, id)
+		if err != nil {
+			return nil, fmt.Errorf("读取%s快照失败: %w", entityType, err)
+		}
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		snapshot = rows[0]
+	}
+	if entity.pairs != "" {
+		pairs, err := querySnapshotRows(ctx, q, entity.pairs, id)
+		if err != nil {
+			return nil, fmt.Errorf("读取%s快照失败: %w", entityType, err)
+		}
+		for _, pair := range pairs {
+			snapshot[fmt.Sprint(pair["key"])] = pair["value"]
+		}
+	}
+	for field, query := range entity.lists {
+		items, err := querySnapshotRows(ctx, q, query, id)
+		if err != nil {
+			return nil, fmt.Errorf("读取%s快照的%s失败: %w", entityType, field, err)
+		}
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			values[i] = item
+			if len(item) == 1 {
+				for _, v := range item {
+					values[i] = v
+				}
+			}
+		}
+		snapshot[field] = values
+	}
+	return snapshot, nil
+}
+
+// querySnapshotRows 执行查询，每一行转换为以列名为键的快照
+func querySnapshotRows(ctx context.Context, q sqlQueryer, query string, args ...interface{}) ([]auditSnapshot, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []auditSnapshot
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		snapshot := make(auditSnapshot, len(columns))
+		for i, column := range columns {
+			snapshot[column] = auditValue(values[i])
+		}
+		result = append(result, snapshot)
+	}
+	return result, rows.Err()
+}
+
+// auditValue 将驱动返回的值转换为适合写入JSON的值
+func auditValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return v
+	}
+}
+
+// auditDiff 比较修改前后的快照，只保留发生变化的字段。新建或彻底删除时保留完整的快照
+func auditDiff(before, after auditSnapshot) (map[string]auditSnapshot, bool) {
+	if before == nil || after == nil {
+		return map[string]auditSnapshot{"before": before, "after": after}, before != nil || after != nil
+	}
+	changedBefore, changedAfter := auditSnapshot{}, auditSnapshot{}
+	for key, value := range before {
+		if !auditValueEqual(value, after[key]) {
+			changedBefore[key], changedAfter[key] = value, after[key]
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			changedBefore[key], changedAfter[key] = nil, value
+		}
+	}
+	if len(changedAfter) == 0 {
+		return nil, false
+	}
+	return map[string]auditSnapshot{"before": changedBefore, "after": changedAfter}, true
+}
+
+// redactAuditDiff 将审计日志差异中所有字符串值里的疑似密钥替换掉，写入和导出时使用。
+// 导出时再处理一次，覆盖扫描规则更新之前写入的记录
+func redactAuditDiff(diff json.RawMessage) (json.RawMessage, error) {
+	if len(diff) == 0 {
+		return diff, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(diff))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("解析审计日志失败: %w", err)
+	}
+	data, err := json.Marshal(redactAuditValue(v))
+	if err != nil {
+		return nil, fmt.Errorf("编码审计日志失败: %w", err)
+	}
+	return data, nil
+}
+
+// redactAuditValue 递归处理解析后的JSON值中的字符串
+func redactAuditValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return redactSecrets(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = redactAuditValue(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = redactAuditValue(v[k])
+		}
+	}
+	return v
+}
+
+func auditValueEqual(a, b interface{}) bool {
+	x, errX := json.Marshal(a)
+	y, errY := json.Marshal(b)
+	return errX == nil && errY == nil && string(x) == string(y)
+}
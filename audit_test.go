@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := auditSnapshot{"name": "ls", "content": "ls -l", "os": []interface{}{"linux"}}
+	tests := []struct {
+		name    string
+		before  auditSnapshot
+		after   auditSnapshot
+		changed bool
+		want    string
+	}{
+		{"unchanged", before, auditSnapshot{"name": "ls", "content": "ls -l", "os": []interface{}{"linux"}}, false, ""},
+		{"changed field", before, auditSnapshot{"name": "ls", "content": "ls -la", "os": []interface{}{"linux"}},
+			true, `{"after":{"content":"ls -la"},"before":{"content":"ls -l"}}`},
+		{"list changed", before, auditSnapshot{"name": "ls", "content": "ls -l", "os": []interface{}{"all"}},
+			true, `{"after":{"os":["all"]},"before":{"os":["linux"]}}`},
+		{"new field", auditSnapshot{}, auditSnapshot{"theme": `"dark"`},
+			true, `{"after":{"theme":"\"dark\""},"before":{"theme":null}}`},
+		{"create", nil, auditSnapshot{"name": "ls"}, true, `{"after":{"name":"ls"},"before":null}`},
+		{"hard delete", auditSnapshot{"name": "ls"}, nil, true, `{"after":null,"before":{"name":"ls"}}`},
+		{"missing", nil, nil, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, changed := auditDiff(tt.before, tt.after)
+			if changed != tt.changed {
+				t.Fatalf("auditDiff() changed = %v, want %v", changed, tt.changed)
+			}
+			if !changed {
+				return
+			}
+			data, err := json.Marshal(diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("auditDiff() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditValue(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	if got := auditValue(at); got != "2026-01-02 03:04:05" {
+		t.Errorf("auditValue(time) = %v", got)
+	}
+	if got := auditValue([]byte("abc")); got != "abc" {
+		t.Errorf("auditValue([]byte) = %v", got)
+	}
+	if got := auditValue(int64(1)); got != int64(1) {
+		t.Errorf("auditValue(int64) = %v", got)
+	}
+}
+
+func TestRedactAuditDiff(t *testing.T) {
+	diff := json.RawMessage(`{"after":{"content":"mysql --password=Sup3rS3cret db","tags":["ops"],"sortOrder":3},"before":{"content":"mysql db","sortOrder":2}}`)
+	got, err := redactAuditDiff(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"after":{"content":"mysql --password=[REDACTED] db","sortOrder":3,"tags":["ops"]},"before":{"content":"mysql db","sortOrder":2}}`
+	if string(got) != want {
+		t.Errorf("redactAuditDiff() = %s, want %s", got, want)
+	}
+	if got, err := redactAuditDiff(nil); err != nil || got != nil {
+		t.Errorf("redactAuditDiff(nil) = %s, %v", got, err)
+	}
+}
+
+func TestEncodeAuditLogCSV(t *testing.T) {
+	entries := []*AuditEntry{{
+		ID: 1, EntityType: AuditEntityCommand, EntityID: 2, Action: AuditActionUpdate, Source: AuditSourceGUI,
+		Diff: json.RawMessage(`{"after":{"name":"b"},"before":{"name":"a"}}`), CreatedAt: "2026-01-02 03:04:05",
+	}}
+	content, err := encodeAuditLog(entries, auditExportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `1,2026-01-02 03:04:05,command,2,update,gui,"{""after""`) {
+		t.Errorf("encodeAuditLog(csv) = %q", content)
+	}
+
+	content, err = encodeAuditLog(entries, auditExportJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded AuditEntry
+	if err = json.Unmarshal([]byte(content), &decoded); err != nil || decoded.EntityID != 2 {
+		t.Errorf("encodeAuditLog(jsonl) = %q, %v", content, err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runCLI 处理命令行子命令，不是已知的子命令时返回false，继续启动图形界面
+func runCLI(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "hook":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "用法: quickcmd hook bash|zsh|fish")
+			return 2, true
+		}
+		script, err := HookScript(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1, true
+		}
+		fmt.Print(script)
+		return 0, true
+	case "report":
+		rest := args[1:]
+		if len(rest) > 0 && rest[0] == "--" {
+			rest = rest[1:]
+		}
+		// 应用未运行时静默失败，避免干扰shell
+		if err := ReportTypedCommand(strings.Join(rest, " ")); err != nil {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// CommandVariant 指令在某个OS（和shell）下的专用内容，
+// 例如"查看监听端口"在Linux下为 ss -tlnp，在Windows下为 netstat -ano
+type CommandVariant struct {
+	ID        uint64 `json:"id,omitempty"`
+	CommandID uint64 `json:"commandId,omitempty"`
+	Os        string `json:"os"`
+	Shell     string `json:"shell,omitempty"` // 为空表示适用于该OS下的任意shell
+	Content   string `json:"content"`
+}
+
+// supportedShells 变体可以指定的shell
+var supportedShells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "fish": true,
+	"powershell": true, "pwsh": true, "cmd": true,
+}
+
+// ValidateCommandVariants 校验变体的OS和shell，并检查同一OS和shell下是否重复
+func ValidateCommandVariants(variants []*CommandVariant) error {
+	seen := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		v.Shell = strings.ToLower(strings.TrimSpace(v.Shell))
+		// 变体必须对应具体的OS，不能是all
+		os, ok := CanonicalOS(v.Os)
+		if !ok || os == AllOs {
+			return NewValidationError("variants", "variant.invalid_os", v.Os, Windows, Mac, Linux)
+		}
+		v.Os = os
+		if v.Shell != "" && !supportedShells[v.Shell] {
+			return NewValidationError("variants", "variant.invalid_shell", v.Shell)
+		}
+		if strings.TrimSpace(v.Content) == "" {
+			return NewValidationError("variants", "variant.content_required", v.Os)
+		}
+		key := v.Os + "/" + v.Shell
+		if seen[key] {
+			return NewValidationError("variants", "variant.duplicate", v.Os, v.Shell)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// mergeVariantOs 把变体的OS补充到指令的OS列表中，有某个OS的变体就说明指令可以在该OS上使用；
+// 指令已适用于所有OS时不需要补充
+func mergeVariantOs(cmd *Command) {
+	if slices.Contains(cmd.Os, AllOs) {
+		return
+	}
+	for _, v := range cmd.Variants {
+		found := false
+		for _, os := range cmd.Os {
+			if strings.EqualFold(os, v.Os) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			cmd.Os = append(cmd.Os, v.Os)
+		}
+	}
+}
+
+// selectCommandVariant 按osList的顺序选出第一个匹配的变体，同一OS下优先选择shell完全匹配的变体，
+// 其次是不限shell的变体；osList为空时使用当前系统，其中的all也视为当前系统
+func selectCommandVariant(variants []*CommandVariant, osList []string, shell string) *CommandVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(osList) == 0 {
+		osList = []string{currentOS()}
+	}
+	for _, os := range osList {
+		if os == AllOs {
+			os = currentOS()
+		}
+		var generic *CommandVariant
+		for _, v := range variants {
+			if !strings.EqualFold(v.Os, os) {
+				continue
+			}
+			if shell != "" && v.Shell == shell {
+				return v
+			}
+			if v.Shell == "" && generic == nil {
+				generic = v
+			}
+		}
+		if generic != nil {
+			return generic
+		}
+	}
+	return nil
+}
+
+// applyCommandVariant 用匹配的变体替换指令内容，并在Variant中记录使用了哪个变体
+func applyCommandVariant(cmd *Command, osList []string, shell string) {
+	if v := selectCommandVariant(cmd.Variants, osList, shell); v != nil {
+		cmd.Content = v.Content
+		cmd.Variant = v
+	}
+}
+
+// currentOS 返回当前系统对应的OS值
+func currentOS() string {
+	switch runtime.GOOS {
+	case "windows":
+		return Windows
+	case "darwin":
+		return Mac
+	default:
+		return Linux
+	}
+}
+
+// currentShell 返回设置中的默认shell，没有设置时检测当前用户使用的shell，Windows下默认为powershell
+func currentShell() string {
+	if shell := currentSettings().DefaultShell; shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	if shell := filepath.Base(os.Getenv("SHELL")); supportedShells[shell] {
+		return shell
+	}
+	return "sh"
+}
+
+// shellCommand 返回用指定shell执行content的命令，ctx取消时终止执行。
+// args作为位置参数传给sh、bash、zsh（$1起）和fish（$argv），PowerShell和cmd不支持
+func shellCommand(ctx context.Context, shell, content string, args ...string) *exec.Cmd {
+	switch shell {
+	case "powershell", "pwsh":
+		return exec.CommandContext(ctx, shell, "-NoProfile", "-Command", content)
+	case "cmd":
+		return exec.CommandContext(ctx, "cmd", "/C", content)
+	case "fish":
+		return exec.CommandContext(ctx, shell, append([]string{"-c", content}, args...)...)
+	default:
+		// -c之后的第一个参数是$0
+		return exec.CommandContext(ctx, shell, append([]string{"-c", content, "quickcmd"}, args...)...)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestSelectCommandVariant(t *testing.T) {
+	variants := []*CommandVariant{
+		{Os: Linux, Content: "ss -tlnp"},
+		{Os: Mac, Content: "lsof -iTCP -sTCP:LISTEN"},
+		{Os: Windows, Content: "netstat -ano"},
+		{Os: Windows, Shell: "powershell", Content: "Get-NetTCPConnection -State Listen"},
+	}
+	tests := []struct {
+		name   string
+		osList []string
+		shell  string
+		want   string
+	}{
+		{"按OS选择", []string{Mac}, "zsh", "lsof -iTCP -sTCP:LISTEN"},
+		{"优先匹配shell", []string{Windows}, "powershell", "Get-NetTCPConnection -State Listen"},
+		{"shell不匹配时用通用变体", []string{Windows}, "cmd", "netstat -ano"},
+		{"按筛选顺序", []string{Windows, Linux}, "bash", "netstat -ano"},
+		{"忽略大小写", []string{"Linux"}, "bash", "ss -tlnp"},
+	}
+	for _, tt := range tests {
+		got := selectCommandVariant(variants, tt.osList, tt.shell)
+		if got == nil || got.Content != tt.want {
+			t.Errorf("%s: got %+v, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := selectCommandVariant(variants[:1], []string{Mac}, "zsh"); got != nil {
+		t.Errorf("没有匹配的变体时应返回nil, got %+v", got)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	got, err := RenderTemplate("ssh {{user}}@{{ host }} # {{user}}", map[string]string{"user": "root", "host": "db1"})
+	if err != nil || got != "ssh root@db1 # root" {
+		t.Errorf("RenderTemplate = %q, %v", got, err)
+	}
+	if _, err = RenderTemplate("ping {{host}}", nil); err == nil {
+		t.Error("缺少变量时应返回错误")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// defaultDuplicateThreshold 近似重复的默认相似度阈值
+const defaultDuplicateThreshold = 0.8
+
+// contentToken 指令内容按shell规则切分出的一个词
+type contentToken struct {
+	text     string
+	operator bool // 未加引号的 | || && ; &
+}
+
+// tokenizeContent 按shell的引号和转义规则切分指令内容，去掉引号本身，
+// 因此 'a b'、"a b" 和 a\ b 得到相同的词
+func tokenizeContent(content string) []contentToken {
+	var tokens []contentToken
+	var sb strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, contentToken{text: sb.String()})
+			sb.Reset()
+			inWord = false
+		}
+	}
+
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				sb.WriteRune(runes[i])
+			}
+		case r == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+		case r == '\\' && i+1 < len(runes):
+			inWord = true
+			i++
+			if runes[i] != '\n' {
+				sb.WriteRune(runes[i])
+			}
+		case unicode.IsSpace(r):
+			flush()
+		case r == '|' || r == '&' || r == ';':
+			flush()
+			op := string(r)
+			if (r == '|' || r == '&') && i+1 < len(runes) && runes[i+1] == r {
+				op += string(r)
+				i++
+			}
+			tokens = append(tokens, contentToken{text: op, operator: true})
+		default:
+			inWord = true
+			sb.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// normalizeContentTokens 返回规范化后的词：每段命令保留程序名和位置参数的顺序，
+// 选项按字典序排列，单横线的短选项组合（如 -la）内部按字母排序
+func normalizeContentTokens(content string) []string {
+	var result []string
+	var segment []string
+	flushSegment := func() {
+		result = append(result, canonicalSegment(segment)...)
+		segment = segment[:0]
+	}
+	for _, t := range tokenizeContent(content) {
+		if t.operator {
+			flushSegment()
+			result = append(result, t.text)
+			continue
+		}
+		segment = append(segment, t.text)
+	}
+	flushSegment()
+	return result
+}
+
+// canonicalSegment 规范化一段不含管道和连接符的命令
+func canonicalSegment(words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	var flags, args []string
+	endOfFlags := false
+	for _, w := range words[1:] {
+		switch {
+		case endOfFlags:
+			args = append(args, w)
+		case w == "--":
+			endOfFlags = true
+			args = append(args, w)
+		case len(w) > 1 && w[0] == '-':
+			flags = append(flags, sortShortFlags(w))
+		default:
+			args = append(args, w)
+		}
+	}
+	sort.Strings(flags)
+
+	result := make([]string, 0, len(words))
+	result = append(result, words[0])
+	result = append(result, flags...)
+	return append(result, args...)
+}
+
+// sortShortFlags 将 -la 这类短选项组合的字母排序为 -al，其他选项原样返回
+func sortShortFlags(flag string) string {
+	if len(flag) < 3 || flag[1] == '-' {
+		return flag
+	}
+	letters := []rune(flag[1:])
+	for _, r := range letters {
+		if !unicode.IsLetter(r) {
+			return flag
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+	return "-" + string(letters)
+}
+
+// NormalizeCommandContent 返回指令内容的规范形式，规范形式相同的指令视为完全重复
+func NormalizeCommandContent(content string) string {
+	return strings.Join(normalizeContentTokens(content), " ")
+}
+
+// tokenSimilarity 计算两组词的多重集合Jaccard相似度
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	counts := make(map[string]int, len(a))
+	for _, t := range a {
+		counts[t]++
+	}
+	intersection := 0
+	for _, t := range b {
+		if counts[t] > 0 {
+			counts[t]--
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
+
+// DuplicateGroup 一组内容重复或近似重复的指令
+type DuplicateGroup struct {
+	Exact      bool       `json:"exact"`      // 组内所有指令的规范内容完全相同
+	Similarity float64    `json:"similarity"` // 组内相连指令之间的最低相似度
+	SurvivorID uint64     `json:"survivorId"` // 建议保留的指令：使用次数最多的，其次是最早创建的
+	Commands   []*Command `json:"commands"`
+}
+
+// findDuplicateGroups 找出相似度不低于threshold的指令并按连通关系分组，只包含两条及以上指令的组
+func findDuplicateGroups(commands []*Command, threshold float64) []*DuplicateGroup {
+	tokens := make([][]string, len(commands))
+	keys := make([]string, len(commands))
+	for i, cmd := range commands {
+		tokens[i] = normalizeContentTokens(cmd.Content)
+		keys[i] = strings.Join(tokens[i], " ")
+	}
+
+	parent := make([]int, len(commands))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	minSimilarity := make(map[int]float64)
+
+	for i := range commands {
+		for j := i + 1; j < len(commands); j++ {
+			// Jaccard相似度不会超过两组词数量之比，可以提前跳过
+			short, long := len(tokens[i]), len(tokens[j])
+			if short > long {
+				short, long = long, short
+			}
+			if long > 0 && float64(short)/float64(long) < threshold {
+				continue
+			}
+			similarity := 1.0
+			if keys[i] != keys[j] {
+				similarity = tokenSimilarity(tokens[i], tokens[j])
+			}
+			if similarity < threshold {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			s := similarity
+			for _, r := range []int{ri, rj} {
+				if v, ok := minSimilarity[r]; ok && v < s {
+					s = v
+				}
+			}
+			parent[rj] = ri
+			minSimilarity[ri] = s
+		}
+	}
+
+	byRoot := make(map[int]*DuplicateGroup)
+	firstKey := make(map[int]string)
+	var groups []*DuplicateGroup
+	for i, cmd := range commands {
+		root := find(i)
+		g, ok := byRoot[root]
+		if !ok {
+			g = &DuplicateGroup{Exact: true, Similarity: minSimilarity[root]}
+			byRoot[root] = g
+			firstKey[root] = keys[i]
+			groups = append(groups, g)
+		} else if keys[i] != firstKey[root] {
+			g.Exact = false
+		}
+		g.Commands = append(g.Commands, cmd)
+	}
+
+	result := groups[:0]
+	for _, g := range groups {
+		if len(g.Commands) < 2 {
+			continue
+		}
+		g.SurvivorID = suggestSurvivor(g.Commands).ID
+		result = append(result, g)
+	}
+	return result
+}
+
+// suggestSurvivor 选出合并时建议保留的指令：复制和执行次数最多的，次数相同时保留ID最小（最早创建）的
+func suggestSurvivor(commands []*Command) *Command {
+	best := commands[0]
+	for _, cmd := range commands[1:] {
+		used, bestUsed := cmd.CopyCounts+cmd.RunCount, best.CopyCounts+best.RunCount
+		if used > bestUsed || (used == bestUsed && cmd.ID < best.ID) {
+			best = cmd
+		}
+	}
+	return best
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestNormalizeCommandContent(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"ls   -la  /tmp", "ls -al /tmp"},
+		{`grep -r "TODO list" .`, `grep -r 'TODO list' .`},
+		{`echo a\ b`, `echo "a b"`},
+		{"tar -x -v -f a.tar", "tar -f -v -x a.tar"},
+		{"ps aux|grep nginx", "ps aux | grep nginx"},
+	}
+	for _, tt := range tests {
+		if a, b := NormalizeCommandContent(tt.a), NormalizeCommandContent(tt.b); a != b {
+			t.Errorf("NormalizeCommandContent(%q) = %q, NormalizeCommandContent(%q) = %q", tt.a, a, tt.b, b)
+		}
+	}
+	// 位置参数的顺序有意义
+	if NormalizeCommandContent("cp a b") == NormalizeCommandContent("cp b a") {
+		t.Error("positional arguments must keep their order")
+	}
+	// 引号内的管道符不是分隔符
+	if NormalizeCommandContent(`echo "a|b"`) == NormalizeCommandContent("echo a | b") {
+		t.Error("quoted operator must not split the command")
+	}
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	commands := []*Command{
+		{ID: 1, Content: "docker ps -a"},
+		{ID: 2, Content: "docker  ps   -a", CopyCounts: 3},
+		{ID: 3, Content: "kubectl get pods -n kube-system -o wide"},
+		{ID: 4, Content: "kubectl get pods -o wide -n kube-system --watch"},
+		{ID: 5, Content: "git status"},
+	}
+	groups := findDuplicateGroups(commands, defaultDuplicateThreshold)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	if g := groups[0]; !g.Exact || len(g.Commands) != 2 || g.SurvivorID != 2 {
+		t.Errorf("exact group = %+v", g)
+	}
+	if g := groups[1]; g.Exact || len(g.Commands) != 2 || g.Similarity < defaultDuplicateThreshold || g.SurvivorID != 3 {
+		t.Errorf("near duplicate group = %+v", g)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrorCode 返回给前端的错误码，数值固定不变，前端据此区分处理方式
+type ErrorCode int
+
+const (
+	CodeOK         ErrorCode = 0
+	CodeValidation ErrorCode = 400 // 参数校验失败，Field为出错的字段
+	CodeNotFound   ErrorCode = 404 // 记录不存在或已删除
+	CodeConflict   ErrorCode = 409 // 名称、别名重复，或与当前状态冲突
+	CodeLocked     ErrorCode = 423 // 密钥库未解锁
+	// CodeConfirmRequired 危险指令需要先调用ConfirmDangerousCommand确认
+	CodeConfirmRequired ErrorCode = 428
+	// CodeCanceled 请求被新的请求取代或应用正在关闭，前端可以直接忽略
+	CodeCanceled ErrorCode = 499
+	CodeInternal ErrorCode = 500 // 数据库、文件读写等内部错误
+)
+
+// AppError 带错误码的错误，存储层和App层返回的错误都应能归到其中一类。
+// 消息按Key从消息目录中取出，使用当前语言
+type AppError struct {
+	Code  ErrorCode
+	Field string // 校验失败的字段，使用JSON字段名
+	Key   string // 消息目录中的键
+	Args  []interface{}
+}
+
+func (e *AppError) Error() string {
+	return localize(e.Key, e.Args...)
+}
+
+// NewNotFoundError 记录不存在
+func NewNotFoundError(key string, args ...interface{}) error {
+	return &AppError{Code: CodeNotFound, Key: key, Args: args}
+}
+
+// NewConflictError 与已有记录或当前状态冲突
+func NewConflictError(key string, args ...interface{}) error {
+	return &AppError{Code: CodeConflict, Key: key, Args: args}
+}
+
+// NewValidationError 参数校验失败，field为出错的字段，不针对某个字段时为空
+func NewValidationError(field, key string, args ...interface{}) error {
+	return &AppError{Code: CodeValidation, Field: field, Key: key, Args: args}
+}
+
+// classifyError 返回err的错误码和出错的字段。未分类的错误视为内部错误
+func classifyError(err error) (ErrorCode, string) {
+	var appErr *AppError
+	var queryErr *SearchQueryError
+	var syntaxErr *CommandSyntaxError
+	var riskErr *RiskConfirmationError
+	var sqliteErr sqlite3.Error
+	switch {
+	case err == nil:
+		return CodeOK, ""
+	case errors.As(err, &appErr):
+		return appErr.Code, appErr.Field
+	case errors.As(err, &queryErr):
+		return CodeValidation, "name"
+	case errors.As(err, &syntaxErr):
+		return CodeValidation, "content"
+	case errors.As(err, &riskErr):
+		return CodeConfirmRequired, ""
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled, ""
+	case errors.Is(err, sql.ErrNoRows):
+		return CodeNotFound, ""
+	case errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint:
+		return CodeConflict, ""
+	}
+	return CodeInternal, ""
+}
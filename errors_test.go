@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		code  ErrorCode
+		field string
+	}{
+		{"nil", nil, CodeOK, ""},
+		{"not found", fmt.Errorf("获取指令失败: %w", NewNotFoundError("command.not_found", 1)), CodeNotFound, ""},
+		{"conflict", fmt.Errorf("创建标签失败: %w", NewConflictError("tag.exists", "go")), CodeConflict, ""},
+		{"validation", fmt.Errorf("创建指令失败: %w", NewValidationError("alias", "alias.invalid", "a b")), CodeValidation, "alias"},
+		{"search query", &SearchQueryError{Message: "括号不匹配"}, CodeValidation, "name"},
+		{"syntax", fmt.Errorf("创建指令失败: %w", &CommandSyntaxError{}), CodeValidation, "content"},
+		{"risk", &RiskConfirmationError{Report: &RiskReport{Level: RiskHigh}}, CodeConfirmRequired, ""},
+		{"vault locked", fmt.Errorf("保存密钥失败: %w", errVaultLocked), CodeLocked, ""},
+		{"canceled", fmt.Errorf("查询指令失败: %w", context.Canceled), CodeCanceled, ""},
+		{"no rows", fmt.Errorf("查询失败: %w", sql.ErrNoRows), CodeNotFound, ""},
+		{"internal", errors.New("disk I/O error"), CodeInternal, ""},
+		{"unwrapped", fmt.Errorf("获取指令失败: %v", NewNotFoundError("command.not_found", 1)), CodeInternal, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, field := classifyError(tt.err)
+			if code != tt.code || field != tt.field {
+				t.Errorf("classifyError = %d, %q, want %d, %q", code, field, tt.code, tt.field)
+			}
+		})
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	if r := errorResponse(nil); r.Code != CodeOK {
+		t.Errorf("errorResponse(nil).Code = %d", r.Code)
+	}
+
+	withLocale(t, LocaleZhCN)
+	r := errorResponse(fmt.Errorf("更新集合失败: %w", NewValidationError("name", "collection.name_required")))
+	if r.Code != CodeValidation || r.Field != "name" || r.Msg != "集合名称不能为空" {
+		t.Errorf("errorResponse = %+v", r)
+	}
+
+	withLocale(t, LocaleEN)
+	r = errorResponse(fmt.Errorf("保存密钥失败: %w", errVaultLocked))
+	if r.Code != CodeLocked || r.Msg != "The vault is locked; unlock it first" {
+		t.Errorf("errorResponse = %+v", r)
+	}
+	r = errorResponse(errors.New("disk I/O error"))
+	if r.Code != CodeInternal || r.Msg != "Internal error; see the log for details" || r.Detail != "disk I/O error" {
+		t.Errorf("errorResponse = %+v", r)
+	}
+
+	queryErr := &SearchQueryError{Position: 3, Message: "括号不匹配"}
+	if r := errorResponse(queryErr); r.Data != queryErr {
+		t.Errorf("search query error data = %v", r.Data)
+	}
+
+	r = newResponse([]int{1}, nil)
+	if r.Code != CodeOK || r.Data == nil {
+		t.Errorf("newResponse = %+v", r)
+	}
+}
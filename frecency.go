@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// 频率+时效（frecency）评分：每次使用都给指令加一个权重，权重随时间按半衰期衰减。
+//
+// 为了能在SQL中直接排序并增量更新，数据库里保存的是以frecencyEpoch为基准的对数值：
+//
+//	stored = log2( Σ weight_i * 2^((t_i - epoch) / halfLife) )
+//
+// 所有指令在同一时刻衰减的比例相同，所以按stored排序就等于按当前的实际评分排序，
+// 记录新的使用时只需要把新权重在对数域中累加上去，不用重新计算历史事件。
+const frecencyHalfLife = 7 * 24 * time.Hour
+
+// frecencyEpoch 对数评分的时间基准
+var frecencyEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// usageKind 指令的使用方式，不同方式的权重和计数字段不同
+type usageKind struct {
+	column string  // 对应的计数字段
+	weight float64 // 每次使用增加的评分
+}
+
+var (
+	usageCopy   = usageKind{column: "copy_count", weight: 1}
+	usageRun    = usageKind{column: "run_count", weight: 2}
+	usageSearch = usageKind{column: "search_count", weight: 0.2}
+)
+
+// frecencyExponent 返回时刻at相对基准经过的半衰期个数
+func frecencyExponent(at time.Time) float64 {
+	return float64(at.Sub(frecencyEpoch)) / float64(frecencyHalfLife)
+}
+
+// addFrecency 在对数域中把at时刻的一次使用累加到已保存的评分上，stored为0表示从未使用
+func addFrecency(stored float64, weight float64, at time.Time) float64 {
+	return combineFrecency(stored, math.Log2(weight)+frecencyExponent(at))
+}
+
+// combineFrecency 合并两个对数评分，即 log2(2^a + 2^b)，0表示没有评分
+func combineFrecency(a, b float64) float64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	// 先提出较大的一项避免溢出
+	hi, lo := math.Max(a, b), math.Min(a, b)
+	return hi + math.Log2(1+math.Exp2(lo-hi))
+}
+
+// frecencyScore 将保存的对数评分换算为now时刻的实际评分，用于展示
+func frecencyScore(stored float64, now time.Time) float64 {
+	if stored == 0 {
+		return 0
+	}
+	return math.Exp2(stored - frecencyExponent(now))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAddFrecencyDecaysOverTime(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// 一个月前复制了10次的指令，不如5分钟前刚执行过的指令
+	var old float64
+	for i := 0; i < 10; i++ {
+		old = addFrecency(old, usageCopy.weight, now.AddDate(0, -1, 0))
+	}
+	recent := addFrecency(0, usageRun.weight, now.Add(-5*time.Minute))
+	if recent <= old {
+		t.Errorf("recent = %f, old = %f, want recent > old", recent, old)
+	}
+
+	// 经过一个半衰期后评分减半
+	score := addFrecency(0, 4, now)
+	if got := frecencyScore(score, now.Add(frecencyHalfLife)); math.Abs(got-2) > 1e-9 {
+		t.Errorf("score after one half-life = %f, want 2", got)
+	}
+
+	// 同一时刻的两次使用评分相加
+	twice := addFrecency(addFrecency(0, 1, now), 1, now)
+	if got := frecencyScore(twice, now); math.Abs(got-2) > 1e-9 {
+		t.Errorf("score of two uses = %f, want 2", got)
+	}
+}
@@ -2,42 +2,86 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
-export function CreateCollection(arg1:main.Collection):Promise<void>;
+export function BulkAddCollections(arg1:Array<number>,arg2:Array<number>):Promise<main.Response>;
 
-export function CreateCommand(arg1:main.Command):Promise<void>;
+export function BulkAddTags(arg1:Array<number>,arg2:Array<number>):Promise<main.Response>;
 
-export function CreateTag(arg1:main.Tag):Promise<void>;
+export function BulkDeleteCommands(arg1:Array<number>):Promise<main.Response>;
 
-export function DeleteCollection(arg1:number):Promise<void>;
+export function BulkRemoveCollections(arg1:Array<number>,arg2:Array<number>):Promise<main.Response>;
 
-export function DeleteCommand(arg1:number):Promise<void>;
+export function BulkRemoveTags(arg1:Array<number>,arg2:Array<number>):Promise<main.Response>;
 
-export function DeleteTag(arg1:number):Promise<void>;
+export function BulkRestoreCommands(arg1:Array<number>):Promise<main.Response>;
 
-export function GetAllCollectionsIDAndName():Promise<Array<main.Collection>>;
+export function BulkSetOs(arg1:Array<number>,arg2:Array<string>):Promise<main.Response>;
 
-export function GetAllCommandsIDAndName():Promise<Array<main.Command>>;
+export function BulkUpdateCommands(arg1:main.BulkCommandRequest):Promise<main.Response>;
 
-export function GetAllTagsIDAndName():Promise<Array<main.Tag>>;
+export function ConfirmDangerousCommand(arg1:number,arg2:{[key: string]: string}):Promise<main.Response>;
 
-export function GetCollection(arg1:number):Promise<main.Collection>;
+export function CopyCommand(arg1:number,arg2:{[key: string]: string},arg3:string):Promise<main.Response>;
 
-export function GetCommand(arg1:number):Promise<main.Command>;
+export function CreateCollection(arg1:main.Collection):Promise<main.Response>;
 
-export function GetCommandsByCollectionID(arg1:main.Option):Promise<Array<main.Command>>;
+export function CreateCommand(arg1:main.Command):Promise<main.Response>;
 
-export function GetCommandsByTagId(arg1:main.Option):Promise<Array<main.Command>>;
+export function CreateTag(arg1:main.Tag):Promise<main.Response>;
 
-export function GetMenuItems():Promise<Record<string, any>>;
+export function DeleteCollection(arg1:number):Promise<main.Response>;
+
+export function DeleteCommand(arg1:number):Promise<main.Response>;
+
+export function DeleteSecret(arg1:string):Promise<main.Response>;
+
+export function DeleteTag(arg1:number):Promise<main.Response>;
+
+export function GetAllCollectionsIDAndName():Promise<main.Response>;
+
+export function GetAllCommandsIDAndName():Promise<main.Response>;
+
+export function GetAllTagsIDAndName():Promise<main.Response>;
+
+export function GetCollection(arg1:number):Promise<main.Response>;
+
+export function GetCommand(arg1:number):Promise<main.Response>;
+
+export function GetCommandsByCollectionID(arg1:main.Option):Promise<main.Response>;
+
+export function GetCommandsByTagId(arg1:main.Option):Promise<main.Response>;
+
+export function GetMenuItems():Promise<main.Response>;
 
 export function GetOptions(arg1:main.Option):Promise<main.Response>;
 
-export function GetStatus():Promise<main.Status>;
+export function GetSecrets():Promise<main.Response>;
+
+export function GetSettings():Promise<main.Response>;
+
+export function GetStatus():Promise<main.Response>;
+
+export function GetTag(arg1:number):Promise<main.Response>;
+
+export function GetVaultStatus():Promise<main.Response>;
+
+export function InitVault(arg1:string):Promise<main.Response>;
+
+export function LockVault():Promise<main.Response>;
+
+export function RunCommand(arg1:number,arg2:{[key: string]: string},arg3:string):Promise<main.Response>;
+
+export function SetSecret(arg1:string,arg2:string):Promise<main.Response>;
+
+export function SetVaultAutoLock(arg1:number):Promise<main.Response>;
+
+export function UnlockVault(arg1:string):Promise<main.Response>;
+
+export function UpdateCollection(arg1:main.Collection):Promise<main.Response>;
 
-export function GetTag(arg1:number):Promise<main.Tag>;
+export function UpdateCommand(arg1:main.Command):Promise<main.Response>;
 
-export function UpdateCollection(arg1:main.Collection):Promise<void>;
+export function PurgeTrash():Promise<main.Response>;
 
-export function UpdateCommand(arg1:main.Command):Promise<void>;
+export function UpdateSettings(arg1:main.Settings):Promise<main.Response>;
 
-export function UpdateTag(arg1:main.Tag):Promise<void>;
+export function UpdateTag(arg1:main.Tag):Promise<main.Response>;
@@ -2,6 +2,46 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function BulkAddCollections(arg1, arg2) {
+  return window['go']['main']['App']['BulkAddCollections'](arg1, arg2);
+}
+
+export function BulkAddTags(arg1, arg2) {
+  return window['go']['main']['App']['BulkAddTags'](arg1, arg2);
+}
+
+export function BulkDeleteCommands(arg1) {
+  return window['go']['main']['App']['BulkDeleteCommands'](arg1);
+}
+
+export function BulkRemoveCollections(arg1, arg2) {
+  return window['go']['main']['App']['BulkRemoveCollections'](arg1, arg2);
+}
+
+export function BulkRemoveTags(arg1, arg2) {
+  return window['go']['main']['App']['BulkRemoveTags'](arg1, arg2);
+}
+
+export function BulkRestoreCommands(arg1) {
+  return window['go']['main']['App']['BulkRestoreCommands'](arg1);
+}
+
+export function BulkSetOs(arg1, arg2) {
+  return window['go']['main']['App']['BulkSetOs'](arg1, arg2);
+}
+
+export function BulkUpdateCommands(arg1) {
+  return window['go']['main']['App']['BulkUpdateCommands'](arg1);
+}
+
+export function ConfirmDangerousCommand(arg1, arg2) {
+  return window['go']['main']['App']['ConfirmDangerousCommand'](arg1, arg2);
+}
+
+export function CopyCommand(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CopyCommand'](arg1, arg2, arg3);
+}
+
 export function CreateCollection(arg1) {
   return window['go']['main']['App']['CreateCollection'](arg1);
 }
@@ -22,6 +62,10 @@ export function DeleteCommand(arg1) {
   return window['go']['main']['App']['DeleteCommand'](arg1);
 }
 
+export function DeleteSecret(arg1) {
+  return window['go']['main']['App']['DeleteSecret'](arg1);
+}
+
 export function DeleteTag(arg1) {
   return window['go']['main']['App']['DeleteTag'](arg1);
 }
@@ -62,6 +106,14 @@ export function GetOptions(arg1) {
   return window['go']['main']['App']['GetOptions'](arg1);
 }
 
+export function GetSecrets() {
+  return window['go']['main']['App']['GetSecrets']();
+}
+
+export function GetSettings() {
+  return window['go']['main']['App']['GetSettings']();
+}
+
 export function GetStatus() {
   return window['go']['main']['App']['GetStatus']();
 }
@@ -70,6 +122,34 @@ export function GetTag(arg1) {
   return window['go']['main']['App']['GetTag'](arg1);
 }
 
+export function GetVaultStatus() {
+  return window['go']['main']['App']['GetVaultStatus']();
+}
+
+export function InitVault(arg1) {
+  return window['go']['main']['App']['InitVault'](arg1);
+}
+
+export function LockVault() {
+  return window['go']['main']['App']['LockVault']();
+}
+
+export function RunCommand(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RunCommand'](arg1, arg2, arg3);
+}
+
+export function SetSecret(arg1, arg2) {
+  return window['go']['main']['App']['SetSecret'](arg1, arg2);
+}
+
+export function SetVaultAutoLock(arg1) {
+  return window['go']['main']['App']['SetVaultAutoLock'](arg1);
+}
+
+export function UnlockVault(arg1) {
+  return window['go']['main']['App']['UnlockVault'](arg1);
+}
+
 export function UpdateCollection(arg1) {
   return window['go']['main']['App']['UpdateCollection'](arg1);
 }
@@ -78,6 +158,14 @@ export function UpdateCommand(arg1) {
   return window['go']['main']['App']['UpdateCommand'](arg1);
 }
 
+export function PurgeTrash() {
+  return window['go']['main']['App']['PurgeTrash']();
+}
+
+export function UpdateSettings(arg1) {
+  return window['go']['main']['App']['UpdateSettings'](arg1);
+}
+
 export function UpdateTag(arg1) {
   return window['go']['main']['App']['UpdateTag'](arg1);
 }
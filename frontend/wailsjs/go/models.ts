@@ -133,6 +133,8 @@ export namespace main {
 	export class Response {
 	    code: number;
 	    msg: string;
+	    field?: string;
+	    detail?: string;
 	    data: any;
 	
 	    static createFrom(source: any = {}) {
@@ -143,6 +145,8 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.code = source["code"];
 	        this.msg = source["msg"];
+	        this.field = source["field"];
+	        this.detail = source["detail"];
 	        this.data = source["data"];
 	    }
 	}
@@ -199,6 +203,84 @@ export namespace main {
 		    return a;
 		}
 	}
+	export class APIServerSettings {
+	    enabled: boolean;
+	    address: string;
+	    readOnly: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new APIServerSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.address = source["address"];
+	        this.readOnly = source["readOnly"];
+	    }
+	}
+	export class LogSettings {
+	    level: string;
+	    output: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.level = source["level"];
+	        this.output = source["output"];
+	    }
+	}
+	export class Settings {
+	    os: string[];
+	    sort: SortIndex;
+	    defaultShell: string;
+	    theme: string;
+	    locale: string;
+	    dbProfile: string;
+	    typedCommandWindowDays: number;
+	    trashRetentionDays: number;
+	    apiServer: APIServerSettings;
+	    log: LogSettings;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.os = source["os"];
+	        this.sort = this.convertValues(source["sort"], SortIndex);
+	        this.defaultShell = source["defaultShell"];
+	        this.theme = source["theme"];
+	        this.locale = source["locale"];
+	        this.dbProfile = source["dbProfile"];
+	        this.typedCommandWindowDays = source["typedCommandWindowDays"];
+	        this.trashRetentionDays = source["trashRetentionDays"];
+	        this.apiServer = this.convertValues(source["apiServer"], APIServerSettings);
+	        this.log = this.convertValues(source["log"], LogSettings);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Tag {
 	    id: number;
 	    name: string;
@@ -247,6 +329,188 @@ export namespace main {
 		    return a;
 		}
 	}
+	export class RunResult {
+	    content: string;
+	    shell: string;
+	    output: string;
+	    exitCode: number;
+	    durationMs: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RunResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.content = source["content"];
+	        this.shell = source["shell"];
+	        this.output = source["output"];
+	        this.exitCode = source["exitCode"];
+	        this.durationMs = source["durationMs"];
+	    }
+	}
+	export class RiskFinding {
+	    ruleId: string;
+	    severity: string;
+	    explanation: string;
+	    segment: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RiskFinding(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ruleId = source["ruleId"];
+	        this.severity = source["severity"];
+	        this.explanation = source["explanation"];
+	        this.segment = source["segment"];
+	    }
+	}
+	export class RiskConfirmation {
+	    token: string;
+	    content: string;
+	    level: string;
+	    findings: RiskFinding[];
+	    expiresAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RiskConfirmation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.token = source["token"];
+	        this.content = source["content"];
+	        this.level = source["level"];
+	        this.findings = this.convertValues(source["findings"], RiskFinding);
+	        this.expiresAt = source["expiresAt"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BulkCommandRequest {
+	    action: string;
+	    commandIds: number[];
+	    tagIds?: number[];
+	    collectionIds?: number[];
+	    os?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new BulkCommandRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.commandIds = source["commandIds"];
+	        this.tagIds = source["tagIds"];
+	        this.collectionIds = source["collectionIds"];
+	        this.os = source["os"];
+	    }
+	}
+	export class BulkItemResult {
+	    commandId: number;
+	    ok: boolean;
+	    errorKey?: string;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BulkItemResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.commandId = source["commandId"];
+	        this.ok = source["ok"];
+	        this.errorKey = source["errorKey"];
+	        this.error = source["error"];
+	    }
+	}
+	export class BulkResult {
+	    succeeded: number;
+	    failed: number;
+	    items: BulkItemResult[];
+	
+	    static createFrom(source: any = {}) {
+	        return new BulkResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.succeeded = source["succeeded"];
+	        this.failed = source["failed"];
+	        this.items = this.convertValues(source["items"], BulkItemResult);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class VaultStatus {
+	    initialized: boolean;
+	    unlocked: boolean;
+	    autoLockMinutes: number;
+	    secretCount: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VaultStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.initialized = source["initialized"];
+	        this.unlocked = source["unlocked"];
+	        this.autoLockMinutes = source["autoLockMinutes"];
+	        this.secretCount = source["secretCount"];
+	    }
+	}
+	export class SecretInfo {
+	    name: string;
+	    createdAt: string;
+	    updatedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SecretInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.createdAt = source["createdAt"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	}
 
 }
 
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hookSocketFile = "hook.sock"
+	// typedCommandMinCount 输入次数达到该值后才提示保存
+	typedCommandMinCount = 5
+	// typedCommandMinLength 过短的命令（ls、cd等）没有保存价值，不做统计
+	typedCommandMinLength = 4
+)
+
+// typedCommandReport shell钩子通过套接字上报的一条命令
+type typedCommandReport struct {
+	Line string `json:"line"`
+}
+
+// hookSocketPath 返回shell钩子与应用通信的本地套接字路径
+func hookSocketPath() (string, error) {
+	dir, err := quickcmdConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hookSocketFile), nil
+}
+
+// HookScript 生成指定shell的preexec钩子脚本，每执行一条命令就调用 quickcmd report 上报给正在运行的应用
+func HookScript(shell string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("获取程序路径失败: %w", err)
+	}
+
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(`# %[2]s
+__quickcmd_report() {
+    ( %[1]s report -- "$1" >/dev/null 2>&1 & )
+}
+if [ -n "${bash_preexec_imported:-}" ]; then
+    # 已加载bash-preexec时直接注册preexec
+    preexec_functions+=(__quickcmd_report)
+else
+    # bash没有原生的preexec，退而在每次提示符出现前读取最近一条历史
+    __quickcmd_last_histno=
+    __quickcmd_prompt() {
+        local entry histno line
+        entry=$(HISTTIMEFORMAT= builtin history 1)
+        histno=${entry%%%%[^0-9 ]*}
+        histno=${histno// /}
+        line=${entry#*[0-9]  }
+        if [ -n "$histno" ] && [ "$histno" != "$__quickcmd_last_histno" ]; then
+            [ -n "$__quickcmd_last_histno" ] && __quickcmd_report "$line"
+            __quickcmd_last_histno=$histno
+        fi
+    }
+    PROMPT_COMMAND="__quickcmd_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, posixSingleQuote(exe), hookHeader(exe, "bash", "~/.bashrc")), nil
+	case "zsh":
+		return fmt.Sprintf(`# %[2]s
+__quickcmd_preexec() {
+    ( %[1]s report -- "$1" &>/dev/null & )
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec __quickcmd_preexec
+`, posixSingleQuote(exe), hookHeader(exe, "zsh", "~/.zshrc")), nil
+	case "fish":
+		return fmt.Sprintf(`# %[2]s
+function __quickcmd_preexec --on-event fish_preexec
+    %[1]s report -- $argv[1] >/dev/null 2>&1 &
+    disown 2>/dev/null
+end
+`, posixSingleQuote(exe), hookHeader(exe, "fish", "~/.config/fish/config.fish")), nil
+	default:
+		return "", NewValidationError("shell", "hook.unsupported_shell", shell)
+	}
+}
+
+// hookHeader 钩子脚本第一行的安装说明，使用当前语言
+func hookHeader(exe, shell, profile string) string {
+	line := fmt.Sprintf(`eval "$(%s hook %s)"`, posixSingleQuote(exe), shell)
+	if shell == "fish" {
+		line = fmt.Sprintf("%s hook fish | source", posixSingleQuote(exe))
+	}
+	return localize("hook.header", profile, line)
+}
+
+// ReportTypedCommand 将一条命令上报给正在运行的应用，应用未启动时直接返回错误
+func ReportTypedCommand(line string) error {
+	path, err := hookSocketPath()
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("unix", path, 200*time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("连接quickcmd失败: %w", err)
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(200 * time.Millisecond))
+	return json.NewEncoder(conn).Encode(typedCommandReport{Line: line})
+}
+
+// HookServer 监听本地套接字，接收shell钩子上报的命令并计入频次表
+type HookServer struct {
+	ctx      context.Context // 记录上报命令使用的context
+	listener net.Listener
+	wg       sync.WaitGroup
+	// skip 返回true的命令不记录，用于过滤包含密钥明文的命令
+	skip func(line string) bool
+}
+
+// StartHookServer 在配置目录下创建套接字并开始接收上报，skip返回true的命令不记录
+func StartHookServer(ctx context.Context, skip func(line string) bool) (*HookServer, error) {
+	path, err := hookSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	// 上次异常退出可能遗留套接字文件，能连通说明已有实例在监听
+	if conn, err := net.DialTimeout("unix", path, 200*time.Millisecond); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("已有quickcmd实例在监听%s", path)
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听shell钩子套接字失败: %w", err)
+	}
+	s := &HookServer{ctx: ctx, listener: listener, skip: skip}
+	s.wg.Add(1)
+	go s.serve()
+	slog.Info("shell钩子套接字已启动", "path", path)
+	return s, nil
+}
+
+func (s *HookServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("接收shell钩子连接失败", "err", err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *HookServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var report typedCommandReport
+		if err := decoder.Decode(&report); err != nil {
+			return
+		}
+		line, ok := normalizeTypedCommand(report.Line)
+		if !ok || (s.skip != nil && s.skip(line)) {
+			continue
+		}
+		if err := RecordTypedCommandSQLite(s.ctx, line, time.Now(), currentSettings().TypedCommandWindowDays); err != nil {
+			slog.Warn("记录输入命令失败", "err", err)
+		}
+	}
+}
+
+// Close 停止监听并等待正在处理的连接结束
+func (s *HookServer) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// normalizeTypedCommand 整理上报的命令，过滤掉空命令、过短的命令、以空格开头（不记入历史）的命令和quickcmd自身的调用
+func normalizeTypedCommand(line string) (string, bool) {
+	if strings.HasPrefix(line, " ") {
+		return "", false
+	}
+	line = strings.TrimSpace(line)
+	if len(line) < typedCommandMinLength {
+		return "", false
+	}
+	if line == "quickcmd" || strings.HasPrefix(line, "quickcmd ") {
+		return "", false
+	}
+	return line, true
+}
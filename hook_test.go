@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookScript(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{posixSingleQuote(exe) + ` report -- "$1"`, "preexec_functions+=(__quickcmd_report)", "PROMPT_COMMAND="}},
+		{"zsh", []string{posixSingleQuote(exe) + ` report -- "$1"`, "add-zsh-hook preexec __quickcmd_preexec"}},
+		{"fish", []string{posixSingleQuote(exe) + " report -- $argv[1]", "--on-event fish_preexec"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			script, err := HookScript(tt.shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(script, "# ") {
+				t.Errorf("HookScript(%s) has no header comment:\n%s", tt.shell, script)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("HookScript(%s) missing %q in:\n%s", tt.shell, want, script)
+				}
+			}
+			// 本机装有对应的shell时检查脚本语法
+			if shell, err := exec.LookPath(tt.shell); err == nil {
+				if out, err := exec.Command(shell, "-n", "-c", script).CombinedOutput(); err != nil {
+					t.Errorf("%s -n: %v\n%s", tt.shell, err, out)
+				}
+			}
+		})
+	}
+
+	if _, err = HookScript("tcsh"); errorCode(err) != CodeValidation {
+		t.Errorf("HookScript(tcsh) error = %v", err)
+	}
+}
+
+func TestNormalizeTypedCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{"kubectl get pods", "kubectl get pods", true},
+		{"git status  \n", "git status", true},
+		{" export TOKEN=abc", "", false}, // 以空格开头的命令不记入历史
+		{"ls", "", false},
+		{"   ", "", false},
+		{"quickcmd", "", false},
+		{"quickcmd report -- ls", "", false},
+		{"quickcmdx --help", "quickcmdx --help", true},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeTypedCommand(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeTypedCommand(%q) = %q, %v, want %q, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHookServerFiltersSecrets(t *testing.T) {
+	lines := []string{
+		"mysql -u root --password=Sup3rS3cret db",
+		"psql postgres://admin:hunter22@db:5432/app",
+		"export API_TOKEN=abc123def456",
+		"deploy --db horse-battery-staple",
+		"terraform plan -out tf.plan",
+	}
+	tests := []struct {
+		name   string
+		unlock bool
+		want   []string
+	}{
+		// 未解锁时只能按模式和熵检查，密钥库中的密钥明文看起来不像密钥
+		{"locked", false, []string{"deploy --db horse-battery-staple", "terraform plan -out tf.plan"}},
+		// 解锁后同时比对密钥明文，模式检查仍然有效
+		{"unlocked", true, []string{"terraform plan -out tf.plan"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFixtureDB(t, "store.yaml")
+			t.Setenv("HOME", t.TempDir())
+			ctx := context.Background()
+			app := NewApp()
+			if tt.unlock {
+				if resp := app.InitVault("correct horse"); resp.Code != CodeOK {
+					t.Fatalf("InitVault() = %+v", resp)
+				}
+				if resp := app.SetSecret("prod_db", "horse-battery-staple"); resp.Code != CodeOK {
+					t.Fatalf("SetSecret() = %+v", resp)
+				}
+			}
+			s, err := StartHookServer(ctx, app.containsSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			path, err := hookSocketPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := net.Dial("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 同一连接上的命令按顺序处理，最后一条出现说明前面的都已处理完
+			for range typedCommandMinCount {
+				for _, line := range lines {
+					if err := json.NewEncoder(conn).Encode(typedCommandReport{Line: line}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			conn.Close()
+
+			var got []string
+			for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				suggestions, err := GetTypedCommandSuggestionsSQLite(ctx, time.Now().AddDate(0, 0, -1), typedCommandMinCount)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = got[:0]
+				for _, sg := range suggestions {
+					got = append(got, sg.Line)
+				}
+				if slices.Contains(got, lines[len(lines)-1]) {
+					break
+				}
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recorded typed commands = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// 支持的界面语言
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEN   = "en"
+	// defaultLocale 无法识别系统语言时使用
+	defaultLocale = LocaleZhCN
+)
+
+// settingLocale 设置表中保存用户选择的语言的键，值为空表示跟随系统
+const settingLocale = "locale"
+
+// messageCatalogs 各语言的消息目录，同一个键在所有语言中的参数顺序必须一致
+var messageCatalogs = map[string]map[string]string{
+	LocaleZhCN: messagesZhCN,
+	LocaleEN:   messagesEN,
+}
+
+// messageRef 作为localize的参数时，先按当前语言翻译再代入，例如实体名称
+type messageRef string
+
+var (
+	localeMu       sync.RWMutex
+	localeOverride string // 用户选择的语言，为空时跟随系统
+
+	systemLocaleOnce  sync.Once
+	systemLocaleValue string
+)
+
+// LocaleInfo 当前语言设置
+type LocaleInfo struct {
+	Locale    string   `json:"locale"`    // 当前生效的语言
+	Override  string   `json:"override"`  // 用户选择的语言，为空表示跟随系统
+	System    string   `json:"system"`    // 系统语言
+	Available []string `json:"available"` // 可选的语言
+}
+
+// localize 按当前语言取出key对应的消息并代入参数，当前语言缺少该键时依次回退到默认语言和键本身
+func localize(key string, args ...interface{}) string {
+	return localizeIn(currentLocale(), key, args...)
+}
+
+func localizeIn(locale, key string, args ...interface{}) string {
+	template, ok := messageCatalogs[locale][key]
+	if !ok {
+		if template, ok = messageCatalogs[defaultLocale][key]; !ok {
+			template = key
+		}
+	}
+	if len(args) == 0 {
+		return template
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if ref, ok := arg.(messageRef); ok {
+			values[i] = localizeIn(locale, string(ref))
+		} else {
+			values[i] = arg
+		}
+	}
+	return fmt.Sprintf(template, values...)
+}
+
+// currentLocale 用户选择的语言优先，否则使用系统语言
+func currentLocale() string {
+	localeMu.RLock()
+	override := localeOverride
+	localeMu.RUnlock()
+	if override != "" {
+		return override
+	}
+	return systemLocale()
+}
+
+// setLocaleOverride 设置用户选择的语言，为空表示跟随系统
+func setLocaleOverride(locale string) {
+	localeMu.Lock()
+	defer localeMu.Unlock()
+	localeOverride = locale
+}
+
+// normalizeLocale 将zh_CN.UTF-8、en-US等写法归一为支持的语言，不支持时返回false
+func normalizeLocale(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if i := strings.IndexAny(value, ".@"); i >= 0 {
+		value = value[:i]
+	}
+	switch {
+	case value == "zh" || strings.HasPrefix(value, "zh-") || strings.HasPrefix(value, "zh_"):
+		return LocaleZhCN, true
+	case value == "en" || strings.HasPrefix(value, "en-") || strings.HasPrefix(value, "en_"):
+		return LocaleEN, true
+	}
+	return "", false
+}
+
+// systemLocale 依次读取LC_ALL、LC_MESSAGES、LANG，桌面环境没有设置时询问系统，结果只计算一次
+func systemLocale() string {
+	systemLocaleOnce.Do(func() {
+		systemLocaleValue = defaultLocale
+		for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if value := os.Getenv(name); value != "" && value != "C" && value != "POSIX" {
+				if locale, ok := normalizeLocale(value); ok {
+					systemLocaleValue = locale
+				}
+				return
+			}
+		}
+		if locale, ok := normalizeLocale(queryPlatformLocale()); ok {
+			systemLocaleValue = locale
+		}
+	})
+	return systemLocaleValue
+}
+
+// queryPlatformLocale 从macOS和Windows的系统设置中读取语言，从Finder或开始菜单启动时没有LANG
+func queryPlatformLocale() string {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("defaults", "read", "-g", "AppleLocale")
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", "(Get-Culture).Name")
+	default:
+		return ""
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		slog.Warn("读取系统语言失败", "err", err)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// availableLocales 按固定顺序返回支持的语言
+func availableLocales() []string {
+	return []string{LocaleZhCN, LocaleEN}
+}
@@ -0,0 +1,188 @@
+package main
+
+// messagesEN 英文消息目录
+var messagesEN = map[string]string{
+	"alias.in_use":  "Alias [%s] is already used by another command",
+	"alias.invalid": "Alias [%s] is invalid; only letters, digits, underscores and hyphens are allowed",
+
+	"audit.invalid_action":      "Unsupported audit action: %s",
+	"audit.invalid_date":        "Date [%s] is invalid; expected YYYY-MM-DD",
+	"audit.invalid_entity_type": "Unsupported audit entity type: %s",
+	"audit.invalid_format":      "Unsupported export format: %s; expected jsonl or csv",
+	"audit.invalid_source":      "Unsupported change source: %s",
+
+	"bulk.already_deleted":  "The command has been deleted",
+	"bulk.invalid_action":   "Unsupported bulk action: %s",
+	"bulk.not_deleted":      "The command is not deleted",
+	"bulk.request_required": "Bulk request is required",
+
+	"collection.id_required":   "Collection ID is required",
+	"collection.name_required": "Collection name is required",
+	"collection.not_found":     "Collection not found: %d",
+
+	"command.exists":         "Command [%s] already exists",
+	"command.id_required":    "Command ID is required",
+	"command.not_found":      "Command not found: %d",
+	"command.required":       "Command is required",
+	"command.some_not_found": "Some commands do not exist or have been deleted",
+
+	"duplicate.invalid_threshold": "Similarity threshold must be between 0 and 1",
+
+	"entity.collections": "Collection",
+	"entity.commands":    "Command",
+	"entity.tags":        "Tag",
+
+	"error.canceled":  "The request was canceled",
+	"error.conflict":  "Conflicts with an existing record",
+	"error.internal":  "Internal error; see the log for details",
+	"error.not_found": "The record does not exist or has been deleted",
+
+	"export.generated":    "Generated by quickcmd. Do not edit.",
+	"export.install_hint": "Add this to %s: %s",
+
+	"hook.header":            "quickcmd shell hook; add this to %s: %s",
+	"hook.unsupported_shell": "Unsupported shell: %s; expected bash, zsh or fish",
+
+	"list.separator": "; ",
+
+	"locale.unsupported": "Unsupported locale: %s",
+
+	"merge.nothing_to_merge":  "There are no commands to merge",
+	"merge.survivor_required": "The ID of the command to keep is required",
+
+	"os.invalid": "Unsupported operating system [%s]; expected %s, %s, %s or %s",
+
+	"option.invalid_type": "Unsupported type: %s; expected commands, tags or collections",
+
+	"order.duplicate_id":   "ID %d appears more than once",
+	"order.id_required":    "ID is required",
+	"order.item_not_found": "%s not found: %d",
+	"order.some_not_found": "Some items do not exist or have been deleted",
+
+	"page.cursor_mismatch": "The page cursor does not match the current sort; reload from the first page",
+	"page.invalid_cursor":  "Invalid page cursor",
+
+	"relation.ids_not_found": "%s not found: %s",
+
+	"risk.confirm_required":               "Command %d has %s risk and must be confirmed before continuing: %s",
+	"risk.rule.chmod-chown-root":          "Recursively changes permissions or ownership of everything under /",
+	"risk.rule.dd-device":                 "Writes directly to a device and overwrites data on the disk",
+	"risk.rule.docker-prune":              "Removes unused containers, images or volumes",
+	"risk.rule.git-clean":                 "Deletes untracked files",
+	"risk.rule.git-push-force":            "Force-pushing overwrites commits on the remote branch",
+	"risk.rule.git-reset-hard":            "Discards uncommitted changes in the working tree and index",
+	"risk.rule.helm-uninstall":            "Uninstalls a Helm release and its resources",
+	"risk.rule.kubectl-delete":            "Deletes Kubernetes resources",
+	"risk.rule.kubectl-delete-namespace":  "Deleting a namespace deletes every resource in it",
+	"risk.rule.mkfs":                      "Formats or wipes a file system",
+	"risk.rule.powershell-remove-recurse": "Recursively deletes files and directories; this cannot be undone",
+	"risk.rule.redirect-device":           "Redirects output to a disk device and overwrites data on the disk",
+	"risk.rule.rm-recursive":              "Recursively deletes files and directories; this cannot be undone",
+	"risk.rule.rm-root":                   "Deletes everything under the root, home or current directory",
+	"risk.rule.shutdown":                  "Shuts down or restarts the system",
+	"risk.rule.sql-delete-all":            "DELETE without a WHERE clause removes every row in the table",
+	"risk.rule.sql-drop":                  "Drops or truncates database tables",
+	"risk.rule.terraform-destroy":         "Destroys all infrastructure managed by Terraform",
+	"risk.rule.windows-format":            "Formats a disk partition",
+
+	"risk_rule.empty":            "Risk rule [%s] needs a program or a pattern",
+	"risk_rule.id_required":      "Risk rule ID is required",
+	"risk_rule.invalid_pattern":  "Risk rule [%s] has an invalid pattern regex: %v",
+	"risk_rule.invalid_program":  "Risk rule [%s] has an invalid program regex: %v",
+	"risk_rule.invalid_severity": "Risk rule [%s] has an invalid severity [%s]",
+
+	"runbook.command_not_in_collection": "Command %d is not in the collection",
+	"runbook.invalid_status":            "Step status [%s] is invalid; expected pending, done or skipped",
+	"runbook.invalid_step_order":        "Command %d is not in the collection or appears more than once",
+	"runbook.name_required":             "Run name is required",
+	"runbook.no_steps":                  "The collection has no steps",
+	"runbook.run_exists":                "Run [%s] already exists; resume it or use another name",
+	"runbook.run_id_required":           "Run ID is required",
+	"runbook.run_name_not_found":        "Run [%s] not found",
+	"runbook.run_not_found":             "Run not found: %d",
+	"runbook.step_count_mismatch":       "Step count mismatch: the collection has %d steps but %d were submitted",
+	"runbook.step_ids_required":         "Collection ID and command ID are required",
+	"runbook.step_not_found":            "Run %d has no step %d",
+	"runbook.step_not_in_collection":    "Command %d is not in collection %d",
+	"runbook.step_required":             "Step %d is required and cannot be skipped",
+
+	"search.error":               "Search syntax error at character %d: %s",
+	"search.extra_rparen":        "Unexpected closing parenthesis",
+	"search.invalid_count":       "Copy count [%s] must be an integer",
+	"search.invalid_date":        "Date [%s] must be in YYYY-MM-DD format",
+	"search.missing_term":        "Missing search term",
+	"search.missing_term_before": "Missing search term before %s",
+	"search.missing_value":       "%s: missing value",
+	"search.unclosed_paren":      "Unclosed parenthesis",
+	"search.unclosed_quote":      "Unclosed quote",
+
+	"secret.exists":                   "Secret [%s] already exists; choose another name",
+	"secret.invalid_name":             "Secret name [%s] is invalid; only letters, digits, underscores, dots and hyphens are allowed",
+	"secret.kind.aws_access_key":      "AWS access key ID",
+	"secret.kind.aws_secret_key":      "AWS secret access key",
+	"secret.kind.bearer_token":        "Bearer token",
+	"secret.kind.github_token":        "GitHub token",
+	"secret.kind.gitlab_token":        "GitLab token",
+	"secret.kind.high_entropy":        "High-entropy string; possibly a key or token",
+	"secret.kind.jwt":                 "JWT",
+	"secret.kind.openai_key":          "API key",
+	"secret.kind.password_assignment": "Value assigned to a password or token",
+	"secret.kind.password_flag":       "Password or token passed as a flag",
+	"secret.kind.private_key":         "Private key",
+	"secret.kind.slack_token":         "Slack token",
+	"secret.kind.stripe_key":          "Stripe key",
+	"secret.kind.url_password":        "Password in URL",
+	"secret.not_found":                "Secret not found: %s",
+	"secret.value_required":           "Secret value is required",
+
+	"secret_rewrite.invalid_mode":     "Unsupported rewrite mode: %s",
+	"secret_rewrite.invalid_span":     "Rewrite span [%d, %d) is invalid or overlaps another",
+	"secret_rewrite.invalid_variable": "Template variable name [%s] is invalid",
+	"secret_rewrite.name_reused":      "Secret name [%s] is used for different values",
+
+	"settings.invalid_api_address":    "API server address [%s] is invalid; expected host:port",
+	"settings.invalid_db_profile":     "Database profile [%s] is invalid; only letters, digits, underscores and hyphens are allowed",
+	"settings.invalid_log_level":      "Unsupported log level: %s; use debug, info, warn or error",
+	"settings.invalid_log_output":     "Unsupported log output: %s; use file, stderr or both",
+	"settings.invalid_retention_days": "Trash retention must be between 0 and %d days; 0 keeps deleted items forever",
+	"settings.invalid_shell":          "Unsupported shell: %s",
+	"settings.invalid_theme":          "Unsupported theme: %s",
+	"settings.invalid_window_days":    "The typed command window must be between %d and %d days",
+
+	"sort.invalid_direction": "Sort direction [%s] is invalid; expected asc or desc",
+
+	"suggestion.typed_command": "In the last %d days you have typed this command %d times. Save it as a command?",
+
+	"syntax.error":               "The command content has syntax errors",
+	"syntax.error_at":            "Command content line %d, column %d: %s",
+	"syntax.incomplete":          "Incomplete input: %s",
+	"syntax.parse_error":         "Syntax error: %s",
+	"syntax.unsupported_feature": "%s is not available in %s",
+
+	"tag.exists":           "Tag [%s] already exists",
+	"tag.id_required":      "Tag ID is required",
+	"tag.merge_into_child": "A tag cannot be merged into one of its children",
+	"tag.merge_into_self":  "A tag cannot be merged into itself",
+	"tag.merge_not_found":  "The tags to merge do not exist",
+	"tag.move_into_self":   "A tag cannot be moved under itself or one of its children",
+	"tag.name_required":    "Tag name is required",
+	"tag.not_found":        "Tag not found: %d",
+	"tag.parent_not_found": "Parent tag not found: %d",
+
+	"template.missing_values": "Missing values for template variables: %s",
+	"template.unsafe_value":   "The value of [%s] contains characters that cmd cannot receive safely",
+
+	"typed_command.required": "Command line is required",
+
+	"variant.content_required": "Content of the %s variant is required",
+	"variant.duplicate":        "Duplicate variant for OS [%s] shell [%s]",
+	"variant.invalid_os":       "Variant OS [%s] is invalid; expected %s, %s or %s",
+	"variant.invalid_shell":    "Variant shell [%s] is invalid",
+
+	"vault.already_initialized":  "The vault is already initialized",
+	"vault.invalid_auto_lock":    "Auto-lock minutes cannot be negative",
+	"vault.locked":               "The vault is locked; unlock it first",
+	"vault.not_initialized":      "The vault has not been initialized",
+	"vault.passphrase_too_short": "The master passphrase must be at least %d characters",
+	"vault.wrong_passphrase":     "Incorrect master passphrase",
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// withLocale 在测试期间使用指定的语言，结束后恢复
+func withLocale(t *testing.T, locale string) {
+	t.Helper()
+	localeMu.RLock()
+	old := localeOverride
+	localeMu.RUnlock()
+	setLocaleOverride(locale)
+	t.Cleanup(func() { setLocaleOverride(old) })
+}
+
+var formatVerbPattern = regexp.MustCompile(`%[-+# 0]*\d*(?:\.\d+)?[a-zA-Z%]`)
+
+func TestMessageCatalogsComplete(t *testing.T) {
+	base := messageCatalogs[defaultLocale]
+	for locale, catalog := range messageCatalogs {
+		for key, template := range base {
+			translated, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing key %s", locale, key)
+				continue
+			}
+			want := formatVerbPattern.FindAllString(template, -1)
+			got := formatVerbPattern.FindAllString(translated, -1)
+			sort.Strings(want)
+			sort.Strings(got)
+			if len(want) != len(got) {
+				t.Errorf("%s: %s has verbs %v, want %v", locale, key, got, want)
+			}
+		}
+		for key := range catalog {
+			if _, ok := base[key]; !ok {
+				t.Errorf("%s: key %s not in %s", locale, key, defaultLocale)
+			}
+		}
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"zh_CN.UTF-8", LocaleZhCN, true},
+		{"zh-TW", LocaleZhCN, true},
+		{"en_US.UTF-8", LocaleEN, true},
+		{"EN", LocaleEN, true},
+		{"en-GB@euro", LocaleEN, true},
+		{"de_DE.UTF-8", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeLocale(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeLocale(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	withLocale(t, LocaleEN)
+	if got := localize("command.not_found", 7); got != "Command not found: 7" {
+		t.Errorf("localize = %q", got)
+	}
+	if got := localize("relation.ids_not_found", messageRef("entity.tags"), "3, 5"); got != "Tag not found: 3, 5" {
+		t.Errorf("localize with messageRef = %q", got)
+	}
+	if got := localize("no.such.key"); got != "no.such.key" {
+		t.Errorf("missing key = %q", got)
+	}
+
+	// AppError按读取时的语言生成消息
+	err := NewNotFoundError("tag.not_found", 3)
+	setLocaleOverride(LocaleZhCN)
+	if got := err.Error(); got != "标签不存在: 3" {
+		t.Errorf("zh-CN message = %q", got)
+	}
+}
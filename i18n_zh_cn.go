@@ -0,0 +1,188 @@
+package main
+
+// messagesZhCN 简体中文消息目录，也是缺少翻译时的回退
+var messagesZhCN = map[string]string{
+	"alias.in_use":  "别名[%s]已被其他命令使用",
+	"alias.invalid": "别名[%s]格式不正确，只能包含字母、数字、下划线和连字符",
+
+	"audit.invalid_action":      "不支持的审计操作类型: %s",
+	"audit.invalid_date":        "日期[%s]格式不正确，应为YYYY-MM-DD",
+	"audit.invalid_entity_type": "不支持的审计实体类型: %s",
+	"audit.invalid_format":      "不支持的导出格式: %s，可选jsonl或csv",
+	"audit.invalid_source":      "不支持的修改来源: %s",
+
+	"bulk.already_deleted":  "指令已删除",
+	"bulk.invalid_action":   "不支持的批量操作: %s",
+	"bulk.not_deleted":      "指令未被删除",
+	"bulk.request_required": "批量操作不能为空",
+
+	"collection.id_required":   "集合ID不能为空",
+	"collection.name_required": "集合名称不能为空",
+	"collection.not_found":     "集合不存在: %d",
+
+	"command.exists":         "指令[%s]已存在",
+	"command.id_required":    "指令ID不能为空",
+	"command.not_found":      "指令不存在: %d",
+	"command.required":       "指令不能为空",
+	"command.some_not_found": "部分指令不存在或已删除",
+
+	"duplicate.invalid_threshold": "相似度阈值必须在0到1之间",
+
+	"entity.collections": "集合",
+	"entity.commands":    "指令",
+	"entity.tags":        "标签",
+
+	"error.canceled":  "请求已取消",
+	"error.conflict":  "与已有记录冲突",
+	"error.internal":  "内部错误，请查看日志了解详情",
+	"error.not_found": "记录不存在或已删除",
+
+	"export.generated":    "由 quickcmd 自动生成，请勿手动修改",
+	"export.install_hint": "在 %s 中添加: %s",
+
+	"hook.header":            "quickcmd shell钩子，在 %s 中添加: %s",
+	"hook.unsupported_shell": "不支持的shell: %s，可选值为 bash、zsh、fish",
+
+	"list.separator": "；",
+
+	"locale.unsupported": "不支持的语言: %s",
+
+	"merge.nothing_to_merge":  "没有需要合并的指令",
+	"merge.survivor_required": "保留的指令ID不能为空",
+
+	"os.invalid": "不支持的操作系统[%s]，可选值为 %s、%s、%s、%s",
+
+	"option.invalid_type": "不支持的类型: %s，可选值为 commands、tags、collections",
+
+	"order.duplicate_id":   "ID %d 重复出现",
+	"order.id_required":    "ID不能为空",
+	"order.item_not_found": "%s不存在: %d",
+	"order.some_not_found": "部分记录不存在或已删除",
+
+	"page.cursor_mismatch": "分页游标与当前排序不一致，请从第一页重新加载",
+	"page.invalid_cursor":  "分页游标无效",
+
+	"relation.ids_not_found": "%s不存在: %s",
+
+	"risk.confirm_required":               "指令%d风险等级为%s，需要确认后才能继续: %s",
+	"risk.rule.chmod-chown-root":          "递归修改根目录下所有文件的权限或属主",
+	"risk.rule.dd-device":                 "直接写入设备，会覆盖磁盘上的数据",
+	"risk.rule.docker-prune":              "清理未使用的容器、镜像或数据卷",
+	"risk.rule.git-clean":                 "删除未被跟踪的文件",
+	"risk.rule.git-push-force":            "强制推送会覆盖远程分支上的提交",
+	"risk.rule.git-reset-hard":            "丢弃工作区和暂存区中未提交的修改",
+	"risk.rule.helm-uninstall":            "卸载Helm release及其资源",
+	"risk.rule.kubectl-delete":            "删除Kubernetes资源",
+	"risk.rule.kubectl-delete-namespace":  "删除命名空间会删除其中的所有资源",
+	"risk.rule.mkfs":                      "格式化或擦除文件系统",
+	"risk.rule.powershell-remove-recurse": "递归删除文件和目录，删除后无法恢复",
+	"risk.rule.redirect-device":           "重定向输出到磁盘设备，会覆盖磁盘上的数据",
+	"risk.rule.rm-recursive":              "递归删除文件和目录，删除后无法恢复",
+	"risk.rule.rm-root":                   "删除根目录、用户目录或当前目录下的所有文件",
+	"risk.rule.shutdown":                  "关闭或重启系统",
+	"risk.rule.sql-delete-all":            "没有WHERE条件的DELETE会删除表中所有数据",
+	"risk.rule.sql-drop":                  "删除或清空数据库表",
+	"risk.rule.terraform-destroy":         "销毁Terraform管理的所有基础设施",
+	"risk.rule.windows-format":            "格式化磁盘分区",
+
+	"risk_rule.empty":            "风险规则[%s]的program和pattern不能同时为空",
+	"risk_rule.id_required":      "风险规则ID不能为空",
+	"risk_rule.invalid_pattern":  "风险规则[%s]的pattern正则无效: %v",
+	"risk_rule.invalid_program":  "风险规则[%s]的program正则无效: %v",
+	"risk_rule.invalid_severity": "风险规则[%s]的等级[%s]无效",
+
+	"runbook.command_not_in_collection": "指令%d不在集合中",
+	"runbook.invalid_status":            "步骤状态[%s]无效，只能为pending、done或skipped",
+	"runbook.invalid_step_order":        "指令%d不在集合中或重复出现",
+	"runbook.name_required":             "运行名称不能为空",
+	"runbook.no_steps":                  "集合中没有任何步骤",
+	"runbook.run_exists":                "运行[%s]已存在，请继续该运行或使用其他名称",
+	"runbook.run_id_required":           "运行ID不能为空",
+	"runbook.run_name_not_found":        "运行[%s]不存在",
+	"runbook.run_not_found":             "运行不存在: %d",
+	"runbook.step_count_mismatch":       "步骤数量不一致：集合中有%d个步骤，提交了%d个",
+	"runbook.step_ids_required":         "集合ID和指令ID不能为空",
+	"runbook.step_not_found":            "运行%d中不存在第%d步",
+	"runbook.step_not_in_collection":    "指令%d不在集合%d中",
+	"runbook.step_required":             "第%d步是必需步骤，不能跳过",
+
+	"search.error":               "搜索语法错误（第%d个字符）: %s",
+	"search.extra_rparen":        "多余的右括号",
+	"search.invalid_count":       "复制次数[%s]必须是整数",
+	"search.invalid_date":        "日期[%s]格式应为YYYY-MM-DD",
+	"search.missing_term":        "缺少搜索条件",
+	"search.missing_term_before": "%s 前缺少搜索条件",
+	"search.missing_value":       "%s: 缺少值",
+	"search.unclosed_paren":      "括号未闭合",
+	"search.unclosed_quote":      "引号未闭合",
+
+	"secret.exists":                   "密钥[%s]已存在，请换一个名称",
+	"secret.invalid_name":             "密钥名[%s]格式不正确，只能包含字母、数字、下划线、点和连字符",
+	"secret.kind.aws_access_key":      "AWS访问密钥ID",
+	"secret.kind.aws_secret_key":      "AWS秘密访问密钥",
+	"secret.kind.bearer_token":        "Bearer令牌",
+	"secret.kind.github_token":        "GitHub令牌",
+	"secret.kind.gitlab_token":        "GitLab令牌",
+	"secret.kind.high_entropy":        "高熵字符串，可能是密钥或令牌",
+	"secret.kind.jwt":                 "JWT令牌",
+	"secret.kind.openai_key":          "API密钥",
+	"secret.kind.password_assignment": "赋值给密码或令牌的值",
+	"secret.kind.password_flag":       "命令行参数中的密码或令牌",
+	"secret.kind.private_key":         "私钥",
+	"secret.kind.slack_token":         "Slack令牌",
+	"secret.kind.stripe_key":          "Stripe密钥",
+	"secret.kind.url_password":        "URL中的密码",
+	"secret.not_found":                "密钥不存在: %s",
+	"secret.value_required":           "密钥值不能为空",
+
+	"secret_rewrite.invalid_mode":     "不支持的改写方式: %s",
+	"secret_rewrite.invalid_span":     "改写位置[%d, %d)无效或相互重叠",
+	"secret_rewrite.invalid_variable": "模板变量名[%s]格式不正确",
+	"secret_rewrite.name_reused":      "密钥名[%s]对应了不同的值",
+
+	"settings.invalid_api_address":    "API服务监听地址[%s]无效，格式应为host:port",
+	"settings.invalid_db_profile":     "数据库配置名[%s]格式不正确，只能包含字母、数字、下划线和连字符",
+	"settings.invalid_log_level":      "不支持的日志级别: %s，可选debug、info、warn或error",
+	"settings.invalid_log_output":     "不支持的日志输出位置: %s，可选file、stderr或both",
+	"settings.invalid_retention_days": "回收站保留天数必须在0到%d之间，0表示永久保留",
+	"settings.invalid_shell":          "不支持的shell: %s",
+	"settings.invalid_theme":          "不支持的主题: %s",
+	"settings.invalid_window_days":    "输入命令统计窗口必须在%d到%d天之间",
+
+	"sort.invalid_direction": "排序方向[%s]无效，只能为asc或desc",
+
+	"suggestion.typed_command": "最近%d天你已输入这条命令%d次，是否保存为指令？",
+
+	"syntax.error":               "指令内容存在语法错误",
+	"syntax.error_at":            "指令内容第%d行第%d列: %s",
+	"syntax.incomplete":          "内容不完整: %s",
+	"syntax.parse_error":         "语法错误: %s",
+	"syntax.unsupported_feature": "%s 在%s中不可用",
+
+	"tag.exists":           "标签[%s]已存在",
+	"tag.id_required":      "标签ID不能为空",
+	"tag.merge_into_child": "不能将标签合并到它自己的子标签中",
+	"tag.merge_into_self":  "不能将标签合并到自身",
+	"tag.merge_not_found":  "要合并的标签不存在",
+	"tag.move_into_self":   "不能将标签移动到它自己或它的子标签下",
+	"tag.name_required":    "标签名称不能为空",
+	"tag.not_found":        "标签不存在: %d",
+	"tag.parent_not_found": "父标签不存在: %d",
+
+	"template.missing_values": "缺少模板变量的值: %s",
+	"template.unsafe_value":   "模板变量[%s]的值包含cmd无法安全传递的字符",
+
+	"typed_command.required": "命令不能为空",
+
+	"variant.content_required": "%s变体的内容不能为空",
+	"variant.duplicate":        "OS[%s] shell[%s]的变体重复",
+	"variant.invalid_os":       "变体OS[%s]无效，可选值为 %s、%s、%s",
+	"variant.invalid_shell":    "变体shell[%s]无效",
+
+	"vault.already_initialized":  "密钥库已初始化",
+	"vault.invalid_auto_lock":    "自动锁定时间不能为负数",
+	"vault.locked":               "密钥库已锁定，请先解锁",
+	"vault.not_initialized":      "密钥库尚未初始化",
+	"vault.passphrase_too_short": "主密码至少需要%d个字符",
+	"vault.wrong_passphrase":     "主密码不正确",
+}
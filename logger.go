@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 日志级别
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// 日志输出位置
+const (
+	LogOutputFile   = "file"
+	LogOutputStderr = "stderr"
+	LogOutputBoth   = "both"
+)
+
+const (
+	// logDirName 配置目录下保存日志文件的目录
+	logDirName  = "logs"
+	logFileName = "quickcmd.log"
+	// 日志文件超过logMaxSizeMB后轮转，最多保留logMaxBackups个旧文件和logMaxAgeDays天
+	logMaxSizeMB   = 10
+	logMaxBackups  = 5
+	logMaxAgeDays  = 30
+	recentLogLimit = 1000
+	// redactedLogValue 被隐藏的日志字段显示的内容
+	redactedLogValue = "[REDACTED]"
+)
+
+// LogSettings 日志级别和输出位置
+type LogSettings struct {
+	Level  string `json:"level"`  // debug、info、warn或error，只有debug级别会记录指令内容和源码位置
+	Output string `json:"output"` // file、stderr或both，日志文件保存在配置目录的logs下并按大小轮转
+}
+
+var (
+	logLevel  = new(slog.LevelVar)
+	logOutput = &logWriter{out: os.Stderr}
+	// recentLogs 最近输出的日志行，用于诊断页面，不受输出位置影响
+	recentLogs = &logRing{lines: make([]string, recentLogLimit)}
+)
+
+// contentLogKeys 可能包含指令内容或用户输入的日志字段，非debug级别时隐藏
+var contentLogKeys = map[string]bool{
+	"content": true,
+	"line":    true,
+	"args":    true,
+	"query":   true,
+}
+
+// secretLogKeys 密钥相关的日志字段，任何级别都隐藏
+var secretLogKeys = map[string]bool{
+	"secret":     true,
+	"password":   true,
+	"ciphertext": true,
+}
+
+// initLogging 启动时将slog和标准库log的输出切换到带级别的日志，之后由设置中的LogSettings调整级别和输出位置。
+// 无法确定配置目录时只能输出到stderr
+func initLogging() {
+	if dir, err := quickcmdConfigDir(); err != nil {
+		fmt.Fprintf(os.Stderr, "无法创建日志文件，日志只输出到stderr: %v\n", err)
+	} else {
+		logOutput.setDir(filepath.Join(dir, logDirName))
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       logLevel,
+		ReplaceAttr: redactLogAttr,
+	})))
+	configureLogging(currentSettings().Log)
+}
+
+// configureLogging 应用日志设置，设置无效的部分使用默认值
+func configureLogging(s LogSettings) {
+	level, ok := parseLogLevel(s.Level)
+	if !ok {
+		level = slog.LevelInfo
+	}
+	logLevel.Set(level)
+	logOutput.setOutput(s.Output)
+}
+
+// parseLogLevel 将设置中的日志级别转换为slog.Level，为空表示info
+func parseLogLevel(level string) (slog.Level, bool) {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug, true
+	case LogLevelInfo, "":
+		return slog.LevelInfo, true
+	case LogLevelWarn:
+		return slog.LevelWarn, true
+	case LogLevelError:
+		return slog.LevelError, true
+	default:
+		return 0, false
+	}
+}
+
+// redactLogAttr 非debug级别时隐藏指令内容并去掉源码位置，密钥在任何级别都隐藏
+func redactLogAttr(groups []string, a slog.Attr) slog.Attr {
+	debug := logLevel.Level() <= slog.LevelDebug
+	switch {
+	case a.Key == slog.SourceKey && len(groups) == 0:
+		src, ok := a.Value.Any().(*slog.Source)
+		if !debug || !ok {
+			return slog.Attr{}
+		}
+		a.Value = slog.StringValue(fmt.Sprintf("%s:%d", filepath.Base(src.File), src.Line))
+	case secretLogKeys[a.Key], contentLogKeys[a.Key] && !debug:
+		a.Value = slog.StringValue(redactedLogValue)
+	}
+	return a
+}
+
+// logWriter 可切换输出位置的日志Writer，写入的每一行同时保存到最近日志中
+type logWriter struct {
+	mu   sync.Mutex
+	dir  string // 日志文件所在目录，为空时只能输出到stderr
+	file *lumberjack.Logger
+	out  io.Writer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	recentLogs.add(string(p))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.Write(p)
+}
+
+func (w *logWriter) setDir(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dir = dir
+}
+
+// setOutput 切换输出位置，为空表示输出到文件。不再写文件时关闭日志文件
+func (w *logWriter) setOutput(output string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dir == "" || output == LogOutputStderr {
+		w.closeFile()
+		w.out = os.Stderr
+		return
+	}
+	if w.file == nil {
+		w.file = &lumberjack.Logger{
+			Filename:   filepath.Join(w.dir, logFileName),
+			MaxSize:    logMaxSizeMB,
+			MaxBackups: logMaxBackups,
+			MaxAge:     logMaxAgeDays,
+			LocalTime:  true,
+		}
+	}
+	if output == LogOutputBoth {
+		w.out = io.MultiWriter(os.Stderr, w.file)
+	} else {
+		w.out = w.file
+	}
+}
+
+// filePath 返回当前写入的日志文件，不写文件时返回空
+func (w *logWriter) filePath() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return ""
+	}
+	return w.file.Filename
+}
+
+func (w *logWriter) closeFile() {
+	if w.file == nil {
+		return
+	}
+	if err := w.file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "关闭日志文件失败: %v\n", err)
+	}
+	w.file = nil
+}
+
+// logRing 保存最近recentLogLimit行日志的环形缓冲区
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	count int
+}
+
+// add 按行保存一次写入的日志
+func (r *logRing) add(text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		r.lines[r.next] = line
+		r.next = (r.next + 1) % len(r.lines)
+		if r.count < len(r.lines) {
+			r.count++
+		}
+	}
+}
+
+// last 按时间顺序返回最近的n行日志，n不大于0时返回全部
+func (r *logRing) last(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n <= 0 || n > r.count {
+		n = r.count
+	}
+	lines := make([]string, n)
+	start := r.next - n + len(r.lines)
+	for i := range lines {
+		lines[i] = r.lines[(start+i)%len(r.lines)]
+	}
+	return lines
+}
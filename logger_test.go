@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactLogAttr(t *testing.T) {
+	defer logLevel.Set(logLevel.Level())
+	tests := []struct {
+		name    string
+		level   slog.Level
+		visible []string
+		hidden  []string
+	}{
+		{"info", slog.LevelInfo, []string{"name=deploy", "id=3"}, []string{"rm -rf", "hunter2", "source="}},
+		{"debug", slog.LevelDebug, []string{"name=deploy", `content="rm -rf /tmp/x"`, "source=logger_test.go:"}, []string{"hunter2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logLevel.Set(tt.level)
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{AddSource: true, Level: logLevel, ReplaceAttr: redactLogAttr}))
+			logger.Warn("测试", "id", 3, "name", "deploy", "content", "rm -rf /tmp/x", slog.Group("vault", "secret", "hunter2"))
+			out := buf.String()
+			for _, s := range tt.visible {
+				if !strings.Contains(out, s) {
+					t.Errorf("log %q should contain %q", out, s)
+				}
+			}
+			for _, s := range tt.hidden {
+				if strings.Contains(out, s) {
+					t.Errorf("log %q should not contain %q", out, s)
+				}
+			}
+		})
+	}
+}
+
+func TestLogValuers(t *testing.T) {
+	defer logLevel.Set(logLevel.Level())
+	logLevel.Set(slog.LevelInfo)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactLogAttr}))
+	cmd := &Command{ID: 7, Name: "db-shell", Content: "mysql --password=hunter22", Os: []string{Linux},
+		Variants: []*CommandVariant{{Os: Windows, Shell: "powershell", Content: "mysql.exe --password=hunter22"}}}
+	settings := defaultSettings()
+	logger.Info("测试", "command", cmd, "settings", settings, "findingLine", 2)
+
+	out := buf.String()
+	for _, want := range []string{"command.id=7", "command.name=db-shell", "command.variant0.shell=powershell", "settings.theme=system", "settings.log.level=info", "findingLine=2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log %q should contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "hunter22") {
+		t.Errorf("log %q should not contain the command content", out)
+	}
+}
+
+func TestLogRing(t *testing.T) {
+	r := &logRing{lines: make([]string, 3)}
+	tests := []struct {
+		write string
+		limit int
+		want  []string
+	}{
+		{"", 0, []string{}},
+		{"a\n", 0, []string{"a"}},
+		{"b\nc\n", 2, []string{"b", "c"}},
+		{"d\n", 0, []string{"b", "c", "d"}},
+		{"e\n\n", 10, []string{"c", "d", "e"}},
+		{"", 1, []string{"e"}},
+	}
+	for _, tt := range tests {
+		r.add(tt.write)
+		if got := r.last(tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after %q last(%d) = %v, want %v", tt.write, tt.limit, got, tt.want)
+		}
+	}
+}
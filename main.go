@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"embed"
-	"log"
+	"log/slog"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -13,8 +15,17 @@ import (
 var assets embed.FS
 
 func main() {
-	log.SetFlags(log.LstdFlags | log.Llongfile)
-	InitSqlite()
+	if code, ok := runCLI(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	initLogging()
+	if err := InitSqlite(""); err != nil {
+		slog.Error("初始化数据库失败", "err", err)
+		os.Exit(1)
+	}
+	// 读取设置后按设置调整日志级别和输出位置
+	loadSettings(context.Background())
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -28,12 +39,13 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
 	})
 
 	if err != nil {
-		println("Error:", err.Error())
+		slog.Error("运行应用失败", "err", err)
 	}
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// osAliases 各种OS写法对应的规范值。旧版本、导入的数据和前端可能使用其中任意一种写法
+var osAliases = map[string]string{
+	"windows": Windows,
+	"win":     Windows,
+	"win32":   Windows,
+	"win64":   Windows,
+	"mac":     Mac,
+	"macos":   Mac,
+	"darwin":  Mac,
+	"osx":     Mac,
+	"os x":    Mac,
+	"linux":   Linux,
+	"all":     AllOs,
+	"*":       AllOs,
+}
+
+// osTables 保存OS的表及其指向所属记录的字段
+var osTables = []struct {
+	table string
+	fk    string
+}{
+	{"command_os", "command_id"},
+	{"tag_os", "tag_id"},
+	{"collection_os", "collection_id"},
+}
+
+// CanonicalOS 返回OS的规范写法（windows、mac、linux或all），无法识别时返回false
+func CanonicalOS(value string) (string, bool) {
+	os, ok := osAliases[strings.ToLower(strings.TrimSpace(value))]
+	return os, ok
+}
+
+// normalizeOS 校验单个OS并转换为规范写法，校验失败时错误的Field为field
+func normalizeOS(field, value string) (string, error) {
+	os, ok := CanonicalOS(value)
+	if !ok {
+		return "", NewValidationError(field, "os.invalid", value, Windows, Mac, Linux, AllOs)
+	}
+	return os, nil
+}
+
+// NormalizeOSList 校验OS列表并转换为规范写法，去掉重复的值；包含all时只保留all。
+// 校验失败时错误的Field为field
+func NormalizeOSList(field string, osList []string) ([]string, error) {
+	result := make([]string, 0, len(osList))
+	for _, value := range osList {
+		os, err := normalizeOS(field, value)
+		if err != nil {
+			return nil, err
+		}
+		if os == AllOs {
+			return []string{AllOs}, nil
+		}
+		if !slices.Contains(result, os) {
+			result = append(result, os)
+		}
+	}
+	return result, nil
+}
+
+// ValidateCommandOs 校验并规范化指令的OS，需要在语法检查之前调用
+func ValidateCommandOs(cmd *Command) error {
+	osList, err := NormalizeOSList("os", cmd.Os)
+	if err != nil {
+		return err
+	}
+	cmd.Os = osList
+	return nil
+}
+
+// canonicalOSFilter 将筛选条件中的OS转换为规范写法，无法识别的值转为小写后保留，不会匹配任何记录
+func canonicalOSFilter(osList []string) []string {
+	result := make([]string, 0, len(osList))
+	for _, value := range osList {
+		os, ok := CanonicalOS(value)
+		if !ok {
+			os = strings.ToLower(strings.TrimSpace(value))
+		}
+		if !slices.Contains(result, os) {
+			result = append(result, os)
+		}
+	}
+	return result
+}
+
+// osFilterValues 返回OS筛选条件实际匹配的值。条件为空或包含all时返回nil，表示不筛选；
+// 否则追加all，让适用于所有OS的记录总能匹配
+func osFilterValues(osList []string) []string {
+	osList = canonicalOSFilter(osList)
+	if len(osList) == 0 || slices.Contains(osList, AllOs) {
+		return nil
+	}
+	return append(osList, AllOs)
+}
+
+// migrateOSValues 将旧数据中Windows、macOS等写法统一为规范写法，已包含all的记录只保留all。
+// 无法识别的值保留不动，只记录日志
+func migrateOSValues(ctx context.Context) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	for _, t := range osTables {
+		var values []string
+		if values, err = distinctOSValues(ctx, tx, t.table); err != nil {
+			return err
+		}
+		for _, value := range values {
+			os, ok := CanonicalOS(value)
+			if !ok {
+				slog.Warn("存在无法识别的OS", "table", t.table, "os", value)
+				continue
+			}
+			if os == value {
+				continue
+			}
+			_, err = tx.ExecContext(ctx,
+				fmt.Sprintf("INSERT OR IGNORE INTO %[1]s (%[2]s, os) SELECT %[2]s, ? FROM %[1]s WHERE os = ?", t.table, t.fk),
+				os, value,
+			)
+			if err != nil {
+				return fmt.Errorf("规范化%s中的OS[%s]失败: %w", t.table, value, err)
+			}
+			if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE os = ?", t.table), value); err != nil {
+				return fmt.Errorf("删除%s中的OS[%s]失败: %w", t.table, value, err)
+			}
+			slog.Info("已规范化OS", "table", t.table, "from", value, "to", os)
+		}
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %[1]s WHERE os != ? AND %[2]s IN (SELECT %[2]s FROM %[1]s WHERE os = ?)", t.table, t.fk),
+			AllOs, AllOs,
+		)
+		if err != nil {
+			return fmt.Errorf("合并%s中的all失败: %w", t.table, err)
+		}
+	}
+
+	// 变体不能是all，同一指令下规范化后重复的变体保留原值，留给用户处理
+	var values []string
+	if values, err = distinctOSValues(ctx, tx, "command_variants"); err != nil {
+		return err
+	}
+	for _, value := range values {
+		os, ok := CanonicalOS(value)
+		if !ok || os == AllOs || os == value {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, "UPDATE OR IGNORE command_variants SET os = ? WHERE os = ?", os, value); err != nil {
+			return fmt.Errorf("规范化变体的OS[%s]失败: %w", value, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// distinctOSValues 查询表中出现过的所有OS值
+func distinctOSValues(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT os FROM %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("查询%s中的OS失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("扫描%s中的OS失败: %w", table, err)
+		}
+		values = append(values, value)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历%s中的OS失败: %w", table, err)
+	}
+	return values, nil
+}
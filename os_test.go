@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalOS(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"windows", Windows, true},
+		{" Windows ", Windows, true},
+		{"macOS", Mac, true},
+		{"darwin", Mac, true},
+		{"Linux", Linux, true},
+		{"ALL", AllOs, true},
+		{"beos", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CanonicalOS(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("CanonicalOS(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeOSList(t *testing.T) {
+	tests := []struct {
+		input []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{"Windows", "macOS", "Linux", "linux"}, []string{Windows, Mac, Linux}},
+		{[]string{"linux", "all", "mac"}, []string{AllOs}},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeOSList("os", tt.input)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeOSList(%v) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	_, err := NormalizeOSList("os", []string{"linux", "solaris"})
+	var appErr *AppError
+	if !errors.As(err, &appErr) || appErr.Code != CodeValidation || appErr.Field != "os" {
+		t.Errorf("NormalizeOSList with unknown OS = %v", err)
+	}
+}
+
+func TestOsFilterValues(t *testing.T) {
+	tests := []struct {
+		input []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"all"}, nil},
+		{[]string{"Linux", "all"}, nil},
+		{[]string{"Linux", "darwin"}, []string{Linux, Mac, AllOs}},
+		{[]string{"Solaris"}, []string{"solaris", AllOs}},
+	}
+	for _, tt := range tests {
+		if got := osFilterValues(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("osFilterValues(%v) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSelectCommandVariantAll(t *testing.T) {
+	variants := []*CommandVariant{{Os: Windows, Content: "dir"}, {Os: Linux, Content: "ls"}, {Os: Mac, Content: "ls -G"}}
+	want := selectCommandVariant(variants, nil, "")
+	if got := selectCommandVariant(variants, []string{AllOs}, ""); got != want {
+		t.Errorf("selectCommandVariant(all) = %+v, want %+v", got, want)
+	}
+}
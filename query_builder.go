@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxPageSize 单页最多返回的记录数
+	maxPageSize = 200
+)
+
+// SelectBuilder 拼接SELECT语句，所有外部输入都通过?占位符绑定，只有代码中写死的表名和列名会直接拼进SQL
+type SelectBuilder struct {
+	from    string
+	columns []string
+	where   []string
+	args    []interface{}
+	orderBy []sortKey
+	limit   int
+}
+
+// sortKey 一个排序列及其方向（ASC/DESC），column为表达式时args与其中的?一一对应
+type sortKey struct {
+	column    string
+	direction string
+	args      []interface{}
+}
+
+// NewSelectBuilder 创建查询构造器，from为带别名的表名，例如 "commands c"
+func NewSelectBuilder(from string, columns ...string) *SelectBuilder {
+	return &SelectBuilder{from: from, columns: columns}
+}
+
+// Where 追加一个AND条件，cond中的?与args一一对应
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	b.where = append(b.where, cond)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// OrderBy 追加一个排序列，direction为ASC或DESC；column也可以是带?的表达式，args与其中的?一一对应
+func (b *SelectBuilder) OrderBy(column, direction string, args ...interface{}) *SelectBuilder {
+	b.orderBy = append(b.orderBy, sortKey{column: column, direction: direction, args: args})
+	return b
+}
+
+// Limit 限制返回的行数，n<=0表示不限制
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Build 生成SQL语句和绑定参数
+func (b *SelectBuilder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(b.columns, ", "))
+	sb.WriteString(" FROM ")
+	sb.WriteString(b.from)
+	if len(b.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(b.where, " AND "))
+	}
+	args := b.args[:len(b.args):len(b.args)]
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(b.orderClause())
+		for _, k := range b.orderBy {
+			args = append(args, k.args...)
+		}
+	}
+	if b.limit > 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, b.limit)
+	}
+	return sb.String(), args
+}
+
+// BuildCount 生成统计满足条件的记录数的SQL，忽略排序和行数限制
+func (b *SelectBuilder) BuildCount() (string, []interface{}) {
+	query := "SELECT COUNT(*) FROM " + b.from
+	if len(b.where) > 0 {
+		query += " WHERE " + strings.Join(b.where, " AND ")
+	}
+	return query, b.args
+}
+
+// orderClause 返回ORDER BY子句内容
+func (b *SelectBuilder) orderClause() string {
+	parts := make([]string, len(b.orderBy))
+	for i, k := range b.orderBy {
+		parts[i] = k.column + " " + k.direction
+	}
+	return strings.Join(parts, ", ")
+}
+
+// orderSignature 用于校验游标是否属于当前排序。排序表达式的参数也会影响顺序，所以一并写在末尾
+func (b *SelectBuilder) orderSignature() string {
+	signature := b.orderClause()
+	for _, k := range b.orderBy {
+		for _, arg := range k.args {
+			signature += fmt.Sprintf(" %q", fmt.Sprint(arg))
+		}
+	}
+	return signature
+}
+
+// inPlaceholders 生成IN子句的占位符，例如 n=3 时返回 "(?,?,?)"
+func inPlaceholders(n int) string {
+	if n <= 0 {
+		return "(NULL)"
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?,", n), ",") + ")"
+}
+
+// toArgs 将切片转换为查询参数
+func toArgs[T any](values []T) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// escapeLike 转义LIKE模式中的通配符，配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// listEntity 描述一种可按Option查询的实体（指令、标签、集合）
+type listEntity struct {
+	table   string // 表名
+	alias   string // 查询中使用的表别名
+	osTable string // OS关联表
+	osFK    string // OS关联表中指向本表的外键
+	// auditType 审计日志中的实体类型
+	auditType string
+	// sortColumns 排序键对应的列，不在其中的排序键对该实体无效
+	sortColumns map[string]string
+}
+
+var (
+	commandListEntity = listEntity{
+		table:     "commands",
+		alias:     "c",
+		osTable:   "command_os",
+		osFK:      "command_id",
+		auditType: AuditEntityCommand,
+		sortColumns: map[string]string{
+			sortKeyName:       "c.name",
+			sortKeyCreateTime: "c.created_at",
+			sortKeyCopyCounts: "c.copy_count",
+			sortKeySortValue:  "c.sort_value",
+			sortKeyFrecency:   "c.frecency",
+			sortKeyRelevance:  commandRelevanceSQL,
+		},
+	}
+	tagListEntity = listEntity{
+		table:     "tags",
+		alias:     "t",
+		osTable:   "tag_os",
+		osFK:      "tag_id",
+		auditType: AuditEntityTag,
+		sortColumns: map[string]string{
+			sortKeyName:       "t.name",
+			sortKeyCreateTime: "t.created_at",
+			sortKeySortValue:  "t.sort_value",
+		},
+	}
+	collectionListEntity = listEntity{
+		table:     "collections",
+		alias:     "col",
+		osTable:   "collection_os",
+		osFK:      "collection_id",
+		auditType: AuditEntityCollection,
+		sortColumns: map[string]string{
+			sortKeyName:       "col.name",
+			sortKeyCreateTime: "col.created_at",
+			sortKeySortValue:  "col.sort_value",
+		},
+	}
+)
+
+// 排序键，与 SortOption 的json字段一致
+const (
+	sortKeyName       = "name"
+	sortKeyCreateTime = "create_time"
+	sortKeyCopyCounts = "copy_counts"
+	sortKeySortValue  = "sort_value"
+	sortKeyFrecency   = "frecency"
+	// sortKeyRelevance 搜索时的默认排序，由后端内部设置，不对应SortOption的json字段
+	sortKeyRelevance = "relevance"
+)
+
+// column 返回带表别名的列名
+func (e listEntity) column(name string) string {
+	return e.alias + "." + name
+}
+
+// newSelect 创建该实体的查询构造器，默认排除已软删除的记录
+func (e listEntity) newSelect(columns ...string) *SelectBuilder {
+	return NewSelectBuilder(e.table+" "+e.alias, columns...).Where(e.column("deleted_at") + " IS NULL")
+}
+
+// applyFilters 将Option中的名称、ID和OS筛选条件应用到查询上
+func (e listEntity) applyFilters(b *SelectBuilder, option Option) {
+	if option.Name != "" {
+		b.Where(e.column("name")+` LIKE ? ESCAPE '\'`, "%"+escapeLike(option.Name)+"%")
+	}
+	if option.ID != 0 {
+		b.Where(e.column("id")+" = ?", option.ID)
+	}
+	e.applyOsFilter(b, option.Os)
+}
+
+// applyOsFilter 只保留关联了任一指定OS或all的记录，使用EXISTS避免JOIN产生重复行；筛选条件包含all时不筛选
+func (e listEntity) applyOsFilter(b *SelectBuilder, osList []string) {
+	osList = osFilterValues(osList)
+	if len(osList) == 0 {
+		return
+	}
+	b.Where(
+		fmt.Sprintf("EXISTS (SELECT 1 FROM %s o WHERE o.%s = %s AND o.os IN %s)",
+			e.osTable, e.osFK, e.column("id"), inPlaceholders(len(osList))),
+		toArgs(osList)...,
+	)
+}
+
+// applySort 置顶的记录总是排在最前，搜索时的默认排序见search_rank.go；其余按SortOption中字段的先后顺序组合多列排序，
+// 没有任何排序条件时按创建时间倒序
+func (e listEntity) applySort(b *SelectBuilder, sort SortOption) error {
+	b.OrderBy(e.column("pinned"), "DESC")
+	keys := []struct {
+		key       string
+		direction *string
+	}{
+		{sortKeyName, sort.Name},
+		{sortKeyCreateTime, sort.CreateTime},
+		{sortKeyCopyCounts, sort.CopyCounts},
+		{sortKeySortValue, sort.SortValue},
+		{sortKeyFrecency, sort.Frecency},
+	}
+	sorted := false
+	if sort.relevance != "" {
+		if column, ok := e.sortColumns[sortKeyRelevance]; ok {
+			b.OrderBy(column, "DESC", sort.relevance)
+			sorted = true
+		}
+	}
+	for _, k := range keys {
+		if k.direction == nil {
+			continue
+		}
+		column, ok := e.sortColumns[k.key]
+		if !ok {
+			continue
+		}
+		direction, err := sortDirection(*k.direction)
+		if err != nil {
+			return err
+		}
+		b.OrderBy(column, direction)
+		sorted = true
+	}
+	if !sorted {
+		b.OrderBy(e.column("created_at"), "DESC")
+	}
+	// 以ID兜底，保证排序结果稳定，也是游标分页的前提
+	b.OrderBy(e.column("id"), "DESC")
+	return nil
+}
+
+// sortDirection 校验排序方向，只允许asc和desc
+func sortDirection(direction string) (string, error) {
+	switch strings.ToLower(direction) {
+	case "asc":
+		return "ASC", nil
+	case "desc", "":
+		return "DESC", nil
+	default:
+		return "", NewValidationError("sort", "sort.invalid_direction", direction)
+	}
+}
+
+// listCursor 分页游标，记录上一页最后一条记录的ID以及生成游标时的排序
+type listCursor struct {
+	ID   uint64 `json:"id"`
+	Sort string `json:"sort"`
+}
+
+// encodeCursor 将游标编码为可以直接传给前端的字符串
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析前端传回的游标，编码或内容无效时都返回校验错误
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, NewValidationError("cursor", "page.invalid_cursor")
+	}
+	if err = json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return c, NewValidationError("cursor", "page.invalid_cursor")
+	}
+	return c, nil
+}
+
+// Page 列表分页信息
+type Page struct {
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// listPage 记录一次分页查询的上下文，用于在取回数据后生成分页信息
+type listPage struct {
+	total     int
+	limit     int
+	signature string
+}
+
+// prepareList 应用筛选、排序和游标条件，统计总数并限制行数（多取一行用于判断是否还有下一页）
+func (e listEntity) prepareList(ctx context.Context, b *SelectBuilder, option Option) (*listPage, error) {
+	e.applyFilters(b, option)
+
+	total, err := countRows(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = e.applySort(b, option.Sort); err != nil {
+		return nil, err
+	}
+	page := &listPage{total: total, signature: b.orderSignature()}
+
+	if option.Cursor != "" {
+		cursor, err := decodeCursor(option.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != page.signature {
+			return nil, NewValidationError("cursor", "page.cursor_mismatch")
+		}
+		e.applyCursor(b, cursor.ID)
+	}
+
+	if option.Limit > 0 {
+		page.limit = min(option.Limit, maxPageSize)
+		b.Limit(page.limit + 1)
+	}
+	return page, nil
+}
+
+// applyCursor 按键集分页：只保留在排序上位于游标记录之后的记录。
+// 游标记录的排序值通过子查询取得，因此游标中只需要保存ID
+func (e listEntity) applyCursor(b *SelectBuilder, cursorID uint64) {
+	var or []string
+	var args []interface{}
+	// compare 生成 排序值 op 游标记录的排序值，排序表达式在两边各出现一次，参数也要绑定两次
+	compare := func(key sortKey, op string) string {
+		args = append(args, key.args...)
+		args = append(args, key.args...)
+		args = append(args, cursorID)
+		return fmt.Sprintf("%s %s %s", key.column, op, e.cursorValue(key.column))
+	}
+	for i, key := range b.orderBy {
+		var and []string
+		for _, prev := range b.orderBy[:i] {
+			and = append(and, compare(prev, "="))
+		}
+		op := "<"
+		if key.direction == "ASC" {
+			op = ">"
+		}
+		and = append(and, compare(key, op))
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	b.Where("("+strings.Join(or, " OR ")+")", args...)
+}
+
+// cursorValue 返回查询游标记录某一列的子查询，子查询使用相同的表别名，排序列也可以是表达式
+func (e listEntity) cursorValue(column string) string {
+	return fmt.Sprintf("(SELECT %s FROM %s %s WHERE %s = ?)", column, e.table, e.alias, e.column("id"))
+}
+
+// pageResult 去掉多取的一行并生成分页信息
+func pageResult[T any](items []T, page *listPage, id func(T) uint64) ([]T, Page) {
+	result := Page{Total: page.total}
+	if page.limit > 0 && len(items) > page.limit {
+		items = items[:page.limit]
+		result.HasMore = true
+		result.NextCursor = encodeCursor(listCursor{ID: id(items[len(items)-1]), Sort: page.signature})
+	}
+	return items, result
+}
+
+// countRows 统计查询构造器当前条件下的记录数
+func countRows(ctx context.Context, b *SelectBuilder) (int, error) {
+	query, args := b.BuildCount()
+	var total int
+	if err := DB.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("统计记录数失败: %w", err)
+	}
+	return total, nil
+}
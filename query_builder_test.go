@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestSelectBuilderBindsOptionValues(t *testing.T) {
+	desc := "desc"
+	b := commandListEntity.newSelect("c.id")
+	commandListEntity.applyFilters(b, Option{
+		Name: "50%_off' OR '1'='1",
+		Os:   []string{"Linux", "macOS"},
+	})
+	if err := commandListEntity.applySort(b, SortOption{CopyCounts: &desc}); err != nil {
+		t.Fatal(err)
+	}
+	query, args := b.Build()
+
+	wantQuery := `SELECT c.id FROM commands c WHERE c.deleted_at IS NULL AND c.name LIKE ? ESCAPE '\'` +
+		` AND EXISTS (SELECT 1 FROM command_os o WHERE o.command_id = c.id AND o.os IN (?,?,?))` +
+		` ORDER BY c.pinned DESC, c.copy_count DESC, c.id DESC`
+	if query != wantQuery {
+		t.Errorf("query = %s\nwant %s", query, wantQuery)
+	}
+	wantArgs := []interface{}{`%50\%\_off' OR '1'='1%`, "linux", "mac", "all"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestApplySortRejectsInvalidDirection(t *testing.T) {
+	bad := "asc; DROP TABLE commands"
+	b := tagListEntity.newSelect("t.id")
+	if err := tagListEntity.applySort(b, SortOption{Name: &bad}); err == nil {
+		t.Fatal("expected error for invalid sort direction")
+	}
+}
+
+func TestApplyOsFilterAllIsWildcard(t *testing.T) {
+	b := tagListEntity.newSelect("t.id")
+	tagListEntity.applyFilters(b, Option{Os: []string{"linux", "all"}})
+	query, args := b.Build()
+	if want := "SELECT t.id FROM tags t WHERE t.deleted_at IS NULL"; query != want || len(args) != 0 {
+		t.Errorf("query = %s, args = %v", query, args)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	valid := listCursor{ID: 42, Sort: "name:asc"}
+	tests := []struct {
+		name   string
+		cursor string
+		want   listCursor
+		code   ErrorCode
+	}{
+		{"valid", encodeCursor(valid), valid, CodeOK},
+		{"malformed base64", "not*base64!", listCursor{}, CodeValidation},
+		{"invalid json", base64.RawURLEncoding.EncodeToString([]byte("{id:")), listCursor{}, CodeValidation},
+		{"zero id", encodeCursor(listCursor{Sort: "name:asc"}), listCursor{}, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if errorCode(err) != tt.code {
+				t.Fatalf("decodeCursor(%q) error = %v, want code %d", tt.cursor, err, tt.code)
+			}
+			if err != nil {
+				if appErr := err.(*AppError); appErr.Field != "cursor" {
+					t.Errorf("decodeCursor(%q) field = %q, want cursor", tt.cursor, appErr.Field)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("decodeCursor(%q) = %+v, want %+v", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
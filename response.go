@@ -1,7 +1,67 @@
 package main
 
+import (
+	"errors"
+	"log/slog"
+)
+
+// Response App方法统一的返回值。Code为0表示成功，否则为ErrorCode，
+// 校验失败时Field为出错的字段
 type Response struct {
-	Code int         `json:"code"`
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	Code   ErrorCode   `json:"code"`
+	Msg    string      `json:"msg"`
+	Field  string      `json:"field,omitempty"`
+	Detail string      `json:"detail,omitempty"` // 内部错误的原始信息，用于排查
+	Data   interface{} `json:"data"`
+}
+
+// dataResponse 成功的返回值
+func dataResponse(data interface{}) Response {
+	return Response{Data: data}
+}
+
+// errorResponse 按错误类型设置错误码的返回值，err为nil时表示成功。
+// Msg使用当前语言，只包含出错的原因，不包含各层附加的操作说明；
+// 搜索语法错误和危险指令确认的详细信息放在Data中，方便前端标记和展示
+func errorResponse(err error) Response {
+	if err == nil {
+		return Response{}
+	}
+	code, field := classifyError(err)
+	response := Response{Code: code, Field: field}
+	var appErr *AppError
+	var queryErr *SearchQueryError
+	var syntaxErr *CommandSyntaxError
+	var riskErr *RiskConfirmationError
+	switch {
+	case errors.As(err, &appErr):
+		response.Msg = appErr.Error()
+	case errors.As(err, &queryErr):
+		response.Msg = queryErr.Error()
+		response.Data = queryErr
+	case errors.As(err, &syntaxErr):
+		response.Msg = syntaxErr.Error()
+	case errors.As(err, &riskErr):
+		response.Msg = riskErr.Error()
+		response.Data = riskErr.Report
+	case code == CodeCanceled:
+		response.Msg = localize("error.canceled")
+	case code == CodeNotFound:
+		response.Msg = localize("error.not_found")
+	case code == CodeConflict:
+		response.Msg = localize("error.conflict")
+	default:
+		response.Msg = localize("error.internal")
+		response.Detail = err.Error()
+		slog.Error("内部错误", "err", err)
+	}
+	return response
+}
+
+// newResponse err不为nil时返回错误，否则返回data
+func newResponse(data interface{}, err error) Response {
+	if err != nil {
+		return errorResponse(err)
+	}
+	return dataResponse(data)
 }
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 指令风险等级，从低到高
+const (
+	RiskNone     = "none"
+	RiskLow      = "low"
+	RiskMedium   = "medium"
+	RiskHigh     = "high"
+	RiskCritical = "critical"
+)
+
+// riskRanks 风险等级的大小顺序
+var riskRanks = map[string]int{RiskNone: 0, RiskLow: 1, RiskMedium: 2, RiskHigh: 3, RiskCritical: 4}
+
+// riskRulesFile 用户自定义风险规则的配置文件，保存在配置目录下
+const riskRulesFile = "risk_rules.json"
+
+// riskConfirmLevel 达到该等级的指令复制和执行前需要确认
+const riskConfirmLevel = RiskHigh
+
+// RiskRule 一条危险指令规则
+type RiskRule struct {
+	ID string `json:"id"`
+	// Program 匹配程序名的正则（完整匹配），为空时Pattern匹配整段命令
+	Program string `json:"program,omitempty"`
+	// Pattern 匹配参数的正则；Program为空时匹配整段命令，为空表示只要程序名匹配即可
+	Pattern     string `json:"pattern,omitempty"`
+	Severity    string `json:"severity"`
+	Explanation string `json:"explanation"`
+	Disabled    bool   `json:"disabled,omitempty"` // 用于在配置文件中关闭内置规则
+	Builtin     bool   `json:"builtin"`
+
+	program *regexp.Regexp
+	pattern *regexp.Regexp
+}
+
+// RiskFinding 指令内容中命中的一条规则
+type RiskFinding struct {
+	RuleID      string `json:"ruleId"`
+	Severity    string `json:"severity"`
+	Explanation string `json:"explanation"`
+	Segment     string `json:"segment"` // 命中的那段命令
+}
+
+// RiskReport 指令内容的风险分析结果
+type RiskReport struct {
+	Level    string         `json:"level"`
+	Findings []*RiskFinding `json:"findings"`
+}
+
+// defaultRiskRules 内置规则，说明在消息目录中（risk.rule.<ID>），配置文件中同ID的规则会覆盖内置规则
+var defaultRiskRules = []*RiskRule{
+	{ID: "rm-root", Program: `rm`, Pattern: `(^|\s)(/|/\*|~|~/|~/\*|\*|\$HOME)(\s|$)`, Severity: RiskCritical},
+	{ID: "rm-recursive", Program: `rm`, Pattern: `(^|\s)(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)(\s|$)`, Severity: RiskHigh},
+	{ID: "dd-device", Program: `dd`, Pattern: `(^|\s)of=/dev/`, Severity: RiskCritical},
+	{ID: "redirect-device", Pattern: `>\s*/dev/(sd|hd|vd|xvd|nvme|disk|mmcblk)`, Severity: RiskCritical},
+	{ID: "mkfs", Program: `mkfs(\..+)?|mke2fs|wipefs`, Severity: RiskCritical},
+	{ID: "chmod-chown-root", Program: `chmod|chown`, Pattern: `(^|\s)(-[a-zA-Z]*R[a-zA-Z]*|--recursive)\s(.*\s)?/(\s|$)`, Severity: RiskCritical},
+	{ID: "git-push-force", Program: `git`, Pattern: `^push\s(.*\s)?(-[a-zA-Z]*f[a-zA-Z]*|--force|\+\S+)(\s|$)`, Severity: RiskHigh},
+	{ID: "git-reset-hard", Program: `git`, Pattern: `^reset\s(.*\s)?--hard(\s|$)`, Severity: RiskMedium},
+	{ID: "git-clean", Program: `git`, Pattern: `^clean\s(.*\s)?(-[a-zA-Z]*f[a-zA-Z]*|--force)(\s|$)`, Severity: RiskMedium},
+	{ID: "kubectl-delete-namespace", Program: `kubectl|oc`, Pattern: `^delete\s+(ns|namespace|namespaces)(\s|/|$)`, Severity: RiskCritical},
+	{ID: "kubectl-delete", Program: `kubectl|oc`, Pattern: `^delete(\s|$)`, Severity: RiskHigh},
+	{ID: "helm-uninstall", Program: `helm`, Pattern: `^(uninstall|delete|del)(\s|$)`, Severity: RiskHigh},
+	{ID: "terraform-destroy", Program: `terraform|tofu`, Pattern: `^destroy(\s|$)`, Severity: RiskHigh},
+	{ID: "docker-prune", Program: `docker|podman`, Pattern: `(^|\s)prune(\s|$)`, Severity: RiskMedium},
+	{ID: "sql-drop", Pattern: `(?i)\b(drop\s+(table|database|schema)|truncate(\s+table)?\s+\w)`, Severity: RiskHigh},
+	{ID: "sql-delete-all", Pattern: `(?i)\bdelete\s+from\s+[\w.` + "`" + `"]+\s*$`, Severity: RiskHigh},
+	{ID: "shutdown", Program: `shutdown|reboot|halt|poweroff`, Severity: RiskMedium},
+	{ID: "powershell-remove-recurse", Program: `(?i)remove-item|rm|del|rd|rmdir`, Pattern: `(?i)(^|\s)-recurse(\s|$)`, Severity: RiskHigh},
+	{ID: "windows-format", Program: `(?i)format(\.com)?`, Pattern: `(?i)^[a-z]:`, Severity: RiskCritical},
+}
+
+// riskAnalyzer 按规则分析指令内容的风险
+type riskAnalyzer struct {
+	rules []*RiskRule
+}
+
+var (
+	riskMu      sync.RWMutex
+	riskCurrent *riskAnalyzer
+)
+
+// currentRiskAnalyzer 返回当前使用的风险分析器，首次调用时从配置文件加载规则
+func currentRiskAnalyzer() *riskAnalyzer {
+	riskMu.RLock()
+	analyzer := riskCurrent
+	riskMu.RUnlock()
+	if analyzer != nil {
+		return analyzer
+	}
+	analyzer, err := reloadRiskAnalyzer()
+	if err != nil {
+		slog.Warn("加载风险规则失败，使用内置规则", "err", err)
+	}
+	return analyzer
+}
+
+// reloadRiskAnalyzer 重新读取配置文件中的规则；配置有误时使用内置规则并返回错误
+func reloadRiskAnalyzer() (*riskAnalyzer, error) {
+	var custom []*RiskRule
+	path, err := riskRulesPath()
+	if err == nil {
+		custom, err = loadRiskRules(path)
+	}
+	analyzer, buildErr := newRiskAnalyzer(custom)
+	if buildErr != nil {
+		err = buildErr
+		analyzer, _ = newRiskAnalyzer(nil)
+	}
+
+	riskMu.Lock()
+	riskCurrent = analyzer
+	riskMu.Unlock()
+	return analyzer, err
+}
+
+// riskRulesPath 返回风险规则配置文件的路径
+func riskRulesPath() (string, error) {
+	dir, err := quickcmdConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, riskRulesFile), nil
+}
+
+// loadRiskRules 读取配置文件中的规则，文件不存在时返回空
+func loadRiskRules(path string) ([]*RiskRule, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取风险规则文件失败: %w", err)
+	}
+	var config struct {
+		Rules []*RiskRule `json:"rules"`
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析风险规则文件%s失败: %w", path, err)
+	}
+	return config.Rules, nil
+}
+
+// newRiskAnalyzer 合并内置规则和自定义规则，自定义规则与内置规则ID相同时覆盖内置规则
+func newRiskAnalyzer(custom []*RiskRule) (*riskAnalyzer, error) {
+	var rules []*RiskRule
+	index := make(map[string]int)
+	add := func(r RiskRule, builtin bool) error {
+		r.Builtin = builtin
+		if err := compileRiskRule(&r); err != nil {
+			return err
+		}
+		if i, ok := index[r.ID]; ok {
+			rules[i] = &r
+			return nil
+		}
+		index[r.ID] = len(rules)
+		rules = append(rules, &r)
+		return nil
+	}
+	for _, r := range defaultRiskRules {
+		if err := add(*r, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range custom {
+		if err := add(*r, false); err != nil {
+			return nil, err
+		}
+	}
+	return &riskAnalyzer{rules: rules}, nil
+}
+
+// compileRiskRule 校验规则并编译其中的正则
+func compileRiskRule(r *RiskRule) error {
+	if r.ID == "" {
+		return NewValidationError("id", "risk_rule.id_required")
+	}
+	if r.Disabled {
+		// 只用于关闭同ID的内置规则，其余字段可以省略
+		return nil
+	}
+	if _, ok := riskRanks[r.Severity]; !ok || r.Severity == RiskNone {
+		return NewValidationError("severity", "risk_rule.invalid_severity", r.ID, r.Severity)
+	}
+	if r.Program == "" && r.Pattern == "" {
+		return NewValidationError("pattern", "risk_rule.empty", r.ID)
+	}
+	var err error
+	if r.Program != "" {
+		if r.program, err = regexp.Compile(`^(?:` + r.Program + `)$`); err != nil {
+			return NewValidationError("program", "risk_rule.invalid_program", r.ID, err)
+		}
+	}
+	if r.Pattern != "" {
+		if r.pattern, err = regexp.Compile(r.Pattern); err != nil {
+			return NewValidationError("pattern", "risk_rule.invalid_pattern", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// Analyze 将内容按管道和连接符切分为多段命令，逐段匹配规则
+func (ra *riskAnalyzer) Analyze(content string) *RiskReport {
+	report := &RiskReport{Level: RiskNone, Findings: []*RiskFinding{}}
+	for _, words := range splitCommandSegments(content) {
+		program, args := segmentProgram(words)
+		segment := strings.Join(words, " ")
+		argText := strings.Join(args, " ")
+		for _, r := range ra.rules {
+			if r.Disabled || !r.matches(program, argText, segment) {
+				continue
+			}
+			report.Findings = append(report.Findings, &RiskFinding{
+				RuleID:      r.ID,
+				Severity:    r.Severity,
+				Explanation: r.explanation(),
+				Segment:     segment,
+			})
+			report.Level = maxRiskLevel(report.Level, r.Severity)
+		}
+	}
+	return report
+}
+
+// matches 判断一段命令是否命中规则
+func (r *RiskRule) matches(program, args, segment string) bool {
+	if r.program == nil {
+		return r.pattern.MatchString(segment)
+	}
+	if !r.program.MatchString(program) {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(args)
+}
+
+// explanation 内置规则的说明按当前语言从消息目录中取，自定义规则使用配置文件中的说明
+func (r *RiskRule) explanation() string {
+	if r.Builtin {
+		return localize("risk.rule." + r.ID)
+	}
+	return r.Explanation
+}
+
+// Rules 返回当前生效的所有规则，内置规则的说明使用当前语言
+func (ra *riskAnalyzer) Rules() []*RiskRule {
+	rules := make([]*RiskRule, len(ra.rules))
+	for i, r := range ra.rules {
+		rule := *r
+		rule.Explanation = r.explanation()
+		rules[i] = &rule
+	}
+	return rules
+}
+
+// AnalyzeCommand 分析指令默认内容和所有变体，取最高的风险等级
+func (ra *riskAnalyzer) AnalyzeCommand(cmd *Command) *RiskReport {
+	report := ra.Analyze(cmd.Content)
+	for _, v := range cmd.Variants {
+		variant := ra.Analyze(v.Content)
+		report.Findings = append(report.Findings, variant.Findings...)
+		report.Level = maxRiskLevel(report.Level, variant.Level)
+	}
+	return report
+}
+
+// splitCommandSegments 按shell规则切分内容，在 | || && ; & 处分段
+func splitCommandSegments(content string) [][]string {
+	var segments [][]string
+	var words []string
+	for _, t := range tokenizeContent(content) {
+		if t.operator {
+			if len(words) > 0 {
+				segments = append(segments, words)
+			}
+			words = nil
+			continue
+		}
+		words = append(words, t.text)
+	}
+	if len(words) > 0 {
+		segments = append(segments, words)
+	}
+	return segments
+}
+
+// segmentProgram 跳过sudo、env、nohup等前缀和变量赋值，返回实际执行的程序名和参数
+func segmentProgram(words []string) (string, []string) {
+	i := 0
+	for i < len(words) {
+		w := words[i]
+		switch {
+		case w == "sudo" || w == "doas":
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				// 带参数的sudo选项，参数一并跳过
+				if words[i] == "-u" || words[i] == "-g" || words[i] == "-C" {
+					i++
+				}
+				i++
+			}
+		case w == "env" || w == "nohup" || w == "time" || w == "command" || w == "exec":
+			i++
+		case strings.Contains(w, "=") && !strings.HasPrefix(w, "-") && i+1 < len(words):
+			i++
+		default:
+			return filepath.Base(w), words[i+1:]
+		}
+	}
+	return "", nil
+}
+
+// maxRiskLevel 返回两个等级中较高的一个
+func maxRiskLevel(a, b string) string {
+	if riskRanks[b] > riskRanks[a] {
+		return b
+	}
+	return a
+}
+
+// riskNeedsConfirm 判断该等级的指令复制和执行前是否需要确认
+func riskNeedsConfirm(level string) bool {
+	return riskRanks[level] >= riskRanks[riskConfirmLevel]
+}
+
+// riskLevelOrNone 未分析过的指令按无风险保存
+func riskLevelOrNone(level string) string {
+	if level == "" {
+		return RiskNone
+	}
+	return level
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRiskAnalyzerAnalyze(t *testing.T) {
+	analyzer, err := newRiskAnalyzer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		content string
+		level   string
+		rule    string
+	}{
+		{"ls -la", RiskNone, ""},
+		{"rm -fr ./build", RiskHigh, "rm-recursive"},
+		{"sudo -u root rm -rf /", RiskCritical, "rm-root"},
+		{"rm build.log", RiskNone, ""},
+		{"dd if=image.iso of=/dev/sdb bs=4M", RiskCritical, "dd-device"},
+		{"git push origin main --force", RiskHigh, "git-push-force"},
+		{"git push --force-with-lease", RiskNone, ""},
+		{"git fetch && git reset --hard origin/main", RiskMedium, "git-reset-hard"},
+		{"kubectl delete ns staging", RiskCritical, "kubectl-delete-namespace"},
+		{"kubectl get pods | grep web", RiskNone, ""},
+		{`psql -c "DROP TABLE users"`, RiskHigh, "sql-drop"},
+		{`mysql -e 'delete from orders'`, RiskHigh, "sql-delete-all"},
+		{`mysql -e 'delete from orders where id = 1'`, RiskNone, ""},
+		{"Remove-Item C:\\tmp -Recurse -Force", RiskHigh, "powershell-remove-recurse"},
+	}
+	for _, tt := range tests {
+		report := analyzer.Analyze(tt.content)
+		if report.Level != tt.level {
+			t.Errorf("Analyze(%q) level = %s, want %s", tt.content, report.Level, tt.level)
+			continue
+		}
+		if tt.rule == "" {
+			continue
+		}
+		found := false
+		for _, f := range report.Findings {
+			found = found || f.RuleID == tt.rule
+		}
+		if !found {
+			t.Errorf("Analyze(%q) findings %+v, want rule %s", tt.content, report.Findings, tt.rule)
+		}
+	}
+}
+
+func TestRiskAnalyzerCustomRules(t *testing.T) {
+	analyzer, err := newRiskAnalyzer([]*RiskRule{
+		{ID: "rm-recursive", Disabled: true},
+		{ID: "prod-db", Pattern: `--host[= ]prod`, Severity: RiskCritical, Explanation: "连接生产数据库"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := analyzer.Analyze("rm -r ./build").Level; level != RiskNone {
+		t.Errorf("disabled builtin rule still matched, level = %s", level)
+	}
+	if level := analyzer.Analyze("psql --host=prod-1 app").Level; level != RiskCritical {
+		t.Errorf("custom rule level = %s, want %s", level, RiskCritical)
+	}
+
+	if _, err = newRiskAnalyzer([]*RiskRule{{ID: "bad", Pattern: "(", Severity: RiskHigh}}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+	if _, err = newRiskAnalyzer([]*RiskRule{{ID: "bad", Pattern: "x", Severity: "extreme"}}); err == nil {
+		t.Error("expected error for invalid severity")
+	}
+}
+
+func TestCheckRiskConfirmation(t *testing.T) {
+	a := NewApp()
+	if err := a.checkRiskConfirmation(1, "echo hello", ""); err != nil {
+		t.Fatalf("low risk content should not need confirmation: %v", err)
+	}
+	var confirmErr *RiskConfirmationError
+	if err := a.checkRiskConfirmation(1, "rm -rf /", "unknown"); !errors.As(err, &confirmErr) {
+		t.Fatalf("expected RiskConfirmationError, got %v", err)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 搜索语法：
+//   docker                 名称、内容或描述中包含docker
+//   "docker compose"       短语匹配
+//   tag:docker col:deploy  按标签名、集合名筛选
+//   os:linux               按OS筛选
+//   copies:>5              按复制次数筛选，支持 > >= < <= =
+//   created:<2026-01-01    按创建日期筛选，支持 > >= < <= =
+//   -tag:deprecated        排除，也可以写成 NOT tag:deprecated
+//   a OR (b AND c)         布尔组合，相邻条件默认为AND
+// 为了能直接搜索 "ls -la" 这类命令，减号只在字段条件、短语和括号前表示排除
+
+// 搜索语法树节点类型
+const (
+	searchNodeAnd  = "and"
+	searchNodeOr   = "or"
+	searchNodeNot  = "not"
+	searchNodeTerm = "term"
+)
+
+// 支持的搜索字段
+const (
+	searchFieldText    = ""
+	searchFieldTag     = "tag"
+	searchFieldCol     = "col"
+	searchFieldOs      = "os"
+	searchFieldCopies  = "copies"
+	searchFieldCreated = "created"
+)
+
+var searchFields = map[string]bool{
+	searchFieldTag:     true,
+	searchFieldCol:     true,
+	searchFieldOs:      true,
+	searchFieldCopies:  true,
+	searchFieldCreated: true,
+}
+
+// SearchNode 搜索语法树节点
+type SearchNode struct {
+	Kind     string        `json:"kind"`
+	Children []*SearchNode `json:"children,omitempty"`
+	Field    string        `json:"field,omitempty"` // 为空表示全文匹配
+	Op       string        `json:"op,omitempty"`    // copies和created的比较运算符
+	Value    string        `json:"value,omitempty"`
+	Pos      int           `json:"pos"`
+}
+
+// SearchQueryError 搜索语法错误，Position为出错位置（从0开始的字符下标）
+type SearchQueryError struct {
+	Position int    `json:"position"`
+	Length   int    `json:"length"`
+	Message  string `json:"message"`
+}
+
+func (e *SearchQueryError) Error() string {
+	return localize("search.error", e.Position+1, e.Message)
+}
+
+// 词法单元类型
+const (
+	searchTokenWord = iota
+	searchTokenPhrase
+	searchTokenLParen
+	searchTokenRParen
+	searchTokenAnd
+	searchTokenOr
+	searchTokenNot
+	searchTokenEOF
+)
+
+type searchToken struct {
+	kind   int
+	text   string // 原文
+	value  string // 去掉引号后的值
+	field  string // 字段名，仅字段条件有值
+	pos    int    // 字符下标
+	length int    // 字符长度
+}
+
+// ParseSearchQuery 解析搜索框输入，输入为空时返回nil
+func ParseSearchQuery(input string) (*SearchNode, error) {
+	tokens, err := tokenizeSearchQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &searchParser{tokens: tokens}
+	if p.peek().kind == searchTokenEOF {
+		return nil, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != searchTokenEOF {
+		return nil, tokenError(tok, localize("search.extra_rparen"))
+	}
+	return node, nil
+}
+
+// tokenizeSearchQuery 将输入切分为词法单元，位置按字符（而非字节）计算
+func tokenizeSearchQuery(input string) ([]searchToken, error) {
+	runes := []rune(input)
+	var tokens []searchToken
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, searchToken{kind: searchTokenLParen, text: "(", pos: i, length: 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, searchToken{kind: searchTokenRParen, text: ")", pos: i, length: 1})
+			i++
+		case r == '-' && i+1 < len(runes) && isSearchNegatable(runes[i+1:]):
+			tokens = append(tokens, searchToken{kind: searchTokenNot, text: "-", pos: i, length: 1})
+			i++
+		case r == '"':
+			value, end, err := readSearchPhrase(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, searchToken{kind: searchTokenPhrase, text: string(runes[i:end]), value: value, pos: i, length: end - i})
+			i = end
+		default:
+			tok, end, err := readSearchWord(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end
+		}
+	}
+	tokens = append(tokens, searchToken{kind: searchTokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// isSearchNegatable 判断减号后面的内容是否可以被排除（字段条件、短语或括号）
+func isSearchNegatable(rest []rune) bool {
+	if rest[0] == '"' || rest[0] == '(' {
+		return true
+	}
+	field, _, ok := strings.Cut(string(rest), ":")
+	return ok && searchFields[strings.ToLower(field)]
+}
+
+// readSearchPhrase 读取双引号包裹的短语，支持 \" 转义
+func readSearchPhrase(runes []rune, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, &SearchQueryError{Position: start, Length: len(runes) - start, Message: localize("search.unclosed_quote")}
+}
+
+// readSearchWord 读取一个普通词，识别AND/OR/NOT关键字和 字段:值 形式的条件
+func readSearchWord(runes []rune, start int) (searchToken, int, error) {
+	i := start
+	for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+		if runes[i] == ':' && i+1 < len(runes) && runes[i+1] == '"' && searchFields[strings.ToLower(string(runes[start:i]))] {
+			// 字段值为短语，例如 tag:"my tag"
+			value, end, err := readSearchPhrase(runes, i+1)
+			if err != nil {
+				return searchToken{}, 0, err
+			}
+			return searchToken{
+				kind: searchTokenWord, text: string(runes[start:end]), field: strings.ToLower(string(runes[start:i])),
+				value: value, pos: start, length: end - start,
+			}, end, nil
+		}
+		i++
+	}
+	text := string(runes[start:i])
+	tok := searchToken{kind: searchTokenWord, text: text, value: text, pos: start, length: i - start}
+	switch text {
+	case "AND":
+		tok.kind = searchTokenAnd
+	case "OR":
+		tok.kind = searchTokenOr
+	case "NOT":
+		tok.kind = searchTokenNot
+	default:
+		if field, value, ok := strings.Cut(text, ":"); ok && searchFields[strings.ToLower(field)] {
+			tok.field = strings.ToLower(field)
+			tok.value = value
+		}
+	}
+	return tok, i, nil
+}
+
+// searchParser 递归下降解析器：
+//
+//	or    := and ("OR" and)*
+//	and   := unary (["AND"] unary)*
+//	unary := ("NOT" | "-") unary | "(" or ")" | term
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+}
+
+func (p *searchParser) peek() searchToken {
+	return p.tokens[p.pos]
+}
+
+func (p *searchParser) next() searchToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != searchTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *searchParser) parseOr() (*SearchNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == searchTokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &SearchNode{Kind: searchNodeOr, Children: []*SearchNode{left, right}, Pos: left.Pos}
+	}
+	return left, nil
+}
+
+func (p *searchParser) parseAnd() (*SearchNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case searchTokenAnd:
+			p.next()
+		case searchTokenWord, searchTokenPhrase, searchTokenLParen, searchTokenNot:
+			// 相邻条件之间默认为AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &SearchNode{Kind: searchNodeAnd, Children: []*SearchNode{left, right}, Pos: left.Pos}
+	}
+}
+
+func (p *searchParser) parseUnary() (*SearchNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case searchTokenNot:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &SearchNode{Kind: searchNodeNot, Children: []*SearchNode{operand}, Pos: tok.pos}, nil
+	case searchTokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != searchTokenRParen {
+			return nil, tokenError(tok, localize("search.unclosed_paren"))
+		}
+		p.next()
+		return node, nil
+	case searchTokenPhrase:
+		return &SearchNode{Kind: searchNodeTerm, Value: tok.value, Pos: tok.pos}, nil
+	case searchTokenWord:
+		return parseSearchTerm(tok)
+	case searchTokenEOF:
+		return nil, &SearchQueryError{Position: tok.pos, Message: localize("search.missing_term")}
+	case searchTokenRParen:
+		return nil, tokenError(tok, localize("search.extra_rparen"))
+	default:
+		return nil, tokenError(tok, localize("search.missing_term_before", tok.text))
+	}
+}
+
+// parseSearchTerm 解析单个条件，校验字段值的格式
+func parseSearchTerm(tok searchToken) (*SearchNode, error) {
+	node := &SearchNode{Kind: searchNodeTerm, Field: tok.field, Value: tok.value, Pos: tok.pos}
+	if tok.field == searchFieldText {
+		return node, nil
+	}
+	if tok.value == "" {
+		return nil, tokenError(tok, localize("search.missing_value", tok.field))
+	}
+
+	switch tok.field {
+	case searchFieldCopies:
+		node.Op, node.Value = splitSearchOperator(tok.value)
+		if _, err := strconv.Atoi(node.Value); err != nil {
+			return nil, tokenError(tok, localize("search.invalid_count", node.Value))
+		}
+	case searchFieldCreated:
+		node.Op, node.Value = splitSearchOperator(tok.value)
+		if _, err := time.Parse("2006-01-02", node.Value); err != nil {
+			return nil, tokenError(tok, localize("search.invalid_date", node.Value))
+		}
+	}
+	return node, nil
+}
+
+// splitSearchOperator 拆分比较运算符和值，没有运算符时视为等于
+func splitSearchOperator(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "=", value
+}
+
+func tokenError(tok searchToken, message string) *SearchQueryError {
+	return &SearchQueryError{Position: tok.pos, Length: max(tok.length, utf8.RuneCountInString(tok.text)), Message: message}
+}
+
+// Compile 将语法树编译为针对commands表（别名c）的参数化WHERE条件
+func (n *SearchNode) Compile() (string, []interface{}) {
+	switch n.Kind {
+	case searchNodeAnd, searchNodeOr:
+		left, leftArgs := n.Children[0].Compile()
+		right, rightArgs := n.Children[1].Compile()
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(n.Kind), right), append(leftArgs, rightArgs...)
+	case searchNodeNot:
+		cond, args := n.Children[0].Compile()
+		return "NOT (" + cond + ")", args
+	}
+
+	switch n.Field {
+	case searchFieldTag:
+		// 按标签筛选时包含所有子标签下的指令
+		subtree := fmt.Sprintf(tagSubtreeQuery, "name = ? COLLATE NOCASE")
+		return "EXISTS (SELECT 1 FROM command_tags ct WHERE ct.command_id = c.id AND ct.tag_id IN (" + subtree + "))", []interface{}{n.Value}
+	case searchFieldCol:
+		return `EXISTS (SELECT 1 FROM command_collections cc JOIN collections col ON col.id = cc.collection_id
+			WHERE cc.command_id = c.id AND col.deleted_at IS NULL AND col.name = ? COLLATE NOCASE)`, []interface{}{n.Value}
+	case searchFieldOs:
+		// os:all 不限制OS；其他OS同时匹配适用于所有OS的指令
+		values := osFilterValues([]string{n.Value})
+		if len(values) == 0 {
+			return "1 = 1", nil
+		}
+		return "EXISTS (SELECT 1 FROM command_os o WHERE o.command_id = c.id AND o.os IN " + inPlaceholders(len(values)) + ")", toArgs(values)
+	case searchFieldCopies:
+		count, _ := strconv.Atoi(n.Value)
+		return fmt.Sprintf("c.copy_count %s ?", n.Op), []interface{}{count}
+	case searchFieldCreated:
+		return compileCreatedCondition(n.Op, n.Value)
+	default:
+		pattern := "%" + escapeLike(n.Value) + "%"
+		return `(c.name LIKE ? ESCAPE '\' OR c.content LIKE ? ESCAPE '\' OR c.description LIKE ? ESCAPE '\')`,
+			[]interface{}{pattern, pattern, pattern}
+	}
+}
+
+// compileCreatedCondition created_at以 "2006-01-02 15:04:05" 格式保存，
+// 按天比较时换算为半开区间，例如 created:<=2026-01-01 即 created_at < 2026-01-02
+func compileCreatedCondition(op, value string) (string, []interface{}) {
+	day, _ := time.Parse("2006-01-02", value)
+	start := day.Format("2006-01-02")
+	end := day.AddDate(0, 0, 1).Format("2006-01-02")
+	switch op {
+	case ">":
+		return "c.created_at >= ?", []interface{}{end}
+	case ">=":
+		return "c.created_at >= ?", []interface{}{start}
+	case "<":
+		return "c.created_at < ?", []interface{}{start}
+	case "<=":
+		return "c.created_at < ?", []interface{}{end}
+	default:
+		return "(c.created_at >= ? AND c.created_at < ?)", []interface{}{start, end}
+	}
+}
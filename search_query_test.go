@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchQueryCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			input:    "ls -la",
+			wantSQL:  `((c.name LIKE ? ESCAPE '\' OR c.content LIKE ? ESCAPE '\' OR c.description LIKE ? ESCAPE '\') AND (c.name LIKE ? ESCAPE '\' OR c.content LIKE ? ESCAPE '\' OR c.description LIKE ? ESCAPE '\'))`,
+			wantArgs: []interface{}{"%ls%", "%ls%", "%ls%", "%-la%", "%-la%", "%-la%"},
+		},
+		{
+			input:    "copies:>5 OR created:<2026-01-01",
+			wantSQL:  "(c.copy_count > ? OR c.created_at < ?)",
+			wantArgs: []interface{}{5, "2026-01-01"},
+		},
+		{
+			input:    `-os:Windows (copies:<=1 AND created:2026-03-04)`,
+			wantSQL:  "(NOT (EXISTS (SELECT 1 FROM command_os o WHERE o.command_id = c.id AND o.os IN (?,?))) AND (c.copy_count <= ? AND (c.created_at >= ? AND c.created_at < ?)))",
+			wantArgs: []interface{}{"windows", "all", 1, "2026-03-04", "2026-03-05"},
+		},
+		{
+			input:    "docker os:all",
+			wantSQL:  `((c.name LIKE ? ESCAPE '\' OR c.content LIKE ? ESCAPE '\' OR c.description LIKE ? ESCAPE '\') AND 1 = 1)`,
+			wantArgs: []interface{}{"%docker%", "%docker%", "%docker%"},
+		},
+	}
+	for _, tt := range tests {
+		node, err := ParseSearchQuery(tt.input)
+		if err != nil {
+			t.Fatalf("ParseSearchQuery(%q): %v", tt.input, err)
+		}
+		sql, args := node.Compile()
+		if sql != tt.wantSQL {
+			t.Errorf("ParseSearchQuery(%q) sql = %s\nwant %s", tt.input, sql, tt.wantSQL)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("ParseSearchQuery(%q) args = %v, want %v", tt.input, args, tt.wantArgs)
+		}
+	}
+}
+
+func TestParseSearchQueryFieldPhrase(t *testing.T) {
+	node, err := ParseSearchQuery(`tag:"my tag" -tag:deprecated`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := node.Children[0], node.Children[1]
+	if first.Field != searchFieldTag || first.Value != "my tag" {
+		t.Errorf("first term = %+v", first)
+	}
+	if second.Kind != searchNodeNot || second.Children[0].Value != "deprecated" {
+		t.Errorf("second term = %+v", second)
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		position int
+	}{
+		{`docker "compose`, 7},
+		{"(tag:docker OR col:deploy", 0},
+		{"tag:docker )", 11},
+		{"copies:>many", 0},
+		{"创建 created:2026-13-01", 3},
+		{"docker OR", 9},
+		{"tag:", 0},
+	}
+	for _, tt := range tests {
+		_, err := ParseSearchQuery(tt.input)
+		var queryErr *SearchQueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("ParseSearchQuery(%q) error = %v, want SearchQueryError", tt.input, err)
+			continue
+		}
+		if queryErr.Position != tt.position {
+			t.Errorf("ParseSearchQuery(%q) position = %d, want %d", tt.input, queryErr.Position, tt.position)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// 搜索结果的默认排序把frecency和全文相关度合在一起：
+//
+//	rank = stored_frecency + ftsRelevanceWeight * bm25
+//
+// stored_frecency是以2为底的对数评分（见frecency.go），相关度每高2分相当于实际评分翻一倍，
+// 名称中命中一个不常见的词大约相当于实际评分高出五六倍。
+// 两项都不随时间变化，分页过程中排序保持稳定，游标可以继续使用。
+// 从未使用过的指令frecency为0，排在所有用过的指令之后，彼此之间按相关度排序。
+//
+// 全文索引commands_fts只用于计算相关度，是否匹配仍然由搜索条件的LIKE决定，
+// 所以 "ls -la" 这类索引分词后丢失符号的搜索结果不变，只是相关度为0。
+const ftsRelevanceWeight = 0.5
+
+// BM25参数，取常用的默认值
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// ftsColumnWeights commands_fts各列（name, content, description）的权重，名称中的匹配最重要
+var ftsColumnWeights = []float64{3, 1, 1}
+
+// ftsRankFunction 在连接上注册的BM25函数名，参数为 matchinfo(commands_fts, 'pcnalx')
+const ftsRankFunction = "fts_bm25"
+
+// commandRelevanceSQL 指令的搜索排序表达式，?为FTS的MATCH表达式
+var commandRelevanceSQL = fmt.Sprintf("c.frecency + %g * COALESCE((SELECT %s(matchinfo(commands_fts, 'pcnalx')) FROM commands_fts"+
+	" WHERE commands_fts MATCH ? AND commands_fts.docid = c.id), 0)", ftsRelevanceWeight, ftsRankFunction)
+
+// ftsTokenPattern 与unicode61分词器一致，连续的字母和数字组成一个词，其余字符都是分隔符
+var ftsTokenPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// textTerms 返回语法树中参与全文匹配的搜索词，NOT下的词只用于排除，不参与相关度
+func (n *SearchNode) textTerms() []string {
+	switch n.Kind {
+	case searchNodeNot:
+		return nil
+	case searchNodeAnd, searchNodeOr:
+		var terms []string
+		for _, child := range n.Children {
+			terms = append(terms, child.textTerms()...)
+		}
+		return terms
+	}
+	if n.Field != searchFieldText {
+		return nil
+	}
+	return []string{n.Value}
+}
+
+// ftsMatchQuery 将搜索词转换为FTS4的MATCH表达式：每个词按前缀匹配，命中任一个词即可，
+// 命中的词越多相关度越高。没有可以匹配的词时返回空字符串
+func ftsMatchQuery(terms []string) string {
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		for _, token := range ftsTokenPattern.FindAllString(strings.ToLower(term), -1) {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token+"*")
+			}
+		}
+	}
+	return strings.Join(tokens, " OR ")
+}
+
+// ftsBM25 根据 matchinfo(commands_fts, 'pcnalx') 的结果计算Okapi BM25相关度。
+// matchinfo按本机字节序返回无符号32位整数：
+// 短语数p、列数c、总行数n、各列平均词数a、当前行各列词数l，以及每个短语在每列中的
+// 当前行命中次数、所有行命中次数和命中的行数x
+func ftsBM25(info []byte) float64 {
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 3 {
+		return 0
+	}
+	phrases, columns, rows := int(values[0]), int(values[1]), float64(values[2])
+	if len(values) < 3+2*columns+3*phrases*columns {
+		return 0
+	}
+	avgLength := values[3 : 3+columns]
+	length := values[3+columns : 3+2*columns]
+	hits := values[3+2*columns:]
+
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for col := 0; col < columns; col++ {
+			hit := hits[3*(p*columns+col):]
+			tf, df := float64(hit[0]), float64(hit[2])
+			if tf == 0 {
+				continue
+			}
+			// 加1保证常见词的idf也是正数，不会因为命中反而降低相关度
+			idf := math.Log(1 + (rows-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(length[col])/math.Max(float64(avgLength[col]), 1)
+			weight := 1.0
+			if col < len(ftsColumnWeights) {
+				weight = ftsColumnWeights[col]
+			}
+			score += weight * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return score
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestFTSMatchQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"docker", "docker*"},
+		{"Docker PS", "docker* OR ps*"},
+		{`"docker compose" up`, "docker* OR compose* OR up*"},
+		{"ls -la", "ls* OR la*"},
+		{"docker -tag:old NOT logs", "docker*"},
+		{"docker OR docker", "docker*"},
+		{`"OR" NEAR`, "or* OR near*"},
+		{"tag:docker copies:>1", ""},
+		{"-- ||", ""},
+	}
+	for _, tt := range tests {
+		node, err := ParseSearchQuery(tt.input)
+		if err != nil {
+			t.Fatalf("ParseSearchQuery(%q): %v", tt.input, err)
+		}
+		if got := ftsMatchQuery(node.textTerms()); got != tt.want {
+			t.Errorf("ftsMatchQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// matchinfo 构造一个短语、三列、共10行时 matchinfo(commands_fts, 'pcnalx') 的结果，
+// length为当前行各列的词数，hits为每列的 当前行命中次数、所有行命中次数、命中的行数
+func matchinfo(length [3]uint32, hits ...uint32) []byte {
+	values := append([]uint32{1, 3, 10, 2, 4, 4}, length[:]...)
+	values = append(values, hits...)
+	var info []byte
+	for _, v := range values {
+		info = binary.NativeEndian.AppendUint32(info, v)
+	}
+	return info
+}
+
+func TestFTSBM25(t *testing.T) {
+	length := [3]uint32{2, 4, 4}
+	tests := []struct {
+		name   string
+		higher []byte
+		lower  []byte
+	}{
+		{"name over content", matchinfo(length, 1, 1, 1, 0, 1, 1, 0, 0, 0), matchinfo(length, 0, 1, 1, 1, 1, 1, 0, 0, 0)},
+		{"more hits", matchinfo(length, 0, 0, 0, 2, 2, 1, 0, 0, 0), matchinfo(length, 0, 0, 0, 1, 1, 1, 0, 0, 0)},
+		{"rare term", matchinfo(length, 0, 0, 0, 1, 1, 1, 0, 0, 0), matchinfo(length, 0, 0, 0, 1, 9, 9, 0, 0, 0)},
+		{"shorter row", matchinfo([3]uint32{1, 2, 4}, 1, 1, 1, 0, 0, 0, 0, 0, 0), matchinfo([3]uint32{6, 2, 4}, 1, 1, 1, 0, 0, 0, 0, 0, 0)},
+		{"any hit over none", matchinfo(length, 0, 0, 0, 1, 10, 10, 0, 0, 0), matchinfo(length, 0, 0, 0, 0, 0, 0, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			higher, lower := ftsBM25(tt.higher), ftsBM25(tt.lower)
+			if higher <= lower || lower < 0 {
+				t.Errorf("ftsBM25 = %v, %v, want the first to be higher and both non-negative", higher, lower)
+			}
+		})
+	}
+	if got := ftsBM25(matchinfo(length)[:20]); got != 0 {
+		t.Errorf("ftsBM25(truncated) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 疑似密钥改写的方式
+const (
+	SecretRewriteVariable = "variable" // 改写为模板变量 {{name}}，使用时再填写
+	SecretRewriteVault    = "vault"    // 存入密钥库并改写为 {{secret:name}}
+)
+
+const (
+	// minEntropyTokenLen 参与熵检测的最短字符串长度
+	minEntropyTokenLen = 20
+	// base64EntropyThreshold、hexEntropyThreshold 香农熵（比特/字符）超过阈值视为随机生成的密钥
+	base64EntropyThreshold = 4.2
+	hexEntropyThreshold    = 3.0
+)
+
+// SecretFinding 指令内容中一处疑似密钥，Start、End为字节偏移，[Start, End)正好是密钥本身
+type SecretFinding struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Preview     string `json:"preview"`    // 只保留开头几个字符的预览，不返回完整的密钥
+	Suggestion  string `json:"suggestion"` // 建议的变量名或密钥名
+	// Os、Shell 所在的变体，都为空表示默认内容
+	Os    string `json:"os,omitempty"`
+	Shell string `json:"shell,omitempty"`
+}
+
+// secretPattern 一种常见凭据的格式，正则的第1个分组是密钥本身，说明在消息目录中（secret.kind.<kind>）
+type secretPattern struct {
+	kind       string
+	suggestion string
+	re         *regexp.Regexp
+}
+
+// secretPatterns 按优先级排列，位置重叠时保留靠前的规则
+var secretPatterns = []secretPattern{
+	{"private_key", "private_key", regexp.MustCompile(`(-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$))`)},
+	{"aws_access_key", "aws_access_key_id", regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+	{"aws_secret_key", "aws_secret_access_key", regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`)},
+	{"github_token", "github_token", regexp.MustCompile(`\b((?:gh[pousr]_[A-Za-z0-9]{36,})|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"gitlab_token", "gitlab_token", regexp.MustCompile(`\b(glpat-[A-Za-z0-9_-]{20,})`)},
+	{"slack_token", "slack_token", regexp.MustCompile(`\b(xox[abprs]-[A-Za-z0-9-]{10,})`)},
+	{"stripe_key", "stripe_key", regexp.MustCompile(`\b((?:sk|rk)_live_[A-Za-z0-9]{16,})`)},
+	{"openai_key", "api_key", regexp.MustCompile(`\b(sk-[A-Za-z0-9_-]{32,})`)},
+	{"jwt", "jwt", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,})`)},
+	{"bearer_token", "bearer_token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/=-]{16,})`)},
+	{"url_password", "password", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@'"]+:([^\s@/'"]+)@`)},
+	{"password_flag", "password", regexp.MustCompile(`(?i)--(?:password|passwd|token|api-key|apikey|secret|client-secret)(?:=|\s+)["']?([^\s"']{4,})`)},
+	{"password_assignment", "password", regexp.MustCompile(`(?i)\b[A-Za-z_]*(?:password|passwd|pwd|secret|token|api_?key|access_?key)["']?\s*[=:]\s*["']?([^\s"'&;]{6,})`)},
+}
+
+// entropyTokenPattern 熵检测只考察由base64、hex和常见分隔字符组成的连续字符串
+var entropyTokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_-]{20,}`)
+
+// placeholderPattern 模板变量和密钥引用，其中的内容不是密钥
+var placeholderPattern = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// hexPattern 纯十六进制字符串
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// ScanSecrets 检测content中的疑似密钥：先匹配常见凭据格式，再检测高熵字符串。
+// 模板变量、密钥引用和shell变量不视为密钥
+func ScanSecrets(content string) []*SecretFinding {
+	var findings []*SecretFinding
+	placeholders := placeholderPattern.FindAllStringIndex(content, -1)
+	overlaps := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.End && f.Start < end {
+				return true
+			}
+		}
+		for _, p := range placeholders {
+			if start < p[1] && p[0] < end {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(kind, suggestion string, start, end int) {
+		if end <= start || overlaps(start, end) || isPlaceholderValue(content[start:end]) {
+			return
+		}
+		findings = append(findings, &SecretFinding{
+			Kind:        kind,
+			Description: localize("secret.kind." + kind),
+			Start:       start,
+			End:         end,
+			Preview:     previewSecret(content[start:end]),
+			Suggestion:  suggestion,
+		})
+	}
+
+	for _, p := range secretPatterns {
+		for _, m := range p.re.FindAllStringSubmatchIndex(content, -1) {
+			add(p.kind, p.suggestion, m[2], m[3])
+		}
+	}
+	for _, m := range entropyTokenPattern.FindAllStringIndex(content, -1) {
+		if looksRandom(content[m[0]:m[1]]) {
+			add("high_entropy", "secret", m[0], m[1])
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	for _, f := range findings {
+		f.Line, f.Column = offsetPosition(content, f.Start)
+	}
+	return findings
+}
+
+// redactSecrets 将content中的疑似密钥替换为redactedLogValue，用于导出明文内容前去掉密钥
+func redactSecrets(content string) string {
+	findings := ScanSecrets(content)
+	if len(findings) == 0 {
+		return content
+	}
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(content[last:f.Start])
+		b.WriteString(redactedLogValue)
+		last = f.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// ScanCommandSecrets 检测指令默认内容和所有变体中的疑似密钥
+func ScanCommandSecrets(cmd *Command) []*SecretFinding {
+	findings := ScanSecrets(cmd.Content)
+	for _, v := range cmd.Variants {
+		for _, f := range ScanSecrets(v.Content) {
+			f.Os, f.Shell = v.Os, v.Shell
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// isPlaceholderValue 模板变量、密钥引用和shell变量只是占位，不是密钥本身
+func isPlaceholderValue(value string) bool {
+	return strings.HasPrefix(value, "{{") || strings.HasPrefix(value, "$") || strings.HasPrefix(value, "%")
+}
+
+// looksRandom 判断字符串是否像随机生成的密钥：同时包含字母和数字，且香农熵足够高
+func looksRandom(s string) bool {
+	if len(s) < minEntropyTokenLen || !strings.ContainsAny(s, "0123456789") || strings.Trim(s, "0123456789") == "" {
+		return false
+	}
+	entropy := shannonEntropy(s)
+	if hexPattern.MatchString(s) {
+		return entropy >= hexEntropyThreshold
+	}
+	return entropy >= base64EntropyThreshold
+}
+
+// shannonEntropy 计算字符串每个字符的香农熵（比特）
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var entropy float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// previewSecret 只保留开头4个字符
+func previewSecret(value string) string {
+	if len(value) <= 8 {
+		return secretMask
+	}
+	return value[:4] + secretMask
+}
+
+// offsetPosition 将字节偏移换算为从1开始的行号和列号（列按字节计算）
+func offsetPosition(content string, offset int) (line, column int) {
+	before := content[:offset]
+	line = strings.Count(before, "\n") + 1
+	return line, offset - strings.LastIndex(before, "\n")
+}
+
+// SecretRewrite 对一处疑似密钥的改写
+type SecretRewrite struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Mode  string `json:"mode"` // variable 或 vault
+	Name  string `json:"name"`
+}
+
+// rewriteSecrets 按rewrites把content中的密钥替换为模板变量或密钥引用，
+// 返回改写后的内容和需要存入密钥库的密钥（名称到原值）
+func rewriteSecrets(content string, rewrites []*SecretRewrite) (string, map[string]string, error) {
+	sorted := append([]*SecretRewrite(nil), rewrites...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var b strings.Builder
+	secrets := make(map[string]string)
+	last := 0
+	for _, r := range sorted {
+		if r.Start < last || r.End <= r.Start || r.End > len(content) {
+			return "", nil, NewValidationError("rewrites", "secret_rewrite.invalid_span", r.Start, r.End)
+		}
+		value := content[r.Start:r.End]
+		var ref string
+		switch r.Mode {
+		case SecretRewriteVariable:
+			if !templateVarNamePattern.MatchString(r.Name) {
+				return "", nil, NewValidationError("name", "secret_rewrite.invalid_variable", r.Name)
+			}
+			ref = "{{" + r.Name + "}}"
+		case SecretRewriteVault:
+			if err := ValidateSecretName(r.Name); err != nil {
+				return "", nil, err
+			}
+			if old, ok := secrets[r.Name]; ok && old != value {
+				return "", nil, NewValidationError("name", "secret_rewrite.name_reused", r.Name)
+			}
+			secrets[r.Name] = value
+			ref = "{{secret:" + r.Name + "}}"
+		default:
+			return "", nil, NewValidationError("mode", "secret_rewrite.invalid_mode", r.Mode)
+		}
+		b.WriteString(content[last:r.Start])
+		b.WriteString(ref)
+		last = r.End
+	}
+	b.WriteString(content[last:])
+	return b.String(), secrets, nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 界面主题
+const (
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+const (
+	// defaultDBProfile 默认的数据库配置，对应 quick-cmd.db
+	defaultDBProfile = "default"
+	// dbProfileFile 配置目录下保存当前数据库配置名的文件。设置保存在数据库中，
+	// 打开数据库之前无法读取，所以数据库配置单独保存
+	dbProfileFile = "profile"
+
+	// 统计窗口和回收站保留天数的取值范围
+	minTypedCommandWindowDays = 1
+	maxTypedCommandWindowDays = 365
+	maxTrashRetentionDays     = 3650
+)
+
+// 设置表中各字段对应的键，值都是JSON
+const (
+	settingOs                     = "os"
+	settingSort                   = "sort"
+	settingDefaultShell           = "default_shell"
+	settingTheme                  = "theme"
+	settingTypedCommandWindowDays = "typed_command_window_days"
+	settingTrashRetentionDays     = "trash_retention_days"
+	settingAPIServer              = "api_server"
+	settingLog                    = "log"
+)
+
+// dbProfilePattern 数据库配置名会成为文件名的一部分，只允许字母、数字、下划线和连字符
+var dbProfilePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Settings 用户设置，每个字段在settings表中保存为一行
+type Settings struct {
+	Os           []string  `json:"os"`           // 启动时默认的OS筛选条件
+	Sort         SortIndex `json:"sort"`         // 列表各排序字段是否升序
+	DefaultShell string    `json:"defaultShell"` // 运行指令和选择变体时使用的shell，为空表示自动检测
+	Theme        string    `json:"theme"`        // system、light或dark
+	Locale       string    `json:"locale"`       // 界面语言，为空表示跟随系统
+	DBProfile    string    `json:"dbProfile"`    // 使用的数据库配置，重启后生效
+	// TypedCommandWindowDays shell钩子上报命令的统计窗口（天），窗口之外的记录会被清理
+	TypedCommandWindowDays int `json:"typedCommandWindowDays"`
+	// TrashRetentionDays 清理回收站时保留最近多少天删除的指令、标签和集合，0表示永久保留。不会自动清理
+	TrashRetentionDays int               `json:"trashRetentionDays"`
+	APIServer          APIServerSettings `json:"apiServer"`
+	Log                LogSettings       `json:"log"`
+}
+
+// LogValue 将设置逐项记录，使每一项都经过redactLogAttr，以后增加的敏感设置也能按键名隐藏
+func (s Settings) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("os", s.Os),
+		slog.Any("sort", s.Sort),
+		slog.String("defaultShell", s.DefaultShell),
+		slog.String("theme", s.Theme),
+		slog.String("locale", s.Locale),
+		slog.String("dbProfile", s.DBProfile),
+		slog.Int("typedCommandWindowDays", s.TypedCommandWindowDays),
+		slog.Int("trashRetentionDays", s.TrashRetentionDays),
+		slog.Group("apiServer", "enabled", s.APIServer.Enabled, "address", s.APIServer.Address, "readOnly", s.APIServer.ReadOnly),
+		slog.Group("log", "level", s.Log.Level, "output", s.Log.Output),
+	)
+}
+
+// APIServerSettings 本地REST接口服务的开关
+type APIServerSettings struct {
+	Enabled  bool   `json:"enabled"`
+	Address  string `json:"address"`  // 监听地址，建议只监听本机
+	ReadOnly bool   `json:"readOnly"` // 只允许查询，不允许修改
+}
+
+var (
+	settingsMu    sync.RWMutex
+	settingsValue = defaultSettings()
+)
+
+// defaultSettings 没有保存过的设置项使用的默认值，OS筛选条件默认为当前系统
+func defaultSettings() Settings {
+	return Settings{
+		Os: []string{currentOS()},
+		Sort: SortIndex{
+			CreatTimeAsc: true,
+			IDAsc:        true,
+			NameAsc:      true,
+			SortValueAsc: true,
+			CopyCountAsc: true,
+		},
+		Theme:                  ThemeSystem,
+		DBProfile:              defaultDBProfile,
+		TypedCommandWindowDays: 7,
+		APIServer: APIServerSettings{
+			Address: "127.0.0.1:17890",
+		},
+		Log: LogSettings{
+			Level:  LogLevelInfo,
+			Output: LogOutputFile,
+		},
+	}
+}
+
+// currentSettings 返回当前生效的设置
+func currentSettings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	s := settingsValue
+	s.Os = append([]string(nil), settingsValue.Os...)
+	return s
+}
+
+// setCurrentSettings 替换当前生效的设置，同时应用语言和日志设置
+func setCurrentSettings(s Settings) {
+	settingsMu.Lock()
+	settingsValue = s
+	settingsMu.Unlock()
+	setLocaleOverride(s.Locale)
+	configureLogging(s.Log)
+}
+
+// loadSettings 启动时从数据库读取设置，读取失败的项使用默认值
+func loadSettings(ctx context.Context) {
+	s, err := readSettings(ctx)
+	if err != nil {
+		slog.Warn("读取设置失败，使用默认设置", "err", err)
+	}
+	setCurrentSettings(s)
+}
+
+// readSettings 读取所有设置，没有保存过的项使用默认值
+func readSettings(ctx context.Context) (Settings, error) {
+	s := defaultSettings()
+	s.DBProfile = activeDBProfile()
+	values, err := GetSettingsSQLite(ctx)
+	if err != nil {
+		return s, err
+	}
+	if locale, ok := normalizeLocale(values[settingLocale]); ok {
+		s.Locale = locale
+	}
+	for key, target := range s.fields() {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		if err = json.Unmarshal([]byte(value), target); err != nil {
+			slog.Warn("设置格式不正确，使用默认值", "key", key, "err", err)
+		}
+	}
+	// 单独的项格式不正确时回退到默认值，避免整份设置不可用
+	if err = s.Validate(); err != nil {
+		slog.Warn("已保存的设置无效，使用默认设置", "err", err)
+		fallback := defaultSettings()
+		fallback.Locale, fallback.DBProfile = s.Locale, s.DBProfile
+		return fallback, nil
+	}
+	return s, nil
+}
+
+// fields 设置表的键与字段的对应关系，语言和数据库配置单独保存
+func (s *Settings) fields() map[string]interface{} {
+	return map[string]interface{}{
+		settingOs:                     &s.Os,
+		settingSort:                   &s.Sort,
+		settingDefaultShell:           &s.DefaultShell,
+		settingTheme:                  &s.Theme,
+		settingTypedCommandWindowDays: &s.TypedCommandWindowDays,
+		settingTrashRetentionDays:     &s.TrashRetentionDays,
+		settingAPIServer:              &s.APIServer,
+		settingLog:                    &s.Log,
+	}
+}
+
+// encode 将设置转换为设置表的键值
+func (s *Settings) encode() (map[string]string, error) {
+	values := map[string]string{settingLocale: s.Locale}
+	for key, field := range s.fields() {
+		data, err := json.Marshal(field)
+		if err != nil {
+			return nil, fmt.Errorf("编码设置%s失败: %w", key, err)
+		}
+		values[key] = string(data)
+	}
+	return values, nil
+}
+
+// Validate 校验并规范化设置，错误的Field为对应的JSON字段名
+func (s *Settings) Validate() error {
+	osList, err := NormalizeOSList("os", s.Os)
+	if err != nil {
+		return err
+	}
+	s.Os = osList
+
+	s.DefaultShell = strings.ToLower(strings.TrimSpace(s.DefaultShell))
+	if s.DefaultShell != "" && !supportedShells[s.DefaultShell] {
+		return NewValidationError("defaultShell", "settings.invalid_shell", s.DefaultShell)
+	}
+
+	switch s.Theme {
+	case ThemeSystem, ThemeLight, ThemeDark:
+	case "":
+		s.Theme = ThemeSystem
+	default:
+		return NewValidationError("theme", "settings.invalid_theme", s.Theme)
+	}
+
+	if s.Locale != "" {
+		locale, ok := normalizeLocale(s.Locale)
+		if !ok {
+			return NewValidationError("locale", "locale.unsupported", s.Locale)
+		}
+		s.Locale = locale
+	}
+
+	if s.DBProfile == "" {
+		s.DBProfile = defaultDBProfile
+	}
+	if !dbProfilePattern.MatchString(s.DBProfile) {
+		return NewValidationError("dbProfile", "settings.invalid_db_profile", s.DBProfile)
+	}
+
+	if s.TypedCommandWindowDays < minTypedCommandWindowDays || s.TypedCommandWindowDays > maxTypedCommandWindowDays {
+		return NewValidationError("typedCommandWindowDays", "settings.invalid_window_days", minTypedCommandWindowDays, maxTypedCommandWindowDays)
+	}
+	if s.TrashRetentionDays < 0 || s.TrashRetentionDays > maxTrashRetentionDays {
+		return NewValidationError("trashRetentionDays", "settings.invalid_retention_days", maxTrashRetentionDays)
+	}
+
+	s.APIServer.Address = strings.TrimSpace(s.APIServer.Address)
+	if !validListenAddress(s.APIServer.Address) {
+		return NewValidationError("apiServer.address", "settings.invalid_api_address", s.APIServer.Address)
+	}
+
+	s.Log.Level = strings.ToLower(strings.TrimSpace(s.Log.Level))
+	if s.Log.Level == "" {
+		s.Log.Level = LogLevelInfo
+	}
+	if _, ok := parseLogLevel(s.Log.Level); !ok {
+		return NewValidationError("log.level", "settings.invalid_log_level", s.Log.Level)
+	}
+	s.Log.Output = strings.ToLower(strings.TrimSpace(s.Log.Output))
+	switch s.Log.Output {
+	case LogOutputFile, LogOutputStderr, LogOutputBoth:
+	case "":
+		s.Log.Output = LogOutputFile
+	default:
+		return NewValidationError("log.output", "settings.invalid_log_output", s.Log.Output)
+	}
+	return nil
+}
+
+// validListenAddress 校验host:port形式的监听地址
+func validListenAddress(address string) bool {
+	_, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	port, err := strconv.Atoi(portText)
+	return err == nil && port > 0 && port <= 65535
+}
+
+// activeDBProfile 读取当前使用的数据库配置，没有设置过时返回默认配置
+func activeDBProfile() string {
+	dir, err := quickcmdConfigDir()
+	if err != nil {
+		return defaultDBProfile
+	}
+	data, err := os.ReadFile(filepath.Join(dir, dbProfileFile))
+	if err != nil {
+		return defaultDBProfile
+	}
+	if profile := strings.TrimSpace(string(data)); dbProfilePattern.MatchString(profile) {
+		return profile
+	}
+	return defaultDBProfile
+}
+
+// saveDBProfile 保存下次启动时使用的数据库配置
+func saveDBProfile(profile string) error {
+	dir, err := quickcmdConfigDir()
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(filepath.Join(dir, dbProfileFile), []byte(profile+"\n")); err != nil {
+		return fmt.Errorf("保存数据库配置失败: %w", err)
+	}
+	return nil
+}
+
+// dbProfileFileName 数据库配置对应的数据库文件，默认配置沿用 quick-cmd.db
+func dbProfileFileName(profile string) string {
+	if profile == "" || profile == defaultDBProfile {
+		return "quick-cmd.db"
+	}
+	return "quick-cmd-" + profile + ".db"
+}
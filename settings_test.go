@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Settings)
+		field  string
+	}{
+		{"default", func(s *Settings) {}, ""},
+		{"os normalized", func(s *Settings) { s.Os = []string{" Linux", "mac", "linux"} }, ""},
+		{"invalid os", func(s *Settings) { s.Os = []string{"beos"} }, "os"},
+		{"shell", func(s *Settings) { s.DefaultShell = "ZSH" }, ""},
+		{"invalid shell", func(s *Settings) { s.DefaultShell = "tcsh" }, "defaultShell"},
+		{"empty theme", func(s *Settings) { s.Theme = "" }, ""},
+		{"invalid theme", func(s *Settings) { s.Theme = "blue" }, "theme"},
+		{"locale", func(s *Settings) { s.Locale = "en_US.UTF-8" }, ""},
+		{"invalid locale", func(s *Settings) { s.Locale = "fr" }, "locale"},
+		{"invalid profile", func(s *Settings) { s.DBProfile = "../work" }, "dbProfile"},
+		{"window too small", func(s *Settings) { s.TypedCommandWindowDays = 0 }, "typedCommandWindowDays"},
+		{"negative retention", func(s *Settings) { s.TrashRetentionDays = -1 }, "trashRetentionDays"},
+		{"invalid address", func(s *Settings) { s.APIServer.Address = "localhost" }, "apiServer.address"},
+		{"invalid port", func(s *Settings) { s.APIServer.Address = "127.0.0.1:70000" }, "apiServer.address"},
+		{"log level", func(s *Settings) { s.Log.Level = " DEBUG" }, ""},
+		{"empty log settings", func(s *Settings) { s.Log = LogSettings{} }, ""},
+		{"invalid log level", func(s *Settings) { s.Log.Level = "trace" }, "log.level"},
+		{"invalid log output", func(s *Settings) { s.Log.Output = "syslog" }, "log.output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := defaultSettings()
+			tt.modify(&s)
+			err := s.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			var appErr *AppError
+			if !errors.As(err, &appErr) || appErr.Code != CodeValidation || appErr.Field != tt.field {
+				t.Fatalf("Validate() = %v, want validation error on %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestSettingsNormalize(t *testing.T) {
+	s := defaultSettings()
+	s.Os = []string{" Linux", "mac", "linux"}
+	s.DefaultShell = "ZSH"
+	s.Theme = ""
+	s.Locale = "en_US.UTF-8"
+	s.DBProfile = ""
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Os, []string{Linux, Mac}) || s.DefaultShell != "zsh" || s.Theme != ThemeSystem ||
+		s.Locale != LocaleEN || s.DBProfile != defaultDBProfile {
+		t.Errorf("Validate() normalized to %+v", s)
+	}
+}
+
+func TestSettingsEncode(t *testing.T) {
+	s := defaultSettings()
+	s.Locale = LocaleEN
+	s.TrashRetentionDays = 30
+	values, err := s.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[settingLocale] != LocaleEN || values[settingTrashRetentionDays] != "30" || values[settingOs] != `["linux"]` {
+		t.Errorf("encode() = %v", values)
+	}
+	if _, ok := values["db_profile"]; ok {
+		t.Error("db profile should not be stored in the database")
+	}
+}
+
+func TestDBProfileFileName(t *testing.T) {
+	if got := dbProfileFileName(defaultDBProfile); got != "quick-cmd.db" {
+		t.Errorf("dbProfileFileName(default) = %s", got)
+	}
+	if got := dbProfileFileName("work"); got != "quick-cmd-work.db" {
+		t.Errorf("dbProfileFileName(work) = %s", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// 语法检查结果的严重程度
+const (
+	SyntaxError   = "error"   // 无法解析，不能保存
+	SyntaxWarning = "warning" // 可以保存，但在部分shell中可能无法执行
+)
+
+// SyntaxIssue 指令内容中的一处语法问题，行列号从1开始，列按字节计算
+type SyntaxIssue struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Line     uint   `json:"line"`
+	Column   uint   `json:"column"`
+	// Os、Shell 问题所在的变体，都为空表示默认内容
+	Os    string `json:"os,omitempty"`
+	Shell string `json:"shell,omitempty"`
+}
+
+// CommandSyntaxError 指令内容存在语法错误时返回的错误
+type CommandSyntaxError struct {
+	Issues []*SyntaxIssue
+}
+
+func (e *CommandSyntaxError) Error() string {
+	for _, issue := range e.Issues {
+		if issue.Severity == SyntaxError {
+			return localize("syntax.error_at", issue.Line, issue.Column, issue.Message)
+		}
+	}
+	return localize("syntax.error")
+}
+
+// CheckCommandSyntax 检查面向linux和mac的默认内容和变体能否被shell解析，cmd.SkipSyntaxCheck为true时不检查。
+// 需要在mergeVariantOs之前调用，以免把只属于变体的OS当作默认内容的目标系统
+func CheckCommandSyntax(cmd *Command) []*SyntaxIssue {
+	if cmd.skipSyntaxCheck() {
+		return nil
+	}
+	var issues []*SyntaxIssue
+	if targetsPosixOS(cmd.Os) {
+		issues = append(issues, checkShellSyntax(cmd.Content, "")...)
+	}
+	for _, v := range cmd.Variants {
+		if v.Os != Linux && v.Os != Mac {
+			continue
+		}
+		for _, issue := range checkShellSyntax(v.Content, v.Shell) {
+			issue.Os, issue.Shell = v.Os, v.Shell
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// hasSyntaxError 是否存在不能保存的语法错误
+func hasSyntaxError(issues []*SyntaxIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SyntaxError {
+			return true
+		}
+	}
+	return false
+}
+
+// targetsPosixOS 未指定OS或包含linux、mac时，默认内容需要按shell语法检查
+func targetsPosixOS(osList []string) bool {
+	if len(osList) == 0 {
+		return true
+	}
+	for _, os := range osList {
+		if os == Linux || os == Mac || os == AllOs {
+			return true
+		}
+	}
+	return false
+}
+
+// shellLangVariant 返回shell对应的解析语法，fish、PowerShell等不兼容POSIX的shell返回false
+func shellLangVariant(shell string) (syntax.LangVariant, bool) {
+	switch shell {
+	case "", "bash", "zsh":
+		return syntax.LangBash, true
+	case "sh", "dash":
+		return syntax.LangPOSIX, true
+	case "mksh":
+		return syntax.LangMirBSDKorn, true
+	default:
+		return 0, false
+	}
+}
+
+// checkShellSyntax 按shell的语法解析content。模板变量先替换为等长的占位符，行列号保持不变；
+// 未指定shell时按bash解析，另外用到POSIX sh不支持的语法时给出警告
+func checkShellSyntax(content, shell string) []*SyntaxIssue {
+	lang, ok := shellLangVariant(shell)
+	if !ok {
+		return nil
+	}
+	src := templateVarPattern.ReplaceAllStringFunc(content, func(m string) string {
+		return strings.Repeat("_", len(m))
+	})
+
+	if issue := parseShell(src, lang, SyntaxError); issue != nil {
+		return []*SyntaxIssue{issue}
+	}
+	if shell == "" {
+		if issue := parseShell(src, syntax.LangPOSIX, SyntaxWarning); issue != nil {
+			return []*SyntaxIssue{issue}
+		}
+	}
+	return nil
+}
+
+// parseShell 解析src，返回第一个问题，解析成功时返回nil
+func parseShell(src string, lang syntax.LangVariant, severity string) *SyntaxIssue {
+	_, err := syntax.NewParser(syntax.Variant(lang)).Parse(strings.NewReader(src), "")
+	if err == nil {
+		return nil
+	}
+
+	var parseErr syntax.ParseError
+	var langErr syntax.LangError
+	switch {
+	case errors.As(err, &parseErr):
+		msg := localize("syntax.parse_error", parseErr.Text)
+		if parseErr.Incomplete {
+			msg = localize("syntax.incomplete", parseErr.Text)
+		}
+		return &SyntaxIssue{Severity: severity, Message: msg, Line: parseErr.Pos.Line(), Column: parseErr.Pos.Col()}
+	case errors.As(err, &langErr):
+		return &SyntaxIssue{
+			Severity: severity,
+			Message:  localize("syntax.unsupported_feature", langErr.Feature, langErr.LangUsed),
+			Line:     langErr.Pos.Line(),
+			Column:   langErr.Pos.Col(),
+		}
+	default:
+		return &SyntaxIssue{Severity: severity, Message: err.Error(), Line: 1, Column: 1}
+	}
+}
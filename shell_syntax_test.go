@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestCheckShellSyntax(t *testing.T) {
+	tests := []struct {
+		content  string
+		shell    string
+		severity string
+		line     uint
+		column   uint
+	}{
+		{"ls -la | grep {{name}}", "", "", 0, 0},
+		{"echo 'unterminated", "", SyntaxError, 1, 6},
+		{"cat <<EOF\nhello\n", "bash", SyntaxError, 1, 5},
+		{"if true; then\n  echo ok\n", "", SyntaxError, 1, 1},
+		{"arr=(a b); echo ${arr[0]}", "", SyntaxWarning, 1, 5},
+		{"arr=(a b)", "sh", SyntaxError, 1, 5},
+		{"arr=(a b)", "bash", "", 0, 0},
+		{"echo 'unterminated", "fish", "", 0, 0},
+	}
+	for _, tt := range tests {
+		issues := checkShellSyntax(tt.content, tt.shell)
+		if tt.severity == "" {
+			if len(issues) != 0 {
+				t.Errorf("checkShellSyntax(%q, %q) = %+v, want no issues", tt.content, tt.shell, issues[0])
+			}
+			continue
+		}
+		if len(issues) != 1 {
+			t.Errorf("checkShellSyntax(%q, %q) returned %d issues, want 1", tt.content, tt.shell, len(issues))
+			continue
+		}
+		got := issues[0]
+		if got.Severity != tt.severity || got.Line != tt.line || got.Column != tt.column {
+			t.Errorf("checkShellSyntax(%q, %q) = %s %d:%d %s, want %s %d:%d",
+				tt.content, tt.shell, got.Severity, got.Line, got.Column, got.Message, tt.severity, tt.line, tt.column)
+		}
+	}
+}
+
+func TestCheckCommandSyntax(t *testing.T) {
+	cmd := &Command{
+		Content: "dir 'C:\\Program Files",
+		Os:      []string{Windows},
+		Variants: []*CommandVariant{
+			{Os: Linux, Content: "ls 'unterminated"},
+			{Os: Windows, Shell: "powershell", Content: "Get-ChildItem 'x"},
+		},
+	}
+	issues := CheckCommandSyntax(cmd)
+	if len(issues) != 1 || issues[0].Os != Linux || !hasSyntaxError(issues) {
+		t.Fatalf("CheckCommandSyntax = %+v, want one error in linux variant", issues)
+	}
+
+	skip := true
+	cmd.SkipSyntaxCheck = &skip
+	if issues := CheckCommandSyntax(cmd); len(issues) != 0 {
+		t.Errorf("CheckCommandSyntax with SkipSyntaxCheck = %+v, want none", issues)
+	}
+}
@@ -1,67 +1,144 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// sqliteDriverName 注册了自定义SQL函数的SQLite驱动
+const sqliteDriverName = "sqlite3_quickcmd"
+
 var (
 	DB *sql.DB
+
+	// writeMu 串行化本进程内的写事务。SQLite同一时间只允许一个写者，
+	// 进程内先排队，busy_timeout只用于等待其他进程的写入
+	writeMu sync.Mutex
+
+	// sqliteDriverOnce 保证自定义驱动只注册一次
+	sqliteDriverOnce sync.Once
 )
 
+// sqliteDSNParams 打开数据库时使用的参数：WAL模式下读不阻塞写；
+// 写事务以BEGIN IMMEDIATE开始，在开启时就等待写锁，避免读锁升级为写锁时直接失败
+const sqliteDSNParams = "mode=rwc&_foreign_keys=1&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// sqlQueryer *sql.DB 和 *sql.Tx 共有的查询方法，便于同一个查询函数在事务内外复用
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// queryIDs 执行只返回一列ID的查询
+func queryIDs(ctx context.Context, q sqlQueryer, query string, args ...interface{}) ([]uint64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // 安全说明：
 // 1. 所有SQL查询都使用参数化查询（?占位符）来防止SQL注入
 // 2. 所有接受外部输入的函数都包含输入验证
 // 3. 使用软删除（deleted_at字段）而不是物理删除
 // 4. 所有数据库操作都有适当的错误处理
 
-// InitSqlite 初始化SQLite数据库
-func InitSqlite() {
+// sqliteDSN 将数据库文件路径或 file: 开头的DSN转换为最终的DSN，并追加默认参数。
+// DSN中已经写明的参数优先于默认参数
+func sqliteDSN(source string) string {
+	if !strings.HasPrefix(source, "file:") {
+		return "file:" + source + "?" + sqliteDSNParams
+	}
+	path, rawQuery, _ := strings.Cut(source, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return source
+	}
+	defaults, _ := url.ParseQuery(sqliteDSNParams)
+	for key := range defaults {
+		if !params.Has(key) {
+			params.Set(key, defaults.Get(key))
+		}
+	}
+	return path + "?" + params.Encode()
+}
+
+// openSqlite 打开数据库，source为文件路径或DSN，文件不存在时自动创建
+func openSqlite(source string) (*sql.DB, error) {
+	sqliteDriverOnce.Do(func() {
+		sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				// 搜索排序使用的BM25相关度，见search_rank.go
+				return conn.RegisterFunc(ftsRankFunction, ftsBM25, true)
+			},
+		})
+	})
+	db, err := sql.Open(sqliteDriverName, sqliteDSN(source))
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+	// 增加最大连接数，避免嵌套查询时的连接阻塞
+	db.SetMaxOpenConns(10)
+	return db, nil
+}
+
+// InitSqlite 初始化SQLite数据库。source为数据库文件路径或 file: 开头的DSN，
+// 为空时使用当前数据库配置对应的文件
+func InitSqlite(source string) error {
 	// 检查数据库连接是否已经初始化
 	if DB != nil {
-		return
+		return nil
 	}
 
-	// 检查数据库文件是否存在
-	_, err := os.Stat("quick-cmd.db")
-	fileExists := err == nil
+	ctx := context.Background()
 
-	// 打开SQLite数据库，使用文件存储而不是内存存储
-	// 使用mode=rwc模式，当文件不存在时会自动创建
-	db, err := sql.Open("sqlite3", "file:quick-cmd.db?cache=shared&mode=rwc&_foreign_keys=1")
+	// 不同的数据库配置使用不同的文件
+	if source == "" {
+		source = dbProfileFileName(activeDBProfile())
+	}
+	db, err := openSqlite(source)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	DB = db
-	// 增加最大连接数，避免嵌套查询时的连接阻塞
-	DB.SetMaxOpenConns(10)
-
-	// 如果文件不存在，先创建数据库文件
-	if !fileExists {
-		log.Println("数据库文件不存在，正在创建...")
-		// 执行一个空查询来触发数据库文件的创建
-		if _, err := DB.Exec("PRAGMA foreign_keys = ON"); err != nil {
-			panic(fmt.Errorf("创建数据库文件失败: %v", err))
-		}
-		log.Println("数据库文件创建成功")
+	// 连接是延迟建立的，先执行一次查询来创建数据库文件并确认可以访问
+	if _, err = db.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return fmt.Errorf("打开数据库失败: %w", err)
 	}
+	DB = db
 
-	// 创建所有必需的表
-	if err := createTables(); err != nil {
-		panic(fmt.Errorf("创建表失败: %v", err))
+	// 创建所有必需的表，失败时关闭连接，下次调用可以重新初始化
+	if err = createTables(ctx); err != nil {
+		db.Close()
+		DB = nil
+		return fmt.Errorf("创建表失败: %w", err)
 	}
+	return nil
 }
 
 // createTables 创建所有必需的表
-func createTables() error {
+func createTables(ctx context.Context) error {
 	var err error
 	// 创建标签表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS tags (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
@@ -73,11 +150,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建tags表失败: %v", err)
+		return fmt.Errorf("创建tags表失败: %w", err)
 	}
 
 	// 创建集合表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS collections (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
@@ -89,11 +166,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建collections表失败: %v", err)
+		return fmt.Errorf("创建collections表失败: %w", err)
 	}
 
 	// 创建命令表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS commands (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
@@ -107,11 +184,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建commands表失败: %v", err)
+		return fmt.Errorf("创建commands表失败: %w", err)
 	}
 
 	// 创建命令与标签的多对多关系表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS command_tags (
 		command_id INTEGER NOT NULL,
 		tag_id INTEGER NOT NULL,
@@ -121,11 +198,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建command_tags表失败: %v", err)
+		return fmt.Errorf("创建command_tags表失败: %w", err)
 	}
 
 	// 创建命令与集合的多对多关系表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS command_collections (
 		command_id INTEGER NOT NULL,
 		collection_id INTEGER NOT NULL,
@@ -135,11 +212,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建command_collections表失败: %v", err)
+		return fmt.Errorf("创建command_collections表失败: %w", err)
 	}
 
 	// 创建标签与OS的关联表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS tag_os (
 		tag_id INTEGER NOT NULL,
 		os TEXT NOT NULL,
@@ -148,11 +225,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建tag_os表失败: %v", err)
+		return fmt.Errorf("创建tag_os表失败: %w", err)
 	}
 
 	// 创建集合与OS的关联表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS collection_os (
 		collection_id INTEGER NOT NULL,
 		os TEXT NOT NULL,
@@ -161,11 +238,11 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建collection_os表失败: %v", err)
+		return fmt.Errorf("创建collection_os表失败: %w", err)
 	}
 
 	// 创建命令与OS的关联表
-	_, err = DB.Exec(`
+	_, err = DB.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS command_os (
 		command_id INTEGER NOT NULL,
 		os TEXT NOT NULL,
@@ -174,33 +251,306 @@ func createTables() error {
 	);
 	`)
 	if err != nil {
-		return fmt.Errorf("创建command_os表失败: %v", err)
+		return fmt.Errorf("创建command_os表失败: %w", err)
+	}
+
+	// 创建shell钩子上报的命令频次表，按天累计
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS typed_commands (
+		line TEXT NOT NULL,
+		day TEXT NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (line, day)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建typed_commands表失败: %w", err)
+	}
+
+	// 创建用户忽略的命令建议表
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS typed_command_dismissed (
+		line TEXT PRIMARY KEY,
+		dismissed_at DATETIME NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建typed_command_dismissed表失败: %w", err)
+	}
+
+	// 创建指令变体表，同一条指令在不同OS和shell下可以有不同的内容
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS command_variants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		command_id INTEGER NOT NULL,
+		os TEXT NOT NULL,
+		shell TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		UNIQUE (command_id, os, shell),
+		FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建command_variants表失败: %w", err)
+	}
+
+	// 创建集合运行记录表，每次按集合逐步执行指令都是一次命名的运行
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS runbook_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		collection_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'running',
+		started_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		finished_at DATETIME,
+		UNIQUE (collection_id, name),
+		FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建runbook_runs表失败: %w", err)
+	}
+
+	// 创建运行步骤表，开始运行时从集合复制步骤，之后调整集合顺序不影响进行中的运行
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS runbook_run_steps (
+		run_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		command_id INTEGER NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		optional INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'pending',
+		completed_at DATETIME,
+		PRIMARY KEY (run_id, position),
+		FOREIGN KEY (run_id) REFERENCES runbook_runs(id) ON DELETE CASCADE
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建runbook_run_steps表失败: %w", err)
+	}
+
+	// 创建密钥库表，只有一行，保存派生主密钥的参数和用于校验主密码的密文
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS vault (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		salt BLOB NOT NULL,
+		kdf_time INTEGER NOT NULL,
+		kdf_memory INTEGER NOT NULL,
+		kdf_threads INTEGER NOT NULL,
+		check_nonce BLOB NOT NULL,
+		check_value BLOB NOT NULL,
+		auto_lock_minutes INTEGER NOT NULL DEFAULT 15,
+		created_at DATETIME NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建vault表失败: %w", err)
+	}
+
+	// 创建密钥表，只保存AES-GCM加密后的密文
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS secrets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		nonce BLOB NOT NULL,
+		ciphertext BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建secrets表失败: %w", err)
+	}
+
+	// 创建设置表，每项设置一行
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建settings表失败: %w", err)
+	}
+
+	// 创建审计日志表，只允许追加，触发器阻止修改和删除已有记录
+	_, err = DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		source TEXT NOT NULL,
+		diff TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+	`)
+	if err != nil {
+		return fmt.Errorf("创建audit_log表失败: %w", err)
 	}
 
 	// 为OS关联表创建索引，提高查询性能
-	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_tag_os_os ON tag_os(os)`)
+	_, err = DB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_tag_os_os ON tag_os(os)`)
 	if err != nil {
-		log.Printf("创建tag_os索引失败: %v", err)
+		slog.Warn("创建tag_os索引失败", "err", err)
 	}
 
-	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_collection_os_os ON collection_os(os)`)
+	_, err = DB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_collection_os_os ON collection_os(os)`)
 	if err != nil {
-		log.Printf("创建collection_os索引失败: %v", err)
+		slog.Warn("创建collection_os索引失败", "err", err)
 	}
 
-	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_command_os_os ON command_os(os)`)
+	_, err = DB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_command_os_os ON command_os(os)`)
 	if err != nil {
-		log.Printf("创建command_os索引失败: %v", err)
+		slog.Warn("创建command_os索引失败", "err", err)
+	}
+
+	// 为旧版本数据库补充新增字段
+	if err = migrateColumns(ctx); err != nil {
+		return fmt.Errorf("迁移表字段失败: %w", err)
+	}
+
+	// 统一旧数据中的OS写法
+	if err = migrateOSValues(ctx); err != nil {
+		return fmt.Errorf("规范化OS失败: %w", err)
+	}
+
+	_, err = DB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_commands_frecency ON commands(frecency)`)
+	if err != nil {
+		slog.Warn("创建commands frecency索引失败", "err", err)
+	}
+
+	if err = createCommandsFTS(ctx); err != nil {
+		return fmt.Errorf("创建全文索引失败: %w", err)
 	}
 
 	return nil
 }
 
+// createCommandsFTS 创建指令的全文索引commands_fts，用于计算搜索相关度。
+// 索引不另存原文，由触发器与commands表同步；索引第一次创建时从已有的指令重建
+func createCommandsFTS(ctx context.Context) error {
+	var exists int
+	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'commands_fts'").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	_, err = DB.ExecContext(ctx, `
+	CREATE VIRTUAL TABLE IF NOT EXISTS commands_fts USING fts4(content="commands", name, content, description, tokenize=unicode61);
+	-- 外部内容的索引删除时需要原来的内容，所以在commands修改之前删除旧索引
+	CREATE TRIGGER IF NOT EXISTS commands_fts_before_update BEFORE UPDATE OF name, content, description ON commands
+	BEGIN DELETE FROM commands_fts WHERE docid = old.id; END;
+	CREATE TRIGGER IF NOT EXISTS commands_fts_before_delete BEFORE DELETE ON commands
+	BEGIN DELETE FROM commands_fts WHERE docid = old.id; END;
+	CREATE TRIGGER IF NOT EXISTS commands_fts_after_update AFTER UPDATE OF name, content, description ON commands
+	BEGIN INSERT INTO commands_fts(docid, name, content, description) VALUES (new.id, new.name, new.content, new.description); END;
+	CREATE TRIGGER IF NOT EXISTS commands_fts_after_insert AFTER INSERT ON commands
+	BEGIN INSERT INTO commands_fts(docid, name, content, description) VALUES (new.id, new.name, new.content, new.description); END;
+	`)
+	if err != nil || exists > 0 {
+		return err
+	}
+	_, err = DB.ExecContext(ctx, "INSERT INTO commands_fts(commands_fts) VALUES('rebuild')")
+	return err
+}
+
+// migrateColumns 为已存在的表补充后续版本新增的字段
+func migrateColumns(ctx context.Context) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"commands", "alias", "TEXT NOT NULL DEFAULT ''"},
+		{"tags", "parent_id", "INTEGER"},
+		{"command_collections", "position", "INTEGER NOT NULL DEFAULT 0"},
+		{"command_collections", "note", "TEXT NOT NULL DEFAULT ''"},
+		{"command_collections", "optional", "INTEGER NOT NULL DEFAULT 0"},
+		{"commands", "pinned", "INTEGER NOT NULL DEFAULT 0"},
+		{"commands", "sort_value", "INTEGER NOT NULL DEFAULT 0"},
+		{"tags", "pinned", "INTEGER NOT NULL DEFAULT 0"},
+		{"tags", "sort_value", "INTEGER NOT NULL DEFAULT 0"},
+		{"collections", "pinned", "INTEGER NOT NULL DEFAULT 0"},
+		{"collections", "sort_value", "INTEGER NOT NULL DEFAULT 0"},
+		{"commands", "run_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"commands", "frecency", "REAL NOT NULL DEFAULT 0"},
+		{"commands", "last_used_at", "DATETIME"},
+		{"commands", "risk_level", "TEXT NOT NULL DEFAULT 'none'"},
+		{"commands", "skip_syntax_check", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfNotExists(ctx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// 旧数据没有顺序，按指令ID为每个集合内的步骤编号；新加入的步骤总是排在末尾，位置不会为0
+	_, err := DB.ExecContext(ctx, `
+	UPDATE command_collections SET position = (
+		SELECT COUNT(*) FROM command_collections cc
+		WHERE cc.collection_id = command_collections.collection_id AND cc.command_id <= command_collections.command_id
+	) WHERE position = 0`)
+	if err != nil {
+		return fmt.Errorf("初始化集合步骤顺序失败: %w", err)
+	}
+
+	// 旧数据没有手动排序值，按ID初始化；新记录创建时总是排在最后，排序值不会为0
+	for _, table := range []string{"commands", "tags", "collections"} {
+		if _, err = DB.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET sort_value = id WHERE sort_value = 0", table)); err != nil {
+			return fmt.Errorf("初始化%s排序值失败: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// addColumnIfNotExists 字段不存在时通过ALTER TABLE添加
+func addColumnIfNotExists(ctx context.Context, table, column, definition string) error {
+	rows, err := DB.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("查询%s表结构失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("扫描%s表结构失败: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历%s表结构失败: %w", table, err)
+	}
+	rows.Close()
+
+	_, err = DB.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("为%s表添加%s字段失败: %w", table, column, err)
+	}
+	slog.Info("已添加字段", "table", table, "column", column)
+	return nil
+}
+
 // 辅助函数：处理OS关联表的操作
 
-// AddOSToTagSQLite 为标签添加OS
-func AddOSToTagSQLite(tx *sql.Tx, tagID uint64, os string) error {
-	_, err := tx.Exec(
+// AddOSToTagSQLite 为标签添加OS，OS转换为规范写法，无法识别时返回校验错误
+func AddOSToTagSQLite(ctx context.Context, tx *sql.Tx, tagID uint64, os string) error {
+	os, err := normalizeOS("os", os)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
 		"INSERT OR IGNORE INTO tag_os (tag_id, os) VALUES (?, ?)",
 		tagID, os,
 	)
@@ -208,9 +558,9 @@ func AddOSToTagSQLite(tx *sql.Tx, tagID uint64, os string) error {
 }
 
 // GetTagOSsSQLite 获取标签的所有OS
-func GetTagOSsSQLite(tagID uint64) ([]string, error) {
+func GetTagOSsSQLite(ctx context.Context, tagID uint64) ([]string, error) {
 	var osList []string
-	rows, err := DB.Query(
+	rows, err := DB.QueryContext(ctx,
 		"SELECT os FROM tag_os WHERE tag_id = ?",
 		tagID,
 	)
@@ -231,28 +581,10 @@ func GetTagOSsSQLite(tagID uint64) ([]string, error) {
 	return osList, nil
 }
 
-// AddOSToCollectionSQLite 为集合添加OS
-func AddOSToCollectionSQLite(collectionID uint64, os string) error {
-	_, err := DB.Exec(
-		"INSERT OR IGNORE INTO collection_os (collection_id, os) VALUES (?, ?)",
-		collectionID, os,
-	)
-	return err
-}
-
-// RemoveAllOSFromCollectionSQLite 从集合移除所有OS
-func RemoveAllOSFromCollectionSQLite(collectionID uint64) error {
-	_, err := DB.Exec(
-		"DELETE FROM collection_os WHERE collection_id = ?",
-		collectionID,
-	)
-	return err
-}
-
 // GetCollectionOSsSQLite 获取集合的所有OS
-func GetCollectionOSsSQLite(collectionID uint64) ([]string, error) {
+func GetCollectionOSsSQLite(ctx context.Context, collectionID uint64) ([]string, error) {
 	var osList []string
-	rows, err := DB.Query(
+	rows, err := DB.QueryContext(ctx,
 		"SELECT os FROM collection_os WHERE collection_id = ?",
 		collectionID,
 	)
@@ -273,28 +605,10 @@ func GetCollectionOSsSQLite(collectionID uint64) ([]string, error) {
 	return osList, nil
 }
 
-// AddOSToCommandSQLite 为命令添加OS
-func AddOSToCommandSQLite(commandID uint64, os string) error {
-	_, err := DB.Exec(
-		"INSERT OR IGNORE INTO command_os (command_id, os) VALUES (?, ?)",
-		commandID, os,
-	)
-	return err
-}
-
-// RemoveAllOSFromCommandSQLite 从命令移除所有OS
-func RemoveAllOSFromCommandSQLite(commandID uint64) error {
-	_, err := DB.Exec(
-		"DELETE FROM command_os WHERE command_id = ?",
-		commandID,
-	)
-	return err
-}
-
 // GetCommandOSsSQLite 获取命令的所有OS
-func GetCommandOSsSQLite(commandID uint64) ([]string, error) {
+func GetCommandOSsSQLite(ctx context.Context, commandID uint64) ([]string, error) {
 	var osList []string
-	rows, err := DB.Query(
+	rows, err := DB.QueryContext(ctx,
 		"SELECT os FROM command_os WHERE command_id = ?",
 		commandID,
 	)
@@ -315,238 +629,9 @@ func GetCommandOSsSQLite(commandID uint64) ([]string, error) {
 	return osList, nil
 }
 
-// 数据库迁移相关函数
-
-// MigrateOSFieldsSQLite 迁移OS字段到关联表（可选执行）
-func MigrateOSFieldsSQLite() error {
-	log.Println("开始OS字段数据迁移...")
+// 读取命令的标签、集合和OS关联。写入关联只通过事务中的commandRelation进行
 
-	// 1. 迁移tags表的os字段数据
-	log.Println("迁移tags表数据...")
-	rows, err := DB.Query("SELECT id, os FROM tags WHERE os IS NOT NULL AND os != '' AND deleted_at IS NULL")
-	if err != nil {
-		return fmt.Errorf("查询tags表失败: %v", err)
-	}
-	defer rows.Close()
-
-	tagCount := 0
-	for rows.Next() {
-		var tagID uint64
-		var osJSON string
-		if err := rows.Scan(&tagID, &osJSON); err != nil {
-			log.Printf("扫描tag失败: %v", err)
-			continue
-		}
-
-		// 解析JSON数据
-		var osList []string
-		if err := json.Unmarshal([]byte(osJSON), &osList); err != nil {
-			log.Printf("解析tag %d的OS数据失败: %v", tagID, err)
-			continue
-		}
-
-		// 插入到关联表
-		// for _, os := range osList {
-		// 	if err := AddOSToTagSQLite(tagID, os); err != nil {
-		// 		log.Printf("为tag %d添加OS %s失败: %v", tagID, os, err)
-		// 	}
-		// }
-		tagCount++
-	}
-
-	log.Printf("tags表迁移完成，处理了 %d 条记录", tagCount)
-
-	// 2. 迁移collections表的os字段数据
-	log.Println("迁移collections表数据...")
-	rows, err = DB.Query("SELECT id, os FROM collections WHERE os IS NOT NULL AND os != '' AND deleted_at IS NULL")
-	if err != nil {
-		return fmt.Errorf("查询collections表失败: %v", err)
-	}
-	defer rows.Close()
-
-	collectionCount := 0
-	for rows.Next() {
-		var collectionID uint64
-		var osJSON string
-		if err := rows.Scan(&collectionID, &osJSON); err != nil {
-			log.Printf("扫描collection失败: %v", err)
-			continue
-		}
-
-		// 解析JSON数据
-		var osList []string
-		if err := json.Unmarshal([]byte(osJSON), &osList); err != nil {
-			log.Printf("解析collection %d的OS数据失败: %v", collectionID, err)
-			continue
-		}
-
-		// 插入到关联表
-		for _, os := range osList {
-			if err := AddOSToCollectionSQLite(collectionID, os); err != nil {
-				log.Printf("为collection %d添加OS %s失败: %v", collectionID, os, err)
-			}
-		}
-		collectionCount++
-	}
-
-	log.Printf("collections表迁移完成，处理了 %d 条记录", collectionCount)
-
-	// 3. 迁移commands表的os字段数据（这里是整数类型）
-	log.Println("迁移commands表数据...")
-	rows, err = DB.Query("SELECT id, os FROM commands WHERE os IS NOT NULL AND os != 0 AND deleted_at IS NULL")
-	if err != nil {
-		return fmt.Errorf("查询commands表失败: %v", err)
-	}
-	defer rows.Close()
-
-	commandCount := 0
-	for rows.Next() {
-		var commandID uint64
-		var osValue int
-		if err := rows.Scan(&commandID, &osValue); err != nil {
-			log.Printf("扫描command失败: %v", err)
-			continue
-		}
-
-		// 这里需要根据实际的OS枚举值来转换
-		// 假设有一些预设的OS值映射
-		osList := getOSListByValue(osValue)
-		for _, os := range osList {
-			if err := AddOSToCommandSQLite(commandID, os); err != nil {
-				log.Printf("为command %d添加OS %s失败: %v", commandID, os, err)
-			}
-		}
-		commandCount++
-	}
-
-	log.Printf("commands表迁移完成，处理了 %d 条记录", commandCount)
-	log.Println("OS字段数据迁移完成！")
-
-	return nil
-}
-
-// CleanupOSFieldsSQLite 清理OS字段（谨慎操作）
-func CleanupOSFieldsSQLite() error {
-	log.Println("开始清理OS字段...")
-
-	// 询问用户确认
-	log.Println("警告：此操作将永久删除os字段中的数据！")
-
-	// 1. 清理tags表的os字段
-	_, err := DB.Exec("UPDATE tags SET os = '' WHERE os IS NOT NULL")
-	if err != nil {
-		return fmt.Errorf("清理tags表os字段失败: %v", err)
-	}
-	log.Println("tags表os字段已清空")
-
-	// 2. 清理collections表的os字段
-	_, err = DB.Exec("UPDATE collections SET os = '' WHERE os IS NOT NULL")
-	if err != nil {
-		return fmt.Errorf("清理collections表os字段失败: %v", err)
-	}
-	log.Println("collections表os字段已清空")
-
-	// 3. 清理commands表的os字段
-	_, err = DB.Exec("UPDATE commands SET os = 0 WHERE os IS NOT NULL")
-	if err != nil {
-		return fmt.Errorf("清理commands表os字段失败: %v", err)
-	}
-	log.Println("commands表os字段已清空")
-
-	log.Println("OS字段清理完成！")
-	return nil
-}
-
-// getOSListByValue 根据整数值获取OS列表（需要根据实际枚举值调整）
-func getOSListByValue(osValue int) []string {
-	var osList []string
-
-	// 这里需要根据实际的OS枚举值进行调整
-	// 示例：如果osValue是标志位组合
-	if osValue&1 != 0 { // 假设第1位代表Windows
-		osList = append(osList, "Windows")
-	}
-	if osValue&2 != 0 { // 假设第2位代表macOS
-		osList = append(osList, "macOS")
-	}
-	if osValue&4 != 0 { // 假设第3位代表Linux
-		osList = append(osList, "Linux")
-	}
-
-	return osList
-}
-
-// 管理命令与标签的多对多关系
-
-// AddTagToCommandSQLite 添加标签到命令
-func AddTagToCommandSQLite(commandID uint64, tagID uint64) error {
-	// 输入验证
-	if commandID == 0 {
-		return fmt.Errorf("命令ID不能为空")
-	}
-	if tagID == 0 {
-		return fmt.Errorf("标签ID不能为空")
-	}
-
-	// 检查标签是否存在
-	if _, err := GetTagSQLite(tagID); err != nil {
-		return fmt.Errorf("标签不存在: %v", err)
-	}
-
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"INSERT OR IGNORE INTO command_tags (command_id, tag_id) VALUES (?, ?)",
-		commandID, tagID,
-	)
-	if err != nil {
-		return fmt.Errorf("添加命令标签关系失败: %v", err)
-	}
-
-	return nil
-}
-
-// RemoveTagFromCommandSQLite 从命令移除标签
-func RemoveTagFromCommandSQLite(commandID, tagID uint64) error {
-	// 输入验证
-	if commandID == 0 {
-		return fmt.Errorf("命令ID不能为空")
-	}
-	if tagID == 0 {
-		return fmt.Errorf("标签ID不能为空")
-	}
-
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"DELETE FROM command_tags WHERE command_id = ? AND tag_id = ?",
-		commandID, tagID,
-	)
-	if err != nil {
-		return fmt.Errorf("删除命令标签关系失败: %v", err)
-	}
-
-	return nil
-}
-
-// RemoveAllTagsFromCommandSQLite 从命令移除所有标签
-func RemoveAllTagsFromCommandSQLite(commandID uint64) error {
-	// 输入验证
-	if commandID == 0 {
-		return fmt.Errorf("命令ID不能为空")
-	}
-
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"DELETE FROM command_tags WHERE command_id = ?",
-		commandID,
-	)
-	if err != nil {
-		return fmt.Errorf("删除所有命令标签关系失败: %v", err)
-	}
-
-	return nil
-}
-
-func FillCommandRelations(commands []*Command) error {
+func FillCommandRelations(ctx context.Context, commands []*Command) error {
 	if len(commands) == 0 {
 		return nil
 	}
@@ -556,124 +641,102 @@ func FillCommandRelations(commands []*Command) error {
 		commandIDs[i] = cmd.ID
 	}
 
-	tagMap, err := GetTagIDsByCommandIDsSQLite(commandIDs)
+	tagMap, err := GetTagIDsByCommandIDsSQLite(ctx, commandIDs)
+	if err != nil {
+		return fmt.Errorf("批量获取命令标签ID失败: %w", err)
+	}
+	collectionMap, err := GetCollectionIDsByCommandIDsSQLite(ctx, commandIDs)
 	if err != nil {
-		return fmt.Errorf("批量获取命令标签ID失败: %v", err)
+		return fmt.Errorf("批量获取命令集合ID失败: %w", err)
 	}
-	collectionMap, err := GetCollectionIDsByCommandIDsSQLite(commandIDs)
+	osMap, err := GetCommandOSsByCommandIDsSQLite(ctx, commandIDs)
 	if err != nil {
-		return fmt.Errorf("批量获取命令集合ID失败: %v", err)
+		return fmt.Errorf("批量获取命令OS失败: %w", err)
 	}
-	osMap, err := GetCommandOSsByCommandIDsSQLite(commandIDs)
+	variantMap, err := GetVariantsByCommandIDsSQLite(ctx, commandIDs)
 	if err != nil {
-		return fmt.Errorf("批量获取命令OS失败: %v", err)
+		return fmt.Errorf("批量获取命令变体失败: %w", err)
 	}
 
 	for _, cmd := range commands {
 		cmd.TagIDs = tagMap[cmd.ID]
 		cmd.CollectionIDs = collectionMap[cmd.ID]
 		cmd.Os = osMap[cmd.ID]
+		cmd.Variants = variantMap[cmd.ID]
 	}
 
 	return nil
 }
 
-func GetTagIDsByCommandIDsSQLite(commandIDs []uint64) (map[uint64][]uint64, error) {
+func GetTagIDsByCommandIDsSQLite(ctx context.Context, commandIDs []uint64) (map[uint64][]uint64, error) {
 	result := make(map[uint64][]uint64)
 	if len(commandIDs) == 0 {
 		return result, nil
 	}
 
-	query := "SELECT command_id, tag_id FROM command_tags WHERE command_id IN ("
-	args := make([]interface{}, len(commandIDs))
-	for i, id := range commandIDs {
-		if i > 0 {
-			query += ","
-		}
-		query += "?"
-		args[i] = id
-	}
-	query += ")"
+	query := "SELECT command_id, tag_id FROM command_tags WHERE command_id IN " + inPlaceholders(len(commandIDs))
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.QueryContext(ctx, query, toArgs(commandIDs)...)
 	if err != nil {
-		return nil, fmt.Errorf("批量获取命令标签ID失败: %v", err)
+		return nil, fmt.Errorf("批量获取命令标签ID失败: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var commandID, tagID uint64
 		if err = rows.Scan(&commandID, &tagID); err != nil {
-			return nil, fmt.Errorf("扫描命令标签ID失败: %v", err)
+			return nil, fmt.Errorf("扫描命令标签ID失败: %w", err)
 		}
 		result[commandID] = append(result[commandID], tagID)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历命令标签ID结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历命令标签ID结果集失败: %w", err)
 	}
 
 	return result, nil
 }
 
-func GetCollectionIDsByCommandIDsSQLite(commandIDs []uint64) (map[uint64][]uint64, error) {
+func GetCollectionIDsByCommandIDsSQLite(ctx context.Context, commandIDs []uint64) (map[uint64][]uint64, error) {
 	result := make(map[uint64][]uint64)
 	if len(commandIDs) == 0 {
 		return result, nil
 	}
 
-	query := "SELECT command_id, collection_id FROM command_collections WHERE command_id IN ("
-	args := make([]interface{}, len(commandIDs))
-	for i, id := range commandIDs {
-		if i > 0 {
-			query += ","
-		}
-		query += "?"
-		args[i] = id
-	}
-	query += ")"
+	query := "SELECT command_id, collection_id FROM command_collections WHERE command_id IN " + inPlaceholders(len(commandIDs))
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.QueryContext(ctx, query, toArgs(commandIDs)...)
 	if err != nil {
-		return nil, fmt.Errorf("批量获取命令集合ID失败: %v", err)
+		return nil, fmt.Errorf("批量获取命令集合ID失败: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var commandID, collectionID uint64
 		if err = rows.Scan(&commandID, &collectionID); err != nil {
-			return nil, fmt.Errorf("扫描命令集合ID失败: %v", err)
+			return nil, fmt.Errorf("扫描命令集合ID失败: %w", err)
 		}
 		result[commandID] = append(result[commandID], collectionID)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历命令集合ID结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历命令集合ID结果集失败: %w", err)
 	}
 
 	return result, nil
 }
 
-func GetCommandOSsByCommandIDsSQLite(commandIDs []uint64) (map[uint64][]string, error) {
+func GetCommandOSsByCommandIDsSQLite(ctx context.Context, commandIDs []uint64) (map[uint64][]string, error) {
 	result := make(map[uint64][]string)
 	if len(commandIDs) == 0 {
 		return result, nil
 	}
 
-	query := "SELECT command_id, os FROM command_os WHERE command_id IN ("
-	args := make([]interface{}, len(commandIDs))
-	for i, id := range commandIDs {
-		if i > 0 {
-			query += ","
-		}
-		query += "?"
-		args[i] = id
-	}
-	query += ")"
+	query := "SELECT command_id, os FROM command_os WHERE command_id IN " + inPlaceholders(len(commandIDs))
 
-	rows, err := DB.Query(query, args...)
+	rows, err := DB.QueryContext(ctx, query, toArgs(commandIDs)...)
 	if err != nil {
-		return nil, fmt.Errorf("批量获取命令OS失败: %v", err)
+		return nil, fmt.Errorf("批量获取命令OS失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -681,13 +744,13 @@ func GetCommandOSsByCommandIDsSQLite(commandIDs []uint64) (map[uint64][]string,
 		var commandID uint64
 		var os string
 		if err = rows.Scan(&commandID, &os); err != nil {
-			return nil, fmt.Errorf("扫描命令OS失败: %v", err)
+			return nil, fmt.Errorf("扫描命令OS失败: %w", err)
 		}
 		result[commandID] = append(result[commandID], os)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历命令OS结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历命令OS结果集失败: %w", err)
 	}
 
 	return result, nil
@@ -695,90 +758,22 @@ func GetCommandOSsByCommandIDsSQLite(commandIDs []uint64) (map[uint64][]string,
 
 // 管理命令与集合的多对多关系
 
-// AddCollectionToCommandSQLite 添加集合到命令
-func AddCollectionToCommandSQLite(commandID uint64, collectionID uint64) error {
-	// 输入验证
-	if commandID == 0 {
-		return fmt.Errorf("命令ID不能为空")
-	}
-	if collectionID == 0 {
-		return fmt.Errorf("集合ID不能为空")
-	}
-
-	// 检查集合是否存在
-	if _, err := GetCollectionSQLite(collectionID); err != nil {
-		return fmt.Errorf("集合不存在: %v", err)
-	}
-
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"INSERT OR IGNORE INTO command_collections (command_id, collection_id) VALUES (?, ?)",
-		commandID, collectionID,
-	)
-	if err != nil {
-		return fmt.Errorf("添加命令集合关系失败: %v", err)
-	}
-
-	return nil
-}
-
-// RemoveCollectionFromCommandSQLite 从命令移除集合
-func RemoveCollectionFromCommandSQLite(commandID uint64, collectionID uint64) error {
-	// 输入验证
-	if commandID == 0 {
-		return fmt.Errorf("命令ID不能为空")
-	}
-	if collectionID == 0 {
-		return fmt.Errorf("集合ID不能为空")
-	}
-
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"DELETE FROM command_collections WHERE command_id = ? AND collection_id = ?",
-		commandID, collectionID,
-	)
-	if err != nil {
-		return fmt.Errorf("删除命令集合关系失败: %v", err)
-	}
-
-	return nil
-}
-
-// RemoveAllCollectionsFromCommandSQLite 从命令移除所有集合
-func RemoveAllCollectionsFromCommandSQLite(commandID uint64) error {
-	// 输入验证
-	if commandID == 0 {
-		return fmt.Errorf("命令ID不能为空")
-	}
-
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"DELETE FROM command_collections WHERE command_id = ?",
-		commandID,
-	)
-	if err != nil {
-		return fmt.Errorf("删除所有命令集合关系失败: %v", err)
-	}
-
-	return nil
-}
-
 // GetCollectionIDsByCommandIDSQLite 获取命令的所有集合ID
-func GetCollectionIDsByCommandIDSQLite(commandID uint64) ([]uint64, error) {
+func GetCollectionIDsByCommandIDSQLite(ctx context.Context, commandID uint64) ([]uint64, error) {
 	// 输入验证
 	if commandID == 0 {
-		return nil, fmt.Errorf("commandID不能为空")
+		return nil, NewValidationError("commandId", "command.id_required")
 	}
 
 	var collectionIDs []uint64
 
 	// 使用参数化查询，防止SQL注入
-	rows, err := DB.Query(
+	rows, err := DB.QueryContext(ctx,
 		"SELECT collection_id FROM command_collections WHERE command_id = ?",
 		commandID,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("获取命令集合ID失败: %v", err)
+		return nil, fmt.Errorf("获取命令集合ID失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -786,13 +781,13 @@ func GetCollectionIDsByCommandIDSQLite(commandID uint64) ([]uint64, error) {
 	for rows.Next() {
 		var collectionID uint64
 		if err := rows.Scan(&collectionID); err != nil {
-			return nil, fmt.Errorf("扫描集合ID失败: %v", err)
+			return nil, fmt.Errorf("扫描集合ID失败: %w", err)
 		}
 		collectionIDs = append(collectionIDs, collectionID)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历集合ID结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历集合ID结果集失败: %w", err)
 	}
 
 	return collectionIDs, nil
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// auditDateLayout 审计日志查询条件中日期的格式
+const auditDateLayout = "2006-01-02"
+
+// AuditQuery 审计日志的查询条件，为空的条件不参与筛选
+type AuditQuery struct {
+	EntityType string `json:"entityType"`
+	EntityID   uint64 `json:"entityId"`
+	Action     string `json:"action"`
+	Source     string `json:"source"`
+	Since      string `json:"since"`  // 开始日期（含），格式为YYYY-MM-DD
+	Until      string `json:"until"`  // 结束日期（含），格式为YYYY-MM-DD
+	Limit      int    `json:"limit"`  // 每页条数，0表示不分页
+	Cursor     string `json:"cursor"` // 上一页返回的nextCursor，为空时从第一页开始
+}
+
+// AuditLog 一页审计日志，按时间倒序
+type AuditLog struct {
+	Entries []*AuditEntry `json:"entries"`
+	Page
+}
+
+// GetAuditLogSQLite 按条件分页查询审计日志，最新的记录在前
+func GetAuditLogSQLite(ctx context.Context, query AuditQuery) ([]*AuditEntry, Page, error) {
+	b := NewSelectBuilder("audit_log a", "a.id", "a.entity_type", "a.entity_id", "a.action", "a.source", "a.diff", "a.created_at")
+	if err := applyAuditFilters(b, query); err != nil {
+		return nil, Page{}, err
+	}
+
+	total, err := countRows(ctx, b)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	b.OrderBy("a.id", "DESC")
+	page := &listPage{total: total, signature: b.orderSignature()}
+
+	// ID单调递增，游标只需要比较ID
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		if cursor.Sort != page.signature {
+			return nil, Page{}, NewValidationError("cursor", "page.cursor_mismatch")
+		}
+		b.Where("a.id < ?", cursor.ID)
+	}
+	if query.Limit > 0 {
+		page.limit = min(query.Limit, maxPageSize)
+		b.Limit(page.limit + 1)
+	}
+
+	sqlQuery, args := b.Build()
+	rows, err := DB.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, Page{}, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var diff string
+		var createdAt time.Time
+		if err = rows.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.Action, &e.Source, &diff, &createdAt); err != nil {
+			return nil, Page{}, fmt.Errorf("扫描审计日志失败: %w", err)
+		}
+		e.Diff = []byte(diff)
+		e.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
+		entries = append(entries, &e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, fmt.Errorf("遍历审计日志结果集失败: %w", err)
+	}
+
+	entries, result := pageResult(entries, page, func(e *AuditEntry) uint64 { return e.ID })
+	return entries, result, nil
+}
+
+// applyAuditFilters 校验查询条件并添加到查询中
+func applyAuditFilters(b *SelectBuilder, query AuditQuery) error {
+	if query.EntityType != "" {
+		if _, ok := auditEntities[query.EntityType]; !ok {
+			return NewValidationError("entityType", "audit.invalid_entity_type", query.EntityType)
+		}
+		b.Where("a.entity_type = ?", query.EntityType)
+	}
+	if query.EntityID != 0 {
+		b.Where("a.entity_id = ?", query.EntityID)
+	}
+	if query.Action != "" {
+		switch query.Action {
+		case AuditActionCreate, AuditActionUpdate, AuditActionDelete, AuditActionRestore, AuditActionMerge:
+		default:
+			return NewValidationError("action", "audit.invalid_action", query.Action)
+		}
+		b.Where("a.action = ?", query.Action)
+	}
+	if query.Source != "" {
+		switch query.Source {
+		case AuditSourceGUI, AuditSourceCLI, AuditSourceAPI, AuditSourceImport:
+		default:
+			return NewValidationError("source", "audit.invalid_source", query.Source)
+		}
+		b.Where("a.source = ?", query.Source)
+	}
+	if query.Since != "" {
+		since, err := time.ParseInLocation(auditDateLayout, query.Since, time.Local)
+		if err != nil {
+			return NewValidationError("since", "audit.invalid_date", query.Since)
+		}
+		b.Where("a.created_at >= ?", since.Format("2006-01-02 15:04:05"))
+	}
+	if query.Until != "" {
+		until, err := time.ParseInLocation(auditDateLayout, query.Until, time.Local)
+		if err != nil {
+			return NewValidationError("until", "audit.invalid_date", query.Until)
+		}
+		b.Where("a.created_at < ?", until.AddDate(0, 0, 1).Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// commandRelation 指令的一种多对多关联（标签、集合、OS），用于在事务中批量增删
+type commandRelation struct {
+	table  string // 关联表
+	column string // 关联表中另一端的列
+	insert string // 插入一条关联的SQL
+	// args 返回insert的参数
+	args func(commandID uint64, value interface{}) []interface{}
+	what string // 用于错误信息
+}
+
+// relationArgs 插入关联的SQL只需要指令ID和另一端的值
+func relationArgs(commandID uint64, value interface{}) []interface{} {
+	return []interface{}{commandID, value}
+}
+
+var (
+	commandTagRelation = commandRelation{
+		table: "command_tags", column: "tag_id",
+		insert: "INSERT OR IGNORE INTO command_tags (command_id, tag_id) VALUES (?, ?)",
+		args:   relationArgs,
+		what:   "标签",
+	}
+	commandCollectionRelation = commandRelation{
+		table: "command_collections", column: "collection_id",
+		insert: insertCollectionStepSQL,
+		// 步骤追加到集合末尾，计算位置时还需要一次集合ID
+		args: func(commandID uint64, collectionID interface{}) []interface{} {
+			return []interface{}{commandID, collectionID, collectionID}
+		},
+		what: "集合",
+	}
+	commandOsRelation = commandRelation{
+		table: "command_os", column: "os",
+		insert: "INSERT OR IGNORE INTO command_os (command_id, os) VALUES (?, ?)",
+		args:   relationArgs,
+		what:   "OS",
+	}
+)
+
+// add 添加关联，已存在的关联保持不变
+func (r commandRelation) add(ctx context.Context, tx *sql.Tx, commandID uint64, values []interface{}) error {
+	for _, v := range values {
+		if _, err := tx.ExecContext(ctx, r.insert, r.args(commandID, v)...); err != nil {
+			return fmt.Errorf("添加指令%s关系失败: %w", r.what, err)
+		}
+	}
+	return nil
+}
+
+// remove 删除指定的关联
+func (r commandRelation) remove(ctx context.Context, tx *sql.Tx, commandID uint64, values []interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE command_id = ? AND %s IN %s", r.table, r.column, inPlaceholders(len(values))),
+		append([]interface{}{commandID}, values...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("删除指令%s关系失败: %w", r.what, err)
+	}
+	return nil
+}
+
+// replace 将关联替换为values：删除不在values中的关联再补上缺少的，
+// 已存在的关联不会被删除重建（集合中的步骤顺序和备注因此得以保留）
+func (r commandRelation) replace(ctx context.Context, tx *sql.Tx, commandID uint64, values []interface{}) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE command_id = ?", r.table)
+	if len(values) > 0 {
+		query += fmt.Sprintf(" AND %s NOT IN %s", r.column, inPlaceholders(len(values)))
+	}
+	if _, err := tx.ExecContext(ctx, query, append([]interface{}{commandID}, values...)...); err != nil {
+		return fmt.Errorf("删除指令%s关系失败: %w", r.what, err)
+	}
+	return r.add(ctx, tx, commandID, values)
+}
+
+// checkIDsExist 一次查询确认ids都存在且未删除，返回缺失ID的错误
+func checkIDsExist(ctx context.Context, q sqlQueryer, table string, ids []uint64, what string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := q.QueryContext(ctx,
+		fmt.Sprintf("SELECT id FROM %s WHERE deleted_at IS NULL AND id IN %s", table, inPlaceholders(len(ids))),
+		toArgs(ids)...,
+	)
+	if err != nil {
+		return fmt.Errorf("检查%s是否存在失败: %w", what, err)
+	}
+	defer rows.Close()
+
+	found := make(map[uint64]bool, len(ids))
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			return fmt.Errorf("扫描%sID失败: %w", what, err)
+		}
+		found[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历%sID失败: %w", what, err)
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, fmt.Sprint(id))
+		}
+	}
+	if len(missing) > 0 {
+		return NewNotFoundError("relation.ids_not_found", messageRef("entity."+table), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// 批量操作类型
+const (
+	bulkAddTags           = "add_tags"
+	bulkRemoveTags        = "remove_tags"
+	bulkAddCollections    = "add_collections"
+	bulkRemoveCollections = "remove_collections"
+	bulkSetOs             = "set_os"
+	bulkDelete            = "delete"
+	bulkRestore           = "restore"
+)
+
+// bulkCommandState 批量操作前读取的指令状态
+type bulkCommandState struct {
+	name    string
+	alias   string
+	deleted bool
+}
+
+// BulkUpdateCommandsSQLite 在一个事务中对多条指令执行同一种操作。
+// 单条指令的问题（不存在、已删除、恢复时重名等）记录在该条的结果中，不影响其他指令；
+// 数据库错误会回滚整个事务
+func BulkUpdateCommandsSQLite(ctx context.Context, req *BulkCommandRequest) (*BulkResult, error) {
+	if req == nil {
+		return nil, NewValidationError("", "bulk.request_required")
+	}
+	ids := uniqueIDs(req.CommandIDs)
+	if len(ids) == 0 {
+		return nil, NewValidationError("commandId", "command.id_required")
+	}
+	switch req.Action {
+	case bulkAddTags, bulkRemoveTags:
+		if len(req.TagIDs) == 0 {
+			return nil, NewValidationError("tagId", "tag.id_required")
+		}
+	case bulkAddCollections, bulkRemoveCollections:
+		if len(req.CollectionIDs) == 0 {
+			return nil, NewValidationError("collectionId", "collection.id_required")
+		}
+	case bulkSetOs:
+		osList, err := NormalizeOSList("os", req.Os)
+		if err != nil {
+			return nil, err
+		}
+		req.Os = osList
+	case bulkDelete, bulkRestore:
+	default:
+		return nil, NewValidationError("action", "bulk.invalid_action", req.Action)
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	// 关联的标签和集合只检查一次
+	switch req.Action {
+	case bulkAddTags:
+		err = checkIDsExist(ctx, tx, "tags", req.TagIDs, "标签")
+	case bulkAddCollections:
+		err = checkIDsExist(ctx, tx, "collections", req.CollectionIDs, "集合")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := loadBulkCommandStates(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCommand, ids...)
+	if err != nil {
+		return nil, err
+	}
+	action := AuditActionUpdate
+	switch req.Action {
+	case bulkDelete:
+		action = AuditActionDelete
+	case bulkRestore:
+		action = AuditActionRestore
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	result := &BulkResult{Items: make([]BulkItemResult, 0, len(ids))}
+	for _, id := range ids {
+		var reason *AppError
+		if reason, err = applyBulkAction(ctx, tx, req, id, states[id], now); err != nil {
+			return nil, err
+		}
+		item := BulkItemResult{CommandID: id, OK: reason == nil}
+		if item.OK {
+			if err = audit.recordOne(ctx, tx, id, action); err != nil {
+				return nil, err
+			}
+			result.Succeeded++
+			if (req.Action == bulkDelete || req.Action == bulkRestore) && states[id].alias != "" {
+				result.aliasChanged = true
+			}
+		} else {
+			item.ErrorKey, item.reason = reason.Key, reason
+			result.Failed++
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return result, nil
+}
+
+// applyBulkAction 对一条指令执行批量操作，返回值reason非空表示该条指令被跳过的原因
+func applyBulkAction(ctx context.Context, tx *sql.Tx, req *BulkCommandRequest, id uint64, state *bulkCommandState, now string) (reason *AppError, err error) {
+	if state == nil {
+		return &AppError{Code: CodeNotFound, Key: "command.not_found", Args: []interface{}{id}}, nil
+	}
+	if req.Action == bulkRestore {
+		if !state.deleted {
+			return &AppError{Code: CodeConflict, Key: "bulk.not_deleted"}, nil
+		}
+		return restoreCommand(ctx, tx, id, state, now)
+	}
+	if state.deleted {
+		return &AppError{Code: CodeConflict, Key: "bulk.already_deleted"}, nil
+	}
+
+	switch req.Action {
+	case bulkAddTags:
+		err = commandTagRelation.add(ctx, tx, id, toArgs(req.TagIDs))
+	case bulkRemoveTags:
+		err = commandTagRelation.remove(ctx, tx, id, toArgs(req.TagIDs))
+	case bulkAddCollections:
+		err = commandCollectionRelation.add(ctx, tx, id, toArgs(req.CollectionIDs))
+	case bulkRemoveCollections:
+		err = commandCollectionRelation.remove(ctx, tx, id, toArgs(req.CollectionIDs))
+	case bulkSetOs:
+		err = commandOsRelation.replace(ctx, tx, id, toArgs(req.Os))
+	case bulkDelete:
+		_, err = tx.ExecContext(ctx, "UPDATE commands SET deleted_at = ?, updated_at = ? WHERE id = ?", now, now, id)
+		if err != nil {
+			err = fmt.Errorf("删除命令失败: %w", err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE commands SET updated_at = ? WHERE id = ?", now, id); err != nil {
+		return nil, fmt.Errorf("更新命令失败: %w", err)
+	}
+	return nil, nil
+}
+
+// restoreCommand 恢复已删除的指令，名称或别名已被其他指令占用时不恢复
+func restoreCommand(ctx context.Context, tx *sql.Tx, id uint64, state *bulkCommandState, now string) (*AppError, error) {
+	var conflict string
+	err := tx.QueryRowContext(ctx,
+		`SELECT CASE WHEN name = ? THEN 'name' ELSE 'alias' END FROM commands
+		WHERE deleted_at IS NULL AND id != ? AND (name = ? OR (? != '' AND alias = ?)) LIMIT 1`,
+		state.name, id, state.name, state.alias, state.alias,
+	).Scan(&conflict)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, fmt.Errorf("检查指令是否冲突失败: %w", err)
+	case conflict == "name":
+		return &AppError{Code: CodeConflict, Key: "command.exists", Args: []interface{}{state.name}}, nil
+	default:
+		return &AppError{Code: CodeConflict, Key: "alias.in_use", Args: []interface{}{state.alias}}, nil
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE commands SET deleted_at = NULL, updated_at = ? WHERE id = ?", now, id); err != nil {
+		return nil, fmt.Errorf("恢复命令失败: %w", err)
+	}
+	return nil, nil
+}
+
+// loadBulkCommandStates 读取指令的名称、别名和删除状态，不存在的指令不在结果中
+func loadBulkCommandStates(ctx context.Context, tx *sql.Tx, ids []uint64) (map[uint64]*bulkCommandState, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, name, alias, deleted_at IS NOT NULL FROM commands WHERE id IN "+inPlaceholders(len(ids)),
+		toArgs(ids)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("获取指令失败: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[uint64]*bulkCommandState, len(ids))
+	for rows.Next() {
+		var id uint64
+		var state bulkCommandState
+		if err = rows.Scan(&id, &state.name, &state.alias, &state.deleted); err != nil {
+			return nil, fmt.Errorf("扫描指令失败: %w", err)
+		}
+		states[id] = &state
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历指令结果集失败: %w", err)
+	}
+	return states, nil
+}
+
+// uniqueIDs 去掉重复和为0的ID，保持原有顺序
+func uniqueIDs(ids []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(ids))
+	result := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestUniqueIDs(t *testing.T) {
+	got := uniqueIDs([]uint64{3, 1, 0, 3, 2, 1})
+	if want := []uint64{3, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueIDs = %v, want %v", got, want)
+	}
+	if got := uniqueIDs(nil); len(got) != 0 {
+		t.Errorf("uniqueIDs(nil) = %v, want empty", got)
+	}
+}
+
+func TestBulkUpdateCommandsRejectsInvalidRequest(t *testing.T) {
+	ctx := context.Background()
+	tests := []*BulkCommandRequest{
+		nil,
+		{Action: bulkDelete},
+		{Action: bulkAddTags, CommandIDs: []uint64{1}},
+		{Action: bulkRemoveCollections, CommandIDs: []uint64{1}},
+		{Action: "rename", CommandIDs: []uint64{1}},
+	}
+	for _, req := range tests {
+		if _, err := BulkUpdateCommandsSQLite(ctx, req); err == nil {
+			t.Errorf("BulkUpdateCommandsSQLite(%+v) expected error", req)
+		}
+	}
+	if resp := NewApp().BulkUpdateCommands(nil); resp.Code != CodeValidation {
+		t.Errorf("BulkUpdateCommands(nil) = %+v, want validation error", resp)
+	}
+}
+
+func TestBulkUpdateCommandsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		req       *BulkCommandRequest
+		succeeded int
+		failed    int
+	}{
+		{"add tags skips deleted", &BulkCommandRequest{
+			Action: bulkAddTags, CommandIDs: f.commandIDs("dir", "greet", "old-backup"), TagIDs: []uint64{f.tag("ops")},
+		}, 2, 1},
+		{"missing tag", &BulkCommandRequest{
+			Action: bulkAddTags, CommandIDs: f.commandIDs("dir"), TagIDs: []uint64{f.tag("archived")},
+		}, -1, -1},
+		{"remove collections", &BulkCommandRequest{
+			Action: bulkRemoveCollections, CommandIDs: f.commandIDs("docker-ps", "kubectl-pods"), CollectionIDs: []uint64{f.collection("deploy")},
+		}, 2, 0},
+		{"set os", &BulkCommandRequest{
+			Action: bulkSetOs, CommandIDs: f.commandIDs("list-files"), Os: []string{"Linux"},
+		}, 1, 0},
+		{"delete", &BulkCommandRequest{
+			Action: bulkDelete, CommandIDs: f.commandIDs("list-files", "old-backup"),
+		}, 1, 1},
+		{"restore", &BulkCommandRequest{
+			Action: bulkRestore, CommandIDs: f.commandIDs("list-files", "old-backup", "dir"),
+		}, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := BulkUpdateCommandsSQLite(ctx, tt.req)
+			if tt.succeeded < 0 {
+				if errorCode(err) != CodeNotFound {
+					t.Errorf("BulkUpdateCommandsSQLite() error = %v, want not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Succeeded != tt.succeeded || result.Failed != tt.failed {
+				t.Errorf("BulkUpdateCommandsSQLite() = %+v, want %d succeeded and %d failed", result, tt.succeeded, tt.failed)
+			}
+		})
+	}
+
+	commands, _, err := GetCommandsSQLite(ctx, Option{Sort: byName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := commandNames(commands); !reflect.DeepEqual(names, []string{"dir", "docker-ps", "greet", "kubectl-pods", "list-files", "old-backup"}) {
+		t.Fatalf("commands after restore = %v", names)
+	}
+	for _, cmd := range commands {
+		switch cmd.Name {
+		case "list-files":
+			if !reflect.DeepEqual(cmd.Os, []string{Linux}) {
+				t.Errorf("os of list-files = %v", cmd.Os)
+			}
+		case "old-backup":
+			// 恢复的指令保留删除前的关联
+			if !reflect.DeepEqual(cmd.TagIDs, []uint64{f.tag("ops")}) || !reflect.DeepEqual(cmd.CollectionIDs, []uint64{f.collection("deploy")}) {
+				t.Errorf("relations of restored command = %v, %v", cmd.TagIDs, cmd.CollectionIDs)
+			}
+		case "docker-ps", "kubectl-pods":
+			if slices.Contains(cmd.CollectionIDs, f.collection("deploy")) {
+				t.Errorf("%s still in deploy: %v", cmd.Name, cmd.CollectionIDs)
+			}
+		}
+	}
+}
+
+func TestBulkRestoreNameConflict(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	if err := CreateCommandSQLite(ctx, &Command{Name: "old-backup", Content: "rsync", Os: []string{Linux}}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := BulkUpdateCommandsSQLite(ctx, &BulkCommandRequest{Action: bulkRestore, CommandIDs: f.commandIDs("old-backup")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Succeeded != 0 || result.Failed != 1 || result.Items[0].ErrorKey != "command.exists" {
+		t.Errorf("restore with taken name = %+v", result)
+	}
+
+	// 跳过原因按当前语言返回给前端
+	withLocale(t, LocaleEN)
+	t.Setenv("HOME", t.TempDir())
+	resp := NewApp().BulkRestoreCommands(f.commandIDs("old-backup"))
+	result, ok := resp.Data.(*BulkResult)
+	if !ok || result.Items[0].Error != "Command [old-backup] already exists" {
+		t.Errorf("BulkRestoreCommands() = %+v", resp)
+	}
+}
@@ -1,112 +1,117 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
+// insertCollectionStepSQL 将指令加入集合并排在最后一步，参数依次为指令ID、集合ID、集合ID
+const insertCollectionStepSQL = `INSERT OR IGNORE INTO command_collections (command_id, collection_id, position)
+	VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM command_collections WHERE collection_id = ?))`
+
 // CreateCollectionSQLite 创建集合
-func CreateCollectionSQLite(collection *Collection) error {
+func CreateCollectionSQLite(ctx context.Context, collection *Collection) error {
+	osList, err := NormalizeOSList("os", collection.Os)
+	if err != nil {
+		return err
+	}
+	collection.Os = osList
 	now := time.Now().Format("2006-01-02 15:04:05")
 	collection.CreatedAt = now
 	collection.UpdatedAt = now
 	collection.SearchCount = 0
-	log.Printf("创建集合: %+v", collection)
+	slog.Debug("创建集合", "name", collection.Name, "os", collection.Os, "commandIds", collection.CommandIDs)
 	// 开启事务，确保所有操作要么全部成功，要么全部失败
-	tx, err := DB.Begin()
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开启事务失败: %v", err)
+		return fmt.Errorf("开启事务失败: %w", err)
 	}
 	defer func() {
 		if err != nil {
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("回滚事务失败: %v", rollbackErr)
+				slog.Error("回滚事务失败", "err", rollbackErr)
 			}
 		}
 	}()
 
 	// 保存到SQLite数据库，不指定id字段，让SQLite自动生成
-	result, err := tx.Exec(
-		"INSERT INTO collections (name, description, search_count, created_at, updated_at, deleted_at) VALUES (?, ?, ?, ?, ?, ?)",
-		collection.Name, collection.Description, collection.SearchCount, collection.CreatedAt, collection.UpdatedAt, collection.DeletedAt,
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO collections (name, description, search_count, pinned, sort_value, created_at, updated_at) VALUES (?, ?, ?, ?, "+nextSortValueSQL("collections")+", ?, ?)",
+		collection.Name, collection.Description, collection.SearchCount, collection.Pinned, collection.CreatedAt, collection.UpdatedAt,
 	)
 	if err != nil {
-		log.Printf("创建集合失败: %v", err)
-		return fmt.Errorf("创建集合失败: %v", err)
+		return fmt.Errorf("创建集合失败: %w", err)
 	}
-	log.Printf("创建集合成功: %+v", collection)
 	// 获取SQLite自动生成的ID
 	id, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("获取集合ID失败: %v", err)
-		return fmt.Errorf("获取集合ID失败: %v", err)
+		return fmt.Errorf("获取集合ID失败: %w", err)
 	}
 	collection.ID = uint64(id)
 
 	// 保存OS关联关系（在事务中执行）
 	for _, os := range collection.Os {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT OR IGNORE INTO collection_os (collection_id, os) VALUES (?, ?)",
 			collection.ID, os,
 		)
 		if err != nil {
-			log.Printf("添加集合OS关系失败: %v", err)
-			return fmt.Errorf("添加集合OS关系失败: %v", err)
+			return fmt.Errorf("添加集合OS关系失败: %w", err)
 		}
 	}
-	log.Printf("添加集合OS关系成功: %+v", collection)
 
 	// 保存指令关联关系
 	for _, commandID := range collection.CommandIDs {
-		_, err = tx.Exec(
-			"INSERT OR IGNORE INTO command_collections (command_id, collection_id) VALUES (?, ?)",
-			commandID, collection.ID,
-		)
+		_, err = tx.ExecContext(ctx, insertCollectionStepSQL, commandID, collection.ID, collection.ID)
 		if err != nil {
-			log.Printf("添加集合指令关系失败: %v", err)
-			return fmt.Errorf("添加集合指令关系失败: %v", err)
+			return fmt.Errorf("添加集合指令关系失败: %w", err)
 		}
 	}
-	log.Printf("添加集合指令关系成功: %+v", collection)
+
+	if err = recordAudit(ctx, tx, AuditEntityCollection, collection.ID, AuditActionCreate, nil); err != nil {
+		return err
+	}
 
 	// 提交事务，所有操作都成功完成
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return fmt.Errorf("提交事务失败: %w", err)
 	}
-	log.Printf("提交事务成功: %+v", collection)
+	slog.Debug("创建集合成功", "id", collection.ID)
 	return nil
 }
 
 // GetCollectionSQLite 获取单个集合
-func GetCollectionSQLite(id uint64) (*Collection, error) {
+func GetCollectionSQLite(ctx context.Context, id uint64) (*Collection, error) {
 	// 输入验证
 	if id == 0 {
-		return nil, fmt.Errorf("集合ID不能为空")
+		return nil, NewValidationError("collectionId", "collection.id_required")
 	}
 
 	var collection Collection
-	var osString string
 	var deletedAt sql.NullTime
 
 	// 使用参数化查询，防止SQL注入
-	err := DB.QueryRow(
-		"SELECT id, name, description, search_count, os, created_at, updated_at, deleted_at FROM collections WHERE id = ? AND deleted_at IS NULL",
+	err := DB.QueryRowContext(ctx,
+		"SELECT id, name, description, search_count, pinned, sort_value, created_at, updated_at, deleted_at FROM collections WHERE id = ? AND deleted_at IS NULL",
 		id,
 	).Scan(
-		&collection.ID, &collection.Name, &collection.Description, &collection.SearchCount, &osString, &collection.CreatedAt, &collection.UpdatedAt, &deletedAt,
+		&collection.ID, &collection.Name, &collection.Description, &collection.SearchCount, &collection.Pinned, &collection.SortValue, &collection.CreatedAt, &collection.UpdatedAt, &deletedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("collection not found: %d", id)
+			return nil, NewNotFoundError("collection.not_found", id)
 		}
-		return nil, fmt.Errorf("获取集合失败: %v", err)
+		return nil, fmt.Errorf("获取集合失败: %w", err)
 	}
 
 	// 从关联表获取OS信息
-	if collection.Os, err = GetCollectionOSsSQLite(id); err != nil {
-		return nil, fmt.Errorf("获取集合OS失败: %v", err)
+	if collection.Os, err = GetCollectionOSsSQLite(ctx, id); err != nil {
+		return nil, fmt.Errorf("获取集合OS失败: %w", err)
 	}
 
 	// 处理deletedAt字段
@@ -117,33 +122,22 @@ func GetCollectionSQLite(id uint64) (*Collection, error) {
 	return &collection, nil
 }
 
-// GetCollectionsSQLite 获取所有集合
-func GetCollectionsSQLite(option Option) ([]*Collection, error) {
+// GetCollectionsSQLite 按Option分页获取集合
+func GetCollectionsSQLite(ctx context.Context, option Option) ([]*Collection, Page, error) {
 	var collections []*Collection
-	query := `SELECT id, name, description, search_count, created_at, updated_at, deleted_at 
-	FROM collections c RIGHT JOIN collection_os cs 
-	ON c.id = cs.collection_id
-	WHERE id IS NOT NULL AND deleted_at IS NULL`
-	if option.Name != "" {
-		query += fmt.Sprintf(" AND name LIKE '%%%s%%'", option.Name)
-	}
-	if option.ID != 0 {
-		query += fmt.Sprintf(" AND id = %d", option.ID)
-	}
-	if len(option.Os) > 0 {
-		args, err := SQL_Slice_To_In_Args(option.Os)
-		if err != nil {
-			return nil, fmt.Errorf("转换OS列表为IN参数失败: %v", err)
-		}
-		query += fmt.Sprintf(" AND os IN %s", args)
+
+	b := collectionListEntity.newSelect("col.id", "col.name", "col.description", "col.search_count", "col.pinned", "col.sort_value", "col.created_at", "col.updated_at", "col.deleted_at")
+	list, err := collectionListEntity.prepareList(ctx, b, option)
+	if err != nil {
+		return nil, Page{}, err
 	}
-	query += ";"
-	log.Printf("GetCollectionsSQLite SQL: %s", query)
+	query, args := b.Build()
+	slog.Debug("查询集合列表", "sql", query, "args", args)
 
 	// 从SQLite数据库获取所有集合
-	rows, err := DB.Query(query)
+	rows, err := DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("获取集合列表失败: %v", err)
+		return nil, Page{}, fmt.Errorf("获取集合列表失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -153,10 +147,10 @@ func GetCollectionsSQLite(option Option) ([]*Collection, error) {
 		var deletedAt sql.NullTime
 
 		err = rows.Scan(
-			&collection.ID, &collection.Name, &collection.Description, &collection.SearchCount, &collection.CreatedAt, &collection.UpdatedAt, &deletedAt,
+			&collection.ID, &collection.Name, &collection.Description, &collection.SearchCount, &collection.Pinned, &collection.SortValue, &collection.CreatedAt, &collection.UpdatedAt, &deletedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("扫描集合失败: %v", err)
+			return nil, Page{}, fmt.Errorf("扫描集合失败: %w", err)
 		}
 
 		// 处理deletedAt字段
@@ -168,74 +162,140 @@ func GetCollectionsSQLite(option Option) ([]*Collection, error) {
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历集合结果集失败: %v", err)
+		return nil, Page{}, fmt.Errorf("遍历集合结果集失败: %w", err)
 	}
 
-	return collections, nil
+	collections, page := pageResult(collections, list, func(c *Collection) uint64 { return c.ID })
+	return collections, page, nil
 }
 
 // UpdateCollectionSQLite 更新集合
-func UpdateCollectionSQLite(collection *Collection) error {
+func UpdateCollectionSQLite(ctx context.Context, collection *Collection) error {
+	osList, err := NormalizeOSList("os", collection.Os)
+	if err != nil {
+		return err
+	}
+	collection.Os = osList
 	collection.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
 
+	// 集合本身和OS关系在一个事务中更新
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCollection, collection.ID)
+	if err != nil {
+		return err
+	}
+
 	// 更新SQLite数据库中的集合
-	_, err := DB.Exec(
-		"UPDATE collections SET name = ?, description = ?, search_count = ?, os = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
-		collection.Name, collection.Description, collection.SearchCount, "", collection.UpdatedAt, collection.ID,
+	result, err := tx.ExecContext(ctx,
+		"UPDATE collections SET name = ?, description = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		collection.Name, collection.Description, collection.UpdatedAt, collection.ID,
 	)
 	if err != nil {
-		return fmt.Errorf("更新集合失败: %v", err)
+		return fmt.Errorf("更新集合失败: %w", err)
 	}
-
-	// 先删除现有的OS关系
-	if err := RemoveAllOSFromCollectionSQLite(collection.ID); err != nil {
-		return fmt.Errorf("删除集合OS关系失败: %v", err)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新影响的行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("collection.not_found", collection.ID)
+		return err
 	}
 
-	// 重新添加OS关系
+	// 先删除现有的OS关系，再重新添加
+	if _, err = tx.ExecContext(ctx, "DELETE FROM collection_os WHERE collection_id = ?", collection.ID); err != nil {
+		return fmt.Errorf("删除集合OS关系失败: %w", err)
+	}
 	for _, os := range collection.Os {
-		if err := AddOSToCollectionSQLite(collection.ID, os); err != nil {
-			return fmt.Errorf("添加集合OS关系失败: %v", err)
+		if _, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO collection_os (collection_id, os) VALUES (?, ?)", collection.ID, os); err != nil {
+			return fmt.Errorf("添加集合OS关系失败: %w", err)
 		}
 	}
 
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
 	return nil
 }
 
 // DeleteCollectionSQLite 删除集合（软删除）
-func DeleteCollectionSQLite(id uint64) error {
+func DeleteCollectionSQLite(ctx context.Context, id uint64) error {
 	now := time.Now()
 
+	// 删除和审计日志在同一个事务中写入
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCollection, id)
+	if err != nil {
+		return err
+	}
+
 	// 更新集合的deleted_at字段
-	result, err := DB.Exec(
+	result, err := tx.ExecContext(ctx,
 		"UPDATE collections SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
 		now, now, id,
 	)
 	if err != nil {
-		return fmt.Errorf("删除集合失败: %v", err)
+		return fmt.Errorf("删除集合失败: %w", err)
 	}
 
 	// 检查是否有行被更新
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("获取删除影响的行数失败: %v", err)
+		return fmt.Errorf("获取删除影响的行数失败: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("collection not found: %d", id)
+		err = NewNotFoundError("collection.not_found", id)
+		return err
+	}
+
+	if err = audit.record(ctx, tx, AuditActionDelete); err != nil {
+		return err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
 	return nil
 }
 
-func GetCollectionIDAndNameSQLite() ([]Collection, error) {
+func GetCollectionIDAndNameSQLite(ctx context.Context) ([]Collection, error) {
 	var collections []Collection
 	query := "SELECT DISTINCT id, name FROM collections WHERE id IS NOT NULL AND deleted_at IS NULL"
-	log.Printf("GetCollectionIDAndNameSQLite SQL: %s", query)
 
-	rows, err := DB.Query(query)
+	rows, err := DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("获取集合ID和名称失败: %v", err)
+		return nil, fmt.Errorf("获取集合ID和名称失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -243,13 +303,13 @@ func GetCollectionIDAndNameSQLite() ([]Collection, error) {
 		var id uint64
 		var name string
 		if err = rows.Scan(&id, &name); err != nil {
-			return nil, fmt.Errorf("扫描集合ID和名称失败: %v", err)
+			return nil, fmt.Errorf("扫描集合ID和名称失败: %w", err)
 		}
 		collections = append(collections, Collection{ID: id, Name: name})
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历集合ID和名称结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历集合ID和名称结果集失败: %w", err)
 	}
 
 	return collections, nil
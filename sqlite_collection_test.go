@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// collectionNames 返回集合的名称列表
+func collectionNames(collections []*Collection) []string {
+	names := make([]string, len(collections))
+	for i, col := range collections {
+		names[i] = col.Name
+	}
+	return names
+}
+
+func TestGetCollectionsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name   string
+		option Option
+		want   []string
+	}{
+		{"all active", Option{}, []string{"cleanup", "deploy"}},
+		{"linux", Option{Os: []string{Linux}}, []string{"cleanup", "deploy"}},
+		{"mac", Option{Os: []string{Mac}}, []string{"cleanup"}},
+		{"name", Option{Name: "dep"}, []string{"deploy"}},
+		{"deleted id", Option{ID: f.collection("retired")}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.option.Sort = byName()
+			collections, page, err := GetCollectionsSQLite(context.Background(), tt.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := collectionNames(collections); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCollectionsSQLite(%+v) = %v, want %v", tt.option, got, tt.want)
+			}
+			if page.Total != len(tt.want) {
+				t.Errorf("page.Total = %d, want %d", page.Total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetCollectionSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name string
+		id   uint64
+		code ErrorCode
+		os   []string
+	}{
+		{"active", f.collection("deploy"), CodeOK, []string{Linux}},
+		{"deleted", f.collection("retired"), CodeNotFound, nil},
+		{"zero", 0, CodeValidation, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col, err := GetCollectionSQLite(context.Background(), tt.id)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("GetCollectionSQLite(%d) error = %v, want code %d", tt.id, err, tt.code)
+			}
+			if err == nil && !reflect.DeepEqual(col.Os, tt.os) {
+				t.Errorf("GetCollectionSQLite(%d).Os = %v, want %v", tt.id, col.Os, tt.os)
+			}
+		})
+	}
+}
+
+func TestCreateCollectionSQLite(t *testing.T) {
+	newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name string
+		col  *Collection
+		code ErrorCode
+	}{
+		{"new", &Collection{Name: "backup", Os: []string{"darwin"}}, CodeOK},
+		{"invalid os", &Collection{Name: "bad", Os: []string{"plan9"}}, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CreateCollectionSQLite(context.Background(), tt.col)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("CreateCollectionSQLite() error = %v, want code %d", err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			osList, err := GetCollectionOSsSQLite(context.Background(), tt.col.ID)
+			if err != nil || !reflect.DeepEqual(osList, []string{Mac}) {
+				t.Errorf("GetCollectionOSsSQLite() = %v, %v", osList, err)
+			}
+		})
+	}
+}
+
+func TestUpdateCollectionSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		col  *Collection
+		code ErrorCode
+	}{
+		{"replace os", &Collection{ID: f.collection("deploy"), Name: "deploy-prod", Os: []string{Linux, Mac}}, CodeOK},
+		{"clear os", &Collection{ID: f.collection("cleanup"), Name: "cleanup"}, CodeOK},
+		{"deleted", &Collection{ID: f.collection("retired"), Name: "retired"}, CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := UpdateCollectionSQLite(ctx, tt.col)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("UpdateCollectionSQLite() error = %v, want code %d", err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			got, err := GetCollectionSQLite(ctx, tt.col.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(got.Os)
+			if got.Name != tt.col.Name || !slices.Equal(got.Os, tt.col.Os) {
+				t.Errorf("updated collection = %+v, want %+v", got, tt.col)
+			}
+		})
+	}
+}
+
+func TestDeleteCollectionSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	id := f.collection("deploy")
+	if err := DeleteCollectionSQLite(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCollectionSQLite(ctx, id); errorCode(err) != CodeNotFound {
+		t.Errorf("GetCollectionSQLite(deleted) error = %v", err)
+	}
+	if err := DeleteCollectionSQLite(ctx, id); errorCode(err) != CodeNotFound {
+		t.Errorf("DeleteCollectionSQLite(deleted) error = %v", err)
+	}
+
+	idNames, err := GetCollectionIDAndNameSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idNames) != 1 || idNames[0].Name != "cleanup" {
+		t.Errorf("GetCollectionIDAndNameSQLite() = %+v", idNames)
+	}
+}
@@ -1,133 +1,145 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
 // CreateCommandSQLite 创建命令
-func CreateCommandSQLite(cmd *Command) error {
-	log.Printf("CreateCommandSQLite请求参数: %+v\n", cmd)
+func CreateCommandSQLite(ctx context.Context, cmd *Command) error {
+	slog.Debug("创建命令", "name", cmd.Name, "content", cmd.Content, "tagIds", cmd.TagIDs, "collectionIds", cmd.CollectionIDs, "os", cmd.Os)
+	if err := ValidateCommandOs(cmd); err != nil {
+		return err
+	}
 	now := time.Now().Format("2006-01-02 15:04:05")
 	cmd.CreatedAt = now
 	cmd.UpdatedAt = now
 	cmd.CopyCounts = 0
 	cmd.SearchCount = 0
 
-	// 检查命令名称是否已存在
-	var exists bool
-	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM commands WHERE name = ? AND deleted_at IS NULL)", cmd.Name).Scan(&exists)
-	if err != nil {
-		log.Printf("检查命令是否存在失败: %v", err)
-		return fmt.Errorf("检查命令是否存在失败: %v", err)
-	}
-	if exists {
-		log.Printf("命令[%s]已存在", cmd.Name)
-		return fmt.Errorf("命令[%s]已存在", cmd.Name)
-	}
-
 	// 开启事务，确保所有操作要么全部成功，要么全部失败
-	tx, err := DB.Begin()
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开启事务失败: %v", err)
+		return fmt.Errorf("开启事务失败: %w", err)
 	}
 	defer func() {
 		if err != nil {
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("回滚事务失败: %v", rollbackErr)
+				slog.Error("回滚事务失败", "err", rollbackErr)
 			}
 		}
 	}()
 
+	// 在事务中检查命令名称是否已存在，避免并发创建同名命令
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM commands WHERE name = ? AND deleted_at IS NULL)", cmd.Name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("检查命令是否存在失败: %w", err)
+	}
+	if exists {
+		err = NewConflictError("command.exists", cmd.Name)
+		return err
+	}
+	if err = checkCommandAlias(ctx, tx, cmd); err != nil {
+		return err
+	}
+
 	// 保存命令基本信息到SQLite数据库，不指定id字段，让SQLite自动生成
-	result, err := tx.Exec(
-		"INSERT INTO commands (name, content, description, copy_count, search_count, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		cmd.Name, cmd.Content, cmd.Description, cmd.CopyCounts, cmd.SearchCount, cmd.CreatedAt, cmd.UpdatedAt,
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO commands (name, content, description, alias, copy_count, search_count, pinned, sort_value, risk_level, skip_syntax_check, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, "+nextSortValueSQL("commands")+", ?, ?, ?, ?)",
+		cmd.Name, cmd.Content, cmd.Description, cmd.Alias, cmd.CopyCounts, cmd.SearchCount, cmd.Pinned, riskLevelOrNone(cmd.Risk), cmd.skipSyntaxCheck(), cmd.CreatedAt, cmd.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("创建命令失败: %v", err)
+		return fmt.Errorf("创建命令失败: %w", err)
 	}
 
 	// 获取SQLite自动生成的ID
 	id, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("获取命令ID失败: %v", err)
+		return fmt.Errorf("获取命令ID失败: %w", err)
 	}
 	cmd.ID = uint64(id)
 
-	log.Printf("创建命令成功, ID: %d", id)
 	// 保存命令与标签的多对多关系（在事务中执行）
 	for _, tagID := range cmd.TagIDs {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT OR IGNORE INTO command_tags (command_id, tag_id) VALUES (?, ?)",
 			cmd.ID, tagID,
 		)
 		if err != nil {
-			return fmt.Errorf("添加命令标签关系失败: %v", err)
+			return fmt.Errorf("添加命令标签关系失败: %w", err)
 		}
 	}
-	log.Printf("添加命令标签关系成功, 命令ID: %d, 标签ID: %v", cmd.ID, cmd.TagIDs)
-	// 保存命令与集合的多对多关系（在事务中执行）
 
-	log.Printf("开始添加命令集合关系, 命令ID: %d, 集合ID: %v", cmd.ID, cmd.CollectionIDs)
+	// 保存命令与集合的多对多关系（在事务中执行）
 	for _, collectionID := range cmd.CollectionIDs {
-		_, err = tx.Exec(
-			"INSERT OR IGNORE INTO command_collections (command_id, collection_id) VALUES (?, ?)",
-			cmd.ID, collectionID,
-		)
+		_, err = tx.ExecContext(ctx, insertCollectionStepSQL, cmd.ID, collectionID, collectionID)
 		if err != nil {
-			return fmt.Errorf("添加命令集合关系失败: %v", err)
+			return fmt.Errorf("添加命令集合关系失败: %w", err)
 		}
 	}
-	log.Printf("添加命令集合关系成功, 命令ID: %d, 集合ID: %v", cmd.ID, cmd.CollectionIDs)
 
 	// 保存OS关联关系（在事务中执行）
 	for _, os := range cmd.Os {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT OR IGNORE INTO command_os (command_id, os) VALUES (?, ?)",
 			cmd.ID, os,
 		)
 		if err != nil {
-			return fmt.Errorf("添加命令OS关系失败: %v", err)
+			return fmt.Errorf("添加命令OS关系失败: %w", err)
 		}
 	}
-	log.Printf("添加命令OS关系成功, 命令ID: %d, OS: %v", cmd.ID, cmd.Os)
+
+	// 保存各OS下的变体（在事务中执行）
+	if err = insertCommandVariants(ctx, tx, cmd.ID, cmd.Variants); err != nil {
+		return err
+	}
+
+	if err = recordAudit(ctx, tx, AuditEntityCommand, cmd.ID, AuditActionCreate, nil); err != nil {
+		return err
+	}
 
 	// 提交事务，所有操作都成功完成
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return fmt.Errorf("提交事务失败: %w", err)
 	}
 
-	log.Printf("创建命令事物完成, ID: %d", cmd.ID)
+	slog.Debug("创建命令成功", "id", cmd.ID)
 	return nil
 }
 
 // GetCommandSQLite 获取单个命令
-func GetCommandSQLite(id uint64) (*Command, error) {
+func GetCommandSQLite(ctx context.Context, id uint64) (*Command, error) {
 	// 输入验证
 	if id == 0 {
-		return nil, fmt.Errorf("命令ID不能为空")
+		return nil, NewValidationError("commandId", "command.id_required")
 	}
 
 	var cmd Command
 	var deletedAt sql.NullTime
-	var osString string
+	var frecency float64
+	var lastUsedAt sql.NullString
 
 	// 使用参数化查询，防止SQL注入
-	err := DB.QueryRow(
-		"SELECT id, name, content, description, copy_count, search_count, os, created_at, updated_at, deleted_at FROM commands WHERE id = ? AND deleted_at IS NULL",
+	err := DB.QueryRowContext(ctx,
+		"SELECT id, name, content, description, alias, copy_count, run_count, search_count, frecency, last_used_at, pinned, sort_value, risk_level, skip_syntax_check, created_at, updated_at, deleted_at FROM commands WHERE id = ? AND deleted_at IS NULL",
 		id,
 	).Scan(
-		&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Description, &cmd.CopyCounts, &cmd.SearchCount, &osString, &cmd.CreatedAt, &cmd.UpdatedAt, &deletedAt,
+		&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Description, &cmd.Alias, &cmd.CopyCounts, &cmd.RunCount, &cmd.SearchCount, &frecency, &lastUsedAt, &cmd.Pinned, &cmd.SortValue, &cmd.Risk, &cmd.SkipSyntaxCheck, &cmd.CreatedAt, &cmd.UpdatedAt, &deletedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("command not found: %d", id)
+			return nil, NewNotFoundError("command.not_found", id)
 		}
-		return nil, fmt.Errorf("获取命令失败: %v", err)
+		return nil, fmt.Errorf("获取命令失败: %w", err)
 	}
+	cmd.Frecency = frecencyScore(frecency, time.Now())
+	cmd.LastUsedAt = lastUsedAt.String
 
 	// 处理deletedAt字段
 	if deletedAt.Valid {
@@ -136,57 +148,45 @@ func GetCommandSQLite(id uint64) (*Command, error) {
 
 	// // 获取命令关联的标签ID
 	// if cmd.TagIDs, err = GetTagIDsByCommandIDSQLite(id); err != nil {
-	// 	return nil, fmt.Errorf("获取命令标签ID失败: %v", err)
+	// 	return nil, fmt.Errorf("获取命令标签ID失败: %w", err)
 	// }
 
 	// // 获取命令关联的集合ID
-	// if cmd.CollectionIDs, err = GetCollectionIDsByCommandIDSQLite(id); err != nil {
-	// 	return nil, fmt.Errorf("获取命令集合ID失败: %v", err)
+	// if cmd.CollectionIDs, err = GetCollectionIDsByCommandIDSQLite(ctx, id); err != nil {
+	// 	return nil, fmt.Errorf("获取命令集合ID失败: %w", err)
 	// }
 
 	// // 从关联表获取OS信息
-	// if cmd.Os, err = GetCommandOSsSQLite(id); err != nil {
-	// 	return nil, fmt.Errorf("获取命令OS失败: %v", err)
+	// if cmd.Os, err = GetCommandOSsSQLite(ctx, id); err != nil {
+	// 	return nil, fmt.Errorf("获取命令OS失败: %w", err)
 	// }
 
 	return &cmd, nil
 }
 
-func GetCommandsByTagIDs(ids []uint64) ([]*Command, error) {
-	if len(ids) == 0 {
-		return []*Command{}, nil
-	}
-	var commands []*Command
-	query := `
-	SELECT cmd.* FROM commands cmd
-	INNER JOIN command_tags ct ON cmd.id = ct.command_id
-	WHERE cmd.deleted_at IS NULL
-	AND ct.tag_id IN %s ;
-	`
-	args, err := SQL_Slice_To_In_Args(ids)
-	if err != nil {
-		return nil, fmt.Errorf("转换ID列表为IN参数失败: %v", err)
-	}
-	log.Printf("GetCommandsByTagIDs SQL: %s", query)
-	log.Printf("GetCommandsByTagIDs args: %s", args)
-	query = fmt.Sprintf(query, args)
-	rows, err := DB.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("获取命令列表失败: %v", err)
-	}
-	defer rows.Close()
+// commandColumns 列表查询命令时使用的列，顺序与scanCommands一致
+var commandColumns = []string{
+	"c.id", "c.name", "c.content", "c.description", "c.alias", "c.copy_count", "c.run_count", "c.search_count", "c.frecency", "c.last_used_at", "c.pinned", "c.sort_value", "c.risk_level", "c.skip_syntax_check", "c.created_at", "c.updated_at", "c.deleted_at",
+}
 
+// scanCommands 扫描按commandColumns查询出的结果集
+func scanCommands(rows *sql.Rows) ([]*Command, error) {
+	var commands []*Command
+	now := time.Now()
 	for rows.Next() {
 		var cmd Command
 		var deletedAt sql.NullTime
-		// var osString string
+		var frecency float64
+		var lastUsedAt sql.NullString
 
 		err := rows.Scan(
-			&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Description, &cmd.CopyCounts, &cmd.SearchCount, &cmd.CreatedAt, &cmd.UpdatedAt, &deletedAt,
+			&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Description, &cmd.Alias, &cmd.CopyCounts, &cmd.RunCount, &cmd.SearchCount, &frecency, &lastUsedAt, &cmd.Pinned, &cmd.SortValue, &cmd.Risk, &cmd.SkipSyntaxCheck, &cmd.CreatedAt, &cmd.UpdatedAt, &deletedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("扫描命令失败: %v", err)
+			return nil, fmt.Errorf("扫描命令失败: %w", err)
 		}
+		cmd.Frecency = frecencyScore(frecency, now)
+		cmd.LastUsedAt = lastUsedAt.String
 
 		// 处理deletedAt字段
 		if deletedAt.Valid {
@@ -195,214 +195,258 @@ func GetCommandsByTagIDs(ids []uint64) ([]*Command, error) {
 
 		commands = append(commands, &cmd)
 	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历命令结果集失败: %w", err)
+	}
 	return commands, nil
 }
 
-func GetCommandByCollectionIds(ids []uint64) ([]*Command, error) {
-	if len(ids) == 0 {
-		return []*Command{}, nil
-	}
-	var commands []*Command
-	query := `
-	SELECT cmd.* FROM commands cmd
-	INNER JOIN command_collections ct ON cmd.id = ct.command_id
-	WHERE cmd.deleted_at IS NULL
-	AND ct.collection_id IN %s ;
-	`
-	args, err := SQL_Slice_To_In_Args(ids)
-	if err != nil {
-		return nil, fmt.Errorf("转换ID列表为IN参数失败: %v", err)
-	}
-	log.Printf("GetCommandByCollectionIds SQL: %s", query)
-	log.Printf("GetCommandByCollectionIds args: %s", args)
-	query = fmt.Sprintf(query, args)
-	rows, err := DB.Query(query)
+// queryCommands 执行查询构造器生成的命令查询
+func queryCommands(ctx context.Context, b *SelectBuilder) ([]*Command, error) {
+	query, args := b.Build()
+	slog.Debug("查询命令", "sql", query, "args", args)
+	rows, err := DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("获取命令列表失败: %v", err)
+		return nil, fmt.Errorf("获取命令列表失败: %w", err)
 	}
 	defer rows.Close()
+	return scanCommands(rows)
+}
 
-	for rows.Next() {
-		var cmd Command
-		var deletedAt sql.NullTime
-		// var osString string
-
-		err := rows.Scan(
-			&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Description, &cmd.CopyCounts, &cmd.SearchCount, &cmd.CreatedAt, &cmd.UpdatedAt, &deletedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("扫描命令失败: %v", err)
-		}
-
-		// 处理deletedAt字段
-		if deletedAt.Valid {
-		cmd.DeletedAt = deletedAt.Time.Format("2006-01-02 15:04:05")
+// GetCommandsByTagIDs 获取关联了任一指定标签或其后代标签的命令
+func GetCommandsByTagIDs(ctx context.Context, ids []uint64, sort SortOption) ([]*Command, error) {
+	if len(ids) == 0 {
+		return []*Command{}, nil
 	}
-
-		commands = append(commands, &cmd)
+	subtree := fmt.Sprintf(tagSubtreeQuery, "id IN "+inPlaceholders(len(ids)))
+	b := commandListEntity.newSelect(commandColumns...).Where(
+		"EXISTS (SELECT 1 FROM command_tags ct WHERE ct.command_id = c.id AND ct.tag_id IN ("+subtree+"))",
+		toArgs(ids)...,
+	)
+	if err := commandListEntity.applySort(b, sort); err != nil {
+		return nil, err
 	}
-	return commands, nil
+	return queryCommands(ctx, b)
 }
 
-// GetCommandsSQLite 获取所有命令
-func GetCommandsSQLite(option Option) ([]*Command, error) {
-	var commands []*Command
-
-	query := "SELECT DISTINCT id, name, content, description, copy_count, search_count, created_at, updated_at, deleted_at FROM commands c JOIN command_os cs WHERE deleted_at IS NULL"
-	// args := []interface{}{}
-
-	if option.Name != "" {
-		query += fmt.Sprintf(" AND name LIKE '%%%s%%'", option.Name)
-		// args = append(args, "%"+option.Name+"%")
-	}
-	if option.ID != 0 {
-		query += fmt.Sprintf(" AND id = %d", option.ID)
-		// args = append(args, option.ID)
+// GetCommandByCollectionIds 获取关联了任一指定集合的命令
+func GetCommandByCollectionIds(ctx context.Context, ids []uint64, sort SortOption) ([]*Command, error) {
+	if len(ids) == 0 {
+		return []*Command{}, nil
 	}
-	if len(option.Os) > 0 {
-		args, err := SQL_Slice_To_In_Args(option.Os)
-		if err != nil {
-			return nil, fmt.Errorf("转换OS列表为IN参数失败: %v", err)
-		}
-		query += fmt.Sprintf(" AND cs.os IN %s", args)
+	b := commandListEntity.newSelect(commandColumns...).Where(
+		"EXISTS (SELECT 1 FROM command_collections cc WHERE cc.command_id = c.id AND cc.collection_id IN "+inPlaceholders(len(ids))+")",
+		toArgs(ids)...,
+	)
+	if err := commandListEntity.applySort(b, sort); err != nil {
+		return nil, err
 	}
-	query += ";"
+	return queryCommands(ctx, b)
+}
 
-	log.Printf("GetCommandsSQLite SQL: %s", query)
-	rows, err := DB.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("获取命令列表失败: %v", err)
-	}
-	defer rows.Close()
+// GetCommandsSQLite 按Option分页获取命令，option.Name按搜索语法解析
+func GetCommandsSQLite(ctx context.Context, option Option) ([]*Command, Page, error) {
+	b := commandListEntity.newSelect(commandColumns...)
 
-	for rows.Next() {
-		var cmd Command
-		var deletedAt sql.NullTime
-
-		err = rows.Scan(
-			&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Description, &cmd.CopyCounts, &cmd.SearchCount, &cmd.CreatedAt, &cmd.UpdatedAt, &deletedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("扫描命令失败: %v", err)
+	search, err := ParseSearchQuery(option.Name)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	if search != nil {
+		cond, args := search.Compile()
+		b.Where(cond, args...)
+	}
+	// 名称条件已经包含在搜索语法中
+	option.Name = ""
+	// 搜索时没有指定排序则综合frecency和全文相关度排序，最近常用、匹配程度高的指令排在前面；
+	// 只有字段条件没有搜索词时只按frecency排序
+	if search != nil && option.Sort.isEmpty() {
+		if match := ftsMatchQuery(search.textTerms()); match != "" {
+			option.Sort.relevance = match
+		} else {
+			desc := "desc"
+			option.Sort.Frecency = &desc
 		}
-
-		if deletedAt.Valid {
-		cmd.DeletedAt = deletedAt.Time.Format("2006-01-02 15:04:05")
 	}
 
-		commands = append(commands, &cmd)
+	list, err := commandListEntity.prepareList(ctx, b, option)
+	if err != nil {
+		return nil, Page{}, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历命令结果集失败: %v", err)
+	commands, err := queryCommands(ctx, b)
+	if err != nil {
+		return nil, Page{}, err
 	}
+	commands, page := pageResult(commands, list, func(c *Command) uint64 { return c.ID })
 
-	if err = FillCommandRelations(commands); err != nil {
-		return nil, fmt.Errorf("填充命令关联数据失败: %v", err)
+	if err = FillCommandRelations(ctx, commands); err != nil {
+		return nil, Page{}, fmt.Errorf("填充命令关联数据失败: %w", err)
 	}
 
-	return commands, nil
+	return commands, page, nil
 }
 
 // UpdateCommandSQLite 更新命令
-func UpdateCommandSQLite(cmd *Command) error {
+func UpdateCommandSQLite(ctx context.Context, cmd *Command) error {
 	// 输入验证
 	if cmd == nil {
-		return fmt.Errorf("命令对象不能为空")
+		return NewValidationError("", "command.required")
 	}
 	if cmd.ID == 0 {
-		return fmt.Errorf("命令ID不能为空")
+		return NewValidationError("commandId", "command.id_required")
+	}
+	if err := ValidateCommandOs(cmd); err != nil {
+		return err
 	}
 
 	cmd.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
 
-	// 使用参数化查询，防止SQL注入
-	_, err := DB.Exec(
-		"UPDATE commands SET name = ?, content = ?, description = ?, copy_count = ?, search_count = ?, os = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
-		cmd.Name, cmd.Content, cmd.Description, cmd.CopyCounts, cmd.SearchCount, 0, cmd.UpdatedAt, cmd.ID,
-	)
+	// 指令本身和所有关联关系在一个事务中更新，任何一步失败都不会留下部分修改
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("更新命令失败: %v", err)
+		return fmt.Errorf("开启事务失败: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
 
-	// 先删除现有的OS关系
-	if err := RemoveAllOSFromCommandSQLite(cmd.ID); err != nil {
-		return fmt.Errorf("删除命令OS关系失败: %v", err)
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCommand, cmd.ID)
+	if err != nil {
+		return err
 	}
-
-	// 重新添加OS关系
-	for _, os := range cmd.Os {
-		if err := AddOSToCommandSQLite(cmd.ID, os); err != nil {
-			return fmt.Errorf("添加命令OS关系失败: %v", err)
-		}
+	if err = checkCommandAlias(ctx, tx, cmd); err != nil {
+		return err
 	}
 
-	// 先删除现有的命令与标签的关系
-	if err := RemoveAllTagsFromCommandSQLite(cmd.ID); err != nil {
-		return fmt.Errorf("删除命令标签关系失败: %v", err)
+	result, err := tx.ExecContext(ctx,
+		"UPDATE commands SET name = ?, content = ?, description = ?, alias = ?, risk_level = ?, skip_syntax_check = COALESCE(?, skip_syntax_check), updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		cmd.Name, cmd.Content, cmd.Description, cmd.Alias, riskLevelOrNone(cmd.Risk), cmd.SkipSyntaxCheck, cmd.UpdatedAt, cmd.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("更新命令失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("command.not_found", cmd.ID)
+		return err
 	}
 
-	// 重新添加命令与标签的关系
-	for _, tagID := range cmd.TagIDs {
-		if err := AddTagToCommandSQLite(cmd.ID, tagID); err != nil {
-			return fmt.Errorf("添加命令标签关系失败: %v", err)
-		}
+	if err = checkIDsExist(ctx, tx, "tags", cmd.TagIDs, "标签"); err != nil {
+		return err
+	}
+	if err = checkIDsExist(ctx, tx, "collections", cmd.CollectionIDs, "集合"); err != nil {
+		return err
 	}
 
-	// 先删除现有的命令与集合的关系
-	if err := RemoveAllCollectionsFromCommandSQLite(cmd.ID); err != nil {
-		return fmt.Errorf("删除命令集合关系失败: %v", err)
+	if err = commandOsRelation.replace(ctx, tx, cmd.ID, toArgs(cmd.Os)); err != nil {
+		return err
+	}
+	if err = commandTagRelation.replace(ctx, tx, cmd.ID, toArgs(cmd.TagIDs)); err != nil {
+		return err
+	}
+	// 只删除不再属于的集合，保留仍在集合中的步骤顺序和备注
+	if err = commandCollectionRelation.replace(ctx, tx, cmd.ID, toArgs(cmd.CollectionIDs)); err != nil {
+		return err
 	}
 
-	// 重新添加命令与集合的关系
-	for _, collectionID := range cmd.CollectionIDs {
-		if err := AddCollectionToCommandSQLite(cmd.ID, collectionID); err != nil {
-			return fmt.Errorf("添加命令集合关系失败: %v", err)
+	// 替换指令变体；Variants为nil表示没有提交变体，保持不变，空列表才会删除所有变体
+	if cmd.Variants != nil {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM command_variants WHERE command_id = ?", cmd.ID); err != nil {
+			return fmt.Errorf("删除指令变体失败: %w", err)
+		}
+		if err = insertCommandVariants(ctx, tx, cmd.ID, cmd.Variants); err != nil {
+			return err
 		}
 	}
 
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
 	return nil
 }
 
 // DeleteCommandSQLite 删除命令（软删除）
-func DeleteCommandSQLite(id uint64) error {
+func DeleteCommandSQLite(ctx context.Context, id uint64) error {
 	// 输入验证
 	if id == 0 {
-		return fmt.Errorf("命令ID不能为空")
+		return NewValidationError("commandId", "command.id_required")
 	}
 
 	now := time.Now()
 
+	// 删除和审计日志在同一个事务中写入
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCommand, id)
+	if err != nil {
+		return err
+	}
+
 	// 使用参数化查询，防止SQL注入
-	result, err := DB.Exec(
+	result, err := tx.ExecContext(ctx,
 		"UPDATE commands SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
 		now, now, id,
 	)
 	if err != nil {
-		return fmt.Errorf("删除命令失败: %v", err)
+		return fmt.Errorf("删除命令失败: %w", err)
 	}
 
 	// 检查是否有行被更新
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("获取删除影响的行数失败: %v", err)
+		return fmt.Errorf("获取删除影响的行数失败: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("command not found: %d", id)
+		err = NewNotFoundError("command.not_found", id)
+		return err
 	}
 
+	if err = audit.record(ctx, tx, AuditActionDelete); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
 	return nil
 }
 
 // 获取所有命令的id和name
-func GetAllCommandsIDAndNameSQLite() ([]*Command, error) {
+func GetAllCommandsIDAndNameSQLite(ctx context.Context) ([]*Command, error) {
 	var commands []*Command
 
 	query := "SELECT id, name FROM commands WHERE deleted_at IS NULL"
-	rows, err := DB.Query(query)
+	rows, err := DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("获取命令列表失败: %v", err)
+		return nil, fmt.Errorf("获取命令列表失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -413,15 +457,73 @@ func GetAllCommandsIDAndNameSQLite() ([]*Command, error) {
 			&cmd.ID, &cmd.Name,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("扫描命令失败: %v", err)
+			return nil, fmt.Errorf("扫描命令失败: %w", err)
 		}
 
 		commands = append(commands, &cmd)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历命令结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历命令结果集失败: %w", err)
+	}
+
+	return commands, nil
+}
+
+// GetAliasCommandsSQLite 获取所有设置了别名的命令
+func GetAliasCommandsSQLite(ctx context.Context) ([]*Command, error) {
+	var commands []*Command
+
+	rows, err := DB.QueryContext(ctx, "SELECT id, name, content, alias FROM commands WHERE alias != '' AND deleted_at IS NULL ORDER BY alias")
+	if err != nil {
+		return nil, fmt.Errorf("获取别名命令列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cmd Command
+		if err = rows.Scan(&cmd.ID, &cmd.Name, &cmd.Content, &cmd.Alias); err != nil {
+			return nil, fmt.Errorf("扫描别名命令失败: %w", err)
+		}
+		commands = append(commands, &cmd)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历别名命令结果集失败: %w", err)
 	}
 
 	return commands, nil
 }
+
+// CommandAliasExistsSQLite 检查别名是否已被其他命令占用
+func CommandAliasExistsSQLite(ctx context.Context, alias string, excludeID uint64) (bool, error) {
+	return commandAliasExists(ctx, DB, alias, excludeID)
+}
+
+// checkCommandAlias 在写事务中检查指令的别名是否已被其他指令占用
+func checkCommandAlias(ctx context.Context, tx *sql.Tx, cmd *Command) error {
+	if cmd.Alias == "" {
+		return nil
+	}
+	exists, err := commandAliasExists(ctx, tx, cmd.Alias, cmd.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return NewConflictError("alias.in_use", cmd.Alias)
+	}
+	return nil
+}
+
+// commandAliasExists 检查别名是否已被excludeID以外的未删除指令使用
+func commandAliasExists(ctx context.Context, q sqlQueryer, alias string, excludeID uint64) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM commands WHERE alias = ? AND id != ? AND deleted_at IS NULL)",
+		alias, excludeID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("检查别名是否存在失败: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// byName 按名称升序排序，让列表结果稳定
+func byName() SortOption {
+	asc := "asc"
+	return SortOption{Name: &asc}
+}
+
+// errorCode 返回err的错误码，nil返回CodeOK
+func errorCode(err error) ErrorCode {
+	code, _ := classifyError(err)
+	return code
+}
+
+func TestGetCommandsSQLite(t *testing.T) {
+	newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		option Option
+		want   []string
+	}{
+		{"all active", Option{}, []string{"dir", "docker-ps", "greet", "kubectl-pods", "list-files"}},
+		{"linux", Option{Os: []string{Linux}}, []string{"docker-ps", "greet", "kubectl-pods", "list-files"}},
+		{"windows", Option{Os: []string{Windows}}, []string{"dir", "greet"}},
+		{"mac and windows", Option{Os: []string{"macOS", "Windows"}}, []string{"dir", "docker-ps", "greet", "list-files"}},
+		{"all os", Option{Os: []string{AllOs, Linux}}, []string{"dir", "docker-ps", "greet", "kubectl-pods", "list-files"}},
+		{"unknown os", Option{Os: []string{"plan9"}}, []string{"greet"}},
+		{"search", Option{Name: "docker"}, []string{"docker-ps"}},
+		{"search with os", Option{Name: "dir", Os: []string{Windows}}, []string{"dir"}},
+		{"search other os", Option{Name: "dir", Os: []string{Linux}}, []string{}},
+		{"deleted not searchable", Option{Name: "backup"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.option.Sort = byName()
+			commands, page, err := GetCommandsSQLite(ctx, tt.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commandNames(commands); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCommandsSQLite(%+v) = %v, want %v", tt.option, got, tt.want)
+			}
+			if page.Total != len(tt.want) || page.HasMore {
+				t.Errorf("page = %+v, want total %d", page, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetCommandsSQLiteRelations(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	commands, _, err := GetCommandsSQLite(context.Background(), Option{Name: "kubectl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 1 {
+		t.Fatalf("GetCommandsSQLite() = %v", commandNames(commands))
+	}
+	cmd := commands[0]
+	slices.Sort(cmd.CollectionIDs)
+	if !reflect.DeepEqual(cmd.TagIDs, []uint64{f.tag("k8s")}) ||
+		!reflect.DeepEqual(cmd.CollectionIDs, []uint64{f.collection("deploy"), f.collection("cleanup")}) ||
+		!reflect.DeepEqual(cmd.Os, []string{Linux}) {
+		t.Errorf("relations = tags %v, collections %v, os %v", cmd.TagIDs, cmd.CollectionIDs, cmd.Os)
+	}
+
+	commands, _, err = GetCommandsSQLite(context.Background(), Option{Name: "greet"})
+	if err != nil || len(commands) != 1 {
+		t.Fatalf("GetCommandsSQLite() = %v, %v", commands, err)
+	}
+	if v := commands[0].Variants; len(v) != 1 || v[0].Os != Windows || v[0].Shell != "powershell" {
+		t.Errorf("variants = %+v", v)
+	}
+}
+
+func TestGetCommandsSQLitePaging(t *testing.T) {
+	newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	option := Option{Sort: byName(), Limit: 2}
+	var names []string
+	for {
+		commands, page, err := GetCommandsSQLite(ctx, option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, commandNames(commands)...)
+		if !page.HasMore {
+			break
+		}
+		option.Cursor = page.NextCursor
+	}
+	want := []string{"dir", "docker-ps", "greet", "kubectl-pods", "list-files"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("paged commands = %v, want %v", names, want)
+	}
+}
+
+func TestGetCommandSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name string
+		id   uint64
+		code ErrorCode
+	}{
+		{"active", f.command("dir"), CodeOK},
+		{"deleted", f.command("old-backup"), CodeNotFound},
+		{"missing", 9999, CodeNotFound},
+		{"zero", 0, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := GetCommandSQLite(context.Background(), tt.id)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("GetCommandSQLite(%d) error = %v, want code %d", tt.id, err, tt.code)
+			}
+			if err == nil && cmd.ID != tt.id {
+				t.Errorf("GetCommandSQLite(%d).ID = %d", tt.id, cmd.ID)
+			}
+		})
+	}
+}
+
+func TestGetCommandsByTagIDs(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"subtree", []string{"ops"}, []string{"docker-ps", "kubectl-pods", "list-files"}},
+		{"child", []string{"docker"}, []string{"docker-ps", "kubectl-pods"}},
+		{"leaf", []string{"k8s"}, []string{"kubectl-pods"}},
+		{"several", []string{"k8s", "windows-admin"}, []string{"dir", "kubectl-pods"}},
+		{"deleted tag", []string{"archived"}, []string{}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []uint64
+			for _, name := range tt.tags {
+				ids = append(ids, f.tag(name))
+			}
+			commands, err := GetCommandsByTagIDs(context.Background(), ids, byName())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commandNames(commands); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCommandsByTagIDs(%v) = %v, want %v", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommandByCollectionIds(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name        string
+		collections []string
+		want        []string
+	}{
+		{"skips deleted commands", []string{"deploy"}, []string{"docker-ps", "kubectl-pods"}},
+		{"cleanup", []string{"cleanup"}, []string{"greet", "kubectl-pods"}},
+		{"several", []string{"deploy", "cleanup"}, []string{"docker-ps", "greet", "kubectl-pods"}},
+		{"empty", []string{"retired"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []uint64
+			for _, name := range tt.collections {
+				ids = append(ids, f.collection(name))
+			}
+			commands, err := GetCommandByCollectionIds(context.Background(), ids, byName())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commandNames(commands); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCommandByCollectionIds(%v) = %v, want %v", tt.collections, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateCommandSQLite(t *testing.T) {
+	newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name string
+		cmd  *Command
+		code ErrorCode
+	}{
+		{"new", &Command{Name: "uptime", Content: "uptime", Os: []string{"Linux"}}, CodeOK},
+		{"duplicate name", &Command{Name: "dir", Content: "dir /w", Os: []string{Windows}}, CodeConflict},
+		{"reuses deleted name", &Command{Name: "old-backup", Content: "tar", Os: []string{Linux}}, CodeOK},
+		{"invalid os", &Command{Name: "bad-os", Content: "true", Os: []string{"plan9"}}, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CreateCommandSQLite(context.Background(), tt.cmd)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("CreateCommandSQLite() error = %v, want code %d", err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			osList, err := GetCommandOSsSQLite(context.Background(), tt.cmd.ID)
+			if err != nil || !reflect.DeepEqual(osList, tt.cmd.Os) {
+				t.Errorf("GetCommandOSsSQLite() = %v, %v, want %v", osList, err, tt.cmd.Os)
+			}
+		})
+	}
+}
+
+func TestUpdateCommandSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		cmd  *Command
+		code ErrorCode
+	}{
+		{"replace relations", &Command{
+			ID: f.command("docker-ps"), Name: "docker-ps", Content: "docker ps -a", Os: []string{Mac},
+			TagIDs: []uint64{f.tag("ops")}, CollectionIDs: []uint64{f.collection("cleanup")},
+		}, CodeOK},
+		{"clear relations", &Command{ID: f.command("dir"), Name: "dir", Content: "dir"}, CodeOK},
+		{"deleted command", &Command{ID: f.command("old-backup"), Name: "old-backup", Content: "tar"}, CodeNotFound},
+		{"deleted tag", &Command{ID: f.command("greet"), Name: "greet", Content: "echo", TagIDs: []uint64{f.tag("archived")}}, CodeNotFound},
+		{"missing id", &Command{Name: "x", Content: "x"}, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := UpdateCommandSQLite(ctx, tt.cmd)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("UpdateCommandSQLite() error = %v, want code %d", err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			got, err := GetCommandSQLite(ctx, tt.cmd.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			commands := []*Command{got}
+			if err = FillCommandRelations(ctx, commands); err != nil {
+				t.Fatal(err)
+			}
+			if got.Content != tt.cmd.Content || !slices.Equal(got.Os, tt.cmd.Os) ||
+				!slices.Equal(got.TagIDs, tt.cmd.TagIDs) || !slices.Equal(got.CollectionIDs, tt.cmd.CollectionIDs) {
+				t.Errorf("updated command = %+v, want %+v", got, tt.cmd)
+			}
+		})
+	}
+}
+
+func TestDeleteCommandSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	id := f.command("list-files")
+	if err := DeleteCommandSQLite(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCommandSQLite(ctx, id); errorCode(err) != CodeNotFound {
+		t.Errorf("GetCommandSQLite(deleted) error = %v", err)
+	}
+	if err := DeleteCommandSQLite(ctx, id); errorCode(err) != CodeNotFound {
+		t.Errorf("DeleteCommandSQLite(deleted) error = %v", err)
+	}
+	if err := DeleteCommandSQLite(ctx, 0); errorCode(err) != CodeValidation {
+		t.Errorf("DeleteCommandSQLite(0) error = %v", err)
+	}
+
+	// 软删除只标记指令，关联关系保留下来用于恢复
+	tags, err := GetTagIDsByCommandIDsSQLite(ctx, []uint64{id})
+	if err != nil || !reflect.DeepEqual(tags[id], []uint64{f.tag("ops")}) {
+		t.Errorf("tags of deleted command = %v, %v", tags, err)
+	}
+	all, err := GetAllCommandsIDAndNameSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commandNames(all); slices.Contains(got, "list-files") || slices.Contains(got, "old-backup") || len(got) != 4 {
+		t.Errorf("GetAllCommandsIDAndNameSQLite() = %v", got)
+	}
+}
+
+func TestCommandAliases(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	commands, err := GetAliasCommandsSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 1 || commands[0].Alias != "hi" || commands[0].Content != "echo hello" {
+		t.Errorf("GetAliasCommandsSQLite() = %+v", commands)
+	}
+
+	tests := []struct {
+		alias     string
+		excludeID uint64
+		want      bool
+	}{
+		{"hi", 0, true},
+		{"hi", f.command("greet"), false},
+		{"bye", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := CommandAliasExistsSQLite(ctx, tt.alias, tt.excludeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CommandAliasExistsSQLite(%q, %d) = %v, want %v", tt.alias, tt.excludeID, got, tt.want)
+		}
+	}
+
+	if err = DeleteCommandSQLite(ctx, f.command("greet")); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := CommandAliasExistsSQLite(ctx, "hi", 0); err != nil || exists {
+		t.Errorf("alias of deleted command still in use: %v, %v", exists, err)
+	}
+}
+
+func TestCommandVariantsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	variants, err := GetCommandVariantsSQLite(ctx, f.command("greet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 1 || variants[0].Content != "Write-Output hello" {
+		t.Errorf("GetCommandVariantsSQLite() = %+v", variants)
+	}
+	byCommand, err := GetVariantsByCommandIDsSQLite(ctx, f.commandIDs("greet", "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byCommand[f.command("greet")]) != 1 || len(byCommand[f.command("dir")]) != 0 {
+		t.Errorf("GetVariantsByCommandIDsSQLite() = %+v", byCommand)
+	}
+}
+
+func TestUpdateCommandSQLiteVariants(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	id := f.command("greet")
+	tests := []struct {
+		name     string
+		variants []*CommandVariant
+		want     int
+	}{
+		// 前端不提交variants字段，不能因此删除已有的变体
+		{"omitted", nil, 1},
+		{"replaced", []*CommandVariant{{Os: Mac, Shell: "zsh", Content: "print hello"}, {Os: Windows, Shell: "cmd", Content: "echo hello"}}, 2},
+		{"cleared", []*CommandVariant{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{ID: id, Name: "greet", Content: "echo hello", Alias: "hi", Os: []string{AllOs}, Variants: tt.variants}
+			if err := UpdateCommandSQLite(ctx, cmd); err != nil {
+				t.Fatal(err)
+			}
+			variants, err := GetCommandVariantsSQLite(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(variants) != tt.want {
+				t.Errorf("variants after update = %+v, want %d", variants, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCommandKeepsVariants(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	t.Setenv("HOME", t.TempDir())
+	// 与前端保存指令时提交的字段一致，没有variants
+	cmd := &Command{ID: f.command("greet"), Name: "greet", Content: "echo hi", Alias: "hi", Os: []string{AllOs}}
+	if resp := NewApp().UpdateCommand(cmd); resp.Code != CodeOK {
+		t.Fatalf("UpdateCommand() = %+v", resp)
+	}
+	variants, err := GetCommandVariantsSQLite(context.Background(), f.command("greet"))
+	if err != nil || len(variants) != 1 || variants[0].Content != "Write-Output hello" {
+		t.Errorf("variants after UpdateCommand = %+v, %v", variants, err)
+	}
+}
+
+func TestUpdateCommandSkipSyntaxCheck(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	skip, check := true, false
+	snippet := &Command{Name: "orders", Content: "SELECT count(* FROM orders", Os: []string{Linux}, SkipSyntaxCheck: &skip}
+	f.addCommands(snippet)
+
+	tests := []struct {
+		name string
+		flag *bool
+		code ErrorCode
+		want bool
+	}{
+		// 前端保存时不提交skipSyntaxCheck，沿用已保存的值
+		{"omitted", nil, CodeOK, true},
+		{"turned off", &check, CodeValidation, true},
+		{"turned on", &skip, CodeOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{ID: snippet.ID, Name: "orders", Content: "SELECT count(* FROM orders WHERE id = 1", Os: []string{Linux}, SkipSyntaxCheck: tt.flag}
+			if resp := NewApp().UpdateCommand(cmd); resp.Code != tt.code {
+				t.Fatalf("UpdateCommand() = %+v, want code %d", resp, tt.code)
+			}
+			got, err := GetCommandSQLite(ctx, snippet.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.skipSyntaxCheck() != tt.want {
+				t.Errorf("skipSyntaxCheck = %v, want %v", got.skipSyntaxCheck(), tt.want)
+			}
+		})
+	}
+
+	// 存储层同样把nil当作保持不变
+	if err := UpdateCommandSQLite(ctx, &Command{ID: snippet.ID, Name: "orders", Content: "SELECT 1", Os: []string{Linux}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetCommandSQLite(ctx, snippet.ID); err != nil || !got.skipSyntaxCheck() {
+		t.Errorf("UpdateCommandSQLite(nil flag) = %+v, %v", got, err)
+	}
+}
+
+func TestCanceledStoreCall(t *testing.T) {
+	newFixtureDB(t, "store.yaml")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := GetCommandsSQLite(ctx, Option{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetCommandsSQLite(canceled) error = %v", err)
+	}
+	if err := CreateCommandSQLite(ctx, &Command{Name: "late", Content: "true"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateCommandSQLite(canceled) error = %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// GetCommandVariantsSQLite 获取指令的所有变体
+func GetCommandVariantsSQLite(ctx context.Context, commandID uint64) ([]*CommandVariant, error) {
+	variants, err := GetVariantsByCommandIDsSQLite(ctx, []uint64{commandID})
+	if err != nil {
+		return nil, err
+	}
+	return variants[commandID], nil
+}
+
+// GetVariantsByCommandIDsSQLite 批量获取指令的变体，按OS和shell排序
+func GetVariantsByCommandIDsSQLite(ctx context.Context, commandIDs []uint64) (map[uint64][]*CommandVariant, error) {
+	result := make(map[uint64][]*CommandVariant)
+	if len(commandIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := DB.QueryContext(ctx,
+		"SELECT id, command_id, os, shell, content FROM command_variants WHERE command_id IN "+inPlaceholders(len(commandIDs))+" ORDER BY os, shell",
+		toArgs(commandIDs)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("批量获取指令变体失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v CommandVariant
+		if err = rows.Scan(&v.ID, &v.CommandID, &v.Os, &v.Shell, &v.Content); err != nil {
+			return nil, fmt.Errorf("扫描指令变体失败: %w", err)
+		}
+		result[v.CommandID] = append(result[v.CommandID], &v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历指令变体结果集失败: %w", err)
+	}
+	return result, nil
+}
+
+// insertCommandVariants 在事务中保存指令变体
+func insertCommandVariants(ctx context.Context, tx *sql.Tx, commandID uint64, variants []*CommandVariant) error {
+	for _, v := range variants {
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO command_variants (command_id, os, shell, content) VALUES (?, ?, ?, ?)",
+			commandID, v.Os, v.Shell, v.Content,
+		)
+		if err != nil {
+			return fmt.Errorf("添加指令变体失败: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("获取指令变体ID失败: %w", err)
+		}
+		v.ID = uint64(id)
+		v.CommandID = commandID
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// FindDuplicateCommandsSQLite 在所有未删除的指令中查找重复和近似重复的指令
+func FindDuplicateCommandsSQLite(ctx context.Context, threshold float64) ([]*DuplicateGroup, error) {
+	b := commandListEntity.newSelect(commandColumns...).OrderBy("c.id", "ASC")
+	commands, err := queryCommands(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	return findDuplicateGroups(commands, threshold), nil
+}
+
+// MergeCommandsSQLite 将otherIDs指令合并到survivorID：标签、集合、OS和变体取并集，计数和frecency评分累加，
+// 保留指令没有别名或描述时沿用被合并指令的，最后软删除被合并的指令
+func MergeCommandsSQLite(ctx context.Context, survivorID uint64, otherIDs []uint64) error {
+	if survivorID == 0 {
+		return NewValidationError("survivorId", "merge.survivor_required")
+	}
+	var others []uint64
+	for _, id := range otherIDs {
+		if id != survivorID && id != 0 {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		return NewValidationError("otherIds", "merge.nothing_to_merge")
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	all := append([]uint64{survivorID}, others...)
+	in := inPlaceholders(len(others))
+	otherArgs := toArgs(others)
+
+	// 保留指令排在最前，用于确定别名和描述的取值顺序
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, alias, COALESCE(description, ''), copy_count, run_count, search_count, frecency, last_used_at, pinned FROM commands WHERE deleted_at IS NULL AND id IN "+inPlaceholders(len(all))+" ORDER BY id = ? DESC, id",
+		append(toArgs(all), survivorID)...,
+	)
+	if err != nil {
+		return fmt.Errorf("获取待合并指令失败: %w", err)
+	}
+	var merged struct {
+		alias, description             string
+		copyCount, runCount, searchCnt int
+		frecency                       float64
+		lastUsedAt                     sql.NullTime
+		pinned                         bool
+	}
+	found := 0
+	for rows.Next() {
+		var id uint64
+		var alias, description string
+		var copyCount, runCount, searchCount int
+		var frecency float64
+		var lastUsedAt sql.NullTime
+		var pinned bool
+		if err = rows.Scan(&id, &alias, &description, &copyCount, &runCount, &searchCount, &frecency, &lastUsedAt, &pinned); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描待合并指令失败: %w", err)
+		}
+		if found == 0 && id != survivorID {
+			rows.Close()
+			err = NewNotFoundError("command.not_found", survivorID)
+			return err
+		}
+		found++
+		if merged.alias == "" {
+			merged.alias = alias
+		}
+		if merged.description == "" {
+			merged.description = description
+		}
+		merged.copyCount += copyCount
+		merged.runCount += runCount
+		merged.searchCnt += searchCount
+		merged.frecency = combineFrecency(merged.frecency, frecency)
+		if lastUsedAt.Valid && lastUsedAt.Time.After(merged.lastUsedAt.Time) {
+			merged.lastUsedAt = lastUsedAt
+		}
+		merged.pinned = merged.pinned || pinned
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历待合并指令失败: %w", err)
+	}
+	if found != len(all) {
+		err = NewNotFoundError("command.some_not_found")
+		return err
+	}
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCommand, all...)
+	if err != nil {
+		return err
+	}
+
+	// 关联关系取并集：保留指令已有的关系不变，集合中的步骤沿用被合并指令的备注，
+	// 与insertCollectionStepSQL一样追加到集合末尾，不会与已有步骤的位置重复
+	relations := []struct {
+		query string
+		what  string
+	}{
+		{"INSERT OR IGNORE INTO command_tags (command_id, tag_id) SELECT ?, tag_id FROM command_tags WHERE command_id IN " + in, "标签"},
+		{`INSERT OR IGNORE INTO command_collections (command_id, collection_id, position, note, optional)
+		SELECT ?, cc.collection_id, (SELECT COALESCE(MAX(position), 0) + 1 FROM command_collections WHERE collection_id = cc.collection_id), cc.note, cc.optional
+		FROM command_collections cc WHERE cc.command_id IN ` + in, "集合"},
+		{"INSERT OR IGNORE INTO command_os (command_id, os) SELECT ?, os FROM command_os WHERE command_id IN " + in, "OS"},
+		{"INSERT OR IGNORE INTO command_variants (command_id, os, shell, content) SELECT ?, os, shell, content FROM command_variants WHERE command_id IN " + in, "变体"},
+	}
+	for _, r := range relations {
+		if _, err = tx.ExecContext(ctx, r.query, append([]interface{}{survivorID}, otherArgs...)...); err != nil {
+			return fmt.Errorf("合并指令%s失败: %w", r.what, err)
+		}
+	}
+	for _, table := range []string{"command_tags", "command_collections"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE command_id IN "+in, otherArgs...); err != nil {
+			return fmt.Errorf("删除被合并指令的关联关系失败: %w", err)
+		}
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	var lastUsedAt interface{}
+	if merged.lastUsedAt.Valid {
+		lastUsedAt = merged.lastUsedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	// 先清空被合并指令的别名，再把别名转给保留指令，避免短暂出现两个相同的别名
+	if _, err = tx.ExecContext(ctx, "UPDATE commands SET alias = '', deleted_at = ?, updated_at = ? WHERE id IN "+in, append([]interface{}{now, now}, otherArgs...)...); err != nil {
+		return fmt.Errorf("删除被合并指令失败: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE commands SET alias = ?, description = ?, copy_count = ?, run_count = ?, search_count = ?, frecency = ?, last_used_at = ?, pinned = ?, updated_at = ?
+		WHERE id = ?`,
+		merged.alias, merged.description, merged.copyCount, merged.runCount, merged.searchCnt, merged.frecency, lastUsedAt, merged.pinned, now,
+		survivorID,
+	)
+	if err != nil {
+		return fmt.Errorf("更新保留的指令失败: %w", err)
+	}
+
+	// 保留的指令和被合并的指令都记为合并
+	if err = audit.record(ctx, tx, AuditActionMerge); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFindDuplicateCommandsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	f.addCommands(
+		&Command{Name: "docker-ps-copy", Content: "docker  ps", Os: []string{Linux}},
+		// 与已删除的old-backup内容相同，不算重复
+		&Command{Name: "backup", Content: "tar czf backup.tgz .", Os: []string{Linux}},
+	)
+	groups, err := FindDuplicateCommandsSQLite(context.Background(), defaultDuplicateThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("FindDuplicateCommandsSQLite() = %d groups, want 1", len(groups))
+	}
+	names := commandNames(groups[0].Commands)
+	slices.Sort(names)
+	if !groups[0].Exact || !reflect.DeepEqual(names, []string{"docker-ps", "docker-ps-copy"}) {
+		t.Errorf("duplicate group = exact %v, commands %v", groups[0].Exact, names)
+	}
+}
+
+func TestMergeCommandsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	f.addCommands(&Command{
+		Name: "docker-ps-copy", Content: "docker  ps", Alias: "dps", Description: "列出容器",
+		Os: []string{Windows}, TagIDs: []uint64{f.tag("windows-admin")}, CollectionIDs: []uint64{f.collection("cleanup")},
+	}, &Command{
+		Name: "prune", Content: "docker system prune", Os: []string{Linux}, CollectionIDs: []uint64{f.collection("cleanup")},
+	})
+	if err := RecordCommandUsageSQLite(ctx, f.command("docker-ps-copy"), usageCopy, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		survivor uint64
+		others   []uint64
+		code     ErrorCode
+	}{
+		{"no survivor", 0, f.commandIDs("docker-ps-copy"), CodeValidation},
+		{"only survivor", f.command("docker-ps"), f.commandIDs("docker-ps"), CodeValidation},
+		{"deleted other", f.command("docker-ps"), f.commandIDs("old-backup"), CodeNotFound},
+		{"deleted survivor", f.command("old-backup"), f.commandIDs("docker-ps"), CodeNotFound},
+		{"merge", f.command("docker-ps"), f.commandIDs("docker-ps-copy"), CodeOK},
+		{"already merged", f.command("docker-ps"), f.commandIDs("docker-ps-copy"), CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := MergeCommandsSQLite(ctx, tt.survivor, tt.others); errorCode(err) != tt.code {
+				t.Fatalf("MergeCommandsSQLite() error = %v, want code %d", err, tt.code)
+			}
+		})
+	}
+
+	commands, _, err := GetCommandsSQLite(ctx, Option{ID: f.command("docker-ps")})
+	if err != nil || len(commands) != 1 {
+		t.Fatalf("GetCommandsSQLite() = %v, %v", commands, err)
+	}
+	cmd := commands[0]
+	slices.Sort(cmd.TagIDs)
+	slices.Sort(cmd.CollectionIDs)
+	slices.Sort(cmd.Os)
+	if cmd.Alias != "dps" || cmd.Description != "列出容器" || cmd.CopyCounts != 1 ||
+		!reflect.DeepEqual(cmd.TagIDs, []uint64{f.tag("docker"), f.tag("windows-admin")}) ||
+		!reflect.DeepEqual(cmd.CollectionIDs, []uint64{f.collection("deploy"), f.collection("cleanup")}) ||
+		!reflect.DeepEqual(cmd.Os, []string{Linux, Mac, Windows}) {
+		t.Errorf("merged command = %+v", cmd)
+	}
+	if _, err = GetCommandSQLite(ctx, f.command("docker-ps-copy")); errorCode(err) != CodeNotFound {
+		t.Errorf("GetCommandSQLite(merged) error = %v", err)
+	}
+
+	// 合并进来的步骤追加到集合末尾，位置不与已有步骤重复
+	steps, err := GetCollectionStepsSQLite(ctx, f.collection("cleanup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for i, step := range steps {
+		names = append(names, step.Name)
+		if i > 0 && step.Position <= steps[i-1].Position {
+			t.Errorf("step %s position %d not after %d", step.Name, step.Position, steps[i-1].Position)
+		}
+	}
+	if want := []string{"kubectl-pods", "greet", "prune", "docker-ps"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("cleanup steps = %v, want %v", names, want)
+	}
+}
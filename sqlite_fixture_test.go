@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// storeFixtures testdata中的YAML测试数据，标签、集合和指令之间按名称引用
+type storeFixtures struct {
+	Tags []struct {
+		Name    string   `yaml:"name"`
+		Parent  string   `yaml:"parent"`
+		Os      []string `yaml:"os"`
+		Deleted bool     `yaml:"deleted"`
+	} `yaml:"tags"`
+	Collections []struct {
+		Name    string   `yaml:"name"`
+		Os      []string `yaml:"os"`
+		Deleted bool     `yaml:"deleted"`
+	} `yaml:"collections"`
+	Commands []struct {
+		Name        string            `yaml:"name"`
+		Content     string            `yaml:"content"`
+		Description string            `yaml:"description"`
+		Alias       string            `yaml:"alias"`
+		Os          []string          `yaml:"os"`
+		Tags        []string          `yaml:"tags"`
+		Collections []string          `yaml:"collections"`
+		Variants    []*CommandVariant `yaml:"variants"`
+		Deleted     bool              `yaml:"deleted"`
+	} `yaml:"commands"`
+}
+
+// fixtureIDs 加载后的测试数据，按名称查找ID
+type fixtureIDs struct {
+	t           *testing.T
+	tags        map[string]uint64
+	collections map[string]uint64
+	commands    map[string]uint64
+}
+
+// newFixtureDB 创建临时数据库并加载testdata中的YAML测试数据。
+// 数据通过存储层函数写入，标记为deleted的记录在全部写入后软删除
+func newFixtureDB(t *testing.T, name string) *fixtureIDs {
+	t.Helper()
+	useTempDB(t)
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures storeFixtures
+	if err = yaml.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("解析测试数据%s失败: %v", name, err)
+	}
+
+	ctx := context.Background()
+	f := &fixtureIDs{
+		t:           t,
+		tags:        map[string]uint64{},
+		collections: map[string]uint64{},
+		commands:    map[string]uint64{},
+	}
+	// 父标签需要写在子标签前面
+	for _, ft := range fixtures.Tags {
+		tag := &Tag{Name: ft.Name, Os: ft.Os}
+		if ft.Parent != "" {
+			tag.ParentID = f.tag(ft.Parent)
+		}
+		if err = CreateTagSQLite(ctx, tag); err != nil {
+			t.Fatalf("创建标签%s失败: %v", ft.Name, err)
+		}
+		f.tags[ft.Name] = tag.ID
+	}
+	for _, fc := range fixtures.Collections {
+		col := &Collection{Name: fc.Name, Os: fc.Os}
+		if err = CreateCollectionSQLite(ctx, col); err != nil {
+			t.Fatalf("创建集合%s失败: %v", fc.Name, err)
+		}
+		f.collections[fc.Name] = col.ID
+	}
+	for _, fc := range fixtures.Commands {
+		cmd := &Command{
+			Name: fc.Name, Content: fc.Content, Description: fc.Description, Alias: fc.Alias,
+			Os: fc.Os, Variants: fc.Variants,
+		}
+		for _, name := range fc.Tags {
+			cmd.TagIDs = append(cmd.TagIDs, f.tag(name))
+		}
+		for _, name := range fc.Collections {
+			cmd.CollectionIDs = append(cmd.CollectionIDs, f.collection(name))
+		}
+		if err = CreateCommandSQLite(ctx, cmd); err != nil {
+			t.Fatalf("创建指令%s失败: %v", fc.Name, err)
+		}
+		f.commands[fc.Name] = cmd.ID
+	}
+
+	for _, fc := range fixtures.Commands {
+		if fc.Deleted {
+			if err = DeleteCommandSQLite(ctx, f.command(fc.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, fc := range fixtures.Collections {
+		if fc.Deleted {
+			if err = DeleteCollectionSQLite(ctx, f.collection(fc.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, ft := range fixtures.Tags {
+		if ft.Deleted {
+			if err = DeleteTagSQLite(ctx, f.tag(ft.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return f
+}
+
+func (f *fixtureIDs) lookup(kind string, ids map[string]uint64, name string) uint64 {
+	f.t.Helper()
+	id, ok := ids[name]
+	if !ok {
+		f.t.Fatalf("测试数据中没有%s %q", kind, name)
+	}
+	return id
+}
+
+func (f *fixtureIDs) tag(name string) uint64 { return f.lookup("标签", f.tags, name) }
+
+func (f *fixtureIDs) collection(name string) uint64 {
+	return f.lookup("集合", f.collections, name)
+}
+
+func (f *fixtureIDs) command(name string) uint64 { return f.lookup("指令", f.commands, name) }
+
+// commandIDs 按名称列表返回指令ID
+func (f *fixtureIDs) commandIDs(names ...string) []uint64 {
+	f.t.Helper()
+	ids := make([]uint64, len(names))
+	for i, name := range names {
+		ids[i] = f.command(name)
+	}
+	return ids
+}
+
+// addCommands 在测试数据之外追加指令，之后同样可以按名称查找ID
+func (f *fixtureIDs) addCommands(commands ...*Command) {
+	f.t.Helper()
+	for _, cmd := range commands {
+		if err := CreateCommandSQLite(context.Background(), cmd); err != nil {
+			f.t.Fatalf("创建指令%s失败: %v", cmd.Name, err)
+		}
+		f.commands[cmd.Name] = cmd.ID
+	}
+}
+
+// commandNames 返回指令的名称列表，用于和期望结果比较
+func commandNames(commands []*Command) []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.Name
+	}
+	return names
+}
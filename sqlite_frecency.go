@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RecordCommandUsageSQLite 记录一次指令使用：增加对应的计数并累加frecency评分
+func RecordCommandUsageSQLite(ctx context.Context, id uint64, kind usageKind, at time.Time) error {
+	return RecordCommandsUsageSQLite(ctx, []uint64{id}, kind, at)
+}
+
+// RecordCommandsUsageSQLite 在一个事务中为多条指令记录同一种使用，例如一次搜索命中的所有指令
+func RecordCommandsUsageSQLite(ctx context.Context, ids []uint64, kind usageKind, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	for _, id := range ids {
+		var stored float64
+		err = tx.QueryRowContext(ctx, "SELECT frecency FROM commands WHERE id = ? AND deleted_at IS NULL", id).Scan(&stored)
+		if err == sql.ErrNoRows {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("获取指令评分失败: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("UPDATE commands SET %[1]s = %[1]s + 1, frecency = ?, last_used_at = ? WHERE id = ?", kind.column),
+			addFrecency(stored, kind.weight, at), at.Format("2006-01-02 15:04:05"), id,
+		)
+		if err != nil {
+			return fmt.Errorf("更新指令使用记录失败: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
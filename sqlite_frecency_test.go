@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordCommandsUsageSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	at := time.Now()
+	tests := []struct {
+		name  string
+		ids   []uint64
+		kind  usageKind
+		check func(cmd *Command) bool
+	}{
+		{"copy", f.commandIDs("greet"), usageCopy, func(cmd *Command) bool { return cmd.CopyCounts == 1 }},
+		{"run", f.commandIDs("greet"), usageRun, func(cmd *Command) bool { return cmd.RunCount == 1 }},
+		// 已删除的指令被跳过，不影响同一批中的其他指令
+		{"search with deleted", f.commandIDs("old-backup", "greet"), usageSearch, func(cmd *Command) bool { return cmd.SearchCount == 1 }},
+		{"empty", nil, usageCopy, func(cmd *Command) bool { return cmd.CopyCounts == 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RecordCommandsUsageSQLite(ctx, tt.ids, tt.kind, at); err != nil {
+				t.Fatal(err)
+			}
+			cmd, err := GetCommandSQLite(ctx, f.command("greet"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cmd) {
+				t.Errorf("usage counts = copy %d, run %d, search %d", cmd.CopyCounts, cmd.RunCount, cmd.SearchCount)
+			}
+			if cmd.Frecency <= 0 || cmd.LastUsedAt == "" {
+				t.Errorf("frecency = %v, lastUsedAt = %q", cmd.Frecency, cmd.LastUsedAt)
+			}
+		})
+	}
+
+	if err := RecordCommandUsageSQLite(ctx, f.command("dir"), usageRun, at); err != nil {
+		t.Fatal(err)
+	}
+	desc := "desc"
+	commands, _, err := GetCommandsSQLite(ctx, Option{Sort: SortOption{Frecency: &desc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) == 0 || commands[0].Name != "greet" {
+		t.Errorf("frecency order = %v, want greet first", commandNames(commands))
+	}
+}
+
+func TestRecordSearchHit(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	a := NewApp()
+	searchCount := func(name string) int {
+		cmd, err := GetCommandSQLite(ctx, f.command(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cmd.SearchCount
+	}
+
+	// 搜索本身不记录命中，多次输入搜索词也不会给所有结果累加
+	for _, query := range []string{"d", "do", "docker"} {
+		if resp := a.GetOptions(Option{Type: "commands", Name: query}); resp.Code != CodeOK {
+			t.Fatalf("GetOptions(%q) = %+v", query, resp)
+		}
+	}
+	if n := searchCount("docker-ps"); n != 0 {
+		t.Fatalf("search count after typing = %d, want 0", n)
+	}
+
+	tests := []struct {
+		name   string
+		option *Option
+		pick   string
+		want   map[string]int
+	}{
+		{"pick result", nil, "docker-ps", map[string]int{"docker-ps": 1}},
+		{"same search again", nil, "docker-ps", map[string]int{"docker-ps": 1}},
+		{"pick outside results", &Option{Type: "commands", Name: "kubectl"}, "docker-ps", map[string]int{"docker-ps": 1, "kubectl-pods": 0}},
+		{"cleared search", &Option{Type: "commands"}, "kubectl-pods", map[string]int{"kubectl-pods": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.option != nil {
+				if resp := a.GetOptions(*tt.option); resp.Code != CodeOK {
+					t.Fatalf("GetOptions() = %+v", resp)
+				}
+			}
+			a.recordSearchHit(ctx, f.command(tt.pick))
+			for name, want := range tt.want {
+				if n := searchCount(name); n != want {
+					t.Errorf("%s search count = %d, want %d", name, n, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRelevanceOrder(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	compose := &Command{Name: "compose-up", Content: "docker compose up -d", Os: []string{"linux"}}
+	if err := CreateCommandSQLite(ctx, compose); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now()
+	use := func(name string, id uint64, kind usageKind, times int) func() {
+		return func() {
+			for i := 0; i < times; i++ {
+				if err := RecordCommandUsageSQLite(ctx, id, kind, at); err != nil {
+					t.Fatalf("record %s usage: %v", name, err)
+				}
+			}
+		}
+	}
+	search := func(query string, limit int) []string {
+		var names []string
+		option := Option{Name: query, Limit: limit}
+		for {
+			commands, page, err := GetCommandsSQLite(ctx, option)
+			if err != nil {
+				t.Fatalf("GetCommandsSQLite(%q): %v", query, err)
+			}
+			names = append(names, commandNames(commands)...)
+			if !page.HasMore {
+				return names
+			}
+			option.Cursor = page.NextCursor
+		}
+	}
+
+	// 每一步在前一步的基础上记录使用，检查搜索docker时的顺序
+	tests := []struct {
+		name   string
+		record func()
+		want   []string
+	}{
+		// 都没有用过时按相关度排序，名称中的匹配权重更高
+		{"relevance only", func() {}, []string{"docker-ps", "compose-up"}},
+		// 用过的指令排在从未用过的指令之前
+		{"used beats unused", use("compose-up", compose.ID, usageCopy, 1), []string{"compose-up", "docker-ps"}},
+		// 评分相同时按相关度排序
+		{"same frecency", use("docker-ps", f.command("docker-ps"), usageCopy, 1), []string{"docker-ps", "compose-up"}},
+		// 评分相差足够大时，频繁使用的指令排在前面
+		{"frecency beats relevance", use("compose-up", compose.ID, usageRun, 8), []string{"compose-up", "docker-ps"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record()
+			if got := search("docker", 0); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search order = %v, want %v", got, tt.want)
+			}
+			// 逐页取回的顺序与一次取回的相同
+			if got := search("docker", 1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paged search order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 不同搜索词的相关度不同，游标不能混用
+	_, page, err := GetCommandsSQLite(ctx, Option{Name: "docker", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = GetCommandsSQLite(ctx, Option{Name: "docker OR compose", Limit: 1, Cursor: page.NextCursor}); errorCode(err) != CodeValidation {
+		t.Errorf("cursor from another search error = %v, want validation error", err)
+	}
+}
+
+func TestCommandsFTSSync(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	matches := func(match string) []uint64 {
+		ids, err := queryIDs(ctx, DB, "SELECT docid FROM commands_fts WHERE commands_fts MATCH ? ORDER BY docid", match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	if got, want := matches("docker"), f.commandIDs("docker-ps"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("initial matches = %v, want %v", got, want)
+	}
+	cmd, err := GetCommandSQLite(ctx, f.command("list-files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Name, cmd.Description = "docker-images", "list docker images"
+	if err = UpdateCommandSQLite(ctx, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := matches("docker"), f.commandIDs("list-files", "docker-ps"); !reflect.DeepEqual(got, want) {
+		t.Errorf("matches after rename = %v, want %v", got, want)
+	}
+	if got := matches("files"); len(got) != 0 {
+		t.Errorf("old name still indexed: %v", got)
+	}
+
+	if err = DeleteCommandSQLite(ctx, f.command("docker-ps")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = PurgeDeletedSQLite(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := matches("docker"), f.commandIDs("list-files"); !reflect.DeepEqual(got, want) {
+		t.Errorf("matches after purge = %v, want %v", got, want)
+	}
+}
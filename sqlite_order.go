@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// nextSortValueSQL 返回新记录的手动排序值子查询，新记录排在最后
+func nextSortValueSQL(table string) string {
+	return fmt.Sprintf("(SELECT COALESCE(MAX(sort_value), 0) + 1 FROM %s)", table)
+}
+
+// listEntityByType 根据Option.Type返回对应的实体
+func listEntityByType(itemType string) (listEntity, error) {
+	switch itemType {
+	case "commands":
+		return commandListEntity, nil
+	case "tags":
+		return tagListEntity, nil
+	case "collections":
+		return collectionListEntity, nil
+	default:
+		return listEntity{}, NewValidationError("itemType", "option.invalid_type", itemType)
+	}
+}
+
+// SetPinnedSQLite 置顶或取消置顶一条记录
+func SetPinnedSQLite(ctx context.Context, e listEntity, id uint64, pinned bool) error {
+	if id == 0 {
+		return NewValidationError("id", "order.id_required")
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, e.auditType, id)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s SET pinned = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL", e.table),
+		pinned, time.Now().Format("2006-01-02 15:04:05"), id,
+	)
+	if err != nil {
+		return fmt.Errorf("更新置顶状态失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新影响的行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("order.item_not_found", messageRef("entity."+e.table), id)
+		return err
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// ReorderSQLite 按ids的顺序重写这些记录的手动排序值。
+// 只在这些记录原有的排序值之间重新分配，因此可以只提交列表中的一部分（例如当前页），其他记录的相对位置不变
+func ReorderSQLite(ctx context.Context, e listEntity, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return NewValidationError("ids", "order.duplicate_id", id)
+		}
+		seen[id] = true
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx,
+		fmt.Sprintf("SELECT sort_value FROM %s WHERE deleted_at IS NULL AND id IN %s", e.table, inPlaceholders(len(ids))),
+		toArgs(ids)...,
+	)
+	if err != nil {
+		return fmt.Errorf("获取排序值失败: %w", err)
+	}
+	var values []int
+	for rows.Next() {
+		var v int
+		if err = rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描排序值失败: %w", err)
+		}
+		values = append(values, v)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历排序值结果集失败: %w", err)
+	}
+	if len(values) != len(ids) {
+		err = NewNotFoundError("order.some_not_found")
+		return err
+	}
+
+	audit, err := snapshotAudit(ctx, tx, e.auditType, ids...)
+	if err != nil {
+		return err
+	}
+	values = distinctSortValues(values)
+	for i, id := range ids {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET sort_value = ? WHERE id = ?", e.table), values[i], id); err != nil {
+			return fmt.Errorf("更新排序值失败: %w", err)
+		}
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// distinctSortValues 将排序值升序排列，并把重复的值依次加一，保证重排后顺序唯一
+func distinctSortValues(values []int) []int {
+	sort.Ints(values)
+	for i := 1; i < len(values); i++ {
+		if values[i] <= values[i-1] {
+			values[i] = values[i-1] + 1
+		}
+	}
+	return values
+}
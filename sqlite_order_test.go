@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// bySortValue 按手动排序值升序排列
+func bySortValue() SortOption {
+	asc := "asc"
+	return SortOption{SortValue: &asc}
+}
+
+func TestReorderSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	listed := func() []string {
+		commands, _, err := GetCommandsSQLite(ctx, Option{Sort: bySortValue()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return commandNames(commands)
+	}
+	if got, want := listed(), []string{"list-files", "docker-ps", "kubectl-pods", "dir", "greet"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("initial order = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		names []string
+		code  ErrorCode
+		want  []string
+	}{
+		// 只提交一部分时，其他记录的位置不变
+		{"partial", []string{"dir", "docker-ps"}, CodeOK, []string{"list-files", "dir", "kubectl-pods", "docker-ps", "greet"}},
+		{"all", []string{"greet", "dir", "kubectl-pods", "docker-ps", "list-files"}, CodeOK, []string{"greet", "dir", "kubectl-pods", "docker-ps", "list-files"}},
+		{"duplicate", []string{"dir", "dir"}, CodeValidation, []string{"greet", "dir", "kubectl-pods", "docker-ps", "list-files"}},
+		{"deleted", []string{"old-backup", "dir"}, CodeNotFound, []string{"greet", "dir", "kubectl-pods", "docker-ps", "list-files"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReorderSQLite(ctx, commandListEntity, f.commandIDs(tt.names...))
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("ReorderSQLite(%v) error = %v, want code %d", tt.names, err, tt.code)
+			}
+			if got := listed(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPinnedSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		entity listEntity
+		id     uint64
+		pinned bool
+		code   ErrorCode
+	}{
+		{"pin command", commandListEntity, f.command("greet"), true, CodeOK},
+		{"pin tag", tagListEntity, f.tag("k8s"), true, CodeOK},
+		{"pin collection", collectionListEntity, f.collection("cleanup"), true, CodeOK},
+		{"unpin command", commandListEntity, f.command("greet"), false, CodeOK},
+		{"deleted command", commandListEntity, f.command("old-backup"), true, CodeNotFound},
+		{"deleted tag", tagListEntity, f.tag("archived"), true, CodeNotFound},
+		{"zero", commandListEntity, 0, true, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetPinnedSQLite(ctx, tt.entity, tt.id, tt.pinned)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("SetPinnedSQLite() error = %v, want code %d", err, tt.code)
+			}
+		})
+	}
+
+	// 置顶的记录排在最前
+	commands, _, err := GetCommandsSQLite(ctx, Option{Sort: byName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands[0].Name != "dir" || commands[0].Pinned {
+		t.Errorf("unpinned command still first: %v", commandNames(commands))
+	}
+	tags, _, err := GetTagsSQLite(ctx, Option{Sort: byName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags[0].Name != "k8s" || !tags[0].Pinned {
+		t.Errorf("pinned tag not first: %v", tagNames(tags))
+	}
+	collections, _, err := GetCollectionsSQLite(ctx, Option{Sort: byName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !collections[0].Pinned {
+		t.Errorf("pinned collection not marked: %+v", collections[0])
+	}
+}
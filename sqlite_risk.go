@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// RefreshCommandRisksSQLite 按当前规则重新计算指令的风险等级并保存变化的部分，
+// ids为空时重新计算所有未删除的指令，返回等级发生变化的指令数
+func RefreshCommandRisksSQLite(ctx context.Context, analyzer *riskAnalyzer, ids []uint64) (int, error) {
+	b := commandListEntity.newSelect(commandColumns...)
+	if len(ids) > 0 {
+		b.Where("c.id IN "+inPlaceholders(len(ids)), toArgs(ids)...)
+	}
+	commands, err := queryCommands(ctx, b)
+	if err != nil {
+		return 0, err
+	}
+	ids = make([]uint64, len(commands))
+	for i, cmd := range commands {
+		ids[i] = cmd.ID
+	}
+	variants, err := GetVariantsByCommandIDsSQLite(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	changed := 0
+	for _, cmd := range commands {
+		cmd.Variants = variants[cmd.ID]
+		level := analyzer.AnalyzeCommand(cmd).Level
+		if level == cmd.Risk {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, "UPDATE commands SET risk_level = ? WHERE id = ?", level, cmd.ID); err != nil {
+			return 0, fmt.Errorf("更新指令风险等级失败: %w", err)
+		}
+		changed++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return changed, nil
+}
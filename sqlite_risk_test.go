@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRefreshCommandRisksSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	builtin, err := newRiskAnalyzer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := newRiskAnalyzer([]*RiskRule{
+		{ID: "docker", Program: "docker", Severity: RiskHigh, Explanation: "操作容器"},
+		// 只出现在greet的Windows变体中
+		{ID: "write-output", Pattern: "Write-Output", Severity: RiskLow, Explanation: "测试变体"},
+		// old-backup已删除，不重新计算
+		{ID: "tar", Program: "tar", Severity: RiskMedium, Explanation: "打包"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		analyzer *riskAnalyzer
+		ids      []uint64
+		changed  int
+		risks    map[string]string
+	}{
+		{"unchanged", builtin, nil, 0, map[string]string{"docker-ps": RiskNone, "greet": RiskNone}},
+		{"only ids", custom, f.commandIDs("greet"), 1, map[string]string{"docker-ps": RiskNone, "greet": RiskLow}},
+		{"all", custom, nil, 1, map[string]string{"docker-ps": RiskHigh, "greet": RiskLow}},
+		{"again", custom, nil, 0, map[string]string{"docker-ps": RiskHigh, "greet": RiskLow}},
+		{"deleted id", custom, f.commandIDs("old-backup"), 0, map[string]string{"docker-ps": RiskHigh}},
+		{"revert", builtin, nil, 2, map[string]string{"docker-ps": RiskNone, "greet": RiskNone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, err := RefreshCommandRisksSQLite(ctx, tt.analyzer, tt.ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.changed {
+				t.Errorf("RefreshCommandRisksSQLite() = %d, want %d", changed, tt.changed)
+			}
+			for name, want := range tt.risks {
+				cmd, err := GetCommandSQLite(ctx, f.command(name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cmd.Risk != want {
+					t.Errorf("%s risk = %s, want %s", name, cmd.Risk, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// 运行状态
+const (
+	runbookRunRunning   = "running"
+	runbookRunCompleted = "completed"
+)
+
+// 步骤状态
+const (
+	runbookStepPending = "pending"
+	runbookStepDone    = "done"
+	runbookStepSkipped = "skipped"
+)
+
+// GetCollectionStepsSQLite 按顺序获取集合中的步骤，已删除的指令不作为步骤返回
+func GetCollectionStepsSQLite(ctx context.Context, collectionID uint64) ([]*CollectionStep, error) {
+	if collectionID == 0 {
+		return nil, NewValidationError("collectionId", "collection.id_required")
+	}
+
+	rows, err := DB.QueryContext(ctx, `
+	SELECT cc.command_id, cc.position, cc.note, cc.optional, c.name, c.content
+	FROM command_collections cc JOIN commands c ON c.id = cc.command_id AND c.deleted_at IS NULL
+	WHERE cc.collection_id = ?
+	ORDER BY cc.position, cc.command_id`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("获取集合步骤失败: %w", err)
+	}
+	defer rows.Close()
+
+	steps := []*CollectionStep{}
+	for rows.Next() {
+		step := CollectionStep{CollectionID: collectionID}
+		if err = rows.Scan(&step.CommandID, &step.Position, &step.Note, &step.Optional, &step.Name, &step.Content); err != nil {
+			return nil, fmt.Errorf("扫描集合步骤失败: %w", err)
+		}
+		steps = append(steps, &step)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历集合步骤结果集失败: %w", err)
+	}
+	return steps, nil
+}
+
+// UpdateCollectionStepSQLite 更新步骤的备注和是否可跳过
+func UpdateCollectionStepSQLite(ctx context.Context, step *CollectionStep) error {
+	if step.CollectionID == 0 || step.CommandID == 0 {
+		return NewValidationError("collectionId", "runbook.step_ids_required")
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	// 步骤属于集合，记为集合的更新
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCollection, step.CollectionID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE command_collections SET note = ?, optional = ? WHERE collection_id = ? AND command_id = ?",
+		step.Note, step.Optional, step.CollectionID, step.CommandID,
+	)
+	if err != nil {
+		return fmt.Errorf("更新集合步骤失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新影响的行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("runbook.step_not_in_collection", step.CommandID, step.CollectionID)
+		return err
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// ReorderCollectionStepsSQLite 按commandIDs的顺序重排集合步骤，commandIDs必须恰好包含集合中的所有指令
+func ReorderCollectionStepsSQLite(ctx context.Context, collectionID uint64, commandIDs []uint64) error {
+	return reorderCollectionSteps(ctx, collectionID, func(current []uint64) ([]uint64, error) {
+		if len(commandIDs) != len(current) {
+			return nil, NewValidationError("commandIds", "runbook.step_count_mismatch", len(current), len(commandIDs))
+		}
+		remaining := make(map[uint64]bool, len(current))
+		for _, id := range current {
+			remaining[id] = true
+		}
+		for _, id := range commandIDs {
+			if !remaining[id] {
+				return nil, NewValidationError("commandIds", "runbook.invalid_step_order", id)
+			}
+			delete(remaining, id)
+		}
+		return commandIDs, nil
+	})
+}
+
+// MoveCollectionStepSQLite 将一个步骤移动到指定位置（从1开始），超出范围时移动到开头或末尾
+func MoveCollectionStepSQLite(ctx context.Context, collectionID, commandID uint64, position int) error {
+	return reorderCollectionSteps(ctx, collectionID, func(current []uint64) ([]uint64, error) {
+		return moveStepID(current, commandID, position)
+	})
+}
+
+// reorderCollectionSteps 在事务中读取集合当前的步骤顺序，交给reorder计算新顺序后重新编号
+func reorderCollectionSteps(ctx context.Context, collectionID uint64, reorder func(current []uint64) ([]uint64, error)) error {
+	if collectionID == 0 {
+		return NewValidationError("collectionId", "collection.id_required")
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	// 与GetCollectionStepsSQLite一致，只重排未删除指令的步骤；已删除指令的步骤保留原位置，恢复后重新出现
+	rows, err := tx.QueryContext(ctx, `
+	SELECT cc.command_id FROM command_collections cc JOIN commands c ON c.id = cc.command_id AND c.deleted_at IS NULL
+	WHERE cc.collection_id = ?
+	ORDER BY cc.position, cc.command_id`, collectionID)
+	if err != nil {
+		return fmt.Errorf("获取集合步骤失败: %w", err)
+	}
+	var current []uint64
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描集合步骤失败: %w", err)
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历集合步骤结果集失败: %w", err)
+	}
+
+	ordered, err := reorder(current)
+	if err != nil {
+		return err
+	}
+	audit, err := snapshotAudit(ctx, tx, AuditEntityCollection, collectionID)
+	if err != nil {
+		return err
+	}
+	for i, id := range ordered {
+		if _, err = tx.ExecContext(ctx, "UPDATE command_collections SET position = ? WHERE collection_id = ? AND command_id = ?", i+1, collectionID, id); err != nil {
+			return fmt.Errorf("更新步骤顺序失败: %w", err)
+		}
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// moveStepID 返回把id移动到position（从1开始）后的新顺序，不修改原切片
+func moveStepID(ids []uint64, id uint64, position int) ([]uint64, error) {
+	from := -1
+	for i, v := range ids {
+		if v == id {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return nil, NewNotFoundError("runbook.command_not_in_collection", id)
+	}
+
+	rest := make([]uint64, 0, len(ids))
+	rest = append(rest, ids[:from]...)
+	rest = append(rest, ids[from+1:]...)
+	to := min(max(position-1, 0), len(rest))
+
+	result := make([]uint64, 0, len(ids))
+	result = append(result, rest[:to]...)
+	result = append(result, id)
+	result = append(result, rest[to:]...)
+	return result, nil
+}
+
+// StartRunbookRunSQLite 以集合当前的步骤开始一次命名的运行，同一集合下运行名称不能重复
+func StartRunbookRunSQLite(ctx context.Context, collectionID uint64, name string) (*RunbookRun, error) {
+	name = strings.TrimSpace(name)
+	if collectionID == 0 {
+		return nil, NewValidationError("collectionId", "collection.id_required")
+	}
+	if name == "" {
+		return nil, NewValidationError("name", "runbook.name_required")
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	var exists bool
+	if err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM collections WHERE id = ? AND deleted_at IS NULL)", collectionID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("检查集合是否存在失败: %w", err)
+	}
+	if !exists {
+		err = NewNotFoundError("collection.not_found", collectionID)
+		return nil, err
+	}
+	if err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM runbook_runs WHERE collection_id = ? AND name = ?)", collectionID, name).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("检查运行名称失败: %w", err)
+	}
+	if exists {
+		err = NewConflictError("runbook.run_exists", name)
+		return nil, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO runbook_runs (collection_id, name, status, started_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		collectionID, name, runbookRunRunning, now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建运行失败: %w", err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("获取运行ID失败: %w", err)
+	}
+
+	// 复制集合步骤，位置重新从1编号
+	result, err = tx.ExecContext(ctx, `
+	INSERT INTO runbook_run_steps (run_id, position, command_id, note, optional)
+	SELECT ?, ROW_NUMBER() OVER (ORDER BY cc.position, cc.command_id), cc.command_id, cc.note, cc.optional
+	FROM command_collections cc JOIN commands c ON c.id = cc.command_id AND c.deleted_at IS NULL
+	WHERE cc.collection_id = ?`, runID, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("复制集合步骤失败: %w", err)
+	}
+	steps, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("获取步骤数量失败: %w", err)
+	}
+	if steps == 0 {
+		err = NewValidationError("collectionId", "runbook.no_steps")
+		return nil, err
+	}
+	if err = recordAudit(ctx, tx, AuditEntityRunbookRun, uint64(runID), AuditActionCreate, nil); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return GetRunbookRunSQLite(ctx, uint64(runID))
+}
+
+// GetRunbookRunSQLite 获取运行及其所有步骤
+func GetRunbookRunSQLite(ctx context.Context, runID uint64) (*RunbookRun, error) {
+	if runID == 0 {
+		return nil, NewValidationError("runId", "runbook.run_id_required")
+	}
+
+	var run RunbookRun
+	var finishedAt sql.NullString
+	err := DB.QueryRowContext(ctx,
+		"SELECT id, collection_id, name, status, started_at, updated_at, finished_at FROM runbook_runs WHERE id = ?",
+		runID,
+	).Scan(&run.ID, &run.CollectionID, &run.Name, &run.Status, &run.StartedAt, &run.UpdatedAt, &finishedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NewNotFoundError("runbook.run_not_found", runID)
+		}
+		return nil, fmt.Errorf("获取运行失败: %w", err)
+	}
+	run.FinishedAt = finishedAt.String
+
+	// 指令被删除后仍保留步骤，名称和内容为空
+	rows, err := DB.QueryContext(ctx, `
+	SELECT s.position, s.command_id, COALESCE(c.name, ''), COALESCE(c.content, ''), s.note, s.optional, s.status, COALESCE(s.completed_at, '')
+	FROM runbook_run_steps s LEFT JOIN commands c ON c.id = s.command_id
+	WHERE s.run_id = ?
+	ORDER BY s.position`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("获取运行步骤失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var step RunbookStep
+		if err = rows.Scan(&step.Position, &step.CommandID, &step.Name, &step.Content, &step.Note, &step.Optional, &step.Status, &step.CompletedAt); err != nil {
+			return nil, fmt.Errorf("扫描运行步骤失败: %w", err)
+		}
+		run.Steps = append(run.Steps, &step)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历运行步骤结果集失败: %w", err)
+	}
+
+	run.TotalSteps = len(run.Steps)
+	for _, step := range run.Steps {
+		if step.Status != runbookStepPending {
+			run.FinishedSteps++
+		} else if run.CurrentStep == 0 {
+			run.CurrentStep = step.Position
+		}
+	}
+	return &run, nil
+}
+
+// FindRunbookRunSQLite 按集合和名称查找运行，用于继续中断的运行
+func FindRunbookRunSQLite(ctx context.Context, collectionID uint64, name string) (*RunbookRun, error) {
+	name = strings.TrimSpace(name)
+	var runID uint64
+	err := DB.QueryRowContext(ctx, "SELECT id FROM runbook_runs WHERE collection_id = ? AND name = ?", collectionID, name).Scan(&runID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NewNotFoundError("runbook.run_name_not_found", name)
+		}
+		return nil, fmt.Errorf("查找运行失败: %w", err)
+	}
+	return GetRunbookRunSQLite(ctx, runID)
+}
+
+// GetRunbookRunsSQLite 获取集合的所有运行（不含步骤明细），最近更新的在前
+func GetRunbookRunsSQLite(ctx context.Context, collectionID uint64) ([]*RunbookRun, error) {
+	rows, err := DB.QueryContext(ctx, `
+	SELECT r.id, r.collection_id, r.name, r.status, r.started_at, r.updated_at, r.finished_at,
+		(SELECT COUNT(*) FROM runbook_run_steps s WHERE s.run_id = r.id),
+		(SELECT COUNT(*) FROM runbook_run_steps s WHERE s.run_id = r.id AND s.status != ?),
+		COALESCE((SELECT MIN(position) FROM runbook_run_steps s WHERE s.run_id = r.id AND s.status = ?), 0)
+	FROM runbook_runs r
+	WHERE r.collection_id = ?
+	ORDER BY r.updated_at DESC, r.id DESC`, runbookStepPending, runbookStepPending, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("获取运行列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []*RunbookRun{}
+	for rows.Next() {
+		var run RunbookRun
+		var finishedAt sql.NullString
+		if err = rows.Scan(&run.ID, &run.CollectionID, &run.Name, &run.Status, &run.StartedAt, &run.UpdatedAt, &finishedAt,
+			&run.TotalSteps, &run.FinishedSteps, &run.CurrentStep); err != nil {
+			return nil, fmt.Errorf("扫描运行失败: %w", err)
+		}
+		run.FinishedAt = finishedAt.String
+		runs = append(runs, &run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历运行结果集失败: %w", err)
+	}
+	return runs, nil
+}
+
+// SetRunbookStepStatusSQLite 设置运行中某一步的状态，所有步骤都完成或跳过后运行自动结束，
+// 把步骤改回pending会让已结束的运行重新进入进行中
+func SetRunbookStepStatusSQLite(ctx context.Context, runID uint64, position int, status string) (*RunbookRun, error) {
+	switch status {
+	case runbookStepPending, runbookStepDone, runbookStepSkipped:
+	default:
+		return nil, NewValidationError("status", "runbook.invalid_status", status)
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	var optional bool
+	err = tx.QueryRowContext(ctx, "SELECT optional FROM runbook_run_steps WHERE run_id = ? AND position = ?", runID, position).Scan(&optional)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = NewNotFoundError("runbook.step_not_found", runID, position)
+			return nil, err
+		}
+		return nil, fmt.Errorf("获取运行步骤失败: %w", err)
+	}
+	if status == runbookStepSkipped && !optional {
+		err = NewValidationError("status", "runbook.step_required", position)
+		return nil, err
+	}
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityRunbookRun, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	var completedAt interface{}
+	if status != runbookStepPending {
+		completedAt = now
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE runbook_run_steps SET status = ?, completed_at = ? WHERE run_id = ? AND position = ?",
+		status, completedAt, runID, position,
+	); err != nil {
+		return nil, fmt.Errorf("更新步骤状态失败: %w", err)
+	}
+
+	var pending int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM runbook_run_steps WHERE run_id = ? AND status = ?", runID, runbookStepPending).Scan(&pending); err != nil {
+		return nil, fmt.Errorf("统计未完成步骤失败: %w", err)
+	}
+	runStatus, finishedAt := runbookRunRunning, interface{}(nil)
+	if pending == 0 {
+		runStatus, finishedAt = runbookRunCompleted, now
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE runbook_runs SET status = ?, updated_at = ?, finished_at = ? WHERE id = ?",
+		runStatus, now, finishedAt, runID,
+	); err != nil {
+		return nil, fmt.Errorf("更新运行状态失败: %w", err)
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return GetRunbookRunSQLite(ctx, runID)
+}
+
+// DeleteRunbookRunSQLite 删除运行记录及其步骤
+func DeleteRunbookRunSQLite(ctx context.Context, runID uint64) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityRunbookRun, runID)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM runbook_runs WHERE id = ?", runID)
+	if err != nil {
+		return fmt.Errorf("删除运行失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取删除影响的行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("runbook.run_not_found", runID)
+		return err
+	}
+	if err = audit.record(ctx, tx, AuditActionDelete); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
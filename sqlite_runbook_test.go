@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMoveStepID(t *testing.T) {
+	ids := []uint64{1, 2, 3, 4}
+	tests := []struct {
+		id       uint64
+		position int
+		want     []uint64
+	}{
+		{3, 1, []uint64{3, 1, 2, 4}},
+		{1, 3, []uint64{2, 3, 1, 4}},
+		{2, 2, []uint64{1, 2, 3, 4}},
+		{1, 99, []uint64{2, 3, 4, 1}},
+		{4, 0, []uint64{4, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		got, err := moveStepID(ids, tt.id, tt.position)
+		if err != nil {
+			t.Fatalf("moveStepID(%d, %d) error: %v", tt.id, tt.position, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("moveStepID(%d, %d) = %v, want %v", tt.id, tt.position, got, tt.want)
+		}
+	}
+	if !reflect.DeepEqual(ids, []uint64{1, 2, 3, 4}) {
+		t.Errorf("moveStepID modified input: %v", ids)
+	}
+	if _, err := moveStepID(ids, 9, 1); err == nil {
+		t.Error("moveStepID with unknown id should fail")
+	}
+}
+
+// stepNames 返回步骤对应的指令名称
+func stepNames(steps []*CollectionStep) []string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Name
+	}
+	return names
+}
+
+func TestCollectionStepsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	deploy := f.collection("deploy")
+	tests := []struct {
+		name string
+		run  func() error
+		code ErrorCode
+		want []string
+	}{
+		// old-backup已软删除，不作为步骤出现，也不参与重排
+		{"initial", func() error { return nil }, CodeOK, []string{"docker-ps", "kubectl-pods"}},
+		{"move", func() error { return MoveCollectionStepSQLite(ctx, deploy, f.command("kubectl-pods"), 1) }, CodeOK, []string{"kubectl-pods", "docker-ps"}},
+		{"reorder", func() error {
+			return ReorderCollectionStepsSQLite(ctx, deploy, f.commandIDs("docker-ps", "kubectl-pods"))
+		}, CodeOK, []string{"docker-ps", "kubectl-pods"}},
+		{"reorder with deleted", func() error {
+			return ReorderCollectionStepsSQLite(ctx, deploy, f.commandIDs("kubectl-pods", "old-backup"))
+		}, CodeValidation, []string{"docker-ps", "kubectl-pods"}},
+		{"reorder missing step", func() error {
+			return ReorderCollectionStepsSQLite(ctx, deploy, f.commandIDs("docker-ps"))
+		}, CodeValidation, []string{"docker-ps", "kubectl-pods"}},
+		{"move unknown", func() error { return MoveCollectionStepSQLite(ctx, deploy, f.command("dir"), 1) }, CodeNotFound, []string{"docker-ps", "kubectl-pods"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); errorCode(err) != tt.code {
+				t.Fatalf("error = %v, want code %d", err, tt.code)
+			}
+			steps, err := GetCollectionStepsSQLite(ctx, deploy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stepNames(steps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	step := &CollectionStep{CollectionID: deploy, CommandID: f.command("kubectl-pods"), Note: "检查集群上下文", Optional: true}
+	if err := UpdateCollectionStepSQLite(ctx, step); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := GetCollectionStepsSQLite(ctx, deploy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := steps[len(steps)-1]; last.Note != step.Note || !last.Optional {
+		t.Errorf("updated step = %+v", last)
+	}
+}
+
+func TestRunbookRunSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	deploy := f.collection("deploy")
+	if err := UpdateCollectionStepSQLite(ctx, &CollectionStep{CollectionID: deploy, CommandID: f.command("kubectl-pods"), Optional: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	starts := []struct {
+		name       string
+		collection uint64
+		run        string
+		code       ErrorCode
+	}{
+		{"start", deploy, "release-1", CodeOK},
+		{"duplicate name", deploy, "release-1", CodeConflict},
+		{"deleted collection", f.collection("retired"), "release-1", CodeNotFound},
+		{"no steps", f.collection("cleanup"), "", CodeValidation},
+	}
+	for _, tt := range starts {
+		if _, err := StartRunbookRunSQLite(ctx, tt.collection, tt.run); errorCode(err) != tt.code {
+			t.Errorf("StartRunbookRunSQLite(%s) error = %v, want code %d", tt.name, err, tt.code)
+		}
+	}
+
+	run, err := FindRunbookRunSQLite(ctx, deploy, " release-1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.TotalSteps != 2 || run.CurrentStep != 1 || run.Status != runbookRunRunning {
+		t.Fatalf("started run = %+v", run)
+	}
+
+	statuses := []struct {
+		position int
+		status   string
+		code     ErrorCode
+		run      string
+	}{
+		{1, runbookStepSkipped, CodeValidation, runbookRunRunning},
+		{1, runbookStepDone, CodeOK, runbookRunRunning},
+		{2, runbookStepSkipped, CodeOK, runbookRunCompleted},
+		{2, runbookStepPending, CodeOK, runbookRunRunning},
+		{3, runbookStepDone, CodeNotFound, runbookRunRunning},
+		{1, "failed", CodeValidation, runbookRunRunning},
+	}
+	for _, tt := range statuses {
+		_, err := SetRunbookStepStatusSQLite(ctx, run.ID, tt.position, tt.status)
+		if errorCode(err) != tt.code {
+			t.Errorf("SetRunbookStepStatusSQLite(%d, %s) error = %v, want code %d", tt.position, tt.status, err, tt.code)
+		}
+		got, err := GetRunbookRunSQLite(ctx, run.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.run {
+			t.Errorf("after %d=%s run status = %s, want %s", tt.position, tt.status, got.Status, tt.run)
+		}
+	}
+
+	runs, err := GetRunbookRunsSQLite(ctx, deploy)
+	if err != nil || len(runs) != 1 || runs[0].FinishedSteps != 1 || runs[0].CurrentStep != 2 {
+		t.Fatalf("GetRunbookRunsSQLite() = %+v, %v", runs, err)
+	}
+	if err = DeleteRunbookRunSQLite(ctx, run.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetRunbookRunSQLite(ctx, run.ID); errorCode(err) != CodeNotFound {
+		t.Errorf("GetRunbookRunSQLite(deleted) error = %v", err)
+	}
+}
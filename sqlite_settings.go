@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// GetSettingsSQLite 读取所有已保存的设置
+func GetSettingsSQLite(ctx context.Context) (map[string]string, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT key, value FROM settings")
+	if err != nil {
+		return nil, fmt.Errorf("查询设置失败: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("扫描设置失败: %w", err)
+		}
+		values[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历设置结果集失败: %w", err)
+	}
+	return values, nil
+}
+
+// SaveSettingsSQLite 在一个事务中保存多项设置，已存在的项覆盖
+func SaveSettingsSQLite(ctx context.Context, values map[string]string) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("回滚事务失败", "err", rbErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntitySettings, 0)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for key, value := range values {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			key, value, now,
+		)
+		if err != nil {
+			return fmt.Errorf("保存设置%s失败: %w", key, err)
+		}
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeletedSQLite 彻底删除before之前软删除的指令、标签和集合，关联数据随外键级联删除，返回删除的行数。
+// 审计日志不随之删除，其中保留的修改前后内容在清理后仍可查询和导出
+func PurgeDeletedSQLite(ctx context.Context, before time.Time) (int64, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("回滚事务失败", "err", rbErr)
+			}
+		}
+	}()
+
+	cutoff := before.Format("2006-01-02 15:04:05")
+	// 子标签的parent_id没有外键约束，先将指向待清理标签的子标签移到顶层
+	_, err = tx.ExecContext(ctx,
+		"UPDATE tags SET parent_id = NULL WHERE parent_id IN (SELECT id FROM tags WHERE deleted_at IS NOT NULL AND deleted_at < ?)",
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("移动待清理标签的子标签失败: %w", err)
+	}
+
+	var total int64
+	for _, e := range []listEntity{commandListEntity, tagListEntity, collectionListEntity} {
+		var ids []uint64
+		if ids, err = queryIDs(ctx, tx, "SELECT id FROM "+e.table+" WHERE deleted_at IS NOT NULL AND deleted_at < ?", cutoff); err != nil {
+			return 0, fmt.Errorf("查询待清理的%s失败: %w", e.table, err)
+		}
+		var audit *auditBatch
+		if audit, err = snapshotAudit(ctx, tx, e.auditType, ids...); err != nil {
+			return 0, err
+		}
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, "DELETE FROM "+e.table+" WHERE deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+		if err != nil {
+			return 0, fmt.Errorf("清理已删除的%s失败: %w", e.table, err)
+		}
+		if err = audit.record(ctx, tx, AuditActionDelete); err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return total, nil
+}
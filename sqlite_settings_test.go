@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveSettingsSQLite(t *testing.T) {
+	useTempDB(t)
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   map[string]string
+	}{
+		{"empty", nil, map[string]string{}},
+		{"insert", map[string]string{"theme": "dark", "locale": "en"}, map[string]string{"theme": "dark", "locale": "en"}},
+		{"overwrite", map[string]string{"theme": "light"}, map[string]string{"theme": "light", "locale": "en"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SaveSettingsSQLite(ctx, tt.values); err != nil {
+				t.Fatal(err)
+			}
+			got, err := GetSettingsSQLite(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetSettingsSQLite() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurgeDeletedSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	count := func(query string, args ...any) int {
+		var n int
+		if err := DB.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tests := []struct {
+		name   string
+		before time.Time
+		want   int64
+	}{
+		{"nothing expired", time.Now().Add(-time.Hour), 0},
+		// old-backup、archived标签和retired集合
+		{"expired", time.Now().Add(time.Minute), 3},
+		{"already purged", time.Now().Add(time.Minute), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := PurgeDeletedSQLite(ctx, tt.before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("PurgeDeletedSQLite() = %d, want %d", n, tt.want)
+			}
+		})
+	}
+
+	if n := count("SELECT COUNT(*) FROM command_tags WHERE command_id = ?", f.command("old-backup")); n != 0 {
+		t.Errorf("purged command still has %d tag relations", n)
+	}
+	if n := count("SELECT COUNT(*) FROM command_collections WHERE command_id = ?", f.command("old-backup")); n != 0 {
+		t.Errorf("purged command still has %d collection relations", n)
+	}
+	if n := count("SELECT COUNT(*) FROM commands WHERE deleted_at IS NULL"); n != 5 {
+		t.Errorf("active commands = %d, want 5", n)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	previous := currentSettings()
+	t.Cleanup(func() { setCurrentSettings(previous) })
+	old := time.Now().AddDate(0, 0, -40).Format("2006-01-02 15:04:05")
+	if _, err := DB.ExecContext(ctx, "UPDATE commands SET deleted_at = ? WHERE id = ?", old, f.command("old-backup")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		days int
+		want int64
+	}{
+		// 默认永久保留
+		{"keep forever", 0, 0},
+		{"within retention", 60, 0},
+		{"expired", 30, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := previous
+			s.TrashRetentionDays = tt.days
+			setCurrentSettings(s)
+			resp := NewApp().PurgeTrash()
+			if resp.Code != CodeOK || resp.Data != tt.want {
+				t.Errorf("PurgeTrash() = %+v, want %d", resp, tt.want)
+			}
+		})
+	}
+}
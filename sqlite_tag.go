@@ -1,116 +1,132 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"slices"
 	"time"
 )
 
 // CreateTagSQLite 创建标签
-func CreateTagSQLite(tag *Tag) error {
-	log.Printf("CreateTagSQLite: %+v", tag)
+func CreateTagSQLite(ctx context.Context, tag *Tag) error {
+	slog.Debug("创建标签", "name", tag.Name, "parentId", tag.ParentID, "os", tag.Os)
+	osList, err := NormalizeOSList("os", tag.Os)
+	if err != nil {
+		return err
+	}
+	tag.Os = osList
 	now := time.Now().Format("2006-01-02 15:04:05")
 	tag.CreatedAt = now
 	tag.UpdatedAt = now
 	tag.SearchCount = 0
 
+	// 开启事务，创建tag和tag_os关联关系；名称和父标签在事务中检查，避免并发创建同名标签
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
 	//1. 检查标签是否存在
 	var exists bool
-	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tags WHERE name = ? AND deleted_at IS NULL)", tag.Name).Scan(&exists)
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM tags WHERE name = ? AND deleted_at IS NULL)", tag.Name).Scan(&exists)
 	if err != nil {
-		log.Printf("检查标签是否存在失败: %v", err)
-		return fmt.Errorf("检查标签是否存在失败: %v", err)
+		return fmt.Errorf("检查标签是否存在失败: %w", err)
 	}
 	if exists {
-		log.Printf("标签[%s]已存在", tag.Name)
-		return fmt.Errorf("标签[%s]已存在", tag.Name)
+		err = NewConflictError("tag.exists", tag.Name)
+		return err
 	}
 
-	// 开启事务，创建tag和tag_os关联关系
-	tx, err := DB.Begin()
-	if err != nil {
-		log.Printf("开启事务失败: %v", err)
-		return fmt.Errorf("开启事务失败: %v", err)
+	// 检查父标签是否存在
+	if tag.ParentID != 0 {
+		if err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM tags WHERE id = ? AND deleted_at IS NULL)", tag.ParentID).Scan(&exists); err != nil {
+			return fmt.Errorf("检查父标签是否存在失败: %w", err)
+		}
+		if !exists {
+			err = NewNotFoundError("tag.parent_not_found", tag.ParentID)
+			return err
+		}
 	}
-	// defer
 
-	result, err := tx.Exec(
-		"INSERT INTO tags (name, description, search_count, created_at, updated_at, deleted_at) VALUES (?, ?, ?, ?, ?, ?)",
-		tag.Name, tag.Description, tag.SearchCount, tag.CreatedAt, tag.UpdatedAt, tag.DeletedAt,
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO tags (name, description, parent_id, search_count, pinned, sort_value, created_at, updated_at) VALUES (?, ?, ?, ?, ?, "+nextSortValueSQL("tags")+", ?, ?)",
+		tag.Name, tag.Description, nullableID(tag.ParentID), tag.SearchCount, tag.Pinned, tag.CreatedAt, tag.UpdatedAt,
 	)
 	if err != nil {
-		log.Printf("创建标签失败: %v", err)
-		tx.Rollback()
-		return fmt.Errorf("创建标签失败: %v", err)
+		return fmt.Errorf("创建标签失败: %w", err)
 	}
-	log.Printf("更新tags表成功: %+v", tag)
 	// 获取SQLite自动生成的ID
 	id, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("获取标签ID失败: %v", err)
-		return fmt.Errorf("获取标签ID失败: %v", err)
+		return fmt.Errorf("获取标签ID失败: %w", err)
 	}
 	tag.ID = uint64(id)
-	log.Printf("标签ID: %d", tag.ID)
 	// 2. 保存OS关联关系
-	for i, os := range tag.Os {
-		log.Printf("添加标签OS关系%d: %s", i, os)
-		if err = AddOSToTagSQLite(tx, tag.ID, os); err != nil {
-			log.Printf("添加标签OS关系失败: %v", err)
-			tx.Rollback()
-			return fmt.Errorf("添加标签OS关系失败: %v", err)
+	for _, os := range tag.Os {
+		if err = AddOSToTagSQLite(ctx, tx, tag.ID, os); err != nil {
+			return fmt.Errorf("添加标签OS关系失败: %w", err)
 		}
-		log.Printf("更新tag_os关系成功: %s", os)
 	}
-	log.Printf("创建标签成功: %+v", tag)
 
 	// 3. 保存指令关联关系
 	for _, commandID := range tag.CommandIDs {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT OR IGNORE INTO command_tags (command_id, tag_id) VALUES (?, ?)",
 			commandID, tag.ID,
 		)
 		if err != nil {
-			log.Printf("添加标签指令关系失败: %v", err)
-			tx.Rollback()
-			return fmt.Errorf("添加标签指令关系失败: %v", err)
+			return fmt.Errorf("添加标签指令关系失败: %w", err)
 		}
 	}
-	log.Printf("添加标签指令关系成功: %+v", tag)
 
-	tx.Commit()
+	if err = recordAudit(ctx, tx, AuditEntityTag, tag.ID, AuditActionCreate, nil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
 	return nil
 }
 
 // GetTagSQLite 获取单个标签
-func GetTagSQLite(id uint64) (*Tag, error) {
+func GetTagSQLite(ctx context.Context, id uint64) (*Tag, error) {
 	// 输入验证
 	if id == 0 {
-		return nil, fmt.Errorf("标签ID不能为空")
+		return nil, NewValidationError("tagId", "tag.id_required")
 	}
 
 	var tag Tag
 	var deletedAt sql.NullTime
 
 	// 使用参数化查询，防止SQL注入
-	err := DB.QueryRow(
-		"SELECT id, name, description, search_count, created_at, updated_at, deleted_at FROM tags WHERE id = ? AND deleted_at IS NULL",
+	err := DB.QueryRowContext(ctx,
+		"SELECT id, name, description, COALESCE(parent_id, 0), search_count, pinned, sort_value, created_at, updated_at, deleted_at FROM tags WHERE id = ? AND deleted_at IS NULL",
 		id,
 	).Scan(
-		&tag.ID, &tag.Name, &tag.Description, &tag.SearchCount, &tag.CreatedAt, &tag.UpdatedAt, &deletedAt,
+		&tag.ID, &tag.Name, &tag.Description, &tag.ParentID, &tag.SearchCount, &tag.Pinned, &tag.SortValue, &tag.CreatedAt, &tag.UpdatedAt, &deletedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("tag not found: %d", id)
+			return nil, NewNotFoundError("tag.not_found", id)
 		}
-		return nil, fmt.Errorf("获取标签失败: %v", err)
+		return nil, fmt.Errorf("获取标签失败: %w", err)
 	}
 
 	// 从关联表获取OS信息
-	if tag.Os, err = GetTagOSsSQLite(id); err != nil {
-		return nil, fmt.Errorf("获取标签OS失败: %v", err)
+	if tag.Os, err = GetTagOSsSQLite(ctx, id); err != nil {
+		return nil, fmt.Errorf("获取标签OS失败: %w", err)
 	}
 
 	// 处理deletedAt字段
@@ -121,259 +137,254 @@ func GetTagSQLite(id uint64) (*Tag, error) {
 	return &tag, nil
 }
 
-// GetTagsSQLite 获取所有标签
-func GetTagsSQLite(option Option) ([]*Tag, error) {
+// GetTagsSQLite 按Option分页获取标签
+func GetTagsSQLite(ctx context.Context, option Option) ([]*Tag, Page, error) {
 	var tags []*Tag
-	var query string
-	var args []interface{}
-
-	// 构建查询条件
-	if len(option.Os) > 0 {
-		// 如果有OS条件，使用JOIN查询关联表
-		query = `
-			SELECT DISTINCT t.id, t.name, t.description, t.search_count, t.created_at, t.updated_at, t.deleted_at
-			FROM tags t RIGHT JOIN tag_os tos 
-			ON t.id = tos.tag_id
-			WHERE t.deleted_at IS NULL
-		`
-	} else {
-		// 没有OS条件，直接查询tags表
-		query = "SELECT DISTINCT t.id, t.name, t.description, t.search_count, t.created_at, t.updated_at, t.deleted_at FROM tags AS t WHERE t.deleted_at IS NULL"
-	}
-
-	if option.Name != "" {
-		query += " AND t.name LIKE ?"
-		args = append(args, "%"+option.Name+"%")
-	}
-	if option.ID != 0 {
-		query += " AND t.id = ?"
-		args = append(args, option.ID)
-	}
-	if len(option.Os) > 0 {
-		// 转换OS切片为SQLite IN 子句的参数
-		// for _, os := range option.Os {
-		// 	query += fmt.Sprintf(" AND tos.os = '%s' ", os)
-		// }
-		args1, err := SQL_Slice_To_In_Args(option.Os)
-		if err != nil {
-			return nil, fmt.Errorf("转换OS切片为SQLite IN 子句参数失败: %v", err)
-		}
-		query += fmt.Sprintf(" AND tos.os IN %s", args1)
-	}
 
-	query += " ORDER BY t.created_at DESC"
+	b := tagListEntity.newSelect("t.id", "t.name", "t.description", "COALESCE(t.parent_id, 0)", "t.search_count", "t.pinned", "t.sort_value", "t.created_at", "t.updated_at", "t.deleted_at")
+	list, err := tagListEntity.prepareList(ctx, b, option)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	query, args := b.Build()
 
-	log.Printf("SQL: %s,args:%v", query, args)
-	stmt, err := DB.Prepare(query)
+	slog.Debug("查询标签列表", "sql", query, "args", args)
+	stmt, err := DB.PrepareContext(ctx, query)
 	if err != nil {
-		log.Printf("准备查询语句失败: %v", err)
-		return nil, fmt.Errorf("准备查询语句失败: %v", err)
+		return nil, Page{}, fmt.Errorf("准备查询语句失败: %w", err)
 	}
 	defer stmt.Close()
 
 	// 从SQLite数据库获取所有标签
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
-		log.Printf("执行查询语句失败: %v", err)
-		return nil, fmt.Errorf("获取标签列表失败: %v", err)
+		return nil, Page{}, fmt.Errorf("获取标签列表失败: %w", err)
 	}
 	defer rows.Close()
-	log.Printf("查询标签列表成功: %+v", rows)
 	// 遍历结果集
-	var i int
 	for rows.Next() {
-		log.Printf("A%d", i)
 		var tag Tag
 		var deletedAt sql.NullTime
 		// var osString string
 
 		err = rows.Scan(
-			&tag.ID, &tag.Name, &tag.Description, &tag.SearchCount, &tag.CreatedAt, &tag.UpdatedAt, &deletedAt,
+			&tag.ID, &tag.Name, &tag.Description, &tag.ParentID, &tag.SearchCount, &tag.Pinned, &tag.SortValue, &tag.CreatedAt, &tag.UpdatedAt, &deletedAt,
 		)
 		if err != nil {
-			log.Printf("扫描标签失败: %v", err)
-			return nil, fmt.Errorf("扫描标签失败: %v", err)
+			return nil, Page{}, fmt.Errorf("扫描标签失败: %w", err)
 		}
-		log.Printf("B%d", i)
 
 		// 处理deletedAt字段
 		if deletedAt.Valid {
 			tag.DeletedAt = deletedAt.Time.Format("2006-01-02 15:04:05")
 		}
-		log.Printf("C%d", i)
 		// 从关联表获取指令关联关系
-		if tag.ComandIdNames, err = GetCommandIDsByTagIDSQLite(tag.ID); err != nil {
-			return nil, fmt.Errorf("获取标签指令关联关系失败: %v", err)
-		} else {
-			log.Printf("标签[%d]关联指令: %+v", tag.ID, tag.ComandIdNames)
+		if tag.ComandIdNames, err = GetCommandIDsByTagIDSQLite(ctx, tag.ID); err != nil {
+			return nil, Page{}, fmt.Errorf("获取标签指令关联关系失败: %w", err)
 		}
-		log.Printf("D%d", i)
 
 		tags = append(tags, &tag)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("遍历标签结果集失败: %v", err)
-		return nil, fmt.Errorf("遍历标签结果集失败: %v", err)
+		return nil, Page{}, fmt.Errorf("遍历标签结果集失败: %w", err)
 	}
-	log.Printf("获取标签列表成功: %+v\n", tags)
-	return tags, nil
+	tags, page := pageResult(tags, list, func(t *Tag) uint64 { return t.ID })
+	slog.Debug("获取标签列表成功", "count", len(tags))
+	return tags, page, nil
 }
 
 // UpdateTagSQLite 更新标签
-func UpdateTagSQLite(tag *Tag) error {
-	tx, err := DB.Begin()
+func UpdateTagSQLite(ctx context.Context, tag *Tag) error {
+	normalized, err := NormalizeOSList("os", tag.Os)
 	if err != nil {
-		return fmt.Errorf("开启事务失败: %v", err)
+		return err
+	}
+	tag.Os = normalized
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
 	}
 	defer func() {
 		if err != nil {
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("回滚事务失败: %v", rollbackErr)
+				slog.Error("回滚事务失败", "err", rollbackErr)
 			}
 		}
 	}()
 
 	tag.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
 
+	audit, err := snapshotAudit(ctx, tx, AuditEntityTag, tag.ID)
+	if err != nil {
+		return err
+	}
+
 	//0. 查询标签的OS关联关系
-	rows, err := tx.Query("SELECT os FROM tag_os WHERE tag_id = ?", tag.ID)
+	rows, err := tx.QueryContext(ctx, "SELECT os FROM tag_os WHERE tag_id = ?", tag.ID)
 	if err != nil {
-		return fmt.Errorf("查询标签OS关联关系失败: %v", err)
+		return fmt.Errorf("查询标签OS关联关系失败: %w", err)
 	}
 	defer rows.Close()
 	var osList []string
 	for rows.Next() {
 		var os string
 		if err = rows.Scan(&os); err != nil {
-			return fmt.Errorf("扫描标签OS关联关系失败: %v", err)
+			return fmt.Errorf("扫描标签OS关联关系失败: %w", err)
 		}
 		osList = append(osList, os)
 	}
 	if err = rows.Err(); err != nil {
-		return fmt.Errorf("遍历标签OS关联关系结果集失败: %v", err)
+		return fmt.Errorf("遍历标签OS关联关系结果集失败: %w", err)
 	}
 	// 查询标签的指令关联关系
-	rows, err = tx.Query("SELECT command_id FROM command_tags WHERE tag_id = ?", tag.ID)
+	rows, err = tx.QueryContext(ctx, "SELECT command_id FROM command_tags WHERE tag_id = ?", tag.ID)
 	if err != nil {
-		return fmt.Errorf("查询标签指令关联关系失败: %v", err)
+		return fmt.Errorf("查询标签指令关联关系失败: %w", err)
 	}
 	defer rows.Close()
 	var commandIDList []uint64
 	for rows.Next() {
 		var commandID uint64
 		if err = rows.Scan(&commandID); err != nil {
-			return fmt.Errorf("扫描标签指令关联关系失败: %v", err)
+			return fmt.Errorf("扫描标签指令关联关系失败: %w", err)
 		}
 		commandIDList = append(commandIDList, commandID)
 	}
 	if err = rows.Err(); err != nil {
-		return fmt.Errorf("遍历标签指令关联关系结果集失败: %v", err)
+		return fmt.Errorf("遍历标签指令关联关系结果集失败: %w", err)
 	}
 
 	// 1. 更新SQLite数据库中的标签
-	_, err = tx.Exec(
+	result, err := tx.ExecContext(ctx,
 		"UPDATE tags SET name = ?, description = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
 		tag.Name, tag.Description, tag.UpdatedAt, tag.ID,
 	)
 	if err != nil {
-		return fmt.Errorf("更新标签失败: %v", err)
+		return fmt.Errorf("更新标签失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新影响的行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("tag.not_found", tag.ID)
+		return err
 	}
 	// 2. 更新标签的OS关联关系
 	for _, os := range tag.Os {
-		_, err = tx.Exec("INSERT OR IGNORE INTO tag_os (tag_id, os) VALUES (?, ?)", tag.ID, os)
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO tag_os (tag_id, os) VALUES (?, ?)", tag.ID, os)
 		if err != nil {
-			return fmt.Errorf("添加标签OS关联关系失败: %v", err)
+			return fmt.Errorf("添加标签OS关联关系失败: %w", err)
 		}
 	}
 	// 删除标签的OS关联关系中不在tag.Os中的OS
 	for _, os := range osList {
 		if !slices.Contains(tag.Os, os) {
-			_, err = tx.Exec("DELETE FROM tag_os WHERE tag_id = ? AND os = ?", tag.ID, os)
+			_, err = tx.ExecContext(ctx, "DELETE FROM tag_os WHERE tag_id = ? AND os = ?", tag.ID, os)
 			if err != nil {
-				return fmt.Errorf("删除标签OS关联关系失败: %v", err)
+				return fmt.Errorf("删除标签OS关联关系失败: %w", err)
 			}
 		}
 	}
 	//3. 更新标签的指令关联关系
 	for _, commandID := range tag.CommandIDs {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT OR IGNORE INTO command_tags (command_id, tag_id) VALUES (?, ?)", commandID, tag.ID)
 		if err != nil {
-			return fmt.Errorf("添加标签指令关系失败: %v", err)
+			return fmt.Errorf("添加标签指令关系失败: %w", err)
 		}
 	}
 	// 删除标签的指令关联关系中不在tag.CommandIDs中的指令
 	for _, commandID := range commandIDList {
 		if !slices.Contains(tag.CommandIDs, commandID) {
-			_, err = tx.Exec("DELETE FROM command_tags WHERE tag_id = ? AND command_id = ?", tag.ID, commandID)
+			_, err = tx.ExecContext(ctx, "DELETE FROM command_tags WHERE tag_id = ? AND command_id = ?", tag.ID, commandID)
 			if err != nil {
-				return fmt.Errorf("删除标签指令关联关系失败: %v", err)
+				return fmt.Errorf("删除标签指令关联关系失败: %w", err)
 			}
 		}
 	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return fmt.Errorf("提交事务失败: %w", err)
 	}
 	return nil
 }
 
-// DeleteTagSQLite 删除标签（软删除）
-func DeleteTagSQLite(id uint64) error {
+// DeleteTagSQLite 删除标签及其所有子标签（软删除）
+func DeleteTagSQLite(ctx context.Context, id uint64) error {
 	// 输入验证
 	if id == 0 {
-		return fmt.Errorf("标签ID不能为空")
+		return NewValidationError("tagId", "tag.id_required")
 	}
 
-	tx, err := DB.Begin()
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开启事务失败: %v", err)
+		return fmt.Errorf("开启事务失败: %w", err)
 	}
 	defer func() {
 		if err != nil {
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("回滚事务失败: %v", rollbackErr)
+				slog.Error("回滚事务失败", "err", rollbackErr)
 			}
 		}
 	}()
 
+	// 0.查询要删除的子树
+	ids, err := GetTagSubtreeIDsSQLite(ctx, tx, []uint64{id})
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		err = NewNotFoundError("tag.not_found", id)
+		return err
+	}
+	in, args := inPlaceholders(len(ids)), toArgs(ids)
+	audit, err := snapshotAudit(ctx, tx, AuditEntityTag, ids...)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now()
 	// 1.更新标签的deleted_at字段
-	_, err = tx.Exec(
-		"UPDATE tags SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
-		now, now, id,
+	_, err = tx.ExecContext(ctx,
+		"UPDATE tags SET deleted_at = ?, updated_at = ? WHERE id IN "+in+" AND deleted_at IS NULL",
+		append([]interface{}{now, now}, args...)...,
 	)
 	if err != nil {
-		return fmt.Errorf("删除标签失败: %v", err)
+		return fmt.Errorf("删除标签失败: %w", err)
 	}
 	//2.删除标签的OS关联关系
-	_, err = tx.Exec(
-		"DELETE FROM tag_os WHERE tag_id = ?",
-		id,
-	)
+	_, err = tx.ExecContext(ctx, "DELETE FROM tag_os WHERE tag_id IN "+in, args...)
 	if err != nil {
-		return fmt.Errorf("删除标签OS关联关系失败: %v", err)
+		return fmt.Errorf("删除标签OS关联关系失败: %w", err)
 	}
 	//3.删除标签的指令关联关系
-	_, err = tx.Exec(
-		"DELETE FROM command_tags WHERE tag_id = ?",
-		id,
-	)
+	_, err = tx.ExecContext(ctx, "DELETE FROM command_tags WHERE tag_id IN "+in, args...)
 	if err != nil {
-		return fmt.Errorf("删除标签指令关联关系失败: %v", err)
+		return fmt.Errorf("删除标签指令关联关系失败: %w", err)
+	}
+	if err = audit.record(ctx, tx, AuditActionDelete); err != nil {
+		return err
 	}
 	//4.提交事务
 	return tx.Commit()
 }
 
-func GetTagIDAndNameSQLite() ([]Tag, error) {
+func GetTagIDAndNameSQLite(ctx context.Context) ([]Tag, error) {
 	var tags []Tag
 	query := "SELECT DISTINCT id, name FROM tags WHERE id IS NOT NULL AND deleted_at IS NULL"
-	log.Printf("GetTagIDAndNameSQLite SQL: %s", query)
 
-	rows, err := DB.Query(query)
+	rows, err := DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("查询标签ID和名称失败: %v", err)
+		return nil, fmt.Errorf("查询标签ID和名称失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -381,43 +392,282 @@ func GetTagIDAndNameSQLite() ([]Tag, error) {
 		var id uint64
 		var name string
 		if err = rows.Scan(&id, &name); err != nil {
-			return nil, fmt.Errorf("扫描标签ID和名称失败: %v", err)
+			return nil, fmt.Errorf("扫描标签ID和名称失败: %w", err)
 		}
 		tags = append(tags, Tag{ID: id, Name: name})
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历标签ID和名称结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历标签ID和名称结果集失败: %w", err)
 	}
 
 	return tags, nil
 }
 
-func GetCommandIDsByTagIDSQLite(tagID uint64) ([]CommandIDName, error) {
-	log.Printf("GetCommandIDsByTagIDSQLite tagID: %d", tagID)
+func GetCommandIDsByTagIDSQLite(ctx context.Context, tagID uint64) ([]CommandIDName, error) {
 	var commandIDs []CommandIDName
-	query := "SELECT command_id, name FROM command_tags ct LEFT JOIN commands cmd ON ct.command_id = cmd.id WHERE tag_id = ?"
-	log.Printf("GetCommandIDsByTagIDSQLite SQL: %s, tagID: %d", query, tagID)
+	// 软删除的指令保留了标签关联，用于恢复，这里不返回
+	query := "SELECT command_id, name FROM command_tags ct JOIN commands cmd ON ct.command_id = cmd.id WHERE tag_id = ? AND cmd.deleted_at IS NULL"
 
-	rows, err := DB.Query(query, tagID)
+	rows, err := DB.QueryContext(ctx, query, tagID)
 	if err != nil {
-		log.Printf("查询标签指令关联关系失败: %v", err)
-		return nil, fmt.Errorf("查询标签指令关联关系失败: %v", err)
+		return nil, fmt.Errorf("查询标签指令关联关系失败: %w", err)
 	}
 	defer rows.Close()
-	log.Printf("查询标签指令关联关系成功: %+v", rows)
 	for rows.Next() {
 		var commandID uint64
 		var name string
 		if err = rows.Scan(&commandID, &name); err != nil {
-			return nil, fmt.Errorf("扫描标签指令关联关系失败: %v", err)
+			return nil, fmt.Errorf("扫描标签指令关联关系失败: %w", err)
 		}
 		commandIDs = append(commandIDs, CommandIDName{ID: commandID, Name: name})
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历标签指令关联关系结果集失败: %v", err)
+		return nil, fmt.Errorf("遍历标签指令关联关系结果集失败: %w", err)
 	}
-	log.Printf("标签[%d]关联指令: %+v", tagID, commandIDs)
 	return commandIDs, nil
 }
+
+// tagSubtreeQuery 递归查询以指定标签为根的子树（包含根本身），%s为根标签条件
+const tagSubtreeQuery = `WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM tags WHERE %s AND deleted_at IS NULL
+		UNION
+		SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id WHERE t.deleted_at IS NULL
+	) SELECT id FROM subtree`
+
+// GetTagSubtreeIDsSQLite 获取指定标签及其所有后代标签的ID
+func GetTagSubtreeIDsSQLite(ctx context.Context, q sqlQueryer, rootIDs []uint64) ([]uint64, error) {
+	if len(rootIDs) == 0 {
+		return nil, nil
+	}
+	query := fmt.Sprintf(tagSubtreeQuery, "id IN "+inPlaceholders(len(rootIDs)))
+	rows, err := q.QueryContext(ctx, query, toArgs(rootIDs)...)
+	if err != nil {
+		return nil, fmt.Errorf("查询子标签失败: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("扫描子标签失败: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历子标签结果集失败: %w", err)
+	}
+	return ids, nil
+}
+
+// isTagInSubtree 判断标签id是否位于rootID的子树中（包含rootID本身）
+func isTagInSubtree(ctx context.Context, q sqlQueryer, rootID, id uint64) (bool, error) {
+	ids, err := GetTagSubtreeIDsSQLite(ctx, q, []uint64{rootID})
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(ids, id), nil
+}
+
+// MoveTagSQLite 将标签移动到新的父标签下，parentID为0时移动到顶层，不允许移动到自己的子树中
+func MoveTagSQLite(ctx context.Context, id, parentID uint64) error {
+	if id == 0 {
+		return NewValidationError("tagId", "tag.id_required")
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	if parentID != 0 {
+		var cycle bool
+		if cycle, err = isTagInSubtree(ctx, tx, id, parentID); err != nil {
+			return err
+		}
+		if cycle {
+			err = NewValidationError("parentId", "tag.move_into_self")
+			return err
+		}
+		var exists bool
+		if err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM tags WHERE id = ? AND deleted_at IS NULL)", parentID).Scan(&exists); err != nil {
+			return fmt.Errorf("检查父标签是否存在失败: %w", err)
+		}
+		if !exists {
+			err = NewNotFoundError("tag.parent_not_found", parentID)
+			return err
+		}
+	}
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityTag, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE tags SET parent_id = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		nullableID(parentID), time.Now().Format("2006-01-02 15:04:05"), id,
+	)
+	if err != nil {
+		return fmt.Errorf("移动标签失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取移动影响的行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("tag.not_found", id)
+		return err
+	}
+
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// MergeTagsSQLite 将sourceID标签合并到targetID标签：
+// 指令关联和OS转移到目标标签，子标签挂到目标标签下，然后软删除源标签
+func MergeTagsSQLite(ctx context.Context, sourceID, targetID uint64) error {
+	if sourceID == 0 || targetID == 0 {
+		return NewValidationError("tagId", "tag.id_required")
+	}
+	if sourceID == targetID {
+		return NewValidationError("targetId", "tag.merge_into_self")
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	// 目标标签在源标签的子树中时，挂接子标签会形成环
+	var cycle bool
+	if cycle, err = isTagInSubtree(ctx, tx, sourceID, targetID); err != nil {
+		return err
+	}
+	if cycle {
+		err = NewValidationError("targetId", "tag.merge_into_child")
+		return err
+	}
+	var count int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM tags WHERE id IN (?, ?) AND deleted_at IS NULL", sourceID, targetID).Scan(&count); err != nil {
+		return fmt.Errorf("检查标签是否存在失败: %w", err)
+	}
+	if count != 2 {
+		err = NewNotFoundError("tag.merge_not_found")
+		return err
+	}
+
+	// 源标签和目标标签记为合并，挂到目标标签下的子标签记为更新
+	merged, err := snapshotAudit(ctx, tx, AuditEntityTag, sourceID, targetID)
+	if err != nil {
+		return err
+	}
+	childIDs, err := queryIDs(ctx, tx, "SELECT id FROM tags WHERE parent_id = ? AND deleted_at IS NULL", sourceID)
+	if err != nil {
+		return fmt.Errorf("查询子标签失败: %w", err)
+	}
+	moved, err := snapshotAudit(ctx, tx, AuditEntityTag, childIDs...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	steps := []struct {
+		query string
+		args  []interface{}
+		desc  string
+	}{
+		{"INSERT OR IGNORE INTO command_tags (command_id, tag_id) SELECT command_id, ? FROM command_tags WHERE tag_id = ?", []interface{}{targetID, sourceID}, "转移标签指令关联关系"},
+		{"DELETE FROM command_tags WHERE tag_id = ?", []interface{}{sourceID}, "删除源标签指令关联关系"},
+		{"INSERT OR IGNORE INTO tag_os (tag_id, os) SELECT ?, os FROM tag_os WHERE tag_id = ?", []interface{}{targetID, sourceID}, "转移标签OS关联关系"},
+		{"DELETE FROM tag_os WHERE tag_id = ?", []interface{}{sourceID}, "删除源标签OS关联关系"},
+		{"UPDATE tags SET parent_id = ?, updated_at = ? WHERE parent_id = ? AND deleted_at IS NULL", []interface{}{targetID, now, sourceID}, "移动子标签"},
+		{"UPDATE tags SET deleted_at = ?, updated_at = ? WHERE id = ?", []interface{}{now, now, sourceID}, "删除源标签"},
+	}
+	for _, step := range steps {
+		if _, err = tx.ExecContext(ctx, step.query, step.args...); err != nil {
+			return fmt.Errorf("%s失败: %w", step.desc, err)
+		}
+	}
+
+	if err = merged.record(ctx, tx, AuditActionMerge); err != nil {
+		return err
+	}
+	if err = moved.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// GetTagTreeSQLite 获取所有标签并组装为树，同一层级置顶的在前，其余按手动排序值排序
+func GetTagTreeSQLite(ctx context.Context) ([]*Tag, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT id, name, description, COALESCE(parent_id, 0), search_count, pinned, sort_value, created_at, updated_at FROM tags WHERE deleted_at IS NULL ORDER BY pinned DESC, sort_value, id")
+	if err != nil {
+		return nil, fmt.Errorf("获取标签树失败: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*Tag
+	byID := make(map[uint64]*Tag)
+	for rows.Next() {
+		var tag Tag
+		if err = rows.Scan(&tag.ID, &tag.Name, &tag.Description, &tag.ParentID, &tag.SearchCount, &tag.Pinned, &tag.SortValue, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描标签失败: %w", err)
+		}
+		tags = append(tags, &tag)
+		byID[tag.ID] = &tag
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历标签结果集失败: %w", err)
+	}
+
+	var roots []*Tag
+	for _, tag := range tags {
+		// 父标签已被删除的标签作为顶层标签展示
+		if parent, ok := byID[tag.ParentID]; ok && tag.ParentID != tag.ID {
+			parent.Children = append(parent.Children, tag)
+		} else {
+			roots = append(roots, tag)
+		}
+	}
+	fillTagPaths(roots, "")
+	return roots, nil
+}
+
+// fillTagPaths 递归计算标签的完整路径
+func fillTagPaths(tags []*Tag, prefix string) {
+	for _, tag := range tags {
+		tag.Path = prefix + tag.Name
+		fillTagPaths(tag.Children, tag.Path+"/")
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// tagNames 返回标签的名称列表
+func tagNames(tags []*Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+func TestGetTagsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name   string
+		option Option
+		want   []string
+	}{
+		{"all active", Option{}, []string{"docker", "k8s", "ops", "windows-admin"}},
+		{"linux", Option{Os: []string{Linux}}, []string{"docker", "k8s", "ops"}},
+		{"windows", Option{Os: []string{"win"}}, []string{"ops", "windows-admin"}},
+		{"name", Option{Name: "k"}, []string{"docker", "k8s"}},
+		{"id", Option{ID: f.tag("k8s")}, []string{"k8s"}},
+		{"deleted id", Option{ID: f.tag("archived")}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.option.Sort = byName()
+			tags, page, err := GetTagsSQLite(context.Background(), tt.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tagNames(tags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTagsSQLite(%+v) = %v, want %v", tt.option, got, tt.want)
+			}
+			if page.Total != len(tt.want) {
+				t.Errorf("page.Total = %d, want %d", page.Total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetCommandIDsByTagIDSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		tag  string
+		want []CommandIDName
+	}{
+		// old-backup已软删除，不再列出
+		{"ops", []CommandIDName{{ID: f.command("list-files"), Name: "list-files"}}},
+		{"docker", []CommandIDName{{ID: f.command("docker-ps"), Name: "docker-ps"}}},
+		{"archived", nil},
+	}
+	for _, tt := range tests {
+		got, err := GetCommandIDsByTagIDSQLite(context.Background(), f.tag(tt.tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetCommandIDsByTagIDSQLite(%s) = %+v, want %+v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestGetTagSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name   string
+		id     uint64
+		code   ErrorCode
+		parent uint64
+		os     []string
+	}{
+		{"child", f.tag("docker"), CodeOK, f.tag("ops"), []string{Linux, Mac}},
+		{"root", f.tag("ops"), CodeOK, 0, []string{AllOs}},
+		{"deleted", f.tag("archived"), CodeNotFound, 0, nil},
+		{"zero", 0, CodeValidation, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := GetTagSQLite(context.Background(), tt.id)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("GetTagSQLite(%d) error = %v, want code %d", tt.id, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			slices.Sort(tag.Os)
+			if tag.ParentID != tt.parent || !reflect.DeepEqual(tag.Os, tt.os) {
+				t.Errorf("GetTagSQLite(%d) = parent %d, os %v, want %d, %v", tt.id, tag.ParentID, tag.Os, tt.parent, tt.os)
+			}
+		})
+	}
+
+	osList, err := GetTagOSsSQLite(context.Background(), f.tag("windows-admin"))
+	if err != nil || !reflect.DeepEqual(osList, []string{Windows}) {
+		t.Errorf("GetTagOSsSQLite() = %v, %v", osList, err)
+	}
+}
+
+func TestCreateTagSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name string
+		tag  *Tag
+		code ErrorCode
+	}{
+		{"root", &Tag{Name: "net", Os: []string{AllOs}}, CodeOK},
+		{"child", &Tag{Name: "compose", ParentID: f.tag("docker"), Os: []string{Linux}}, CodeOK},
+		{"duplicate", &Tag{Name: "ops", Os: []string{AllOs}}, CodeConflict},
+		{"reuses deleted name", &Tag{Name: "archived", Os: []string{AllOs}}, CodeOK},
+		{"deleted parent", &Tag{Name: "orphan", ParentID: f.tag("archived"), Os: []string{AllOs}}, CodeNotFound},
+		{"invalid os", &Tag{Name: "bad", Os: []string{"plan9"}}, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CreateTagSQLite(context.Background(), tt.tag)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("CreateTagSQLite() error = %v, want code %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestUpdateTagSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tag := &Tag{
+		ID: f.tag("windows-admin"), Name: "win-admin", Os: []string{Windows, Mac},
+		CommandIDs: f.commandIDs("dir", "greet"),
+	}
+	if err := UpdateTagSQLite(ctx, tag); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetTagSQLite(ctx, tag.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got.Os)
+	if got.Name != "win-admin" || !reflect.DeepEqual(got.Os, []string{Mac, Windows}) {
+		t.Errorf("updated tag = %+v", got)
+	}
+	commands, err := GetCommandsByTagIDs(ctx, []uint64{tag.ID}, byName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := commandNames(commands); !reflect.DeepEqual(names, []string{"dir", "greet"}) {
+		t.Errorf("commands of updated tag = %v", names)
+	}
+
+	if err = UpdateTagSQLite(ctx, &Tag{ID: f.tag("archived"), Name: "archived"}); errorCode(err) != CodeNotFound {
+		t.Errorf("UpdateTagSQLite(deleted) error = %v", err)
+	}
+}
+
+func TestDeleteTagSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	if err := DeleteTagSQLite(ctx, f.tag("docker")); err != nil {
+		t.Fatal(err)
+	}
+	// 子标签随父标签一起删除，指令本身保留
+	for _, name := range []string{"docker", "k8s"} {
+		if _, err := GetTagSQLite(ctx, f.tag(name)); errorCode(err) != CodeNotFound {
+			t.Errorf("GetTagSQLite(%s) error = %v, want not found", name, err)
+		}
+	}
+	tags, err := GetTagIDsByCommandIDsSQLite(ctx, f.commandIDs("docker-ps", "kubectl-pods"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("relations of deleted tags = %v", tags)
+	}
+	if _, err = GetCommandSQLite(ctx, f.command("docker-ps")); err != nil {
+		t.Errorf("GetCommandSQLite() after deleting its tag: %v", err)
+	}
+
+	idNames, err := GetTagIDAndNameSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tag := range idNames {
+		names = append(names, tag.Name)
+	}
+	slices.Sort(names)
+	if !reflect.DeepEqual(names, []string{"ops", "windows-admin"}) {
+		t.Errorf("GetTagIDAndNameSQLite() = %v", names)
+	}
+
+	if err = DeleteTagSQLite(ctx, f.tag("docker")); errorCode(err) != CodeNotFound {
+		t.Errorf("DeleteTagSQLite(deleted) error = %v", err)
+	}
+}
+
+func TestMoveTagSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	tests := []struct {
+		name   string
+		tag    string
+		parent string
+		code   ErrorCode
+	}{
+		{"into own subtree", "ops", "k8s", CodeValidation},
+		{"into itself", "docker", "docker", CodeValidation},
+		{"under deleted tag", "k8s", "archived", CodeNotFound},
+		{"deleted tag", "archived", "ops", CodeNotFound},
+		{"to another parent", "k8s", "windows-admin", CodeOK},
+		{"to root", "docker", "", CodeOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parentID uint64
+			if tt.parent != "" {
+				parentID = f.tag(tt.parent)
+			}
+			err := MoveTagSQLite(context.Background(), f.tag(tt.tag), parentID)
+			if code := errorCode(err); code != tt.code {
+				t.Fatalf("MoveTagSQLite(%s, %s) error = %v, want code %d", tt.tag, tt.parent, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			tag, err := GetTagSQLite(context.Background(), f.tag(tt.tag))
+			if err != nil || tag.ParentID != parentID {
+				t.Errorf("parent after move = %v, %v, want %d", tag, err, parentID)
+			}
+		})
+	}
+}
+
+func TestMergeTagsSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		source string
+		target string
+		code   ErrorCode
+	}{
+		{"into itself", "ops", "ops", CodeValidation},
+		{"into child", "ops", "docker", CodeValidation},
+		{"deleted target", "windows-admin", "archived", CodeNotFound},
+	}
+	for _, tt := range tests {
+		if err := MergeTagsSQLite(ctx, f.tag(tt.source), f.tag(tt.target)); errorCode(err) != tt.code {
+			t.Errorf("MergeTagsSQLite(%s) error = %v, want code %d", tt.name, err, tt.code)
+		}
+	}
+
+	if err := MergeTagsSQLite(ctx, f.tag("docker"), f.tag("windows-admin")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetTagSQLite(ctx, f.tag("docker")); errorCode(err) != CodeNotFound {
+		t.Errorf("source tag after merge: %v", err)
+	}
+	k8s, err := GetTagSQLite(ctx, f.tag("k8s"))
+	if err != nil || k8s.ParentID != f.tag("windows-admin") {
+		t.Errorf("child of merged tag = %+v, %v", k8s, err)
+	}
+	target, err := GetTagSQLite(ctx, f.tag("windows-admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(target.Os)
+	if !reflect.DeepEqual(target.Os, []string{Linux, Mac, Windows}) {
+		t.Errorf("os of merge target = %v", target.Os)
+	}
+	commands, err := GetCommandsByTagIDs(ctx, []uint64{f.tag("windows-admin")}, byName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := commandNames(commands); !reflect.DeepEqual(names, []string{"dir", "docker-ps", "kubectl-pods"}) {
+		t.Errorf("commands of merge target = %v", names)
+	}
+}
+
+func TestGetTagTreeSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	roots, err := GetTagTreeSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := tagNames(roots); !reflect.DeepEqual(names, []string{"ops", "windows-admin"}) {
+		t.Fatalf("roots = %v", names)
+	}
+	docker := roots[0].Children
+	if len(docker) != 1 || len(docker[0].Children) != 1 || docker[0].Children[0].Path != "ops/docker/k8s" {
+		t.Errorf("tag tree = %+v", roots[0])
+	}
+
+	subtree, err := GetTagSubtreeIDsSQLite(ctx, DB, []uint64{f.tag("ops")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(subtree)
+	if want := []uint64{f.tag("ops"), f.tag("docker"), f.tag("k8s")}; !reflect.DeepEqual(subtree, want) {
+		t.Errorf("GetTagSubtreeIDsSQLite(ops) = %v, want %v", subtree, want)
+	}
+}
+
+func TestSearchTagIncludesSubtree(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	search := func(query string) []string {
+		t.Helper()
+		commands, _, err := GetCommandsSQLite(ctx, Option{Name: query, Sort: byName()})
+		if err != nil {
+			t.Fatalf("GetCommandsSQLite(%q) error = %v", query, err)
+		}
+		return commandNames(commands)
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"tag:ops", []string{"docker-ps", "kubectl-pods", "list-files"}},
+		{"tag:DOCKER", []string{"docker-ps", "kubectl-pods"}},
+		{"tag:k8s", []string{"kubectl-pods"}},
+		{"tag:ops -tag:docker", []string{"list-files"}},
+		{"tag:archived", []string{}},
+	}
+	for _, tt := range tests {
+		if got := search(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// 移动标签后按新的父标签筛选
+	if err := MoveTagSQLite(ctx, f.tag("k8s"), f.tag("windows-admin")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := search("tag:windows-admin"), []string{"dir", "kubectl-pods"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search tag:windows-admin after move = %v, want %v", got, want)
+	}
+	if got, want := search("tag:docker"), []string{"docker-ps"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search tag:docker after move = %v, want %v", got, want)
+	}
+}
+
+func TestFillTagPaths(t *testing.T) {
+	s3 := &Tag{Name: "s3"}
+	aws := &Tag{Name: "aws", Children: []*Tag{s3}}
+	gcp := &Tag{Name: "gcp"}
+	cloud := &Tag{Name: "cloud", Children: []*Tag{aws, gcp}}
+	fillTagPaths([]*Tag{cloud}, "")
+	for tag, want := range map[*Tag]string{cloud: "cloud", aws: "cloud/aws", s3: "cloud/aws/s3", gcp: "cloud/gcp"} {
+		if tag.Path != want {
+			t.Errorf("%s path = %q, want %q", tag.Name, tag.Path, want)
+		}
+	}
+}
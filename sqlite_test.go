@@ -1,7 +1,465 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
-func TestSqlite(t *testing.T) {
-	InitSqlite()
+func TestInitSqlite(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"path", filepath.Join(dir, "path.db")},
+		{"dsn", "file:" + filepath.Join(dir, "dsn.db")},
+		{"dsn with params", "file:" + filepath.Join(dir, "params.db") + "?_busy_timeout=1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDB(t, tt.source)
+			var n int
+			if err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('commands', 'tags', 'collections', 'audit_log')").Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != 4 {
+				t.Errorf("InitSqlite(%q) created %d of 4 tables", tt.source, n)
+			}
+		})
+	}
+}
+
+func TestInitSqliteCreateTablesFailed(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "broken.db")
+	db, err := openSqlite(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与表同名的视图使建表失败
+	if _, err = db.Exec("CREATE VIEW tags AS SELECT 1 AS id"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+	if err = InitSqlite(source); err == nil {
+		t.Fatal("InitSqlite() with a broken schema succeeded")
+	}
+	if DB != nil {
+		t.Errorf("DB = %v after InitSqlite failed, want nil", DB)
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"quick-cmd.db", "file:quick-cmd.db?" + sqliteDSNParams},
+		{"file:a.db", "file:a.db?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL&_txlock=immediate&mode=rwc"},
+		{"file:a.db?mode=ro&_busy_timeout=1", "file:a.db?_busy_timeout=1&_foreign_keys=1&_journal_mode=WAL&_txlock=immediate&mode=ro"},
+	}
+	for _, tt := range tests {
+		if got := sqliteDSN(tt.source); got != tt.want {
+			t.Errorf("sqliteDSN(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+// useDB 用InitSqlite打开source对应的数据库并替换全局DB，测试结束后关闭并恢复
+func useDB(t *testing.T, source string) {
+	t.Helper()
+	previous := DB
+	DB = nil
+	t.Cleanup(func() {
+		if DB != nil {
+			DB.Close()
+		}
+		DB = previous
+	})
+	if err := InitSqlite(source); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTempDB 在临时目录中创建完整结构的数据库并替换全局DB，测试结束后恢复
+func useTempDB(t *testing.T) {
+	t.Helper()
+	useDB(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	useTempDB(t)
+	base := &Command{Name: "base", Content: "echo base", Os: []string{Linux}}
+	if err := CreateCommandSQLite(ctx, base); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*4)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := &Command{Name: fmt.Sprintf("cmd-%d", i), Content: "echo", Os: []string{Linux}}
+			errs <- CreateCommandSQLite(ctx, cmd)
+			update := &Command{ID: base.ID, Name: "base", Content: fmt.Sprintf("echo %d", i), Os: []string{Mac}}
+			errs <- UpdateCommandSQLite(ctx, update)
+			errs <- SetPinnedSQLite(ctx, commandListEntity, base.ID, i%2 == 0)
+			errs <- RecordTypedCommandSQLite(ctx, "git status", time.Now(), 7)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent write failed: %v", err)
+		}
+	}
+
+	var commands, typed int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM commands").Scan(&commands); err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.QueryRow("SELECT SUM(count) FROM typed_commands").Scan(&typed); err != nil {
+		t.Fatal(err)
+	}
+	if commands != workers+1 || typed != workers {
+		t.Errorf("got %d commands and %d typed commands, want %d and %d", commands, typed, workers+1, workers)
+	}
+}
+
+func TestConcurrentCreateSameName(t *testing.T) {
+	ctx := context.Background()
+	useTempDB(t)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- CreateTagSQLite(ctx, &Tag{Name: "ops", Os: []string{AllOs}})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		var appErr *AppError
+		switch {
+		case err == nil:
+			created++
+		case errors.As(err, &appErr) && appErr.Code == CodeConflict:
+		default:
+			t.Errorf("CreateTagSQLite() = %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d tags with the same name, want 1", created)
+	}
+}
+
+func TestConcurrentSameAlias(t *testing.T) {
+	ctx := context.Background()
+	useTempDB(t)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- CreateCommandSQLite(ctx, &Command{Name: fmt.Sprintf("greet-%d", i), Content: "echo hi", Alias: "hi", Os: []string{AllOs}})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errorCode(err) == CodeConflict:
+		default:
+			t.Errorf("CreateCommandSQLite() = %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d commands with the same alias, want 1", created)
+	}
+
+	// 修改其他指令的别名时同样在事务中检查
+	cmd := &Command{Name: "bye", Content: "echo bye", Os: []string{AllOs}}
+	if err := CreateCommandSQLite(ctx, cmd); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Alias = "hi"
+	if err := UpdateCommandSQLite(ctx, cmd); errorCode(err) != CodeConflict {
+		t.Errorf("UpdateCommandSQLite() with used alias error = %v", err)
+	}
+}
+
+func TestConcurrentUpdateCollection(t *testing.T) {
+	ctx := context.Background()
+	useTempDB(t)
+	col := &Collection{Name: "deploy", Os: []string{Linux}}
+	if err := CreateCollectionSQLite(ctx, col); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每次更新都替换整个OS列表，更新在事务中完成时最终只会剩下其中一次的结果
+	osValues := []string{Windows, Mac, Linux}
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			update := &Collection{ID: col.ID, Name: "deploy", Os: []string{osValues[i%len(osValues)]}}
+			if err := UpdateCollectionSQLite(ctx, update); err != nil {
+				t.Errorf("UpdateCollectionSQLite() = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	osList, err := GetCollectionOSsSQLite(ctx, col.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(osList) != 1 {
+		t.Errorf("collection os = %v, want exactly one value", osList)
+	}
+}
+
+func TestCanceledSearch(t *testing.T) {
+	useTempDB(t)
+	a := NewApp()
+	first, _ := a.startSearch("commands")
+	tags, cancelTags := a.startSearch("tags")
+	defer cancelTags()
+	second, cancel := a.startSearch("commands")
+	defer cancel()
+	if first.Err() == nil || second.Err() != nil {
+		t.Fatalf("startSearch() did not cancel the superseded search: %v, %v", first.Err(), second.Err())
+	}
+	if tags.Err() != nil {
+		t.Errorf("startSearch(commands) canceled the tags query: %v", tags.Err())
+	}
+
+	_, _, err := GetCommandsSQLite(first, Option{})
+	if code, _ := classifyError(err); code != CodeCanceled {
+		t.Errorf("GetCommandsSQLite(canceled) = %v, want code %d", err, CodeCanceled)
+	}
+}
+
+func TestAuditSourceFromContext(t *testing.T) {
+	useTempDB(t)
+	ctx := withAuditSource(context.Background(), AuditSourceImport)
+	if err := CreateTagSQLite(ctx, &Tag{Name: "ops", Os: []string{AllOs}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := GetAuditLogSQLite(context.Background(), AuditQuery{EntityType: AuditEntityTag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Source != AuditSourceImport {
+		t.Errorf("audit entries = %+v, want one entry from %s", entries, AuditSourceImport)
+	}
+	if got := auditSourceFrom(context.Background()); got != AuditSourceGUI {
+		t.Errorf("auditSourceFrom(background) = %q, want %q", got, AuditSourceGUI)
+	}
+}
+
+func TestAuditRedactsSecrets(t *testing.T) {
+	useTempDB(t)
+	ctx := context.Background()
+	cmd := &Command{Name: "db-shell", Content: "mysql -u root --password=Sup3rS3cret db", Os: []string{Linux}}
+	if err := CreateCommandSQLite(ctx, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteCommandSQLite(ctx, cmd.ID); err != nil {
+		t.Fatal(err)
+	}
+	// 审计日志不随回收站清理删除，保存的内容中也不能有密钥
+	if _, err := PurgeDeletedSQLite(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := GetAuditLogSQLite(ctx, AuditQuery{EntityType: AuditEntityCommand})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 新建、删除和清理各一条
+	if len(entries) != 3 {
+		t.Fatalf("audit entries = %d, want 3", len(entries))
+	}
+	for _, e := range entries {
+		if strings.Contains(string(e.Diff), "Sup3rS3cret") {
+			t.Errorf("%s audit diff keeps the secret: %s", e.Action, e.Diff)
+		}
+	}
+	if !strings.Contains(string(entries[2].Diff), "--password=[REDACTED] db") {
+		t.Errorf("create audit diff = %s", entries[2].Diff)
+	}
+}
+
+// inTx 在事务中执行fn并提交，用于直接测试事务内的辅助函数
+func inTx(t *testing.T, fn func(ctx context.Context, tx *sql.Tx) error) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fn(ctx, tx); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandRelations(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	dir := f.command("dir")
+	inTx(t, func(ctx context.Context, tx *sql.Tx) error {
+		steps := []struct {
+			relation commandRelation
+			remove   bool
+			values   []interface{}
+		}{
+			{commandTagRelation, false, []interface{}{f.tag("ops"), f.tag("ops")}},
+			{commandTagRelation, true, []interface{}{f.tag("windows-admin")}},
+			{commandCollectionRelation, false, []interface{}{f.collection("cleanup")}},
+			{commandCollectionRelation, true, []interface{}{f.collection("deploy")}},
+			{commandOsRelation, false, []interface{}{Linux, Mac, Windows}},
+		}
+		for _, step := range steps {
+			apply := step.relation.add
+			if step.remove {
+				apply = step.relation.remove
+			}
+			if err := apply(ctx, tx, dir, step.values); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	tags, err := GetTagIDsByCommandIDsSQLite(ctx, []uint64{dir})
+	if err != nil || !reflect.DeepEqual(tags[dir], []uint64{f.tag("ops")}) {
+		t.Errorf("tags = %v, %v", tags, err)
+	}
+	collections, err := GetCollectionIDsByCommandIDSQLite(ctx, dir)
+	if err != nil || !reflect.DeepEqual(collections, []uint64{f.collection("cleanup")}) {
+		t.Errorf("collections = %v, %v", collections, err)
+	}
+	byCommand, err := GetCollectionIDsByCommandIDsSQLite(ctx, []uint64{dir})
+	if err != nil || !reflect.DeepEqual(byCommand[dir], collections) {
+		t.Errorf("GetCollectionIDsByCommandIDsSQLite() = %v, %v", byCommand, err)
+	}
+	// 新加入的步骤排在集合末尾
+	steps, err := GetCollectionStepsSQLite(ctx, f.collection("cleanup"))
+	if err != nil || len(steps) == 0 || steps[len(steps)-1].CommandID != dir {
+		t.Errorf("cleanup steps = %v, %v", steps, err)
+	}
+	osMap, err := GetCommandOSsByCommandIDsSQLite(ctx, []uint64{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(osMap[dir])
+	if !reflect.DeepEqual(osMap[dir], []string{Linux, Mac, Windows}) {
+		t.Errorf("os = %v", osMap[dir])
+	}
+}
+
+func TestReplaceCommandRelations(t *testing.T) {
+	tests := []struct {
+		name     string
+		relation commandRelation
+		want     func(c *Command) bool // 只有对应的关联被清空
+	}{
+		{"tags", commandTagRelation, func(c *Command) bool {
+			return len(c.TagIDs) == 0 && len(c.CollectionIDs) == 2 && len(c.Os) == 1
+		}},
+		{"collections", commandCollectionRelation, func(c *Command) bool {
+			return len(c.TagIDs) == 1 && len(c.CollectionIDs) == 0 && len(c.Os) == 1
+		}},
+		{"os", commandOsRelation, func(c *Command) bool {
+			return len(c.TagIDs) == 1 && len(c.CollectionIDs) == 2 && len(c.Os) == 0
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixtureDB(t, "store.yaml")
+			id := f.command("kubectl-pods")
+			inTx(t, func(ctx context.Context, tx *sql.Tx) error {
+				return tt.relation.replace(ctx, tx, id, nil)
+			})
+			// 其他指令的关联不受影响
+			commands := []*Command{{ID: id}, {ID: f.command("docker-ps")}}
+			if err := FillCommandRelations(context.Background(), commands); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(commands[0]) {
+				t.Errorf("relations after clearing %s = %+v", tt.name, commands[0])
+			}
+			if c := commands[1]; len(c.TagIDs) != 1 || len(c.CollectionIDs) != 1 || len(c.Os) != 2 {
+				t.Errorf("relations of docker-ps = %+v", c)
+			}
+		})
+	}
+}
+
+func TestAddOSToTagSQLite(t *testing.T) {
+	f := newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	id := f.tag("windows-admin")
+	tests := []struct {
+		name string
+		os   string
+		code ErrorCode
+	}{
+		{"canonical", Linux, CodeOK},
+		{"alias", "macOS", CodeOK},
+		{"existing", "Win", CodeOK},
+		{"invalid", "beos", CodeValidation},
+	}
+	for _, tt := range tests {
+		tx, err := DB.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = AddOSToTagSQLite(ctx, tx, id, tt.os)
+		if errorCode(err) != tt.code {
+			t.Errorf("%s: AddOSToTagSQLite(%q) error = %v, want code %d", tt.name, tt.os, err, tt.code)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	osList, err := GetTagOSsSQLite(ctx, id)
+	slices.Sort(osList)
+	if err != nil || !reflect.DeepEqual(osList, []string{Linux, Mac, Windows}) {
+		t.Errorf("GetTagOSsSQLite() = %v, %v", osList, err)
+	}
 }
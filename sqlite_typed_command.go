@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RecordTypedCommandSQLite 累加shell钩子上报的命令在当天的输入次数，并清理windowDays天统计窗口之外的数据
+func RecordTypedCommandSQLite(ctx context.Context, line string, at time.Time, windowDays int) error {
+	day := at.Format("2006-01-02")
+
+	// shell钩子在后台goroutine中上报，计数和清理在一个事务中完成
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO typed_commands (line, day, count) VALUES (?, ?, 1) ON CONFLICT(line, day) DO UPDATE SET count = count + 1",
+		line, day,
+	)
+	if err != nil {
+		return fmt.Errorf("记录输入命令失败: %w", err)
+	}
+
+	cutoff := at.AddDate(0, 0, -windowDays).Format("2006-01-02")
+	if _, err = tx.ExecContext(ctx, "DELETE FROM typed_commands WHERE day < ?", cutoff); err != nil {
+		return fmt.Errorf("清理过期输入命令失败: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// GetTypedCommandSuggestionsSQLite 统计since之后输入次数不少于minCount的命令，
+// 已保存为指令或已被用户忽略的命令不会出现在结果中
+func GetTypedCommandSuggestionsSQLite(ctx context.Context, since time.Time, minCount int) ([]*TypedCommandSuggestion, error) {
+	var suggestions []*TypedCommandSuggestion
+
+	rows, err := DB.QueryContext(ctx, `
+	SELECT t.line, SUM(t.count) AS total, MAX(t.day)
+	FROM typed_commands t
+	WHERE t.day >= ?
+	AND NOT EXISTS (SELECT 1 FROM typed_command_dismissed d WHERE d.line = t.line)
+	AND NOT EXISTS (SELECT 1 FROM commands c WHERE c.content = t.line AND c.deleted_at IS NULL)
+	GROUP BY t.line
+	HAVING total >= ?
+	ORDER BY total DESC, MAX(t.day) DESC
+	LIMIT 20`,
+		since.Format("2006-01-02"), minCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询输入命令建议失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s TypedCommandSuggestion
+		if err = rows.Scan(&s.Line, &s.Count, &s.LastDay); err != nil {
+			return nil, fmt.Errorf("扫描输入命令建议失败: %w", err)
+		}
+		suggestions = append(suggestions, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历输入命令建议结果集失败: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+// DismissTypedCommandSQLite 忽略某条命令建议，之后不再提示
+func DismissTypedCommandSQLite(ctx context.Context, line string) error {
+	if line == "" {
+		return NewValidationError("command", "typed_command.required")
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	_, err := DB.ExecContext(ctx,
+		"INSERT OR REPLACE INTO typed_command_dismissed (line, dismissed_at) VALUES (?, ?)",
+		line, time.Now().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return fmt.Errorf("忽略命令建议失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedCommandSuggestionsSQLite(t *testing.T) {
+	newFixtureDB(t, "store.yaml")
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	typed := []struct {
+		line string
+		at   time.Time
+	}{
+		{"git status", now.AddDate(0, 0, -40)},
+		{"git status", now.AddDate(0, 0, -1)},
+		{"git status", now},
+		{"git status", now},
+		{"make test", now.AddDate(0, 0, -2)},
+		{"make test", now.AddDate(0, 0, -2)},
+		{"htop", now},
+		// 已保存为指令的命令不作为建议
+		{"docker ps", now},
+		{"docker ps", now},
+		// old-backup已删除，可以再次建议
+		{"tar czf backup.tgz .", now},
+		{"tar czf backup.tgz .", now},
+	}
+	for _, tc := range typed {
+		if err := RecordTypedCommandSQLite(ctx, tc.line, tc.at, 30); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	suggested := func(since time.Time, minCount int) []string {
+		suggestions, err := GetTypedCommandSuggestionsSQLite(ctx, since, minCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := []string{}
+		for _, s := range suggestions {
+			lines = append(lines, s.Line)
+		}
+		return lines
+	}
+	tests := []struct {
+		name     string
+		dismiss  string
+		since    time.Time
+		minCount int
+		code     ErrorCode
+		want     []string
+	}{
+		// 40天前的记录在统计窗口之外，已被清理
+		{"window", "", now.AddDate(0, 0, -60), 2, CodeOK, []string{"git status", "tar czf backup.tgz .", "make test"}},
+		{"since", "", now.AddDate(0, 0, -1), 2, CodeOK, []string{"git status", "tar czf backup.tgz ."}},
+		{"min count", "", now.AddDate(0, 0, -7), 1, CodeOK, []string{"git status", "tar czf backup.tgz .", "make test", "htop"}},
+		{"dismiss", "git status", now.AddDate(0, 0, -7), 1, CodeOK, []string{"tar czf backup.tgz .", "make test", "htop"}},
+		{"dismiss empty", "", now.AddDate(0, 0, -7), 1, CodeValidation, []string{"tar czf backup.tgz .", "make test", "htop"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.dismiss != "" || tt.code != CodeOK {
+				if err := DismissTypedCommandSQLite(ctx, tt.dismiss); errorCode(err) != tt.code {
+					t.Fatalf("DismissTypedCommandSQLite(%q) error = %v, want code %d", tt.dismiss, err, tt.code)
+				}
+			}
+			if got := suggested(tt.since, tt.minCount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggestions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// vaultRecord 密钥库的元数据
+type vaultRecord struct {
+	salt            []byte
+	kdf             vaultKDF
+	checkNonce      []byte
+	checkValue      []byte
+	autoLockMinutes int
+}
+
+// sealedSecret 加密保存的一个密钥
+type sealedSecret struct {
+	nonce      []byte
+	ciphertext []byte
+}
+
+// GetVaultSQLite 获取密钥库元数据，尚未初始化时返回nil
+func GetVaultSQLite(ctx context.Context) (*vaultRecord, error) {
+	var rec vaultRecord
+	err := DB.QueryRowContext(ctx,
+		"SELECT salt, kdf_time, kdf_memory, kdf_threads, check_nonce, check_value, auto_lock_minutes FROM vault WHERE id = 1",
+	).Scan(&rec.salt, &rec.kdf.Time, &rec.kdf.Memory, &rec.kdf.Threads, &rec.checkNonce, &rec.checkValue, &rec.autoLockMinutes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取密钥库失败: %w", err)
+	}
+	return &rec, nil
+}
+
+// CreateVaultSQLite 保存新建的密钥库元数据，已初始化时返回错误
+func CreateVaultSQLite(ctx context.Context, rec *vaultRecord) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO vault (id, salt, kdf_time, kdf_memory, kdf_threads, check_nonce, check_value, auto_lock_minutes, created_at)
+		VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.salt, rec.kdf.Time, rec.kdf.Memory, rec.kdf.Threads, rec.checkNonce, rec.checkValue, rec.autoLockMinutes,
+		time.Now().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return fmt.Errorf("创建密钥库失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewConflictError("vault.already_initialized")
+		return err
+	}
+	if err = recordAudit(ctx, tx, AuditEntityVault, 1, AuditActionCreate, nil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// SetVaultAutoLockSQLite 修改空闲自动锁定的分钟数
+func SetVaultAutoLockSQLite(ctx context.Context, minutes int) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	audit, err := snapshotAudit(ctx, tx, AuditEntityVault, 1)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "UPDATE vault SET auto_lock_minutes = ? WHERE id = 1", minutes)
+	if err != nil {
+		return fmt.Errorf("更新自动锁定时间失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		err = NewNotFoundError("vault.not_initialized")
+		return err
+	}
+	if err = audit.record(ctx, tx, AuditActionUpdate); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// GetSecretsSQLite 获取所有密钥的名称和时间，不包含密文
+func GetSecretsSQLite(ctx context.Context) ([]*SecretInfo, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT name, created_at, updated_at FROM secrets ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("获取密钥列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := []*SecretInfo{}
+	for rows.Next() {
+		var s SecretInfo
+		if err = rows.Scan(&s.Name, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描密钥失败: %w", err)
+		}
+		secrets = append(secrets, &s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历密钥结果集失败: %w", err)
+	}
+	return secrets, nil
+}
+
+// GetSealedSecretsSQLite 获取密钥密文，names为空时获取全部
+func GetSealedSecretsSQLite(ctx context.Context, names []string) (map[string]*sealedSecret, error) {
+	query := "SELECT name, nonce, ciphertext FROM secrets"
+	if len(names) > 0 {
+		query += " WHERE name IN " + inPlaceholders(len(names))
+	}
+	rows, err := DB.QueryContext(ctx, query, toArgs(names)...)
+	if err != nil {
+		return nil, fmt.Errorf("获取密钥失败: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]*sealedSecret)
+	for rows.Next() {
+		var name string
+		var s sealedSecret
+		if err = rows.Scan(&name, &s.nonce, &s.ciphertext); err != nil {
+			return nil, fmt.Errorf("扫描密钥失败: %w", err)
+		}
+		result[name] = &s
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历密钥结果集失败: %w", err)
+	}
+	return result, nil
+}
+
+// SaveSecretSQLite 保存密钥密文，同名密钥已存在时覆盖
+func SaveSecretSQLite(ctx context.Context, name string, s *sealedSecret) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	if err = saveSecretTx(ctx, tx, name, s); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// CreateSecretsSQLite 在一个事务中新建多个密钥，任一名称已存在时全部不保存
+func CreateSecretsSQLite(ctx context.Context, secrets map[string]*sealedSecret) error {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	for _, name := range names {
+		var id uint64
+		if id, err = secretIDSQLite(ctx, tx, name); err != nil {
+			return err
+		}
+		if id != 0 {
+			err = NewConflictError("secret.exists", name)
+			return err
+		}
+		if err = saveSecretTx(ctx, tx, name, secrets[name]); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// saveSecretTx 在事务中保存一个密钥并记录审计日志，同名密钥已存在时覆盖
+func saveSecretTx(ctx context.Context, tx *sql.Tx, name string, s *sealedSecret) error {
+	id, err := secretIDSQLite(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+	audit, err := snapshotAudit(ctx, tx, AuditEntitySecret, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO secrets (name, nonce, ciphertext, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET nonce = excluded.nonce, ciphertext = excluded.ciphertext, updated_at = excluded.updated_at`,
+		name, s.nonce, s.ciphertext, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("保存密钥失败: %w", err)
+	}
+	if id != 0 {
+		return audit.record(ctx, tx, AuditActionUpdate)
+	}
+	if id, err = secretIDSQLite(ctx, tx, name); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, AuditEntitySecret, id, AuditActionCreate, nil)
+}
+
+// secretIDSQLite 获取密钥的ID，不存在时返回0
+func secretIDSQLite(ctx context.Context, q sqlQueryer, name string) (uint64, error) {
+	var id uint64
+	err := q.QueryRowContext(ctx, "SELECT id FROM secrets WHERE name = ?", name).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("获取密钥失败: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteSecretSQLite 删除密钥
+func DeleteSecretSQLite(ctx context.Context, name string) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				slog.Error("回滚事务失败", "err", rollbackErr)
+			}
+		}
+	}()
+
+	id, err := secretIDSQLite(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		err = NewNotFoundError("secret.not_found", name)
+		return err
+	}
+	audit, err := snapshotAudit(ctx, tx, AuditEntitySecret, id)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM secrets WHERE id = ?", id); err != nil {
+		return fmt.Errorf("删除密钥失败: %w", err)
+	}
+	if err = audit.record(ctx, tx, AuditActionDelete); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestVaultSQLite(t *testing.T) {
+	useTempDB(t)
+	ctx := context.Background()
+	if rec, err := GetVaultSQLite(ctx); err != nil || rec != nil {
+		t.Fatalf("GetVaultSQLite() before init = %+v, %v", rec, err)
+	}
+	if err := SetVaultAutoLockSQLite(ctx, 5); errorCode(err) != CodeNotFound {
+		t.Errorf("SetVaultAutoLockSQLite() before init error = %v", err)
+	}
+
+	rec := &vaultRecord{
+		salt:            []byte("salt"),
+		kdf:             vaultKDF{Time: 1, Memory: 64, Threads: 1},
+		checkNonce:      []byte("nonce"),
+		checkValue:      []byte("check"),
+		autoLockMinutes: 15,
+	}
+	if err := CreateVaultSQLite(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateVaultSQLite(ctx, rec); errorCode(err) != CodeConflict {
+		t.Errorf("CreateVaultSQLite() twice error = %v", err)
+	}
+	if err := SetVaultAutoLockSQLite(ctx, 5); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetVaultSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.autoLockMinutes = 5
+	if !reflect.DeepEqual(got, rec) {
+		t.Errorf("GetVaultSQLite() = %+v, want %+v", got, rec)
+	}
+}
+
+func TestSecretsSQLite(t *testing.T) {
+	useTempDB(t)
+	ctx := context.Background()
+	sealed := func(s string) *sealedSecret {
+		return &sealedSecret{nonce: []byte("n-" + s), ciphertext: []byte(s)}
+	}
+	tests := []struct {
+		name   string
+		run    func() error
+		code   ErrorCode
+		stored map[string]string
+	}{
+		{"save", func() error { return SaveSecretSQLite(ctx, "TOKEN", sealed("a")) }, CodeOK, map[string]string{"TOKEN": "a"}},
+		{"save another", func() error { return SaveSecretSQLite(ctx, "API_KEY", sealed("b")) }, CodeOK, map[string]string{"TOKEN": "a", "API_KEY": "b"}},
+		{"overwrite", func() error { return SaveSecretSQLite(ctx, "TOKEN", sealed("c")) }, CodeOK, map[string]string{"TOKEN": "c", "API_KEY": "b"}},
+		{"delete", func() error { return DeleteSecretSQLite(ctx, "API_KEY") }, CodeOK, map[string]string{"TOKEN": "c"}},
+		{"delete missing", func() error { return DeleteSecretSQLite(ctx, "API_KEY") }, CodeNotFound, map[string]string{"TOKEN": "c"}},
+		{"create batch", func() error {
+			return CreateSecretsSQLite(ctx, map[string]*sealedSecret{"DB_USER": sealed("d"), "DB_PASS": sealed("e")})
+		}, CodeOK, map[string]string{"TOKEN": "c", "DB_USER": "d", "DB_PASS": "e"}},
+		// 任一名称已存在时整批都不保存
+		{"create batch conflict", func() error {
+			return CreateSecretsSQLite(ctx, map[string]*sealedSecret{"API_KEY": sealed("f"), "TOKEN": sealed("g")})
+		}, CodeConflict, map[string]string{"TOKEN": "c", "DB_USER": "d", "DB_PASS": "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); errorCode(err) != tt.code {
+				t.Fatalf("error = %v, want code %d", err, tt.code)
+			}
+			all, err := GetSealedSecretsSQLite(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string, len(all))
+			for name, s := range all {
+				got[name] = string(s.ciphertext)
+				if string(s.nonce) != "n-"+got[name] {
+					t.Errorf("secret %s nonce = %q", name, s.nonce)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.stored) {
+				t.Errorf("GetSealedSecretsSQLite() = %v, want %v", got, tt.stored)
+			}
+		})
+	}
+
+	if err := SaveSecretSQLite(ctx, "DB_PASSWORD", sealed("d")); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := GetSecretsSQLite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"DB_PASS", "DB_PASSWORD", "DB_USER", "TOKEN"}) {
+		t.Errorf("GetSecretsSQLite() = %v", names)
+	}
+	some, err := GetSealedSecretsSQLite(ctx, []string{"TOKEN", "MISSING"})
+	if err != nil || len(some) != 1 || some["TOKEN"] == nil {
+		t.Errorf("GetSealedSecretsSQLite(names) = %v, %v", some, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// templateVarPattern 匹配指令内容中的模板变量，例如 {{host}}
+var templateVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// templateRefPattern 同时匹配模板变量和密钥引用，第1组为模板变量名，第2组为密钥名
+var templateRefPattern = regexp.MustCompile(`\{\{\s*(?:([A-Za-z_][A-Za-z0-9_]*)|secret:([A-Za-z_][A-Za-z0-9_.-]*))\s*\}\}`)
+
+// templateVarNamePattern 模板变量名的格式
+var templateVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseTemplateVars 按首次出现的顺序返回指令内容中的模板变量名（去重）
+func ParseTemplateVars(content string) []string {
+	var vars []string
+	seen := make(map[string]bool)
+	for _, m := range templateVarPattern.FindAllStringSubmatch(content, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		vars = append(vars, m[1])
+	}
+	return vars
+}
+
+// RenderTemplate 用values替换指令内容中的模板变量，缺少任一变量的值时返回错误
+func RenderTemplate(content string, values map[string]string) (string, error) {
+	var missing []string
+	for _, name := range ParseTemplateVars(content) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", NewValidationError("values", "template.missing_values", strings.Join(missing, ", "))
+	}
+	return templateVarPattern.ReplaceAllStringFunc(content, func(m string) string {
+		return values[templateVarPattern.FindStringSubmatch(m)[1]]
+	}), nil
+}
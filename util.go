@@ -2,41 +2,27 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"os"
+	"path/filepath"
 )
 
-func SQL_Slice_To_In_Args(slice any) (string, error) {
-	log.Printf("SQL_Slice_To_In_Args: %+v", slice)
-	if slice == nil {
-		return "()", nil
+// quickcmdConfigDir 返回并创建配置目录 ~/.config/quickcmd，别名文件、shell钩子套接字等都保存在这里
+func quickcmdConfigDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户目录失败: %w", err)
 	}
-	args := "("
-	switch sli := slice.(type) {
-	case []string:
-		for _, v := range sli {
-			if args != "(" {
-				args += ","
-			}
-			args += fmt.Sprintf("'%s'", v)
-		}
-		args += ")"
-		return args, nil
-	case []uint64:
-		for _, v := range sli {
-			if args != "(" {
-				args += ","
-			}
-			args += fmt.Sprintf("%d", v)
-		}
-		args += ")"
-		return args, nil
-	case []int:
-		for _, v := range sli {
-			args += fmt.Sprintf("%d,", v)
-		}
-		args += ")"
-		return args, nil
-	default:
-		return "()", fmt.Errorf("slice type %T not supported", slice)
+	dir := filepath.Join(home, ".config", "quickcmd")
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("创建配置目录失败: %w", err)
 	}
+	return dir, nil
+}
+
+// nullableID 将0转换为NULL，用于可选的外键字段
+func nullableID(id uint64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
 }
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	vaultKeyLen             = 32 // AES-256
+	vaultSaltLen            = 16
+	minVaultPassphraseLen   = 8
+	defaultVaultAutoLockMin = 15
+	// vaultCheckPlaintext 初始化时用主密钥加密保存，解锁时能解密说明主密码正确
+	vaultCheckPlaintext = "quickcmd-vault"
+	// secretMask 输出中出现的密钥值替换为该字符串
+	secretMask = "******"
+	// minMaskedSecretLen 过短的密钥值容易误伤正常输出，不做替换
+	minMaskedSecretLen = 4
+)
+
+// errVaultLocked 密钥库未解锁时读写密钥返回的错误
+var errVaultLocked error = &AppError{Code: CodeLocked, Key: "vault.locked"}
+
+// secretNamePattern 密钥名只允许字母、数字、下划线、点和连字符，且不能以数字开头
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// secretRefPattern 匹配指令内容中对密钥的引用，例如 {{secret:github_token}}
+var secretRefPattern = regexp.MustCompile(`\{\{\s*secret:([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// vaultKDF Argon2id的参数，随密钥库一起保存，以后调整默认值不影响已有的密钥库
+type vaultKDF struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+// defaultVaultKDF 新建密钥库使用的Argon2id参数
+var defaultVaultKDF = vaultKDF{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// deriveVaultKey 用Argon2id从主密码派生AES密钥
+func deriveVaultKey(passphrase string, salt []byte, kdf vaultKDF) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, kdf.Time, kdf.Memory, kdf.Threads, vaultKeyLen)
+}
+
+// sealVaultValue 用AES-GCM加密，aad（密钥名）参与认证，密文不能被挪给其他密钥使用
+func sealVaultValue(key []byte, aad string, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newVaultGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, []byte(aad)), nil
+}
+
+// openVaultValue 解密sealVaultValue的结果，密钥错误或密文被篡改时返回错误
+func openVaultValue(key []byte, aad string, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newVaultGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("密文格式不正确")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败")
+	}
+	return plaintext, nil
+}
+
+func newVaultGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretVault 解锁后的密钥库状态：主密钥和已解密的密钥缓存只保存在内存中，
+// 锁定或空闲超时后清除
+type secretVault struct {
+	mu     sync.Mutex
+	key    []byte
+	cache  map[string]string
+	idle   time.Duration // 0表示不自动锁定
+	timer  *time.Timer
+	gen    uint64 // 每次重新计时加1，过期的计时器据此忽略
+	onLock func() // 空闲超时自动锁定后调用
+}
+
+// unlock 保存主密钥并开始计算空闲时间
+func (v *secretVault) unlock(key []byte, idle time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clearLocked()
+	v.key = key
+	v.cache = make(map[string]string)
+	v.idle = idle
+	v.touchLocked()
+}
+
+// lock 清除内存中的主密钥和已解密的密钥
+func (v *secretVault) lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clearLocked()
+}
+
+func (v *secretVault) clearLocked() {
+	for i := range v.key {
+		v.key[i] = 0
+	}
+	v.key = nil
+	v.cache = nil
+	if v.timer != nil {
+		v.timer.Stop()
+		v.timer = nil
+	}
+}
+
+// unlocked 密钥库是否处于解锁状态
+func (v *secretVault) unlocked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key != nil
+}
+
+// setIdle 修改自动锁定时间，已解锁时从现在重新计时
+func (v *secretVault) setIdle(idle time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.idle = idle
+	if v.key != nil {
+		v.touchLocked()
+	}
+}
+
+// touchLocked 重新开始计算空闲时间
+func (v *secretVault) touchLocked() {
+	if v.timer != nil {
+		v.timer.Stop()
+		v.timer = nil
+	}
+	if v.idle <= 0 {
+		return
+	}
+	v.gen++
+	gen := v.gen
+	v.timer = time.AfterFunc(v.idle, func() {
+		v.mu.Lock()
+		// 计时期间又使用过密钥库则忽略这次超时
+		expired := v.key != nil && v.gen == gen
+		if expired {
+			v.clearLocked()
+		}
+		onLock := v.onLock
+		v.mu.Unlock()
+		if expired && onLock != nil {
+			onLock()
+		}
+	})
+}
+
+// withKey 在解锁状态下调用fn并重新计算空闲时间，未解锁时返回errVaultLocked
+func (v *secretVault) withKey(fn func(key []byte, cache map[string]string) error) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return errVaultLocked
+	}
+	v.touchLocked()
+	return fn(v.key, v.cache)
+}
+
+// inspect 在解锁状态下调用fn，不重新计算空闲时间，未解锁时返回errVaultLocked
+func (v *secretVault) inspect(fn func(key []byte, cache map[string]string) error) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return errVaultLocked
+	}
+	return fn(v.key, v.cache)
+}
+
+// ValidateSecretName 校验密钥名格式
+func ValidateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return NewValidationError("name", "secret.invalid_name", name)
+	}
+	return nil
+}
+
+// ParseSecretRefs 按首次出现的顺序返回指令内容中引用的密钥名（去重）
+func ParseSecretRefs(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range secretRefPattern.FindAllStringSubmatch(content, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// renderedCommand 替换模板变量和密钥引用后的指令内容
+type renderedCommand struct {
+	Content  string            // 直接替换后的内容，用于复制，包含密钥明文，不能写入日志和历史
+	Redacted string            // 密钥引用保持 {{secret:name}} 原样的内容，可以展示和记录
+	source   string            // 替换前的指令内容，执行时按shell重新替换
+	values   map[string]string // 模板变量的值
+	secrets  map[string]string // 用到的密钥名和明文，用于执行以及从输出中去除
+}
+
+// renderCommandContent 替换模板变量，并用lookup取得的密钥替换密钥引用。
+// 密钥引用只从指令内容中解析，模板变量的值原样代入，值中写了 {{secret:...}} 也不会被展开
+func renderCommandContent(content string, values map[string]string, lookup func(names []string) (map[string]string, error)) (*renderedCommand, error) {
+	redacted, err := RenderTemplate(content, values)
+	if err != nil {
+		return nil, err
+	}
+	rendered := &renderedCommand{Content: redacted, Redacted: redacted, source: content, values: values}
+	names := ParseSecretRefs(content)
+	if len(names) == 0 {
+		return rendered, nil
+	}
+	secrets, err := lookup(names)
+	if err != nil {
+		return nil, err
+	}
+	rendered.secrets = make(map[string]string, len(names))
+	for _, name := range names {
+		rendered.secrets[name] = secrets[name]
+	}
+	// 在原始内容上一次替换完，代入的值不会再被当作引用解析
+	rendered.Content = templateRefPattern.ReplaceAllStringFunc(content, func(m string) string {
+		_, value := rendered.refValue(templateRefPattern.FindStringSubmatch(m))
+		return value
+	})
+	return rendered, nil
+}
+
+// refValue 返回templateRefPattern子匹配对应的模板变量或密钥的值，以及用于区分的名称（密钥带secret:前缀）
+func (r *renderedCommand) refValue(m []string) (name, value string) {
+	if m[2] != "" {
+		return "secret:" + m[2], r.secrets[m[2]]
+	}
+	return m[1], r.values[m[1]]
+}
+
+// Mask 将text中出现的密钥明文替换为secretMask
+func (r *renderedCommand) Mask(text string) string {
+	secrets := make([]string, 0, len(r.secrets))
+	for _, s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+	return maskSecrets(text, secrets)
+}
+
+// maskSecrets 将text中出现的secrets替换为secretMask，过短的值不替换
+func maskSecrets(text string, secrets []string) string {
+	for _, s := range secrets {
+		if len(s) >= minMaskedSecretLen {
+			text = strings.ReplaceAll(text, s, secretMask)
+		}
+	}
+	return text
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSealVaultValue(t *testing.T) {
+	kdf := vaultKDF{Time: 1, Memory: 1024, Threads: 1}
+	salt := []byte("0123456789abcdef")
+	key := deriveVaultKey("correct horse", salt, kdf)
+
+	nonce, ciphertext, err := sealVaultValue(key, "token", []byte("s3cr3t-value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(ciphertext), "s3cr3t") {
+		t.Fatal("ciphertext contains plaintext")
+	}
+	plaintext, err := openVaultValue(key, "token", nonce, ciphertext)
+	if err != nil || string(plaintext) != "s3cr3t-value" {
+		t.Fatalf("openVaultValue = %q, %v", plaintext, err)
+	}
+	if _, err = openVaultValue(key, "other", nonce, ciphertext); err == nil {
+		t.Error("expected error when ciphertext is moved to another name")
+	}
+	wrong := deriveVaultKey("wrong horse", salt, kdf)
+	if _, err = openVaultValue(wrong, "token", nonce, ciphertext); err == nil {
+		t.Error("expected error with wrong passphrase")
+	}
+}
+
+func TestRenderCommandContent(t *testing.T) {
+	lookup := func(names []string) (map[string]string, error) {
+		return map[string]string{"gh_token": "ghp_abcdef123456"}, nil
+	}
+	r, err := renderCommandContent(`curl -H "Authorization: {{ secret:gh_token }}" {{url}}`, map[string]string{"url": "https://api"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Content != `curl -H "Authorization: ghp_abcdef123456" https://api` {
+		t.Errorf("Content = %q", r.Content)
+	}
+	if r.Redacted != `curl -H "Authorization: {{ secret:gh_token }}" https://api` {
+		t.Errorf("Redacted = %q", r.Redacted)
+	}
+	if got := r.Mask("token=ghp_abcdef123456"); got != "token="+secretMask {
+		t.Errorf("Mask = %q", got)
+	}
+
+	locked := func(names []string) (map[string]string, error) { return nil, errVaultLocked }
+	if _, err = renderCommandContent("echo {{secret:gh_token}}", nil, locked); !errors.Is(err, errVaultLocked) {
+		t.Errorf("expected errVaultLocked, got %v", err)
+	}
+	if r, err = renderCommandContent("echo {{name}}", map[string]string{"name": "x"}, locked); err != nil || r.Content != "echo x" {
+		t.Errorf("content without secrets should not need the vault: %q, %v", r.Content, err)
+	}
+
+	// 调用方传入的值中的密钥引用不展开，也不会去读取密钥库
+	r, err = renderCommandContent("echo {{msg}}", map[string]string{"msg": "{{secret:gh_token}}"}, locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Content != "echo {{secret:gh_token}}" || len(r.secrets) != 0 {
+		t.Errorf("secret reference in values expanded: Content = %q, secrets = %v", r.Content, r.secrets)
+	}
+	r, err = renderCommandContent("echo {{msg}} {{secret:gh_token}}", map[string]string{"msg": "{{ secret:gh_token }}"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Content != "echo {{ secret:gh_token }} ghp_abcdef123456" {
+		t.Errorf("Content = %q", r.Content)
+	}
+}
+
+func TestSecretVaultAutoLock(t *testing.T) {
+	locked := make(chan struct{}, 1)
+	v := &secretVault{onLock: func() { locked <- struct{}{} }}
+	v.unlock(make([]byte, vaultKeyLen), 50*time.Millisecond)
+	if !v.unlocked() {
+		t.Fatal("vault should be unlocked")
+	}
+	select {
+	case <-locked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("vault was not locked after idle timeout")
+	}
+	if v.unlocked() {
+		t.Error("vault should be locked")
+	}
+	if err := v.withKey(func([]byte, map[string]string) error { return nil }); !errors.Is(err, errVaultLocked) {
+		t.Errorf("withKey on locked vault = %v, want errVaultLocked", err)
+	}
+}